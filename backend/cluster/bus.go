@@ -0,0 +1,92 @@
+// Package cluster は複数ノードでゲートウェイを動かすための部品（ルームの所有権とノード間のメッセージバス）
+// 共有ストア（Redis など）を使う実装は Bus / LeaseStore を満たせば差し替えられる
+package cluster
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrBusClosed は閉じたバスへの操作
+var ErrBusClosed = errors.New("message bus is closed")
+
+// Bus はノード間のメッセージバス
+// 同じトピックへのメッセージは発行順に配送される
+type Bus interface {
+	// Publish はトピックにメッセージを発行する（購読者がいなければ捨てる）
+	Publish(topic string, data []byte) error
+
+	// Subscribe はトピックを購読し、購読を解除する関数を返す
+	Subscribe(topic string, handler func(data []byte)) (unsubscribe func(), err error)
+}
+
+// MemoryBus は同一プロセス内のメッセージバス（テストと単一ノード構成向け）
+// 配送は Publish を呼んだゴルーチンで同期的に行う
+type MemoryBus struct {
+	mu     sync.RWMutex
+	subs   map[string]map[int]func([]byte)
+	nextID int
+	closed bool
+}
+
+// NewMemoryBus は新しいMemoryBusを生成
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[string]map[int]func([]byte))}
+}
+
+// Publish はトピックの全購読者にメッセージを配送する
+func (b *MemoryBus) Publish(topic string, data []byte) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBusClosed
+	}
+	handlers := make([]func([]byte), 0, len(b.subs[topic]))
+	for _, handler := range b.subs[topic] {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		// 購読者ごとに独立したコピーを渡す
+		handler(append([]byte(nil), data...))
+	}
+	return nil
+}
+
+// Subscribe はトピックを購読する
+func (b *MemoryBus) Subscribe(topic string, handler func(data []byte)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[int]func([]byte))
+	}
+	id := b.nextID
+	b.nextID++
+	b.subs[topic][id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[topic], id)
+		if len(b.subs[topic]) == 0 {
+			delete(b.subs, topic)
+		}
+	}, nil
+}
+
+// Close は以降の発行・購読を拒否する
+func (b *MemoryBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.subs = make(map[string]map[int]func([]byte))
+}
+
+// NodeTopic はノード宛てのメッセージを流すトピック
+func NodeTopic(nodeID string) string {
+	return "node." + nodeID
+}
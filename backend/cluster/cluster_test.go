@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+// TestOwnership はリースの取得・更新・失効のテスト
+func TestOwnership(t *testing.T) {
+	store := NewMemoryLeaseStore()
+	now := time.Now()
+	a := NewOwnership("a", store, 10*time.Second)
+	b := NewOwnership("b", store, 10*time.Second)
+	a.now = func() time.Time { return now }
+	b.now = func() time.Time { return now }
+
+	// テスト1: 最初に問い合わせたノードが所有し、他のノードにはその所有者を返す
+	if owner, _ := a.Owner("room:1"); owner != "a" {
+		t.Errorf("expected a to acquire room:1, got %q", owner)
+	}
+	if owner, _ := b.Owner("room:1"); owner != "a" {
+		t.Errorf("expected b to see a as the owner, got %q", owner)
+	}
+	if owned := b.Owned(); len(owned) != 0 {
+		t.Errorf("expected b to own nothing, got %v", owned)
+	}
+
+	// テスト2: 期限内に更新すれば所有し続ける
+	now = now.Add(8 * time.Second)
+	if lost, err := a.Renew(); err != nil || len(lost) != 0 {
+		t.Errorf("expected renew to keep the lease, lost=%v err=%v", lost, err)
+	}
+	now = now.Add(8 * time.Second)
+	if owner, _ := b.Owner("room:1"); owner != "a" {
+		t.Errorf("expected renewed lease to stay with a, got %q", owner)
+	}
+
+	// テスト3: 更新が途絶えると他のノードが引き継ぎ、元の所有者は失ったことを知る
+	now = now.Add(11 * time.Second)
+	if owner, _ := b.Owner("room:1"); owner != "b" {
+		t.Errorf("expected b to take over the expired lease, got %q", owner)
+	}
+	if lost, _ := a.Renew(); len(lost) != 1 || lost[0] != "room:1" {
+		t.Errorf("expected a to lose room:1, got %v", lost)
+	}
+
+	// テスト4: 手放したリースはすぐ他のノードが取得できる
+	if err := b.Release("room:1"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if owner, _ := a.Owner("room:1"); owner != "a" {
+		t.Errorf("expected released lease to be available, got %q", owner)
+	}
+}
+
+// TestMemoryBus はトピックごとの配送と購読解除のテスト
+func TestMemoryBus(t *testing.T) {
+	bus := NewMemoryBus()
+	var gotA, gotB []string
+	unsubscribeA, _ := bus.Subscribe(NodeTopic("a"), func(data []byte) { gotA = append(gotA, string(data)) })
+	_, _ = bus.Subscribe(NodeTopic("b"), func(data []byte) { gotB = append(gotB, string(data)) })
+
+	// テスト1: 購読しているトピックにだけ発行順に届く
+	_ = bus.Publish(NodeTopic("a"), []byte("1"))
+	_ = bus.Publish(NodeTopic("a"), []byte("2"))
+	_ = bus.Publish(NodeTopic("b"), []byte("3"))
+	if len(gotA) != 2 || gotA[0] != "1" || gotA[1] != "2" || len(gotB) != 1 {
+		t.Errorf("unexpected deliveries: a=%v b=%v", gotA, gotB)
+	}
+
+	// テスト2: 購読解除後は届かず、閉じたバスはエラー
+	unsubscribeA()
+	_ = bus.Publish(NodeTopic("a"), []byte("4"))
+	if len(gotA) != 2 {
+		t.Errorf("expected no delivery after unsubscribe, got %v", gotA)
+	}
+	bus.Close()
+	if err := bus.Publish(NodeTopic("b"), []byte("5")); err != ErrBusClosed {
+		t.Errorf("expected ErrBusClosed, got %v", err)
+	}
+}
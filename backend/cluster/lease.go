@@ -0,0 +1,71 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+)
+
+// LeaseStore はキー（ルームなど）ごとの所有権を期限付きで保持する共有ストア
+// 所有者が期限内に更新しなければ、他のノードが取得できるようになる
+type LeaseStore interface {
+	// Acquire は未所有または期限切れなら nodeID に割り当て、現在の所有者を返す
+	Acquire(key string, nodeID string, ttl time.Duration, now time.Time) (owner string, err error)
+
+	// Renew は nodeID が所有していれば期限を延ばす（所有していなければ false）
+	Renew(key string, nodeID string, ttl time.Duration, now time.Time) (bool, error)
+
+	// Release は nodeID が所有していれば手放す
+	Release(key string, nodeID string) error
+}
+
+type lease struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// MemoryLeaseStore は同一プロセス内のリースストア（テストと単一ノード構成向け）
+type MemoryLeaseStore struct {
+	mu     sync.Mutex
+	leases map[string]lease
+}
+
+// NewMemoryLeaseStore は新しいMemoryLeaseStoreを生成
+func NewMemoryLeaseStore() *MemoryLeaseStore {
+	return &MemoryLeaseStore{leases: make(map[string]lease)}
+}
+
+// Acquire は所有者を返し、空いていれば nodeID に割り当てる
+func (s *MemoryLeaseStore) Acquire(key string, nodeID string, ttl time.Duration, now time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.leases[key]; ok && l.owner != nodeID && now.Before(l.expiresAt) {
+		return l.owner, nil
+	}
+	s.leases[key] = lease{owner: nodeID, expiresAt: now.Add(ttl)}
+	return nodeID, nil
+}
+
+// Renew は所有中のリースの期限を延ばす
+func (s *MemoryLeaseStore) Renew(key string, nodeID string, ttl time.Duration, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.leases[key]
+	if !ok || l.owner != nodeID || !now.Before(l.expiresAt) {
+		return false, nil
+	}
+	s.leases[key] = lease{owner: nodeID, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// Release は所有中のリースを手放す
+func (s *MemoryLeaseStore) Release(key string, nodeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.leases[key]; ok && l.owner == nodeID {
+		delete(s.leases, key)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLeaseTTL はリースの既定の期限
+const DefaultLeaseTTL = 15 * time.Second
+
+// Ownership はこのノードが所有するキーのリースを管理する
+type Ownership struct {
+	nodeID string
+	store  LeaseStore
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	owned map[string]bool
+}
+
+// NewOwnership は新しいOwnershipを生成
+// ttl が 0 以下なら DefaultLeaseTTL を使う
+func NewOwnership(nodeID string, store LeaseStore, ttl time.Duration) *Ownership {
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	return &Ownership{
+		nodeID: nodeID,
+		store:  store,
+		ttl:    ttl,
+		now:    time.Now,
+		owned:  make(map[string]bool),
+	}
+}
+
+// NodeID はこのノードのID
+func (o *Ownership) NodeID() string {
+	return o.nodeID
+}
+
+// TTL はリースの期限
+func (o *Ownership) TTL() time.Duration {
+	return o.ttl
+}
+
+// Owner はキーの所有者を返す。誰も所有していなければこのノードが取得する
+func (o *Ownership) Owner(key string) (string, error) {
+	owner, err := o.store.Acquire(key, o.nodeID, o.ttl, o.now())
+	if err != nil {
+		return "", err
+	}
+	o.mu.Lock()
+	if owner == o.nodeID {
+		o.owned[key] = true
+	} else {
+		delete(o.owned, key)
+	}
+	o.mu.Unlock()
+	return owner, nil
+}
+
+// Release はキーを手放す
+func (o *Ownership) Release(key string) error {
+	o.mu.Lock()
+	delete(o.owned, key)
+	o.mu.Unlock()
+	return o.store.Release(key, o.nodeID)
+}
+
+// Owned はこのノードが所有しているキーを返す
+func (o *Ownership) Owned() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	keys := make([]string, 0, len(o.owned))
+	for key := range o.owned {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Renew は所有している全てのキーの期限を延ばし、失ったキーを返す
+// 失ったキーは他のノードに引き継がれている可能性があるため、このノードの状態は古いものとして扱う
+func (o *Ownership) Renew() (lost []string, err error) {
+	now := o.now()
+	for _, key := range o.Owned() {
+		ok, renewErr := o.store.Renew(key, o.nodeID, o.ttl, now)
+		if renewErr != nil {
+			err = renewErr
+			continue
+		}
+		if !ok {
+			o.mu.Lock()
+			delete(o.owned, key)
+			o.mu.Unlock()
+			lost = append(lost, key)
+		}
+	}
+	return lost, err
+}
@@ -0,0 +1,67 @@
+// matchmaker はランダムマッチの待ち行列を別プロセスで動かす
+// ゲートウェイは MATCHMAKER_URL にこのプロセスのURLを指定すると、プロセス内のマッチメーカーの代わりに使う
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"recaptchgame-backend/config"
+	"recaptchgame-backend/infrastructure"
+	"recaptchgame-backend/matchmaker"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("MATCHMAKER_ADDR", ":8081"), "待ち受けるアドレス")
+	ttl := flag.String("ticket-ttl", envOr("MATCHMAKER_TICKET_TTL", matchmaker.DefaultTicketTTL.String()), "更新のないチケットを捨てるまでの時間")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil)).With("component", "matchmaker")
+	ticketTTL, err := config.ParseDuration(*ttl)
+	if err != nil {
+		logger.Error("invalid ticket ttl", "error", err)
+		os.Exit(1)
+	}
+
+	service := matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), ticketTTL.Std())
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", matchmaker.NewHTTPHandler(service))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := &http.Server{Addr: *addr, Handler: mux}
+
+	go func() {
+		logger.Info("matchmaker starting", "addr", *addr, "ticket_ttl", ticketTTL.Std())
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("listen failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("matchmaker forced to shutdown", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("matchmaker exiting")
+}
+
+func envOr(key string, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
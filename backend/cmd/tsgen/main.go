@@ -0,0 +1,27 @@
+// tsgen は protocol パッケージのメッセージ定義からフロントエンド用の TypeScript 型定義を生成する
+// backend/protocol の go:generate から実行される
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"recaptchgame-backend/protocol"
+)
+
+func main() {
+	out := flag.String("out", "", "出力先の .d.ts ファイル")
+	flag.Parse()
+	if *out == "" {
+		log.Fatal("tsgen: -out is required")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		log.Fatalf("tsgen: %v", err)
+	}
+	if err := os.WriteFile(*out, protocol.TypeScriptDefinitions(), 0o644); err != nil {
+		log.Fatalf("tsgen: %v", err)
+	}
+}
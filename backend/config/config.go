@@ -0,0 +1,704 @@
+// Package config はサーバーの設定（JSONファイル + 環境変数）を読み込み、起動時に検証する
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"recaptchgame-backend/cluster"
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/matchmaker"
+	"recaptchgame-backend/protocol"
+	"recaptchgame-backend/webhook"
+)
+
+// secretMask は管理APIで秘密の値の代わりに表示する文字列
+const secretMask = "********"
+
+// Duration はJSON・環境変数で "1m30s" のような文字列または整数秒を受け付ける時間
+type Duration time.Duration
+
+// ParseDuration は "1m30s" 形式または整数秒を Duration に変換する
+func ParseDuration(s string) (Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return Duration(time.Duration(n) * time.Second), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (use e.g. \"45s\" or integer seconds)", s)
+	}
+	return Duration(d), nil
+}
+
+// Std は time.Duration を返す
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+// MarshalJSON は "45s" 形式で出力する
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON は文字列または整数秒を受け付ける
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("invalid duration %s", b)
+		}
+		*d = Duration(time.Duration(n) * time.Second)
+		return nil
+	}
+	parsed, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Config はサーバー全体の設定
+// 各フィールドの env タグは上書きに使う環境変数名（カンマ区切りの2つ目以降は旧名で、先に書いた名前を優先する）
+type Config struct {
+	Server       ServerConfig       `json:"server"`
+	WebSocket    WebSocketConfig    `json:"websocket"`
+	Game         GameConfig         `json:"game"`
+	Items        ItemsConfig        `json:"items"`
+	Verify       VerifyConfig       `json:"verify"`
+	BotDetection BotDetectionConfig `json:"bot_detection"`
+	Matchmaking  MatchmakingConfig  `json:"matchmaking"`
+	Rooms        RoomsConfig        `json:"rooms"`
+	Sessions     SessionsConfig     `json:"sessions"`
+	Cluster      ClusterConfig      `json:"cluster"`
+	Admission    AdmissionConfig    `json:"admission"`
+	RateLimit    RateLimitConfig    `json:"rate_limit"`
+	Webhooks     WebhooksConfig     `json:"webhooks"`
+}
+
+// ServerConfig はHTTPサーバー・管理API・ログの設定
+type ServerConfig struct {
+	Port              string   `json:"port" env:"PORT"`
+	TrustProxyHeaders bool     `json:"trust_proxy_headers" env:"TRUST_PROXY_HEADERS"`
+	AdminToken        string   `json:"admin_token" env:"ADMIN_TOKEN"`
+	DrainTimeout      Duration `json:"drain_timeout" env:"DRAIN_TIMEOUT,DRAIN_TIMEOUT_SECONDS"`
+	LogLevel          string   `json:"log_level" env:"LOG_LEVEL"`
+	LogFormat         string   `json:"log_format" env:"LOG_FORMAT"`
+}
+
+// WebSocketConfig は接続ごとの送信キュー・ハートビート・再接続猶予の設定
+type WebSocketConfig struct {
+	SendBufferSize    int      `json:"send_buffer_size" env:"SEND_BUFFER_SIZE"`
+	HeartbeatInterval Duration `json:"heartbeat_interval" env:"HEARTBEAT_INTERVAL"`
+	PongTimeout       Duration `json:"pong_timeout" env:"PONG_TIMEOUT"`
+	GracePeriod       Duration `json:"grace_period" env:"GRACE_PERIOD"`
+}
+
+// GameConfig は対戦ルールの設定
+// Effects は妨害エフェクトの定義（設定ファイルでのみ指定。空なら既定の定義）
+type GameConfig struct {
+	ImagesPerProblem    int            `json:"images_per_problem" env:"IMAGES_PER_PROBLEM"`
+	CorrectPerProblem   int            `json:"correct_per_problem" env:"CORRECT_PER_PROBLEM"`
+	ComboThreshold      int            `json:"combo_threshold" env:"COMBO_THRESHOLD"`
+	EffectDuration      Duration       `json:"effect_duration" env:"EFFECT_DURATION"`
+	DefaultWinningScore int            `json:"default_winning_score" env:"DEFAULT_WINNING_SCORE"`
+	DefaultCapacity     int            `json:"default_capacity" env:"DEFAULT_CAPACITY"`
+	TargetChoiceTimeout Duration       `json:"target_choice_timeout" env:"TARGET_CHOICE_TIMEOUT"`
+	Effects             []EffectConfig `json:"effects"`
+}
+
+// EffectConfig は妨害エフェクト1種類の定義
+// duration が 0 なら game.effect_duration を使い、stacking は replace（期限を数え直す）か extend（残り時間に足す）
+type EffectConfig struct {
+	ID        string   `json:"id"`
+	Duration  Duration `json:"duration"`
+	Weight    int      `json:"weight"`
+	Rarity    string   `json:"rarity"`
+	Intensity float64  `json:"intensity"`
+	Stacking  string   `json:"stacking"`
+}
+
+// ItemsConfig は妨害を防ぐアイテムの設定
+// inventory_size が 0 ならアイテムを使わない。各 interval が 0 ならその入手方法を使わない
+type ItemsConfig struct {
+	InventorySize     int      `json:"inventory_size" env:"ITEM_INVENTORY_SIZE"`
+	StreakInterval    int      `json:"streak_interval" env:"ITEM_STREAK_INTERVAL"`
+	MilestoneInterval int      `json:"milestone_interval" env:"ITEM_MILESTONE_INTERVAL"`
+	Cooldown          Duration `json:"cooldown" env:"ITEM_COOLDOWN"`
+}
+
+// VerifyConfig は不正解時のペナルティ（総当たり対策）の設定
+// score_penalty が 0 なら減点しない
+type VerifyConfig struct {
+	LockoutDuration       Duration `json:"lockout_duration" env:"VERIFY_LOCKOUT_DURATION"`
+	MaxAttemptsPerProblem int      `json:"max_attempts_per_problem" env:"VERIFY_MAX_ATTEMPTS_PER_PROBLEM"`
+	ScorePenalty          int      `json:"score_penalty" env:"VERIFY_SCORE_PENALTY"`
+}
+
+// BotDetectionConfig はボット判定のしきい値
+// quarantine_address が true なら隔離を同じ接続元IPからの接続にも適用する
+type BotDetectionConfig struct {
+	MinSolveTime        Duration `json:"min_solve_time" env:"BOT_MIN_SOLVE_TIME"`
+	FastSolvePoints     float64  `json:"fast_solve_points" env:"BOT_FAST_SOLVE_POINTS"`
+	MinSelectInterval   Duration `json:"min_select_interval" env:"BOT_MIN_SELECT_INTERVAL"`
+	FastSelectPoints    float64  `json:"fast_select_points" env:"BOT_FAST_SELECT_POINTS"`
+	DecayPerMinute      float64  `json:"decay_per_minute" env:"BOT_DECAY_PER_MINUTE"`
+	FlagThreshold       float64  `json:"flag_threshold" env:"BOT_FLAG_THRESHOLD"`
+	QuarantineThreshold float64  `json:"quarantine_threshold" env:"BOT_QUARANTINE_THRESHOLD"`
+	QuarantineAddress   bool     `json:"quarantine_address" env:"BOT_QUARANTINE_ADDRESS"`
+	MaxReasons          int      `json:"max_reasons" env:"BOT_MAX_REASONS"`
+}
+
+// MatchmakingConfig はランダムマッチの設定
+// URL が空ならプロセス内のマッチメーカーを使い、指定すればそのURLの cmd/matchmaker を呼び出す
+type MatchmakingConfig struct {
+	MaxRandomRetries int      `json:"max_random_retries" env:"MAX_RANDOM_RETRIES"`
+	URL              string   `json:"url" env:"MATCHMAKER_URL"`
+	TicketTTL        Duration `json:"ticket_ttl" env:"MATCHMAKER_TICKET_TTL"`
+}
+
+// RoomsConfig はルームの保存先と放置されたルームを片付ける設定
+// StorePath が空ならメモリに保持する（再起動で失われ、保存されたセッションでも元のルームに戻れない）
+type RoomsConfig struct {
+	WaitingTimeout Duration `json:"waiting_timeout" env:"WAITING_ROOM_TIMEOUT"`
+	IdleTimeout    Duration `json:"idle_timeout" env:"IDLE_ROOM_TIMEOUT"`
+	ReaperInterval Duration `json:"reaper_interval" env:"ROOM_REAPER_INTERVAL"`
+	StorePath      string   `json:"store_path" env:"ROOM_STORE_PATH"`
+}
+
+// SessionsConfig はセッションの保存先
+// StorePath が空ならメモリに保持する（再起動で失われる）
+type SessionsConfig struct {
+	StorePath string `json:"store_path" env:"SESSION_STORE_PATH"`
+}
+
+// ClusterConfig は複数ノード構成でのルーム所有権の設定
+// NodeID が空ならホスト名を使う
+type ClusterConfig struct {
+	NodeID            string   `json:"node_id" env:"NODE_ID"`
+	LeaseTTL          Duration `json:"lease_ttl" env:"ROOM_LEASE_TTL"`
+	HeartbeatInterval Duration `json:"heartbeat_interval" env:"ROOM_LEASE_HEARTBEAT_INTERVAL"`
+}
+
+// AdmissionConfig は /ws への接続受け入れ条件
+// AllowedOrigins の環境変数はカンマ区切り
+type AdmissionConfig struct {
+	AllowedOrigins      []string `json:"allowed_origins" env:"ALLOWED_ORIGINS"`
+	MaxConnections      int      `json:"max_connections" env:"MAX_CONNECTIONS"`
+	MaxConnectionsPerIP int      `json:"max_connections_per_ip" env:"MAX_CONNECTIONS_PER_IP"`
+	MaxMessageBytes     int64    `json:"max_message_bytes" env:"MAX_MESSAGE_BYTES"`
+	ReadTimeout         Duration `json:"read_timeout" env:"READ_TIMEOUT,READ_TIMEOUT_SECONDS"`
+}
+
+// RateLimitConfig は受信メッセージのレート制限の設定
+// per_client / per_ip はメッセージ種別ごとの上書き（設定ファイルでのみ指定。指定しない種別は既定の制限のまま）
+// violation_window 内に max_violations 回制限を超えたクライアントは切断する
+type RateLimitConfig struct {
+	PerClient        map[string]RateLimitSpec `json:"per_client"`
+	PerIP            map[string]RateLimitSpec `json:"per_ip"`
+	DefaultPerClient RateLimitSpec            `json:"default_per_client"`
+	DefaultPerIP     RateLimitSpec            `json:"default_per_ip"`
+	MaxViolations    int                      `json:"max_violations" env:"RATE_LIMIT_MAX_VIOLATIONS"`
+	ViolationWindow  Duration                 `json:"violation_window" env:"RATE_LIMIT_VIOLATION_WINDOW"`
+}
+
+// RateLimitSpec はトークンバケット1つの設定（rate は1秒あたりの補充数、burst は容量）
+type RateLimitSpec struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// WebhooksConfig は対戦のライフサイクルを外部へ通知する webhook の設定
+// 通知先（Subscriptions）は設定ファイルでのみ指定し、配送の設定は環境変数でも上書きできる
+type WebhooksConfig struct {
+	Subscriptions  []WebhookSubscriptionConfig `json:"subscriptions"`
+	MaxAttempts    int                         `json:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	InitialBackoff Duration                    `json:"initial_backoff" env:"WEBHOOK_INITIAL_BACKOFF"`
+	MaxBackoff     Duration                    `json:"max_backoff" env:"WEBHOOK_MAX_BACKOFF"`
+	Timeout        Duration                    `json:"timeout" env:"WEBHOOK_TIMEOUT"`
+	QueueSize      int                         `json:"queue_size" env:"WEBHOOK_QUEUE_SIZE"`
+}
+
+// WebhookSubscriptionConfig は1つの通知先
+// Events が空なら全てのイベントを通知し、Secret が空なら署名を付けない
+type WebhookSubscriptionConfig struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// 接続と運用の既定値（handler の Default* はこれを使う。config は handler / usecase に依存しない）
+const (
+	DefaultSendBufferSize           = 32
+	DefaultHeartbeatInterval        = 5 * time.Second
+	DefaultPongTimeout              = 45 * time.Second
+	DefaultGracePeriod              = 10 * time.Second
+	DefaultMaxConnections           = 5000
+	DefaultMaxConnectionsPerIP      = 20
+	DefaultMaxMessageBytes          = 16 << 10
+	DefaultReadTimeout              = 60 * time.Second
+	DefaultDrainTimeout             = 60 * time.Second
+	DefaultReaperInterval           = 30 * time.Second
+	DefaultClusterHeartbeatInterval = 5 * time.Second
+	DefaultMaxViolations            = 20
+	DefaultViolationWindow          = 10 * time.Second
+)
+
+// 種別ごとの制限がないメッセージのレート制限の既定値
+var (
+	DefaultRateLimitPerClient = RateLimitSpec{Rate: 5, Burst: 10}
+	DefaultRateLimitPerIP     = RateLimitSpec{Rate: 25, Burst: 50}
+)
+
+// Default は既定の設定（ゲームのルールなどは domain の Default* と同じ値）
+func Default() *Config {
+	rules := domain.DefaultGameRules()
+	expiry := domain.DefaultRoomExpiryPolicy()
+	items := domain.DefaultItemPolicy()
+	penalty := domain.DefaultVerifyPenaltyPolicy()
+	suspicion := domain.DefaultSuspicionPolicy()
+	return &Config{
+		Server: ServerConfig{
+			Port:         "8080",
+			DrainTimeout: Duration(DefaultDrainTimeout),
+			LogLevel:     "info",
+			LogFormat:    "text",
+		},
+		WebSocket: WebSocketConfig{
+			SendBufferSize:    DefaultSendBufferSize,
+			HeartbeatInterval: Duration(DefaultHeartbeatInterval),
+			PongTimeout:       Duration(DefaultPongTimeout),
+			GracePeriod:       Duration(DefaultGracePeriod),
+		},
+		Game: GameConfig{
+			ImagesPerProblem:    rules.ImagesPerProblem,
+			CorrectPerProblem:   rules.CorrectPerProblem,
+			ComboThreshold:      rules.ComboThreshold,
+			EffectDuration:      Duration(rules.EffectDuration),
+			DefaultWinningScore: rules.DefaultWinningScore,
+			DefaultCapacity:     rules.DefaultCapacity,
+			TargetChoiceTimeout: Duration(rules.TargetChoiceTimeout),
+		},
+		Items: ItemsConfig{
+			InventorySize:     items.InventorySize,
+			StreakInterval:    items.StreakInterval,
+			MilestoneInterval: items.MilestoneInterval,
+			Cooldown:          Duration(items.Cooldown),
+		},
+		Verify: VerifyConfig{
+			LockoutDuration:       Duration(penalty.LockoutDuration),
+			MaxAttemptsPerProblem: penalty.MaxAttemptsPerProblem,
+			ScorePenalty:          penalty.ScorePenalty,
+		},
+		BotDetection: BotDetectionConfig{
+			MinSolveTime:        Duration(suspicion.MinSolveTime),
+			FastSolvePoints:     suspicion.FastSolvePoints,
+			MinSelectInterval:   Duration(suspicion.MinSelectInterval),
+			FastSelectPoints:    suspicion.FastSelectPoints,
+			DecayPerMinute:      suspicion.DecayPerMinute,
+			FlagThreshold:       suspicion.FlagThreshold,
+			QuarantineThreshold: suspicion.QuarantineThreshold,
+			QuarantineAddress:   suspicion.QuarantineAddress,
+			MaxReasons:          suspicion.MaxReasons,
+		},
+		Matchmaking: MatchmakingConfig{
+			MaxRandomRetries: domain.DefaultMatchmakingPolicy().MaxRandomRetries,
+			TicketTTL:        Duration(matchmaker.DefaultTicketTTL),
+		},
+		Rooms: RoomsConfig{
+			WaitingTimeout: Duration(expiry.WaitingTimeout),
+			IdleTimeout:    Duration(expiry.IdleTimeout),
+			ReaperInterval: Duration(DefaultReaperInterval),
+		},
+		Cluster: ClusterConfig{
+			LeaseTTL:          Duration(cluster.DefaultLeaseTTL),
+			HeartbeatInterval: Duration(DefaultClusterHeartbeatInterval),
+		},
+		Admission: AdmissionConfig{
+			MaxConnections:      DefaultMaxConnections,
+			MaxConnectionsPerIP: DefaultMaxConnectionsPerIP,
+			MaxMessageBytes:     DefaultMaxMessageBytes,
+			ReadTimeout:         Duration(DefaultReadTimeout),
+		},
+		RateLimit: RateLimitConfig{
+			DefaultPerClient: DefaultRateLimitPerClient,
+			DefaultPerIP:     DefaultRateLimitPerIP,
+			MaxViolations:    DefaultMaxViolations,
+			ViolationWindow:  Duration(DefaultViolationWindow),
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:    webhook.DefaultMaxAttempts,
+			InitialBackoff: Duration(webhook.DefaultInitialBackoff),
+			MaxBackoff:     Duration(webhook.DefaultMaxBackoff),
+			Timeout:        Duration(webhook.DefaultTimeout),
+			QueueSize:      webhook.DefaultQueueSize,
+		},
+	}
+}
+
+// Load は既定値に path のJSONファイル（空なら読まない）と環境変数を順に重ねて検証する
+// lookupEnv には通常 os.LookupEnv を渡す
+func Load(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), lookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv は env タグを持つフィールドを環境変数で上書きする
+func applyEnv(v reflect.Value, lookupEnv func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(fv, lookupEnv); err != nil {
+				return err
+			}
+			continue
+		}
+		tag := field.Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		for _, key := range strings.Split(tag, ",") {
+			raw, ok := lookupEnv(key)
+			if !ok {
+				continue
+			}
+			if err := setField(fv, raw); err != nil {
+				return fmt.Errorf("config: %s: %w", key, err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, raw string) error {
+	switch fv.Interface().(type) {
+	case Duration:
+		d, err := ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(d))
+		return nil
+	case []string:
+		var values []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+		fv.Set(reflect.ValueOf(values))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		fv.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// Validate は値の範囲を検査し、問題を全てまとめて返す
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port <= 65535, "server.port must be 1-65535, got %q", c.Server.Port)
+	check(c.Server.DrainTimeout > 0, "server.drain_timeout must be positive")
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Server.LogLevel)) == nil, "server.log_level must be debug, info, warn or error, got %q", c.Server.LogLevel)
+	check(c.Server.LogFormat == "text" || c.Server.LogFormat == "json", "server.log_format must be text or json, got %q", c.Server.LogFormat)
+
+	ws := c.WebSocket
+	check(ws.SendBufferSize >= 1 && ws.SendBufferSize <= 4096, "websocket.send_buffer_size must be 1-4096, got %d", ws.SendBufferSize)
+	check(ws.HeartbeatInterval >= Duration(100*time.Millisecond), "websocket.heartbeat_interval must be at least 100ms, got %s", ws.HeartbeatInterval.Std())
+	check(ws.PongTimeout > ws.HeartbeatInterval, "websocket.pong_timeout (%s) must be longer than heartbeat_interval (%s)", ws.PongTimeout.Std(), ws.HeartbeatInterval.Std())
+	check(ws.GracePeriod > 0, "websocket.grace_period must be positive")
+
+	g := c.Game
+	// クライアントは3列のグリッドで表示するため、4〜9枚に制限する
+	check(g.ImagesPerProblem >= 4 && g.ImagesPerProblem <= 9, "game.images_per_problem must be 4-9, got %d", g.ImagesPerProblem)
+	check(g.CorrectPerProblem >= 1 && g.CorrectPerProblem < g.ImagesPerProblem, "game.correct_per_problem must be between 1 and images_per_problem-1, got %d", g.CorrectPerProblem)
+	check(g.ComboThreshold >= 1, "game.combo_threshold must be at least 1, got %d", g.ComboThreshold)
+	check(g.EffectDuration > 0 && g.EffectDuration <= Duration(time.Minute), "game.effect_duration must be between 0 and 1m, got %s", g.EffectDuration.Std())
+	check(g.DefaultWinningScore >= 1 && g.DefaultWinningScore <= 100, "game.default_winning_score must be 1-100, got %d", g.DefaultWinningScore)
+	check(g.DefaultCapacity >= 2 && g.DefaultCapacity <= 10, "game.default_capacity must be 2-10, got %d", g.DefaultCapacity)
+	check(g.TargetChoiceTimeout >= 0 && g.TargetChoiceTimeout <= Duration(30*time.Second), "game.target_choice_timeout must be between 0 and 30s, got %s", g.TargetChoiceTimeout.Std())
+	if len(g.Effects) > 0 {
+		_, err := c.EffectRegistry()
+		check(err == nil, "game.effects: %v", err)
+		for _, e := range g.Effects {
+			check(e.Duration <= Duration(time.Minute), "game.effects: %s duration must not exceed 1m, got %s", e.ID, e.Duration.Std())
+		}
+	}
+
+	it := c.Items
+	check(it.InventorySize >= 0 && it.InventorySize <= 10, "items.inventory_size must be 0-10, got %d", it.InventorySize)
+	check(it.StreakInterval >= 0, "items.streak_interval must not be negative, got %d", it.StreakInterval)
+	check(it.MilestoneInterval >= 0, "items.milestone_interval must not be negative, got %d", it.MilestoneInterval)
+	check(it.Cooldown >= 0 && it.Cooldown <= Duration(time.Minute), "items.cooldown must be between 0 and 1m, got %s", it.Cooldown.Std())
+
+	v := c.Verify
+	check(v.LockoutDuration >= 0, "verify.lockout_duration must not be negative, got %s", v.LockoutDuration.Std())
+	check(v.MaxAttemptsPerProblem >= 1, "verify.max_attempts_per_problem must be at least 1, got %d", v.MaxAttemptsPerProblem)
+	check(v.ScorePenalty >= 0, "verify.score_penalty must not be negative, got %d", v.ScorePenalty)
+
+	bd := c.BotDetection
+	check(bd.MinSolveTime >= 0, "bot_detection.min_solve_time must not be negative, got %s", bd.MinSolveTime.Std())
+	check(bd.MinSelectInterval >= 0, "bot_detection.min_select_interval must not be negative, got %s", bd.MinSelectInterval.Std())
+	check(bd.FastSolvePoints >= 0 && bd.FastSelectPoints >= 0, "bot_detection.fast_solve_points and fast_select_points must not be negative")
+	check(bd.DecayPerMinute >= 0, "bot_detection.decay_per_minute must not be negative, got %g", bd.DecayPerMinute)
+	check(bd.FlagThreshold > 0, "bot_detection.flag_threshold must be positive, got %g", bd.FlagThreshold)
+	check(bd.QuarantineThreshold >= bd.FlagThreshold, "bot_detection.quarantine_threshold (%g) must not be lower than flag_threshold (%g)", bd.QuarantineThreshold, bd.FlagThreshold)
+	check(bd.MaxReasons >= 1, "bot_detection.max_reasons must be at least 1, got %d", bd.MaxReasons)
+
+	check(c.Matchmaking.TicketTTL >= Duration(time.Minute), "matchmaking.ticket_ttl must be at least 1m, got %s", c.Matchmaking.TicketTTL.Std())
+	if c.Matchmaking.URL != "" {
+		u, err := url.Parse(c.Matchmaking.URL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "matchmaking.url must be an http(s) URL, got %q", c.Matchmaking.URL)
+	}
+	check(c.Matchmaking.MaxRandomRetries >= 0 && c.Matchmaking.MaxRandomRetries <= 10, "matchmaking.max_random_retries must be 0-10, got %d", c.Matchmaking.MaxRandomRetries)
+
+	rooms := c.Rooms
+	check(rooms.ReaperInterval >= Duration(time.Second), "rooms.reaper_interval must be at least 1s, got %s", rooms.ReaperInterval.Std())
+	check(rooms.WaitingTimeout >= rooms.ReaperInterval, "rooms.waiting_timeout (%s) must not be shorter than reaper_interval (%s)", rooms.WaitingTimeout.Std(), rooms.ReaperInterval.Std())
+	check(rooms.IdleTimeout >= rooms.ReaperInterval, "rooms.idle_timeout (%s) must not be shorter than reaper_interval (%s)", rooms.IdleTimeout.Std(), rooms.ReaperInterval.Std())
+	// 再接続の猶予中に片付けないよう、対戦中の放置期限は猶予より長くする
+	check(rooms.IdleTimeout > ws.GracePeriod, "rooms.idle_timeout (%s) must be longer than websocket.grace_period (%s)", rooms.IdleTimeout.Std(), ws.GracePeriod.Std())
+
+	cl := c.Cluster
+	check(cl.HeartbeatInterval >= Duration(100*time.Millisecond), "cluster.heartbeat_interval must be at least 100ms, got %s", cl.HeartbeatInterval.Std())
+	// 1回の更新失敗でリースを失わないよう、期限は更新間隔の2倍以上にする
+	check(cl.LeaseTTL >= 2*cl.HeartbeatInterval, "cluster.lease_ttl (%s) must be at least twice heartbeat_interval (%s)", cl.LeaseTTL.Std(), cl.HeartbeatInterval.Std())
+
+	a := c.Admission
+	check(a.MaxConnections >= 0, "admission.max_connections must not be negative")
+	check(a.MaxConnectionsPerIP >= 0, "admission.max_connections_per_ip must not be negative")
+	check(a.MaxMessageBytes >= 1024, "admission.max_message_bytes must be at least 1024, got %d", a.MaxMessageBytes)
+	check(a.ReadTimeout > ws.HeartbeatInterval, "admission.read_timeout (%s) must be longer than websocket.heartbeat_interval (%s)", a.ReadTimeout.Std(), ws.HeartbeatInterval.Std())
+
+	rl := c.RateLimit
+	checkSpec := func(name string, spec RateLimitSpec) {
+		check(spec.Rate > 0 && spec.Burst >= 1, "rate_limit.%s must have a positive rate and a burst of at least 1, got %+v", name, spec)
+	}
+	checkSpec("default_per_client", rl.DefaultPerClient)
+	checkSpec("default_per_ip", rl.DefaultPerIP)
+	for _, group := range []struct {
+		name  string
+		specs map[string]RateLimitSpec
+	}{{"per_client", rl.PerClient}, {"per_ip", rl.PerIP}} {
+		for msgType, spec := range group.specs {
+			_, known := protocol.Lookup(protocol.ClientToServer, msgType)
+			check(known, "rate_limit.%s: unknown message type %q", group.name, msgType)
+			checkSpec(group.name+"."+msgType, spec)
+		}
+	}
+	check(rl.MaxViolations >= 1, "rate_limit.max_violations must be at least 1, got %d", rl.MaxViolations)
+	check(rl.ViolationWindow >= Duration(time.Second), "rate_limit.violation_window must be at least 1s, got %s", rl.ViolationWindow.Std())
+
+	wh := c.Webhooks
+	check(wh.MaxAttempts >= 1 && wh.MaxAttempts <= 20, "webhooks.max_attempts must be 1-20, got %d", wh.MaxAttempts)
+	check(wh.InitialBackoff >= Duration(10*time.Millisecond), "webhooks.initial_backoff must be at least 10ms, got %s", wh.InitialBackoff.Std())
+	check(wh.MaxBackoff >= wh.InitialBackoff, "webhooks.max_backoff (%s) must not be shorter than initial_backoff (%s)", wh.MaxBackoff.Std(), wh.InitialBackoff.Std())
+	check(wh.Timeout > 0 && wh.Timeout <= Duration(time.Minute), "webhooks.timeout must be between 0 and 1m, got %s", wh.Timeout.Std())
+	check(wh.QueueSize >= 1 && wh.QueueSize <= 65536, "webhooks.queue_size must be 1-65536, got %d", wh.QueueSize)
+	for i, sub := range wh.Subscriptions {
+		u, err := url.Parse(sub.URL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "webhooks.subscriptions[%d].url must be an http(s) URL, got %q", i, sub.URL)
+		for _, event := range sub.Events {
+			check(webhook.IsEventName(event), "webhooks.subscriptions[%d].events: unknown event %q (use %s)", i, event, strings.Join(webhook.EventNames(), ", "))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted は秘密の値を伏せたコピーを返す（管理APIでの表示用）
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Admission.AllowedOrigins = append([]string(nil), c.Admission.AllowedOrigins...)
+	redacted.Game.Effects = append([]EffectConfig(nil), c.Game.Effects...)
+	redacted.RateLimit.PerClient = copyRateLimitSpecs(c.RateLimit.PerClient)
+	redacted.RateLimit.PerIP = copyRateLimitSpecs(c.RateLimit.PerIP)
+	if redacted.Server.AdminToken != "" {
+		redacted.Server.AdminToken = secretMask
+	}
+	redacted.Webhooks.Subscriptions = make([]WebhookSubscriptionConfig, len(c.Webhooks.Subscriptions))
+	for i, sub := range c.Webhooks.Subscriptions {
+		sub.Events = append([]string(nil), sub.Events...)
+		if sub.Secret != "" {
+			sub.Secret = secretMask
+		}
+		redacted.Webhooks.Subscriptions[i] = sub
+	}
+	return &redacted
+}
+
+func copyRateLimitSpecs(specs map[string]RateLimitSpec) map[string]RateLimitSpec {
+	if specs == nil {
+		return nil
+	}
+	copied := make(map[string]RateLimitSpec, len(specs))
+	for msgType, spec := range specs {
+		copied[msgType] = spec
+	}
+	return copied
+}
+
+// GameRules はドメインのルールに変換する
+func (c *Config) GameRules() domain.GameRules {
+	return domain.GameRules{
+		ImagesPerProblem:    c.Game.ImagesPerProblem,
+		CorrectPerProblem:   c.Game.CorrectPerProblem,
+		ComboThreshold:      c.Game.ComboThreshold,
+		EffectDuration:      c.Game.EffectDuration.Std(),
+		DefaultWinningScore: c.Game.DefaultWinningScore,
+		DefaultCapacity:     c.Game.DefaultCapacity,
+		TargetChoiceTimeout: c.Game.TargetChoiceTimeout.Std(),
+	}
+}
+
+// EffectRegistry は妨害エフェクトのレジストリに変換する（game.effects が空なら既定の定義）
+func (c *Config) EffectRegistry() (*domain.EffectRegistry, error) {
+	if len(c.Game.Effects) == 0 {
+		return domain.DefaultEffectRegistry(c.Game.EffectDuration.Std()), nil
+	}
+	specs := make([]domain.EffectSpec, 0, len(c.Game.Effects))
+	for _, e := range c.Game.Effects {
+		specs = append(specs, domain.EffectSpec{
+			ID:        domain.EffectType(e.ID),
+			Duration:  e.Duration.Std(),
+			Weight:    e.Weight,
+			Rarity:    domain.EffectRarity(e.Rarity),
+			Intensity: e.Intensity,
+			Stacking:  domain.EffectStacking(e.Stacking),
+		})
+	}
+	return domain.NewEffectRegistry(specs, c.Game.EffectDuration.Std())
+}
+
+// ItemPolicy はアイテムのルールに変換する（入手するアイテムの種類は既定のまま）
+func (c *Config) ItemPolicy() domain.ItemPolicy {
+	policy := domain.DefaultItemPolicy()
+	policy.InventorySize = c.Items.InventorySize
+	policy.StreakInterval = c.Items.StreakInterval
+	policy.MilestoneInterval = c.Items.MilestoneInterval
+	policy.Cooldown = c.Items.Cooldown.Std()
+	return policy
+}
+
+// VerifyPenaltyPolicy は不正解時のペナルティに変換する
+func (c *Config) VerifyPenaltyPolicy() domain.VerifyPenaltyPolicy {
+	return domain.VerifyPenaltyPolicy{
+		LockoutDuration:       c.Verify.LockoutDuration.Std(),
+		MaxAttemptsPerProblem: c.Verify.MaxAttemptsPerProblem,
+		ScorePenalty:          c.Verify.ScorePenalty,
+	}
+}
+
+// SuspicionPolicy はボット判定のしきい値に変換する
+func (c *Config) SuspicionPolicy() domain.SuspicionPolicy {
+	bd := c.BotDetection
+	return domain.SuspicionPolicy{
+		MinSolveTime:        bd.MinSolveTime.Std(),
+		FastSolvePoints:     bd.FastSolvePoints,
+		MinSelectInterval:   bd.MinSelectInterval.Std(),
+		FastSelectPoints:    bd.FastSelectPoints,
+		DecayPerMinute:      bd.DecayPerMinute,
+		FlagThreshold:       bd.FlagThreshold,
+		QuarantineThreshold: bd.QuarantineThreshold,
+		QuarantineAddress:   bd.QuarantineAddress,
+		MaxReasons:          bd.MaxReasons,
+	}
+}
+
+// MatchmakingPolicy はランダムマッチの設定に変換する
+func (c *Config) MatchmakingPolicy() domain.MatchmakingPolicy {
+	return domain.MatchmakingPolicy{MaxRandomRetries: c.Matchmaking.MaxRandomRetries}
+}
+
+// RoomExpiryPolicy は放置されたルームを片付ける条件に変換する
+func (c *Config) RoomExpiryPolicy() domain.RoomExpiryPolicy {
+	return domain.RoomExpiryPolicy{
+		WaitingTimeout: c.Rooms.WaitingTimeout.Std(),
+		IdleTimeout:    c.Rooms.IdleTimeout.Std(),
+	}
+}
+
+// WebhookSubscriptions は webhook の通知先に変換する
+func (c *Config) WebhookSubscriptions() []webhook.Subscription {
+	subs := make([]webhook.Subscription, 0, len(c.Webhooks.Subscriptions))
+	for _, sub := range c.Webhooks.Subscriptions {
+		subs = append(subs, webhook.Subscription{URL: sub.URL, Secret: sub.Secret, Events: sub.Events})
+	}
+	return subs
+}
+
+// WebhookOptions は webhook の配送の設定に変換する
+func (c *Config) WebhookOptions() webhook.Options {
+	return webhook.Options{
+		MaxAttempts:    c.Webhooks.MaxAttempts,
+		InitialBackoff: c.Webhooks.InitialBackoff.Std(),
+		MaxBackoff:     c.Webhooks.MaxBackoff.Std(),
+		Timeout:        c.Webhooks.Timeout.Std(),
+		QueueSize:      c.Webhooks.QueueSize,
+	}
+}
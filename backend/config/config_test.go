@@ -0,0 +1,224 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"recaptchgame-backend/domain"
+)
+
+func envFrom(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+}
+
+// TestLoad は設定の読み込みと検証のテスト
+func TestLoad(t *testing.T) {
+	// テスト1: 何も指定しなければ既定値（各層の既定値と一致する）
+	cfg, err := Load("", envFrom(nil))
+	if err != nil {
+		t.Fatalf("expected defaults to be valid, got %v", err)
+	}
+	if cfg.GameRules() != domain.DefaultGameRules() {
+		t.Errorf("expected default game rules, got %+v", cfg.GameRules())
+	}
+
+	// テスト2: ファイルの値に環境変数を重ねる（時間は文字列・整数秒のどちらも受け付ける）
+	path := filepath.Join(t.TempDir(), "config.json")
+	file := `{
+		"websocket": {"send_buffer_size": 64, "pong_timeout": "30s"},
+		"game": {"images_per_problem": 6, "correct_per_problem": 2, "effect_duration": 5},
+		"admission": {"allowed_origins": ["https://example.com"]},
+		"webhooks": {"subscriptions": [{"url": "https://hooks.example/match", "secret": "hook-secret", "events": ["game.finished"]}]}
+	}`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg, err = Load(path, envFrom(map[string]string{
+		"SEND_BUFFER_SIZE":      "128",
+		"DRAIN_TIMEOUT_SECONDS": "90",
+		"ALLOWED_ORIGINS":       "https://a.example, https://b.example",
+		"TRUST_PROXY_HEADERS":   "true",
+		"ADMIN_TOKEN":           "admin-secret",
+		"WEBHOOK_MAX_ATTEMPTS":  "3",
+	}))
+	if err != nil {
+		t.Fatalf("expected config to load, got %v", err)
+	}
+	if cfg.WebSocket.SendBufferSize != 128 || cfg.WebSocket.PongTimeout.Std() != 30*time.Second {
+		t.Errorf("unexpected websocket config: %+v", cfg.WebSocket)
+	}
+	if cfg.Game.ImagesPerProblem != 6 || cfg.Game.EffectDuration.Std() != 5*time.Second {
+		t.Errorf("unexpected game config: %+v", cfg.Game)
+	}
+	if cfg.Server.DrainTimeout.Std() != 90*time.Second || !cfg.Server.TrustProxyHeaders {
+		t.Errorf("unexpected server config: %+v", cfg.Server)
+	}
+	if len(cfg.Admission.AllowedOrigins) != 2 || cfg.Admission.AllowedOrigins[1] != "https://b.example" {
+		t.Errorf("unexpected allowed origins: %v", cfg.Admission.AllowedOrigins)
+	}
+
+	subs := cfg.WebhookSubscriptions()
+	if len(subs) != 1 || subs[0].Secret != "hook-secret" || !subs[0].Wants("game.finished") || subs[0].Wants("room.created") {
+		t.Errorf("unexpected webhook subscriptions: %+v", subs)
+	}
+	if cfg.WebhookOptions().MaxAttempts != 3 {
+		t.Errorf("unexpected webhook options: %+v", cfg.WebhookOptions())
+	}
+
+	// テスト3: 管理API向けのコピーでは秘密の値を伏せる（元の値は変えない）
+	b, _ := json.Marshal(cfg.Redacted())
+	if strings.Contains(string(b), "admin-secret") || cfg.Server.AdminToken != "admin-secret" {
+		t.Errorf("expected admin token to be masked only in the copy: %s", b)
+	}
+	if strings.Contains(string(b), "hook-secret") {
+		t.Errorf("expected webhook secret to be masked in the copy: %s", b)
+	}
+	if cfg.Webhooks.Subscriptions[0].Secret != "hook-secret" {
+		t.Errorf("expected webhook secret to be kept in the original")
+	}
+
+	// テスト4: 範囲外の値は全てまとめて報告する
+	_, err = Load("", envFrom(map[string]string{
+		"IMAGES_PER_PROBLEM":    "12",
+		"CORRECT_PER_PROBLEM":   "0",
+		"PONG_TIMEOUT":          "1s",
+		"TARGET_CHOICE_TIMEOUT": "1m",
+	}))
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, want := range []string{"images_per_problem", "correct_per_problem", "pong_timeout", "target_choice_timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got %v", want, err)
+		}
+	}
+
+	// テスト5: 解釈できない値や未知のキーはエラー
+	if _, err := Load("", envFrom(map[string]string{"MAX_CONNECTIONS": "many"})); err == nil {
+		t.Errorf("expected error for non-integer env value")
+	}
+	if err := os.WriteFile(path, []byte(`{"game": {"unknown": 1}}`), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	if _, err := Load(path, envFrom(nil)); err == nil {
+		t.Errorf("expected error for unknown key in config file")
+	}
+
+	// テスト6: 妨害エフェクトの定義は設定ファイルで差し替えられ、未知のエフェクトはエラー
+	if err := os.WriteFile(path, []byte(`{"game": {"effect_duration": "4s", "effects": [{"id": "BLUR", "weight": 3, "stacking": "extend"}, {"id": "SPIN", "weight": 1, "duration": "2s"}]}}`), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg, err = Load(path, envFrom(nil))
+	if err != nil {
+		t.Fatalf("expected effects to load, got %v", err)
+	}
+	registry, _ := cfg.EffectRegistry()
+	if blur, ok := registry.Lookup("BLUR"); !ok || blur.Duration != 4*time.Second || blur.Stacking != domain.StackingExtend {
+		t.Errorf("unexpected BLUR spec: %+v", blur)
+	}
+	if _, ok := registry.Lookup("SHAKE"); ok {
+		t.Errorf("expected configured effects to replace the defaults")
+	}
+	if err := os.WriteFile(path, []byte(`{"game": {"effects": [{"id": "LASER", "weight": 1}]}}`), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	if _, err := Load(path, envFrom(nil)); err == nil || !strings.Contains(err.Error(), "game.effects") {
+		t.Errorf("expected error for unknown effect, got %v", err)
+	}
+
+	// テスト7: webhook の通知先はURLとイベント名を検査する
+	if err := os.WriteFile(path, []byte(`{"webhooks": {"subscriptions": [{"url": "ftp://hooks.example"}, {"url": "https://hooks.example", "events": ["game.paused"]}]}}`), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	_, err = Load(path, envFrom(nil))
+	for _, want := range []string{"subscriptions[0].url", `unknown event "game.paused"`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got %v", want, err)
+		}
+	}
+
+	// テスト8: アイテムのルールは環境変数で変えられ、入手するアイテムの種類は既定のまま
+	cfg, err = Load("", envFrom(map[string]string{"ITEM_INVENTORY_SIZE": "1", "ITEM_COOLDOWN": "2s"}))
+	if err != nil {
+		t.Fatalf("expected item config to load, got %v", err)
+	}
+	items := cfg.ItemPolicy()
+	if items.InventorySize != 1 || items.Cooldown != 2*time.Second || items.MilestoneItem != domain.ItemReflect || len(items.StreakItems) != 2 {
+		t.Errorf("unexpected item policy: %+v", items)
+	}
+	if _, err := Load("", envFrom(map[string]string{"ITEM_INVENTORY_SIZE": "-1"})); err == nil || !strings.Contains(err.Error(), "items.inventory_size") {
+		t.Errorf("expected error for negative inventory size, got %v", err)
+	}
+
+	// テスト9: 不正解のペナルティは既定でドメインの既定値と同じで、減点も設定で有効にできる
+	cfg, _ = Load("", envFrom(nil))
+	if cfg.VerifyPenaltyPolicy() != domain.DefaultVerifyPenaltyPolicy() {
+		t.Errorf("expected default verify penalty policy, got %+v", cfg.VerifyPenaltyPolicy())
+	}
+	cfg, err = Load("", envFrom(map[string]string{"VERIFY_LOCKOUT_DURATION": "3s", "VERIFY_MAX_ATTEMPTS_PER_PROBLEM": "5", "VERIFY_SCORE_PENALTY": "1"}))
+	if err != nil {
+		t.Fatalf("expected verify config to load, got %v", err)
+	}
+	if penalty := cfg.VerifyPenaltyPolicy(); penalty.LockoutDuration != 3*time.Second || penalty.MaxAttemptsPerProblem != 5 || penalty.ScorePenalty != 1 {
+		t.Errorf("unexpected verify penalty policy: %+v", penalty)
+	}
+	_, err = Load("", envFrom(map[string]string{"VERIFY_LOCKOUT_DURATION": "-1s", "VERIFY_MAX_ATTEMPTS_PER_PROBLEM": "0", "VERIFY_SCORE_PENALTY": "-1"}))
+	for _, want := range []string{"verify.lockout_duration", "verify.max_attempts_per_problem", "verify.score_penalty"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got %v", want, err)
+		}
+	}
+
+	// テスト10: ボット判定のしきい値は環境変数で変えられ、IP単位の隔離も止められる
+	cfg, err = Load("", envFrom(map[string]string{"BOT_QUARANTINE_THRESHOLD": "20.5", "BOT_MIN_SOLVE_TIME": "500ms", "BOT_QUARANTINE_ADDRESS": "false"}))
+	if err != nil {
+		t.Fatalf("expected bot detection config to load, got %v", err)
+	}
+	suspicion := cfg.SuspicionPolicy()
+	if suspicion.QuarantineThreshold != 20.5 || suspicion.MinSolveTime != 500*time.Millisecond || suspicion.QuarantineAddress || suspicion.FlagThreshold != domain.DefaultSuspicionPolicy().FlagThreshold {
+		t.Errorf("unexpected suspicion policy: %+v", suspicion)
+	}
+	if _, err := Load("", envFrom(map[string]string{"BOT_QUARANTINE_THRESHOLD": "1"})); err == nil || !strings.Contains(err.Error(), "bot_detection.quarantine_threshold") {
+		t.Errorf("expected error for a quarantine threshold below the flag threshold, got %v", err)
+	}
+	if _, err := Load("", envFrom(map[string]string{"BOT_FLAG_THRESHOLD": "high"})); err == nil || !strings.Contains(err.Error(), "BOT_FLAG_THRESHOLD") {
+		t.Errorf("expected error for a non-numeric threshold, got %v", err)
+	}
+
+	// テスト11: 時間の環境変数は単位付きの新しい名前を優先し、旧名（*_SECONDS）の整数秒も受け付ける
+	cfg, err = Load("", envFrom(map[string]string{"DRAIN_TIMEOUT": "2m", "DRAIN_TIMEOUT_SECONDS": "30", "READ_TIMEOUT_SECONDS": "30"}))
+	if err != nil {
+		t.Fatalf("expected timeouts to load, got %v", err)
+	}
+	if cfg.Server.DrainTimeout.Std() != 2*time.Minute || cfg.Admission.ReadTimeout.Std() != 30*time.Second {
+		t.Errorf("unexpected timeouts: drain=%s read=%s", cfg.Server.DrainTimeout.Std(), cfg.Admission.ReadTimeout.Std())
+	}
+
+	// テスト12: レート制限は種別ごとの上書きを設定ファイルで、しきい値を環境変数でも指定でき、未知の種別はエラー
+	if err := os.WriteFile(path, []byte(`{"rate_limit": {"per_client": {"VERIFY": {"rate": 2, "burst": 6}}}}`), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg, err = Load(path, envFrom(map[string]string{"RATE_LIMIT_MAX_VIOLATIONS": "5", "RATE_LIMIT_VIOLATION_WINDOW": "30s"}))
+	if err != nil {
+		t.Fatalf("expected rate limit config to load, got %v", err)
+	}
+	if rl := cfg.RateLimit; rl.PerClient["VERIFY"] != (RateLimitSpec{Rate: 2, Burst: 6}) || rl.MaxViolations != 5 || rl.ViolationWindow.Std() != 30*time.Second || rl.DefaultPerClient != DefaultRateLimitPerClient {
+		t.Errorf("unexpected rate limit config: %+v", rl)
+	}
+	if err := os.WriteFile(path, []byte(`{"rate_limit": {"per_ip": {"PAUSE": {"rate": 1, "burst": 1}}, "default_per_client": {"rate": 0, "burst": 1}}}`), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	_, err = Load(path, envFrom(nil))
+	for _, want := range []string{`rate_limit.per_ip: unknown message type "PAUSE"`, "rate_limit.default_per_client"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got %v", want, err)
+		}
+	}
+}
@@ -97,17 +97,3 @@ func (r *BattleRoyaleRoom) GetWinner() string {
 	}
 	return ""
 }
-
-// EvaluateComboAndApplyObstruction はコンボを評価して妨害発動判定を行う
-// バトルロイヤルでは、コンボが2以上なら妨害（全体攻撃）を発動し、コンボをリセット
-func (r *BattleRoyaleRoom) EvaluateComboAndApplyObstruction(playerID string) bool {
-	player, exists := r.Players[playerID]
-	if !exists {
-		return false
-	}
-	if player.Combo >= 2 {
-		player.ResetCombo()
-		return true // 妨害発動
-	}
-	return false
-}
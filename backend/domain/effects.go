@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// EffectStacking は同じエフェクトが有効な相手に重ねて発動したときの扱い
+type EffectStacking string
+
+const (
+	StackingReplace EffectStacking = "replace" // 発動した時点から期限を数え直す
+	StackingExtend  EffectStacking = "extend"  // 残り時間に持続時間を足す（最大で持続時間の2倍）
+)
+
+// EffectRarity はエフェクトの出やすさの区分（表示用。抽選には Weight を使う）
+type EffectRarity string
+
+const (
+	RarityCommon   EffectRarity = "common"
+	RarityUncommon EffectRarity = "uncommon"
+	RarityRare     EffectRarity = "rare"
+)
+
+// EffectSpec は妨害エフェクト1種類の定義
+type EffectSpec struct {
+	ID        EffectType
+	Duration  time.Duration // 0 なら登録時の既定の持続時間
+	Weight    int           // 抽選の重み（0 なら抽選しない）
+	Rarity    EffectRarity
+	Intensity float64 // 演出の強さ（1 が標準。0 なら 1）
+	Stacking  EffectStacking
+}
+
+// DefaultEffectSpecs は既定のエフェクトの定義
+// 画面全体を覆う ONION_RAIN と操作が難しくなる INVERT は出にくくし、INVERT は短めにする
+func DefaultEffectSpecs() []EffectSpec {
+	return []EffectSpec{
+		{ID: EffectShake, Weight: 20, Rarity: RarityCommon, Stacking: StackingReplace},
+		{ID: EffectSpin, Weight: 15, Rarity: RarityCommon, Stacking: StackingReplace},
+		{ID: EffectBlur, Weight: 15, Rarity: RarityCommon, Stacking: StackingExtend},
+		{ID: EffectGrayscale, Weight: 15, Rarity: RarityCommon, Stacking: StackingExtend},
+		{ID: EffectSepia, Weight: 15, Rarity: RarityCommon, Stacking: StackingExtend},
+		{ID: EffectSkew, Weight: 10, Rarity: RarityUncommon, Stacking: StackingReplace},
+		{ID: EffectInvert, Duration: 2 * time.Second, Weight: 6, Rarity: RarityRare, Stacking: StackingReplace},
+		{ID: EffectOnionRain, Duration: 5 * time.Second, Weight: 4, Rarity: RarityRare, Intensity: 1.5, Stacking: StackingReplace},
+	}
+}
+
+// EffectRegistry は妨害エフェクトの定義と重み付きの抽選
+type EffectRegistry struct {
+	specs       []EffectSpec
+	totalWeight int
+}
+
+// NewEffectRegistry は定義を検査してレジストリを生成する
+// Duration が 0 の定義には defaultDuration を使う
+func NewEffectRegistry(specs []EffectSpec, defaultDuration time.Duration) (*EffectRegistry, error) {
+	known := make(map[string]bool)
+	for _, e := range GetAllEffects() {
+		known[e] = true
+	}
+	r := &EffectRegistry{}
+	seen := make(map[EffectType]bool)
+	for _, spec := range specs {
+		switch {
+		case !known[string(spec.ID)]:
+			return nil, fmt.Errorf("unknown effect %q", spec.ID)
+		case seen[spec.ID]:
+			return nil, fmt.Errorf("effect %s is defined twice", spec.ID)
+		case spec.Duration < 0 || spec.Weight < 0 || spec.Intensity < 0:
+			return nil, fmt.Errorf("effect %s: duration, weight and intensity must not be negative", spec.ID)
+		}
+		seen[spec.ID] = true
+		if spec.Duration == 0 {
+			spec.Duration = defaultDuration
+		}
+		if spec.Duration <= 0 {
+			return nil, fmt.Errorf("effect %s: duration must be positive", spec.ID)
+		}
+		if spec.Intensity == 0 {
+			spec.Intensity = 1
+		}
+		if spec.Rarity == "" {
+			spec.Rarity = RarityCommon
+		}
+		switch spec.Stacking {
+		case "":
+			spec.Stacking = StackingReplace
+		case StackingReplace, StackingExtend:
+		default:
+			return nil, fmt.Errorf("effect %s: unknown stacking %q", spec.ID, spec.Stacking)
+		}
+		r.specs = append(r.specs, spec)
+		r.totalWeight += spec.Weight
+	}
+	if r.totalWeight == 0 {
+		return nil, fmt.Errorf("at least one effect must have a positive weight")
+	}
+	return r, nil
+}
+
+// DefaultEffectRegistry は既定の定義のレジストリ
+func DefaultEffectRegistry(defaultDuration time.Duration) *EffectRegistry {
+	r, err := NewEffectRegistry(DefaultEffectSpecs(), defaultDuration)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Specs は登録された定義を返す（既定値を埋めた後の値）
+func (r *EffectRegistry) Specs() []EffectSpec {
+	return append([]EffectSpec(nil), r.specs...)
+}
+
+// Lookup はIDの定義を返す
+func (r *EffectRegistry) Lookup(id string) (EffectSpec, bool) {
+	for _, spec := range r.specs {
+		if string(spec.ID) == id {
+			return spec, true
+		}
+	}
+	return EffectSpec{}, false
+}
+
+// Pick は重みに比例した確率でエフェクトを1つ選ぶ
+// intn には rand.Intn を渡す（テストでは固定の値を返す関数を渡せる）
+func (r *EffectRegistry) Pick(intn func(n int) int) EffectSpec {
+	n := intn(r.totalWeight)
+	for _, spec := range r.specs {
+		if n < spec.Weight {
+			return spec
+		}
+		n -= spec.Weight
+	}
+	return r.specs[len(r.specs)-1]
+}
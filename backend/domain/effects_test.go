@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+// TestEffectRegistry は妨害エフェクトの定義・重み付きの抽選・重ね方のテスト
+func TestEffectRegistry(t *testing.T) {
+	registry := DefaultEffectRegistry(3 * time.Second)
+
+	// テスト1: 既定値を埋める（持続時間・強さ・重ね方）
+	shake, ok := registry.Lookup(string(EffectShake))
+	if !ok || shake.Duration != 3*time.Second || shake.Intensity != 1 || shake.Stacking != StackingReplace {
+		t.Errorf("unexpected SHAKE spec: %+v", shake)
+	}
+	if rain, _ := registry.Lookup(string(EffectOnionRain)); rain.Duration != 5*time.Second || rain.Rarity != RarityRare {
+		t.Errorf("unexpected ONION_RAIN spec: %+v", rain)
+	}
+
+	// テスト2: 抽選は重みの範囲で選ぶ（重み 0 の定義は選ばれない）
+	weighted, err := NewEffectRegistry([]EffectSpec{
+		{ID: EffectBlur, Weight: 1},
+		{ID: EffectSpin, Weight: 0},
+		{ID: EffectInvert, Weight: 3},
+	}, time.Second)
+	if err != nil {
+		t.Fatalf("failed to build registry: %v", err)
+	}
+	for n, want := range map[int]EffectType{0: EffectBlur, 1: EffectInvert, 3: EffectInvert} {
+		if got := weighted.Pick(func(int) int { return n }); got.ID != want {
+			t.Errorf("pick %d: expected %s, got %s", n, want, got.ID)
+		}
+	}
+	var total int
+	weighted.Pick(func(n int) int { total = n; return 0 })
+	if total != 4 {
+		t.Errorf("expected total weight 4, got %d", total)
+	}
+
+	// テスト3: 不正な定義はエラー
+	invalid := [][]EffectSpec{
+		{{ID: "LASER", Weight: 1}},
+		{{ID: EffectBlur, Weight: 1}, {ID: EffectBlur, Weight: 1}},
+		{{ID: EffectBlur, Weight: -1}},
+		{{ID: EffectBlur, Weight: 0}},
+		{{ID: EffectBlur, Weight: 1, Stacking: "multiply"}},
+	}
+	for i, specs := range invalid {
+		if _, err := NewEffectRegistry(specs, time.Second); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, specs)
+		}
+	}
+
+	// テスト4: extend は同じエフェクトの残り時間に足し（持続時間の2倍まで）、replace は数え直す
+	now := time.Now()
+	player := NewPlayer("player1")
+	blur := EffectSpec{ID: EffectBlur, Duration: 2 * time.Second, Stacking: StackingExtend}
+	player.ApplyObstruction(blur, now)
+	if got := player.ApplyObstruction(blur, now.Add(time.Second)); !got.Equal(now.Add(4 * time.Second)) {
+		t.Errorf("expected extended expiry, got %v", got.Sub(now))
+	}
+	if got := player.ApplyObstruction(blur, now.Add(time.Second)); !got.Equal(now.Add(5 * time.Second)) {
+		t.Errorf("expected extension to be capped at twice the duration, got %v", got.Sub(now))
+	}
+	spin := EffectSpec{ID: EffectSpin, Duration: 2 * time.Second, Stacking: StackingReplace}
+	if got := player.ApplyObstruction(spin, now.Add(time.Second)); !got.Equal(now.Add(3*time.Second)) || player.CurrentEffect != string(EffectSpin) {
+		t.Errorf("expected replaced effect, got %s %v", player.CurrentEffect, got.Sub(now))
+	}
+}
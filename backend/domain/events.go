@@ -0,0 +1,199 @@
+package domain
+
+import "time"
+
+// ドメインイベントの種別
+const (
+	EventRoomCreated           = "RoomCreated"
+	EventGameStarted           = "GameStarted"
+	EventPlayerScored          = "PlayerScored"
+	EventAnswerRejected        = "AnswerRejected"
+	EventComboObstructionFired = "ComboObstructionFired"
+	EventGameFinished          = "GameFinished"
+	EventPlayerLeft            = "PlayerLeft"
+	EventRoomExpired           = "RoomExpired"
+	EventItemAwarded           = "ItemAwarded"
+	EventItemUsed              = "ItemUsed"
+	EventTargetChoiceRequested = "TargetChoiceRequested"
+)
+
+// ゲーム終了の理由
+const (
+	FinishReasonScore   = "score"   // 勝利スコアに到達した
+	FinishReasonForfeit = "forfeit" // 他のプレイヤーが全員退出した
+	FinishReasonIdle    = "idle"    // 誰も回答しないまま期限切れ
+	FinishReasonAdmin   = "admin"   // 管理者が終了させた
+)
+
+// Event はユースケースが状態を変えたときに発行するドメインイベント
+type Event interface {
+	EventType() string
+	Meta() EventMeta
+}
+
+// EventMeta は全てのイベントに共通する情報
+// ClientID / RequestID はイベントのきっかけになったクライアントの操作（サーバー発のイベントなら空）
+type EventMeta struct {
+	RoomID     string
+	OccurredAt time.Time
+	ClientID   string
+	RequestID  string
+}
+
+// Meta は共通情報を返す
+func (m EventMeta) Meta() EventMeta { return m }
+
+// EventPublisher はドメインイベントの発行先
+type EventPublisher interface {
+	Publish(events ...Event)
+}
+
+// RoomCreated はプレイヤーの参加でルームを新しく作った
+type RoomCreated struct {
+	EventMeta
+	CreatorID    string
+	Capacity     int
+	WinningScore int
+	Public       bool // ランダムマッチで作られたルーム
+}
+
+// GameStarted はゲームが開始した
+type GameStarted struct {
+	EventMeta
+	PlayerIDs    []string
+	WinningScore int
+	Unrated      bool
+}
+
+// PlayerScored はプレイヤーが正解して得点した
+// GameOver なら勝利スコアに到達しており、新しい問題（Target / Images）はない
+type PlayerScored struct {
+	EventMeta
+	PlayerID  string
+	Score     int
+	Combo     int
+	SolveTime time.Duration
+	Target    string
+	Images    []string
+	GameOver  bool
+}
+
+// AnswerRejected は回答が不正解、またはロックアウト中で判定しなかった
+// ProblemReplaced なら回答回数の上限に達して問題を差し替えた（Target / Images に新しい問題）
+type AnswerRejected struct {
+	EventMeta
+	PlayerID         string
+	LockedOut        bool
+	LockoutRemaining time.Duration
+	ScoreDeducted    int
+	ProblemReplaced  bool
+	Target           string
+	Images           []string
+	Score            int
+	Combo            int
+}
+
+// ComboObstructionFired はコンボで相手に妨害エフェクトを送った
+type ComboObstructionFired struct {
+	EventMeta
+	AttackerID string
+	TargetID   string
+	Effect     string
+	Duration   time.Duration
+	ExpiresAt  time.Time // 対象のエフェクトが解除される時刻（重ねがけで延びた場合はその時刻）
+	Intensity  float64
+	Blocked    bool // 対象のシールドが防いだ（エフェクトはかかっていない）
+}
+
+// GameFinished はゲームが終了した（WinnerID が空なら引き分け・勝者なし）
+// Note は終了の説明（管理者による終了の理由など。空なら理由ごとの既定の文言）
+type GameFinished struct {
+	EventMeta
+	WinnerID  string
+	Reason    string
+	PlayerIDs []string
+	Scores    map[string]int
+	Unrated   bool
+	Note      string
+}
+
+// PlayerLeft はプレイヤーがルームを退出した
+type PlayerLeft struct {
+	EventMeta
+	PlayerID     string
+	Remaining    int
+	WasActive    bool // 対戦中のルームからの退出
+	RoomDeleted  bool // 最後のプレイヤーが抜けてルームを削除した
+	Disconnected bool // 切断したまま再接続の猶予が切れた（明示的な退出ではない）
+}
+
+// RoomExpired は参加者待ちのまま期限切れになったルームを削除した
+type RoomExpired struct {
+	EventMeta
+	PlayerIDs []string
+}
+
+// ItemAwarded はプレイヤーがアイテムを手に入れた
+type ItemAwarded struct {
+	EventMeta
+	PlayerID string
+	Item     string
+	Reason   string
+	Items    []string // 入手後の持ち物
+}
+
+// ItemUsed はプレイヤーがアイテムを使った
+// 反射した場合は TargetID に妨害が移った（Blocked なら相手のシールドが防いだ）
+type ItemUsed struct {
+	EventMeta
+	PlayerID  string
+	Item      string
+	Outcome   string
+	TargetID  string
+	Effect    string
+	ExpiresAt time.Time
+	Blocked   bool
+	Items     []string // 使用後の持ち物
+}
+
+// TargetChoiceRequested はコンボで妨害を発動した攻撃側に、送る相手を選ばせる
+// Deadline までに選ばなければ無作為に選んだ相手に送る
+type TargetChoiceRequested struct {
+	EventMeta
+	AttackerID string
+	Candidates []TargetCandidate
+	Deadline   time.Time
+}
+
+// EventType はイベントの種別
+func (RoomCreated) EventType() string { return EventRoomCreated }
+
+// EventType はイベントの種別
+func (GameStarted) EventType() string { return EventGameStarted }
+
+// EventType はイベントの種別
+func (PlayerScored) EventType() string { return EventPlayerScored }
+
+// EventType はイベントの種別
+func (AnswerRejected) EventType() string { return EventAnswerRejected }
+
+// EventType はイベントの種別
+func (ComboObstructionFired) EventType() string { return EventComboObstructionFired }
+
+// EventType はイベントの種別
+func (GameFinished) EventType() string { return EventGameFinished }
+
+// EventType はイベントの種別
+func (PlayerLeft) EventType() string { return EventPlayerLeft }
+
+// EventType はイベントの種別
+func (RoomExpired) EventType() string { return EventRoomExpired }
+
+// EventType はイベントの種別
+func (ItemAwarded) EventType() string { return EventItemAwarded }
+
+// EventType はイベントの種別
+func (ItemUsed) EventType() string { return EventItemUsed }
+
+// EventType はイベントの種別
+func (TargetChoiceRequested) EventType() string { return EventTargetChoiceRequested }
@@ -0,0 +1,247 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ItemType は妨害から身を守るアイテムの種類
+type ItemType string
+
+const (
+	ItemShield  ItemType = "SHIELD"  // 次に受ける妨害を1回防ぐ
+	ItemCleanse ItemType = "CLEANSE" // かかっている妨害を解除する
+	ItemReflect ItemType = "REFLECT" // かかっている妨害を送ってきた相手に跳ね返す
+)
+
+// GetAllItems はすべてのアイテムを返す
+func GetAllItems() []ItemType {
+	return []ItemType{ItemShield, ItemCleanse, ItemReflect}
+}
+
+// IsItem は name がアイテムの種類かを返す
+func IsItem(name string) bool {
+	for _, item := range GetAllItems() {
+		if string(item) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// アイテムを手に入れた理由
+const (
+	ItemAwardStreak    = "streak"    // ミスなしの連続正解
+	ItemAwardMilestone = "milestone" // スコアの節目
+)
+
+// アイテムを使った結果
+const (
+	ItemOutcomeShielded  = "shielded"  // 次の妨害を防ぐ状態になった
+	ItemOutcomeCleansed  = "cleansed"  // 妨害を解除した
+	ItemOutcomeReflected = "reflected" // 妨害を相手に跳ね返した（相手のシールドで防がれた場合は Blocked）
+)
+
+var (
+	// ErrItemNotHeld は持っていないアイテムを使おうとした
+	ErrItemNotHeld = errors.New("item not in inventory")
+	// ErrItemCooldown は前回の使用からの待ち時間が終わっていない
+	ErrItemCooldown = errors.New("item is on cooldown")
+	// ErrNoActiveEffect は解除・反射する妨害がかかっていない
+	ErrNoActiveEffect = errors.New("no active effect")
+	// ErrShieldActive はすでにシールドを張っている
+	ErrShieldActive = errors.New("shield is already active")
+	// ErrNoReflectTarget は妨害を送ってきた相手がもうルームにいない
+	ErrNoReflectTarget = errors.New("attacker is no longer in the room")
+)
+
+// ItemPolicy はアイテムの入手と使用のルール
+// StreakInterval: ミスなしの連続正解がこの回数に達するごとに StreakItems から1つ（0 なら入手しない）
+// MilestoneInterval: スコアがこの倍数に初めて達するごとに MilestoneItem を1つ（0 なら入手しない）
+// 持てる数（InventorySize）を超えた分は捨てる。InventorySize が 0 ならアイテムを使わない
+type ItemPolicy struct {
+	InventorySize     int
+	StreakInterval    int
+	StreakItems       []ItemType
+	MilestoneInterval int
+	MilestoneItem     ItemType
+	Cooldown          time.Duration // アイテムを使ってから次に使えるまでの時間
+}
+
+// DefaultItemPolicy は既定のルール
+// 強力な REFLECT はスコアの節目でだけ手に入る
+func DefaultItemPolicy() ItemPolicy {
+	return ItemPolicy{
+		InventorySize:     3,
+		StreakInterval:    3,
+		StreakItems:       []ItemType{ItemShield, ItemCleanse},
+		MilestoneInterval: 4,
+		MilestoneItem:     ItemReflect,
+		Cooldown:          5 * time.Second,
+	}
+}
+
+// Enabled はアイテムを使うルールかを返す
+func (p ItemPolicy) Enabled() bool {
+	return p.InventorySize > 0
+}
+
+// ItemAward は手に入れたアイテム
+type ItemAward struct {
+	Item   ItemType
+	Reason string
+}
+
+// ItemUse はアイテムを使った結果
+// TargetID / Effect / ExpiresAt は反射した場合の相手と、相手にかかった妨害
+type ItemUse struct {
+	Item      ItemType
+	Outcome   string
+	TargetID  string
+	Effect    string
+	ExpiresAt time.Time
+	Blocked   bool // 反射した妨害を相手のシールドが防いだ
+}
+
+// IncreaseStreak はミスなしの連続正解数を1増やす（不正解で RecordFailedVerify がリセットする）
+func (p *Player) IncreaseStreak() {
+	p.Streak++
+}
+
+// EarnItems は連続正解とスコアの節目に応じてアイテムを与え、持ち物に加えたものを返す
+// pick には rand.Intn を渡す（StreakItems からの抽選に使う）
+func (p *Player) EarnItems(policy ItemPolicy, pick func(n int) int) []ItemAward {
+	if !policy.Enabled() {
+		return nil
+	}
+	var awards []ItemAward
+	if policy.StreakInterval > 0 && len(policy.StreakItems) > 0 && p.Streak > 0 && p.Streak%policy.StreakInterval == 0 {
+		awards = append(awards, ItemAward{Item: policy.StreakItems[pick(len(policy.StreakItems))], Reason: ItemAwardStreak})
+	}
+	if policy.MilestoneInterval > 0 && policy.MilestoneItem != "" {
+		// 減点されてから同じ節目に戻っても再び与えない
+		if reached := p.Score / policy.MilestoneInterval; reached > p.Milestones {
+			p.Milestones = reached
+			awards = append(awards, ItemAward{Item: policy.MilestoneItem, Reason: ItemAwardMilestone})
+		}
+	}
+
+	var added []ItemAward
+	for _, award := range awards {
+		if len(p.Items) >= policy.InventorySize {
+			break
+		}
+		p.Items = append(p.Items, award.Item)
+		added = append(added, award)
+	}
+	return added
+}
+
+// ItemNames は持ち物を入手順の文字列で返す
+func (p *Player) ItemNames() []string {
+	names := make([]string, 0, len(p.Items))
+	for _, item := range p.Items {
+		names = append(names, string(item))
+	}
+	return names
+}
+
+// ItemCooldownRemaining は次にアイテムを使えるまでの時間を返す
+func (p *Player) ItemCooldownRemaining(now time.Time) time.Duration {
+	if remaining := p.ItemCooldownUntil.Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// ReceiveObstruction はシールドがあれば消費して妨害を防ぎ、なければ妨害をかける
+// 妨害をかけた場合は解除される時刻を返し、攻撃者を反射の相手として覚えておく
+func (p *Player) ReceiveObstruction(spec EffectSpec, attackerID string, now time.Time) (expiresAt time.Time, blocked bool) {
+	if p.Shielded {
+		p.Shielded = false
+		return time.Time{}, true
+	}
+	expiresAt = p.ApplyObstruction(spec, now)
+	p.EffectAttackerID = attackerID
+	return expiresAt, false
+}
+
+// takeItem は持ち物から最も古い item を1つ取り除く
+func (p *Player) takeItem(item ItemType) bool {
+	for i, held := range p.Items {
+		if held == item {
+			p.Items = append(p.Items[:i:i], p.Items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// hasActiveEffect は now の時点で妨害がかかっているかを返す
+func (p *Player) hasActiveEffect(now time.Time) bool {
+	return p.CurrentEffect != "" && p.EffectExpiresAt.After(now)
+}
+
+// UseItem はプレイヤーのアイテムを使う
+// 使えない場合（未所持・待ち時間中・対象の妨害がないなど）はアイテムを消費せずにエラーを返す
+func (r *Room) UseItem(playerID string, item ItemType, policy ItemPolicy, now time.Time) (ItemUse, error) {
+	player := r.GetPlayerByID(playerID)
+	if player == nil {
+		return ItemUse{}, ErrItemNotHeld
+	}
+	held := false
+	for _, h := range player.Items {
+		held = held || h == item
+	}
+	if !held {
+		return ItemUse{}, ErrItemNotHeld
+	}
+	if player.ItemCooldownRemaining(now) > 0 {
+		return ItemUse{}, ErrItemCooldown
+	}
+
+	use := ItemUse{Item: item}
+	switch item {
+	case ItemShield:
+		if player.Shielded {
+			return ItemUse{}, ErrShieldActive
+		}
+		player.Shielded = true
+		use.Outcome = ItemOutcomeShielded
+	case ItemCleanse:
+		if !player.hasActiveEffect(now) {
+			return ItemUse{}, ErrNoActiveEffect
+		}
+		use.Effect = player.CurrentEffect
+		player.ClearEffect()
+		use.Outcome = ItemOutcomeCleansed
+	case ItemReflect:
+		if !player.hasActiveEffect(now) {
+			return ItemUse{}, ErrNoActiveEffect
+		}
+		attacker := r.GetPlayerByID(player.EffectAttackerID)
+		if attacker == nil || attacker.ID == player.ID {
+			return ItemUse{}, ErrNoReflectTarget
+		}
+		// 残り時間ごと相手に移す（相手のシールドがあれば防がれる）
+		use.Outcome = ItemOutcomeReflected
+		use.TargetID = attacker.ID
+		use.Effect = player.CurrentEffect
+		remaining := player.EffectExpiresAt.Sub(now)
+		player.ClearEffect()
+		if attacker.Shielded {
+			attacker.Shielded = false
+			use.Blocked = true
+		} else {
+			use.ExpiresAt = now.Add(remaining)
+			attacker.ApplyEffect(use.Effect, use.ExpiresAt)
+			attacker.EffectAttackerID = player.ID
+		}
+	default:
+		return ItemUse{}, ErrItemNotHeld
+	}
+
+	player.takeItem(item)
+	player.ItemCooldownUntil = now.Add(policy.Cooldown)
+	return use, nil
+}
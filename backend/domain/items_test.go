@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+// TestItems はアイテムの入手と、シールド・解除・反射の効果のテスト
+func TestItems(t *testing.T) {
+	policy := DefaultItemPolicy()
+	first := func(int) int { return 0 }
+	now := time.Now()
+
+	// テスト1: 連続正解の回数とスコアの節目で入手し、持てる数を超えた分は捨てる
+	p := &Player{ID: "p1"}
+	for i := 0; i < 2; i++ {
+		p.IncreaseScore()
+		p.IncreaseStreak()
+		if awards := p.EarnItems(policy, first); len(awards) != 0 {
+			t.Fatalf("expected no award before streak interval, got %v", awards)
+		}
+	}
+	p.IncreaseScore()
+	p.IncreaseStreak()
+	if awards := p.EarnItems(policy, first); len(awards) != 1 || awards[0].Item != ItemShield || awards[0].Reason != ItemAwardStreak {
+		t.Errorf("expected streak award, got %v", awards)
+	}
+	p.IncreaseScore()
+	p.IncreaseStreak()
+	if awards := p.EarnItems(policy, first); len(awards) != 1 || awards[0].Item != ItemReflect || awards[0].Reason != ItemAwardMilestone {
+		t.Errorf("expected milestone award at score 4, got %v", awards)
+	}
+	// 減点されて同じ節目に戻っても再び与えない
+	p.Score = 3
+	p.IncreaseScore()
+	if awards := p.EarnItems(policy, first); len(awards) != 0 {
+		t.Errorf("expected no repeated milestone award, got %v", awards)
+	}
+	p.Items = []ItemType{ItemShield, ItemShield, ItemShield}
+	p.Streak = 6
+	if awards := p.EarnItems(policy, first); len(awards) != 0 || len(p.Items) != 3 {
+		t.Errorf("expected full inventory to drop awards, got %v %v", awards, p.Items)
+	}
+	p.RecordFailedVerify(DefaultVerifyPenaltyPolicy(), now)
+	if p.Streak != 0 {
+		t.Errorf("expected wrong answer to reset streak, got %d", p.Streak)
+	}
+
+	// テスト2: シールドは次の妨害を1回だけ防ぐ
+	room := NewRoom("room1", "a", "b", 5, 2)
+	attacker, defender := room.Player1, room.Player2
+	spec := EffectSpec{ID: EffectBlur, Duration: 3 * time.Second, Stacking: StackingReplace}
+	defender.Items = []ItemType{ItemShield, ItemCleanse}
+	if use, err := room.UseItem("b", ItemShield, policy, now); err != nil || use.Outcome != ItemOutcomeShielded {
+		t.Fatalf("expected shield to be used, got %+v %v", use, err)
+	}
+	if _, blocked := defender.ReceiveObstruction(spec, "a", now); !blocked || defender.CurrentEffect != "" {
+		t.Errorf("expected obstruction to be blocked")
+	}
+	if _, blocked := defender.ReceiveObstruction(spec, "a", now); blocked || defender.EffectAttackerID != "a" {
+		t.Errorf("expected shield to be consumed by the first obstruction")
+	}
+
+	// テスト3: 待ち時間の間は使えず、待ち時間が過ぎれば妨害を解除できる
+	if _, err := room.UseItem("b", ItemCleanse, policy, now.Add(time.Second)); err != ErrItemCooldown {
+		t.Errorf("expected ErrItemCooldown, got %v", err)
+	}
+	later := now.Add(policy.Cooldown)
+	defender.ReceiveObstruction(spec, "a", later)
+	if use, err := room.UseItem("b", ItemCleanse, policy, later); err != nil || use.Outcome != ItemOutcomeCleansed || defender.CurrentEffect != "" || len(defender.Items) != 0 {
+		t.Errorf("expected cleanse to clear the effect, got %+v %v", use, err)
+	}
+	if _, err := room.UseItem("b", ItemCleanse, policy, later); err != ErrItemNotHeld {
+		t.Errorf("expected ErrItemNotHeld after using the last item, got %v", err)
+	}
+
+	// テスト4: 反射は残り時間ごと攻撃者に移し、攻撃者のシールドがあれば防がれる
+	defender.ItemCooldownUntil = time.Time{}
+	defender.Items = []ItemType{ItemReflect, ItemReflect}
+	if _, err := room.UseItem("b", ItemReflect, policy, later); err != ErrNoActiveEffect {
+		t.Errorf("expected ErrNoActiveEffect without an effect, got %v", err)
+	}
+	defender.ReceiveObstruction(spec, "a", later)
+	use, err := room.UseItem("b", ItemReflect, policy, later.Add(time.Second))
+	if err != nil || use.TargetID != "a" || use.Blocked || !use.ExpiresAt.Equal(later.Add(spec.Duration)) {
+		t.Fatalf("expected effect to be reflected with its remaining time, got %+v %v", use, err)
+	}
+	if attacker.CurrentEffect != string(EffectBlur) || attacker.EffectAttackerID != "b" || defender.CurrentEffect != "" {
+		t.Errorf("expected effect to move to the attacker")
+	}
+	defender.ItemCooldownUntil = time.Time{}
+	attacker.ClearEffect()
+	attacker.Shielded = true
+	defender.ReceiveObstruction(spec, "a", later)
+	if use, err := room.UseItem("b", ItemReflect, policy, later); err != nil || !use.Blocked || attacker.CurrentEffect != "" || attacker.Shielded {
+		t.Errorf("expected reflected effect to be blocked by the attacker's shield, got %+v %v", use, err)
+	}
+}
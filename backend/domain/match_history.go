@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+// MatchStanding は対戦結果の1プレイヤー分の順位
+type MatchStanding struct {
+	PlayerID string
+	Score    int
+}
+
+// MatchRecord は終了した対戦の記録
+type MatchRecord struct {
+	RoomID     string
+	WinnerID   string // 空なら引き分け・勝者なし
+	Reason     string // FinishReason*
+	Standings  []MatchStanding
+	Unrated    bool
+	StartedAt  time.Time // 開始を記録していなければゼロ値
+	FinishedAt time.Time
+}
+
+// NewMatchRecord は GameFinished から対戦の記録を作る
+// 順位はスコアの高い順（同点ならプレイヤーIDの順）
+func NewMatchRecord(finished GameFinished, startedAt time.Time) *MatchRecord {
+	standings := make([]MatchStanding, 0, len(finished.Scores))
+	for playerID, score := range finished.Scores {
+		standings = append(standings, MatchStanding{PlayerID: playerID, Score: score})
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].Score != standings[j].Score {
+			return standings[i].Score > standings[j].Score
+		}
+		return standings[i].PlayerID < standings[j].PlayerID
+	})
+	return &MatchRecord{
+		RoomID:     finished.RoomID,
+		WinnerID:   finished.WinnerID,
+		Reason:     finished.Reason,
+		Standings:  standings,
+		Unrated:    finished.Unrated,
+		StartedAt:  startedAt,
+		FinishedAt: finished.OccurredAt,
+	}
+}
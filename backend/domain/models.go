@@ -9,6 +9,19 @@ type Player struct {
 	Combo           int
 	CurrentEffect   string
 	EffectExpiresAt time.Time
+	FailedAttempts  int       // 現在の問題に対する不正解回数
+	LockedUntil     time.Time // 不正解後に回答を受け付けない期限
+	// アイテム（items.go）
+	Streak            int        // ミスなしの連続正解数（妨害の発動ではリセットしない）
+	Milestones        int        // アイテムを与えたスコアの節目の数
+	Items             []ItemType // 持ち物（入手順）
+	Shielded          bool       // 次に受ける妨害を1回防ぐ
+	ItemCooldownUntil time.Time  // 次にアイテムを使える時刻
+	EffectAttackerID  string     // 現在の妨害エフェクトを送ってきたプレイヤー（反射の相手）
+	// 妨害を送る相手の選び方（targeting.go）
+	Targeting          TargetingMode // 空なら TargetRandom
+	TargetingPlayerID  string        // TargetPlayer で狙うプレイヤー
+	PendingTargetUntil time.Time     // TargetChoose で相手の選択を待つ期限（過ぎたら無作為に送る）
 }
 
 // NewPlayer は新しいプレイヤーを生成する
@@ -41,10 +54,24 @@ func (p *Player) ApplyEffect(effect string, expiresAt time.Time) {
 	p.EffectExpiresAt = expiresAt
 }
 
+// ApplyObstruction は重ね方のルールに従って妨害エフェクトを設定し、解除される時刻を返す
+func (p *Player) ApplyObstruction(spec EffectSpec, now time.Time) time.Time {
+	expiresAt := now.Add(spec.Duration)
+	if spec.Stacking == StackingExtend && p.CurrentEffect == string(spec.ID) && p.EffectExpiresAt.After(now) {
+		expiresAt = p.EffectExpiresAt.Add(spec.Duration)
+		if limit := now.Add(2 * spec.Duration); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+	p.ApplyEffect(string(spec.ID), expiresAt)
+	return expiresAt
+}
+
 // ClearEffect は妨害エフェクトを解除する
 func (p *Player) ClearEffect() {
 	p.CurrentEffect = ""
 	p.EffectExpiresAt = time.Time{}
+	p.EffectAttackerID = ""
 }
 
 // ActiveEffect はまだ有効な妨害エフェクトを返す
@@ -58,10 +85,66 @@ func (p *Player) ActiveEffect() string {
 	return p.CurrentEffect
 }
 
+// VerifyPenaltyPolicy は不正解時のペナルティ（総当たり対策）
+// LockoutDuration: 不正解後に回答を受け付けない時間
+// MaxAttemptsPerProblem: 同じ問題への回答回数の上限（到達すると問題を差し替える。0 は無制限）
+// ScorePenalty: 不正解ごとに減らすスコア（0 なら減点しない）
+type VerifyPenaltyPolicy struct {
+	LockoutDuration       time.Duration
+	MaxAttemptsPerProblem int
+	ScorePenalty          int
+}
+
+// DefaultVerifyPenaltyPolicy は既定のペナルティ（減点なし）
+func DefaultVerifyPenaltyPolicy() VerifyPenaltyPolicy {
+	return VerifyPenaltyPolicy{
+		LockoutDuration:       1500 * time.Millisecond,
+		MaxAttemptsPerProblem: 3,
+		ScorePenalty:          0,
+	}
+}
+
+// LockoutRemaining は回答を受け付けるまでの残り時間を返す
+func (p *Player) LockoutRemaining(now time.Time) time.Duration {
+	if p.LockedUntil.IsZero() || !now.Before(p.LockedUntil) {
+		return 0
+	}
+	return p.LockedUntil.Sub(now)
+}
+
+// RecordFailedVerify は不正解のペナルティを適用する
+// 回答回数の上限に達した場合は true を返す（呼び出し側で問題を差し替える）
+func (p *Player) RecordFailedVerify(policy VerifyPenaltyPolicy, now time.Time) bool {
+	p.ResetCombo()
+	p.Streak = 0
+	if policy.LockoutDuration > 0 {
+		p.LockedUntil = now.Add(policy.LockoutDuration)
+	}
+	if policy.ScorePenalty > 0 {
+		p.Score -= policy.ScorePenalty
+		if p.Score < 0 {
+			p.Score = 0
+		}
+	}
+	p.FailedAttempts++
+	if policy.MaxAttemptsPerProblem > 0 && p.FailedAttempts >= policy.MaxAttemptsPerProblem {
+		p.FailedAttempts = 0
+		return true
+	}
+	return false
+}
+
+// ResetVerifyAttempts は新しい問題に切り替わったときに回答回数とロックアウトをリセットする
+func (p *Player) ResetVerifyAttempts() {
+	p.FailedAttempts = 0
+	p.LockedUntil = time.Time{}
+}
+
 // GameState はゲーム状態を表すドメインエンティティ
 type GameState struct {
-	Target string   // 現在の出題
-	Images []string // 表示されている画像のリスト
+	Target   string    // 現在の出題
+	Images   []string  // 表示されている画像のリスト
+	IssuedAt time.Time // 現在の問題を出題した時刻
 }
 
 // NewGameState は新しいゲーム状態を生成
@@ -72,10 +155,19 @@ func NewGameState(target string, images []string) *GameState {
 	}
 }
 
-// UpdateState はゲーム状態を更新
+// UpdateState はゲーム状態を更新し、出題時刻を記録する
 func (g *GameState) UpdateState(target string, images []string) {
 	g.Target = target
 	g.Images = images
+	g.IssuedAt = time.Now()
+}
+
+// SolveTime は出題から指定時刻までの経過時間を返す（出題時刻が不明なら0）
+func (g *GameState) SolveTime(now time.Time) time.Duration {
+	if g.IssuedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(g.IssuedAt)
 }
 
 // Room はゲームルームを表すドメインエンティティ
@@ -91,6 +183,9 @@ type Room struct {
 	Capacity        int
 	ExtraPlayers    []*Player
 	ExtraGameStates []*GameState
+	Unrated         bool      // 隔離中のプレイヤーが参加したため記録・ランキング対象外
+	CreatedAt       time.Time // 管理画面でルームの経過時間を表示するために使う
+	LastActivityAt  time.Time // 最後に参加・開始・回答があった時刻（放置されたルームの判定に使う）
 }
 
 // NewRoom は新しいルームを生成
@@ -117,6 +212,7 @@ func NewRoom(id string, player1ID string, player2ID string, winningScore int, ca
 		}
 	}
 
+	now := time.Now()
 	return &Room{
 		ID:              id,
 		Player1:         NewPlayer(player1ID),
@@ -129,6 +225,8 @@ func NewRoom(id string, player1ID string, player2ID string, winningScore int, ca
 		Capacity:        capacity,
 		ExtraPlayers:    extraPlayers,
 		ExtraGameStates: extraGameStates,
+		CreatedAt:       now,
+		LastActivityAt:  now,
 	}
 }
 
@@ -142,6 +240,41 @@ func (r *Room) Start() {
 	r.IsActive = true
 }
 
+// Touch は最終アクティビティ時刻を更新する
+func (r *Room) Touch(now time.Time) {
+	r.LastActivityAt = now
+}
+
+// IdleFor は最後のアクティビティからの経過時間
+func (r *Room) IdleFor(now time.Time) time.Duration {
+	return now.Sub(r.LastActivityAt)
+}
+
+// Leader はスコアが最も高いプレイヤーのIDを返す（同点で並んだ場合は引き分けとして空文字）
+func (r *Room) Leader() string {
+	leader, best, tied := "", -1, false
+	consider := func(p *Player) {
+		if p == nil || p.ID == "" {
+			return
+		}
+		switch {
+		case p.Score > best:
+			leader, best, tied = p.ID, p.Score, false
+		case p.Score == best:
+			tied = true
+		}
+	}
+	consider(r.Player1)
+	consider(r.Player2)
+	for _, p := range r.ExtraPlayers {
+		consider(p)
+	}
+	if tied {
+		return ""
+	}
+	return leader
+}
+
 // IsGameOver はゲームが終了したかどうか
 func (r *Room) IsGameOver() bool {
 	return (r.Player1 != nil && r.Player1.Score >= r.WinningScore) || (r.Player2 != nil && r.Player2.Score >= r.WinningScore)
@@ -229,10 +362,10 @@ func (r *Room) GetOpponentGameState(playerID string) *GameState {
 }
 
 // EvaluateComboAndApplyObstruction はコンボを評価して妨害発動判定を行う
-// ドメインルール：コンボが2以上なら妨害を発動し、コンボをリセット
-func (r *Room) EvaluateComboAndApplyObstruction(playerID string) bool {
+// ドメインルール：コンボが threshold（既定2）以上なら妨害を発動し、コンボをリセット
+func (r *Room) EvaluateComboAndApplyObstruction(playerID string, threshold int) bool {
 	player := r.GetPlayerByID(playerID)
-	if player.Combo >= 2 {
+	if player.Combo >= threshold {
 		player.ResetCombo()
 		return true // 妨害発動
 	}
@@ -256,6 +389,34 @@ func (r *Room) CountPlayers() int {
 	return cnt
 }
 
+// PlayerIDs はルームにいるプレイヤーのIDをスロット順に返す
+func (r *Room) PlayerIDs() []string {
+	ids := make([]string, 0, r.CountPlayers())
+	if r.Player1 != nil && r.Player1.ID != "" {
+		ids = append(ids, r.Player1.ID)
+	}
+	if r.Player2 != nil && r.Player2.ID != "" {
+		ids = append(ids, r.Player2.ID)
+	}
+	for _, p := range r.ExtraPlayers {
+		if p != nil && p.ID != "" {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids
+}
+
+// Scores はルームにいるプレイヤーごとの現在のスコア
+func (r *Room) Scores() map[string]int {
+	scores := make(map[string]int, r.CountPlayers())
+	for _, playerID := range r.PlayerIDs() {
+		if p := r.GetPlayerByID(playerID); p != nil {
+			scores[playerID] = p.Score
+		}
+	}
+	return scores
+}
+
 // Problem は問題を表すドメインエンティティ
 type Problem struct {
 	Target string
@@ -12,18 +12,25 @@ var signalSplitCorrectTiles = []int{3, 4, 5}
 
 // ProblemFactory は問題を生成するドメインサービス
 // アルゴリズムはすべてドメイン層に封じ込める
-type ProblemFactory struct{}
+type ProblemFactory struct {
+	imagesPerProblem  int
+	correctPerProblem int
+}
 
 // NewProblemFactory は新しい ProblemFactory を生成
-func NewProblemFactory() *ProblemFactory {
-	return &ProblemFactory{}
+func NewProblemFactory(rules GameRules) *ProblemFactory {
+	return &ProblemFactory{
+		imagesPerProblem:  rules.ImagesPerProblem,
+		correctPerProblem: rules.CorrectPerProblem,
+	}
 }
 
 // CreateProblem はドメインルールに基づいて問題を生成
 // アルゴリズム：
-//   1. ターゲット画像から3つ選択
-//   2. その他から6つ追加
-//   3. 全9枚をシャッフル
+//   1. ターゲット画像から CorrectPerProblem 枚（既定3枚）選択
+//   2. その他から追加
+//   3. 全 ImagesPerProblem 枚（既定9枚）をシャッフル
+// 信号機は1枚の画像を9分割する問題のため、枚数の設定に関わらず9枚になる
 func (pf *ProblemFactory) CreateProblem(target string) *Problem {
 	if target == string(TargetSignal) {
 		return pf.createSplitImageProblem(target)
@@ -48,9 +55,9 @@ func (pf *ProblemFactory) CreateProblem(target string) *Problem {
 	shuffleStrings(corrects)
 	shuffleStrings(others)
 
-	// 正答から3つ選択
-	correctCount := 3
-	if len(corrects) < 3 {
+	// 正答から選択
+	correctCount := pf.correctPerProblem
+	if len(corrects) < correctCount {
 		correctCount = len(corrects)
 	}
 
@@ -61,16 +68,16 @@ func (pf *ProblemFactory) CreateProblem(target string) *Problem {
 	remaining := append(others, corrects[correctCount:]...)
 	shuffleStrings(remaining)
 
-	// 9枚になるまで追加
-	needed := 9 - len(selected)
+	// 規定の枚数になるまで追加
+	needed := pf.imagesPerProblem - len(selected)
 	if len(remaining) < needed {
 		selected = append(selected, remaining...)
 	} else {
 		selected = append(selected, remaining[:needed]...)
 	}
 
-	// 万が一素材が不足しても9枚を保証する
-	for len(selected) < 9 {
+	// 万が一素材が不足しても規定の枚数を保証する
+	for len(selected) < pf.imagesPerProblem {
 		if len(selected) == 0 {
 			break
 		}
@@ -17,12 +17,18 @@ type RoomRepository interface {
 	// ListActive はアクティブなルームをリスト
 	ListActive() ([]*Room, error)
 
+	// ListAll は待機中・対戦中を問わず全てのルームをリスト
+	ListAll() ([]*Room, error)
+
 	// GetWaitingRoom はマッチング待機中のルームを取得
 	GetWaitingRoom(capacity int) (*Room, error)
 
 	// SetWaitingRoom はマッチング待機ルームを設定
 	SetWaitingRoom(capacity int, room *Room) error
 
+	// ListWaitingRooms は定員ごとのマッチング待機ルームをリスト
+	ListWaitingRooms() (map[int]*Room, error)
+
 	// ClearWaitingRoom はマッチング待機ルームをクリア
 	ClearWaitingRoom(capacity int) error
 }
@@ -41,3 +47,43 @@ type ClientRepository interface {
 	// ListClientsByRoomID はルームIDからクライアントをリスト
 	ListClientsByRoomID(roomID string) ([]string, error)
 }
+
+// SuspicionRepository はプレイヤーごとのボット疑いスコアの永続化インターフェース
+type SuspicionRepository interface {
+	// Find はプレイヤーの疑いスコアを取得（記録がなければ nil）
+	Find(playerID string) (*SuspicionRecord, error)
+
+	// Save は疑いスコアを保存
+	Save(record *SuspicionRecord) error
+
+	// ListFlagged はフラグまたは隔離されたプレイヤーをリスト
+	ListFlagged() ([]*SuspicionRecord, error)
+}
+
+// SessionRepository はセッションの永続化インターフェース
+// 1プレイヤーにつき1セッションを保持する
+type SessionRepository interface {
+	// FindByID はセッションIDからセッションを取得（記録がなければ nil）
+	FindByID(sessionID string) (*Session, error)
+
+	// FindByPlayerID はプレイヤーIDからセッションを取得（記録がなければ nil）
+	FindByPlayerID(playerID string) (*Session, error)
+
+	// Save はセッションを保存（同じプレイヤーの古いセッションは置き換える）
+	Save(session *Session) error
+
+	// Delete はセッションを削除
+	Delete(sessionID string) error
+
+	// ListAll は全てのセッションをリスト
+	ListAll() ([]*Session, error)
+}
+
+// MatchHistoryRepository は終了した対戦の記録の永続化インターフェース
+type MatchHistoryRepository interface {
+	// Save は対戦の記録を保存
+	Save(record *MatchRecord) error
+
+	// ListRecent は新しい順に最大 limit 件の記録をリスト
+	ListRecent(limit int) ([]*MatchRecord, error)
+}
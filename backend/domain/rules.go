@@ -0,0 +1,62 @@
+package domain
+
+import "time"
+
+// GameRules は対戦のルール（出題・コンボ・妨害・ルームの既定値）
+type GameRules struct {
+	ImagesPerProblem    int           // 1問あたりの画像枚数（クライアントは3列のグリッドで表示する）
+	CorrectPerProblem   int           // 1問あたりの正解画像の枚数（素材が足りない場合は少なくなる）
+	ComboThreshold      int           // 妨害を発動する連続正解数
+	EffectDuration      time.Duration // 妨害エフェクトの持続時間
+	DefaultWinningScore int           // 勝利スコアの指定がない場合の値
+	DefaultCapacity     int           // 定員の指定がない場合の値
+	TargetChoiceTimeout time.Duration // CHOOSE_TARGET で相手の選択を待つ時間（0 なら選ばせずに無作為に送る）
+}
+
+// DefaultGameRules は既定のルール
+func DefaultGameRules() GameRules {
+	return GameRules{
+		ImagesPerProblem:    9,
+		CorrectPerProblem:   3,
+		ComboThreshold:      2,
+		EffectDuration:      3 * time.Second,
+		DefaultWinningScore: 5,
+		DefaultCapacity:     2,
+		TargetChoiceTimeout: 5 * time.Second,
+	}
+}
+
+// MatchmakingPolicy はランダムマッチの設定
+type MatchmakingPolicy struct {
+	MaxRandomRetries int // 割り当てられたルームが開始済み・満員だった場合に割り当て直してもらう回数
+}
+
+// DefaultMatchmakingPolicy は既定のランダムマッチ設定
+func DefaultMatchmakingPolicy() MatchmakingPolicy {
+	return MatchmakingPolicy{MaxRandomRetries: 3}
+}
+
+// RoomExpiryPolicy は放置されたルームを片付ける条件
+// WaitingTimeout は参加者待ちのルーム、IdleTimeout は対戦中に誰も回答しないルームの期限
+type RoomExpiryPolicy struct {
+	WaitingTimeout time.Duration
+	IdleTimeout    time.Duration
+}
+
+// DefaultRoomExpiryPolicy は既定の期限
+func DefaultRoomExpiryPolicy() RoomExpiryPolicy {
+	return RoomExpiryPolicy{
+		WaitingTimeout: 10 * time.Minute,
+		IdleTimeout:    5 * time.Minute,
+	}
+}
+
+// WaitingExpired は参加者待ちのルームが期限切れかどうか
+func (p RoomExpiryPolicy) WaitingExpired(room *Room, now time.Time) bool {
+	return !room.IsActive && p.WaitingTimeout > 0 && room.IdleFor(now) >= p.WaitingTimeout
+}
+
+// IdleExpired は対戦中のルームが放置されて期限切れかどうか
+func (p RoomExpiryPolicy) IdleExpired(room *Room, now time.Time) bool {
+	return room.IsActive && p.IdleTimeout > 0 && room.IdleFor(now) >= p.IdleTimeout
+}
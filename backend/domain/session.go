@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// Session はクライアントのセッション（ブラウザに保存されたID）とプレイヤー・ルームの対応
+// サーバーの再起動をまたいで保持し、再接続したクライアントを元のプレイヤーに戻すために使う
+type Session struct {
+	ID        string
+	PlayerID  string
+	RoomID    string // 参加中のルーム（未参加なら空）
+	UpdatedAt time.Time
+}
+
+// NewSession は新しいセッションを生成
+func NewSession(sessionID string, playerID string, now time.Time) *Session {
+	return &Session{ID: sessionID, PlayerID: playerID, UpdatedAt: now}
+}
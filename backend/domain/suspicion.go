@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// SuspicionPolicy はボット判定のしきい値
+// 人間には不可能な速さの回答や画像選択を証拠として加点し、一定以上でフラグ・隔離する
+type SuspicionPolicy struct {
+	MinSolveTime        time.Duration // これより速い正解は不自然とみなす
+	FastSolvePoints     float64
+	MinSelectInterval   time.Duration // これより短い間隔の画像選択は不自然とみなす
+	FastSelectPoints    float64
+	DecayPerMinute      float64 // 時間経過で減るスコア
+	FlagThreshold       float64 // 管理者の確認対象にする
+	QuarantineThreshold float64 // ランダムマッチ・ランキングから除外する
+	QuarantineAddress   bool    // 隔離を同じ接続元IPからの接続にも適用する
+	MaxReasons          int     // 保持する判定理由の件数
+}
+
+// DefaultSuspicionPolicy は既定のボット判定しきい値
+func DefaultSuspicionPolicy() SuspicionPolicy {
+	return SuspicionPolicy{
+		MinSolveTime:        700 * time.Millisecond,
+		FastSolvePoints:     3,
+		MinSelectInterval:   60 * time.Millisecond,
+		FastSelectPoints:    0.5,
+		DecayPerMinute:      1,
+		FlagThreshold:       6,
+		QuarantineThreshold: 12,
+		QuarantineAddress:   true,
+		MaxReasons:          20,
+	}
+}
+
+// SuspicionRecord はプレイヤーごとのボット疑いスコア
+// フラグと隔離は一度立つと管理者が解除するまで維持される
+type SuspicionRecord struct {
+	PlayerID     string
+	Score        float64
+	Flagged      bool
+	Quarantined  bool
+	Reasons      []string // 管理者向けの判定理由（発生順）
+	RemoteIP     string   // 最後に観測した接続元IP
+	AddressWide  bool     // 隔離が RemoteIP からの接続にも及ぶ（隔離した時のポリシーで決まる）
+	UpdatedAt    time.Time
+	LastSelectAt time.Time
+}
+
+// NewSuspicionRecord は新しい疑いスコアを生成
+func NewSuspicionRecord(playerID string) *SuspicionRecord {
+	return &SuspicionRecord{PlayerID: playerID}
+}
+
+// ObserveSolve は出題から正解までの時間を評価する
+// 状態が隔離に変わった場合は true を返す
+func (r *SuspicionRecord) ObserveSolve(solveTime time.Duration, policy SuspicionPolicy, now time.Time) bool {
+	if solveTime <= 0 || solveTime >= policy.MinSolveTime {
+		r.decay(policy, now)
+		return false
+	}
+	return r.addEvidence(policy.FastSolvePoints, fmt.Sprintf("solved in %dms (min %dms)", solveTime.Milliseconds(), policy.MinSolveTime.Milliseconds()), policy, now)
+}
+
+// ObserveSelection は画像選択の間隔を評価する
+// 状態が隔離に変わった場合は true を返す
+func (r *SuspicionRecord) ObserveSelection(policy SuspicionPolicy, now time.Time) bool {
+	last := r.LastSelectAt
+	r.LastSelectAt = now
+	if last.IsZero() {
+		return false
+	}
+	interval := now.Sub(last)
+	if interval >= policy.MinSelectInterval {
+		r.decay(policy, now)
+		return false
+	}
+	return r.addEvidence(policy.FastSelectPoints, fmt.Sprintf("selected images %dms apart (min %dms)", interval.Milliseconds(), policy.MinSelectInterval.Milliseconds()), policy, now)
+}
+
+// QuarantinesAddress は隔離が remoteIP からの接続に及ぶかを返す
+// プレイヤーIDはクライアントが自由に変えられるので、隔離は接続元IPにも適用する
+func (r *SuspicionRecord) QuarantinesAddress(remoteIP string) bool {
+	return r.Quarantined && r.AddressWide && remoteIP != "" && r.RemoteIP == remoteIP
+}
+
+// Clear は管理者の判断で疑いを解除する
+func (r *SuspicionRecord) Clear() {
+	r.Score = 0
+	r.Flagged = false
+	r.Quarantined = false
+	r.AddressWide = false
+	r.Reasons = nil
+}
+
+func (r *SuspicionRecord) addEvidence(points float64, reason string, policy SuspicionPolicy, now time.Time) bool {
+	r.decay(policy, now)
+	r.Score += points
+	r.Reasons = append(r.Reasons, reason)
+	if policy.MaxReasons > 0 && len(r.Reasons) > policy.MaxReasons {
+		r.Reasons = r.Reasons[len(r.Reasons)-policy.MaxReasons:]
+	}
+
+	if policy.FlagThreshold > 0 && r.Score >= policy.FlagThreshold {
+		r.Flagged = true
+	}
+	if policy.QuarantineThreshold > 0 && r.Score >= policy.QuarantineThreshold && !r.Quarantined {
+		r.Quarantined = true
+		r.AddressWide = policy.QuarantineAddress
+		return true
+	}
+	return false
+}
+
+func (r *SuspicionRecord) decay(policy SuspicionPolicy, now time.Time) {
+	if !r.UpdatedAt.IsZero() && policy.DecayPerMinute > 0 {
+		r.Score -= now.Sub(r.UpdatedAt).Minutes() * policy.DecayPerMinute
+		if r.Score < 0 {
+			r.Score = 0
+		}
+	}
+	r.UpdatedAt = now
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// TargetingMode は妨害を送る相手の選び方（攻撃側のプレイヤーが設定する）
+type TargetingMode string
+
+const (
+	TargetRandom        TargetingMode = "random"         // 相手の中から無作為に選ぶ（既定）
+	TargetLeader        TargetingMode = "leader"         // スコアが最も高い相手
+	TargetClosestBehind TargetingMode = "closest_behind" // 自分以下のスコアで最も近い相手（すぐ後ろを追う相手）
+	TargetPlayer        TargetingMode = "player"         // 指定したプレイヤー
+	TargetChoose        TargetingMode = "choose"         // 発動のたびに CHOOSE_TARGET で選ぶ
+)
+
+// GetAllTargetingModes はすべての選び方を返す
+func GetAllTargetingModes() []TargetingMode {
+	return []TargetingMode{TargetRandom, TargetLeader, TargetClosestBehind, TargetPlayer, TargetChoose}
+}
+
+// IsTargetingMode は name が選び方の種類かを返す
+func IsTargetingMode(name string) bool {
+	for _, mode := range GetAllTargetingModes() {
+		if string(mode) == name {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// ErrUnknownTargetingMode は未知の選び方
+	ErrUnknownTargetingMode = errors.New("unknown targeting mode")
+	// ErrInvalidTarget は妨害を送れない相手（自分自身やルームにいないプレイヤー）
+	ErrInvalidTarget = errors.New("player cannot be targeted")
+	// ErrNoPendingTarget は相手の選択を待っている妨害がない（時間切れで送った後など）
+	ErrNoPendingTarget = errors.New("no obstruction is waiting for a target")
+)
+
+// TargetCandidate は妨害を送れる相手
+type TargetCandidate struct {
+	PlayerID string
+	Score    int
+}
+
+// SetTargeting は妨害を送る相手の選び方を設定する
+// TargetPlayer の場合だけ targetID を覚える（相手がいなくなった場合は無作為に選ぶ）
+func (p *Player) SetTargeting(mode TargetingMode, targetID string) error {
+	if !IsTargetingMode(string(mode)) {
+		return ErrUnknownTargetingMode
+	}
+	if mode == TargetPlayer && (targetID == "" || targetID == p.ID) {
+		return ErrInvalidTarget
+	}
+	if mode != TargetPlayer {
+		targetID = ""
+	}
+	p.Targeting = mode
+	p.TargetingPlayerID = targetID
+	return nil
+}
+
+// HasPendingTarget は now の時点で相手の選択を待っている妨害があるかを返す
+func (p *Player) HasPendingTarget(now time.Time) bool {
+	return p.PendingTargetUntil.After(now)
+}
+
+// ObstructionCandidates は attackerID が妨害を送れる相手をスロット順に返す
+func (r *Room) ObstructionCandidates(attackerID string) []TargetCandidate {
+	var candidates []TargetCandidate
+	for _, playerID := range r.PlayerIDs() {
+		if playerID == attackerID {
+			continue
+		}
+		if p := r.GetPlayerByID(playerID); p != nil {
+			candidates = append(candidates, TargetCandidate{PlayerID: p.ID, Score: p.Score})
+		}
+	}
+	return candidates
+}
+
+// IsObstructionCandidate は targetID が attackerID の妨害を受けられる相手かを返す
+func (r *Room) IsObstructionCandidate(attackerID string, targetID string) bool {
+	for _, c := range r.ObstructionCandidates(attackerID) {
+		if c.PlayerID == targetID {
+			return true
+		}
+	}
+	return false
+}
+
+// PickObstructionTarget は攻撃側の選び方に従って妨害を送る相手を選ぶ（相手がいなければ空文字）
+// 選び方で決まらない場合（指定した相手が抜けた・後ろに誰もいない・TargetChoose など）や同点は intn で無作為に選ぶ
+func (r *Room) PickObstructionTarget(attackerID string, intn func(n int) int) string {
+	candidates := r.ObstructionCandidates(attackerID)
+	if len(candidates) == 0 {
+		return ""
+	}
+	attacker := r.GetPlayerByID(attackerID)
+	var mode TargetingMode
+	var attackerScore int
+	if attacker != nil {
+		mode, attackerScore = attacker.Targeting, attacker.Score
+	}
+
+	var best []string
+	bestScore := 0
+	consider := func(c TargetCandidate) {
+		switch {
+		case len(best) == 0 || c.Score > bestScore:
+			best, bestScore = []string{c.PlayerID}, c.Score
+		case c.Score == bestScore:
+			best = append(best, c.PlayerID)
+		}
+	}
+	switch mode {
+	case TargetPlayer:
+		if r.IsObstructionCandidate(attackerID, attacker.TargetingPlayerID) {
+			return attacker.TargetingPlayerID
+		}
+	case TargetLeader:
+		for _, c := range candidates {
+			consider(c)
+		}
+	case TargetClosestBehind:
+		for _, c := range candidates {
+			if c.Score <= attackerScore {
+				consider(c)
+			}
+		}
+	}
+	if len(best) > 0 {
+		return best[intn(len(best))]
+	}
+	return candidates[intn(len(candidates))].PlayerID
+}
@@ -0,0 +1,63 @@
+package domain
+
+import "testing"
+
+// TestObstructionTargeting は攻撃側の選び方に従った妨害の相手の選択のテスト
+func TestObstructionTargeting(t *testing.T) {
+	room := NewRoom("room1", "a", "b", 10, 4)
+	room.ExtraPlayers[0] = NewPlayer("c")
+	room.ExtraPlayers[1] = NewPlayer("d")
+	// a: 3点、b: 5点（首位）、c: 2点（すぐ後ろ）、d: 0点
+	room.Player1.Score, room.Player2.Score, room.ExtraPlayers[0].Score = 3, 5, 2
+	attacker := room.Player1
+	last := func(n int) int { return n - 1 }
+
+	// テスト1: 相手は自分以外の全員で、既定では無作為に選ぶ
+	if candidates := room.ObstructionCandidates("a"); len(candidates) != 3 || candidates[0].PlayerID != "b" || candidates[0].Score != 5 {
+		t.Errorf("unexpected candidates: %+v", candidates)
+	}
+	if got := room.PickObstructionTarget("a", last); got != "d" {
+		t.Errorf("expected random pick among candidates, got %s", got)
+	}
+
+	// テスト2: 首位・すぐ後ろ・指定したプレイヤーを狙う
+	cases := []struct {
+		mode   TargetingMode
+		target string
+		want   string
+	}{
+		{TargetLeader, "", "b"},
+		{TargetClosestBehind, "", "c"},
+		{TargetPlayer, "d", "d"},
+	}
+	for _, tc := range cases {
+		if err := attacker.SetTargeting(tc.mode, tc.target); err != nil {
+			t.Fatalf("%s: failed to set targeting: %v", tc.mode, err)
+		}
+		if got := room.PickObstructionTarget("a", last); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.mode, tc.want, got)
+		}
+	}
+
+	// テスト3: 狙った相手がいなければ無作為に選ぶ（指定した相手が抜けた・後ろに誰もいない）
+	room.ExtraPlayers[1] = nil
+	if got := room.PickObstructionTarget("a", last); got != "c" {
+		t.Errorf("expected fallback to random after target left, got %s", got)
+	}
+	_ = attacker.SetTargeting(TargetClosestBehind, "")
+	attacker.Score = 0
+	if got := room.PickObstructionTarget("a", func(int) int { return 0 }); got != "b" {
+		t.Errorf("expected fallback to random when nobody is behind, got %s", got)
+	}
+
+	// テスト4: 未知の選び方や自分自身は設定できない
+	if err := attacker.SetTargeting("weakest", ""); err != ErrUnknownTargetingMode {
+		t.Errorf("expected ErrUnknownTargetingMode, got %v", err)
+	}
+	if err := attacker.SetTargeting(TargetPlayer, "a"); err != ErrInvalidTarget {
+		t.Errorf("expected ErrInvalidTarget, got %v", err)
+	}
+	if attacker.Targeting != TargetClosestBehind {
+		t.Errorf("expected rejected settings to keep the previous mode, got %s", attacker.Targeting)
+	}
+}
@@ -0,0 +1,567 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"recaptchgame-backend/config"
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/logging"
+	"recaptchgame-backend/protocol"
+	"recaptchgame-backend/usecase"
+)
+
+// 管理操作のエラー
+var (
+	ErrRoomNotFound      = errors.New("room not found")
+	ErrPlayerNotInRoom   = errors.New("player is not in the room")
+	ErrEmptyNoticeText   = errors.New("notice message is empty")
+	errAdminBadRequest   = errors.New("bad request")
+	errAdminNotFound     = errors.New("not found")
+	errAdminNotAllowed   = errors.New("method not allowed")
+	errAdminUnauthorized = errors.New("unauthorized")
+)
+
+const (
+	adminForceEndMessage = "Ended by server"
+	adminKickReason      = "Removed by server"
+	adminMaxBodyBytes    = 16 << 10
+	// adminDefaultMatchLimit は GET /admin/matches で limit を指定しなかったときの件数
+	adminDefaultMatchLimit = 50
+)
+
+// ForceEndRoom はルームのゲームを強制終了し、指定した勝者（空なら勝者なし）を全員に通知する
+// message が空なら既定の文言で通知する
+func (h *WebSocketHandler) ForceEndRoom(roomID string, winnerID string, message string) error {
+	err := h.endGameUC.Execute(usecase.EndGameInput{RoomID: roomID, WinnerID: winnerID, Note: message})
+	switch {
+	case errors.Is(err, usecase.ErrRoomNotFound):
+		return ErrRoomNotFound
+	case errors.Is(err, usecase.ErrPlayerNotInRoom):
+		return ErrPlayerNotInRoom
+	case err != nil:
+		return err
+	}
+	h.logger.Warn("room force-ended by admin", "room_id", roomID, "winner_id", winnerID)
+	return nil
+}
+
+// KickPlayer はプレイヤーをルームから退出させる
+// 本人に KICKED を送ったうえで、切断時と同じ退出処理（残りのプレイヤーへの通知・勝敗判定）を行う
+func (h *WebSocketHandler) KickPlayer(playerID string, reason string) error {
+	room, err := h.roomRepo.FindByPlayerID(playerID)
+	if err != nil || room == nil {
+		return ErrPlayerNotInRoom
+	}
+	if reason == "" {
+		reason = adminKickReason
+	}
+
+	h.logger.Warn("player kicked by admin", "room_id", room.ID, "player_id", playerID, "reason", reason)
+	kicked := protocol.KickedPayload{RoomID: room.ID, Reason: reason}
+	b, _ := json.Marshal(kicked)
+	for _, clientID := range h.wsManager.GetClientIDsByPlayerID(playerID) {
+		_ = h.wsManager.SendToClient(clientID, protocol.Message{Type: protocol.TypeKicked, Payload: b})
+	}
+	// 残りのプレイヤーには切断と同じ文言で通知し、クライアントの既存の離脱処理に乗せる
+	h.leaveAndNotify(usecase.LeaveRoomInput{ClientID: "", PlayerID: playerID})
+	return nil
+}
+
+// BroadcastNotice は接続中の全クライアントにお知らせを送り、送信先の数を返す
+func (h *WebSocketHandler) BroadcastNotice(message string, level string) (int, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return 0, ErrEmptyNoticeText
+	}
+	if level != "warning" {
+		level = "info"
+	}
+	b, _ := json.Marshal(protocol.ServerNoticePayload{Message: message, Level: level})
+	sent := h.wsManager.Broadcast(protocol.Message{Type: protocol.TypeServerNotice, Payload: b})
+	h.logger.Info("server notice broadcast", "level", level, "recipients", sent)
+	return sent, nil
+}
+
+// AdminHandler は運用者向けの /admin API
+// 全てのリクエストに Authorization: Bearer <token> を要求する（token が空なら全て拒否する）
+type AdminHandler struct {
+	token          string
+	wsHandler      *WebSocketHandler
+	wsManager      *WebSocketManager
+	roomRepo       domain.RoomRepository
+	botDetectionUC *usecase.BotDetectionUseCase
+	matchHistory   *usecase.MatchHistoryRecorder
+	replays        *usecase.ReplayRecorder
+	config         *config.Config
+	logger         *slog.Logger
+	now            func() time.Time
+}
+
+// NewAdminHandler は新しいAdminHandlerを生成
+// cfg は GET /admin/config でそのままJSONにして返す実効設定（秘密の値を伏せた Redacted を渡す。nil なら 404）
+// matchHistory / replays が nil なら対戦の記録・リプレイは 404
+func NewAdminHandler(
+	token string,
+	wsHandler *WebSocketHandler,
+	wsManager *WebSocketManager,
+	roomRepo domain.RoomRepository,
+	botDetectionUC *usecase.BotDetectionUseCase,
+	matchHistory *usecase.MatchHistoryRecorder,
+	replays *usecase.ReplayRecorder,
+	cfg *config.Config,
+	logger *slog.Logger,
+) *AdminHandler {
+	return &AdminHandler{
+		token:          token,
+		wsHandler:      wsHandler,
+		wsManager:      wsManager,
+		roomRepo:       roomRepo,
+		botDetectionUC: botDetectionUC,
+		matchHistory:   matchHistory,
+		replays:        replays,
+		config:         cfg,
+		logger:         logging.OrDiscard(logger),
+		now:            time.Now,
+	}
+}
+
+// AdminPlayerView は管理画面向けのプレイヤー状態
+type AdminPlayerView struct {
+	ID              string     `json:"id"`
+	Score           int        `json:"score"`
+	Combo           int        `json:"combo"`
+	Effect          string     `json:"effect,omitempty"`
+	EffectExpiresAt *time.Time `json:"effect_expires_at,omitempty"`
+	Connected       bool       `json:"connected"`
+	FailedAttempts  int        `json:"failed_attempts,omitempty"`
+	LockedUntil     *time.Time `json:"locked_until,omitempty"`
+	Target          string     `json:"target,omitempty"`
+	Images          []string   `json:"images,omitempty"`
+}
+
+// AdminRoomView は管理画面向けのルーム状態
+type AdminRoomView struct {
+	ID           string            `json:"id"`
+	State        string            `json:"state"`
+	Capacity     int               `json:"capacity"`
+	WinningScore int               `json:"winning_score"`
+	Unrated      bool              `json:"unrated"`
+	Waiting      bool              `json:"waiting"` // ランダムマッチの待機ルームとして公開中
+	CreatedAt    time.Time         `json:"created_at"`
+	AgeSeconds   float64           `json:"age_seconds"`
+	IdleSeconds  float64           `json:"idle_seconds"` // 最後の参加・開始・回答からの経過時間
+	Players      []AdminPlayerView `json:"players"`
+	EmptySlots   int               `json:"empty_slots"`
+}
+
+// AdminSuspicionView は管理画面向けのボット疑いスコア
+type AdminSuspicionView struct {
+	PlayerID    string    `json:"player_id"`
+	Score       float64   `json:"score"`
+	Flagged     bool      `json:"flagged"`
+	Quarantined bool      `json:"quarantined"`
+	Reasons     []string  `json:"reasons"`
+	RemoteIP    string    `json:"remote_ip,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AdminStandingView は管理画面向けの対戦結果の順位
+type AdminStandingView struct {
+	PlayerID string `json:"player_id"`
+	Score    int    `json:"score"`
+}
+
+// AdminMatchView は管理画面向けの対戦の記録
+type AdminMatchView struct {
+	RoomID          string              `json:"room_id"`
+	WinnerID        string              `json:"winner_id"`
+	Reason          string              `json:"reason"`
+	Standings       []AdminStandingView `json:"standings"`
+	Unrated         bool                `json:"unrated"`
+	StartedAt       time.Time           `json:"started_at"`
+	FinishedAt      time.Time           `json:"finished_at"`
+	DurationSeconds float64             `json:"duration_seconds"`
+}
+
+// AdminReplayEvent は管理画面向けのリプレイの1イベント（event はドメインイベントそのもの）
+type AdminReplayEvent struct {
+	Type       string       `json:"type"`
+	OccurredAt time.Time    `json:"occurred_at"`
+	Event      domain.Event `json:"event"`
+}
+
+// AdminRoomList は GET /admin/rooms の応答
+type AdminRoomList struct {
+	Rooms        []AdminRoomView `json:"rooms"`
+	WaitingRooms map[int]string  `json:"waiting_rooms"` // 定員 -> 待機ルームID
+}
+
+// ServeHTTP は /admin 以下のリクエストを処理する
+func (a *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeAdminError(w, http.StatusUnauthorized, errAdminUnauthorized)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin"), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "rooms":
+		a.route(w, r, http.MethodGet, a.listRooms)
+	case len(parts) == 2 && parts[0] == "rooms":
+		a.route(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) { a.getRoom(w, parts[1]) })
+	case len(parts) == 3 && parts[0] == "rooms" && parts[2] == "replay":
+		a.route(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) { a.getReplay(w, parts[1]) })
+	case path == "matches":
+		a.route(w, r, http.MethodGet, a.listMatches)
+	case len(parts) == 3 && parts[0] == "rooms" && parts[2] == "end":
+		a.route(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) { a.endRoom(w, r, parts[1]) })
+	case len(parts) == 3 && parts[0] == "players" && parts[2] == "kick":
+		a.route(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) { a.kickPlayer(w, r, parts[1]) })
+	case path == "notice":
+		a.route(w, r, http.MethodPost, a.broadcastNotice)
+	case path == "drain":
+		switch r.Method {
+		case http.MethodGet:
+			a.drainStatus(w)
+		case http.MethodPost:
+			a.startDrain(w, r)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeAdminError(w, http.StatusMethodNotAllowed, errAdminNotAllowed)
+		}
+	case path == "config":
+		a.route(w, r, http.MethodGet, a.getConfig)
+	case path == "suspicion":
+		a.route(w, r, http.MethodGet, a.listSuspicion)
+	case len(parts) == 3 && parts[0] == "suspicion" && parts[2] == "clear":
+		a.route(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) { a.clearSuspicion(w, parts[1]) })
+	default:
+		writeAdminError(w, http.StatusNotFound, errAdminNotFound)
+	}
+}
+
+func (a *AdminHandler) authorized(r *http.Request) bool {
+	if a.token == "" {
+		return false
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(a.token)) == 1
+}
+
+func (a *AdminHandler) route(w http.ResponseWriter, r *http.Request, method string, next http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeAdminError(w, http.StatusMethodNotAllowed, errAdminNotAllowed)
+		return
+	}
+	next(w, r)
+}
+
+func (a *AdminHandler) listRooms(w http.ResponseWriter, _ *http.Request) {
+	rooms, err := a.roomRepo.ListAll()
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	waiting, _ := a.roomRepo.ListWaitingRooms()
+	waitingIDs := make(map[int]string, len(waiting))
+	isWaiting := make(map[string]bool, len(waiting))
+	for capacity, room := range waiting {
+		waitingIDs[capacity] = room.ID
+		isWaiting[room.ID] = true
+	}
+
+	list := AdminRoomList{Rooms: make([]AdminRoomView, 0, len(rooms)), WaitingRooms: waitingIDs}
+	for _, room := range rooms {
+		list.Rooms = append(list.Rooms, a.roomView(room, isWaiting[room.ID], false))
+	}
+	sort.Slice(list.Rooms, func(i, j int) bool { return list.Rooms[i].CreatedAt.Before(list.Rooms[j].CreatedAt) })
+	writeAdminJSON(w, http.StatusOK, list)
+}
+
+func (a *AdminHandler) getRoom(w http.ResponseWriter, roomID string) {
+	room, err := a.roomRepo.FindByID(roomID)
+	if err != nil || room == nil {
+		writeAdminError(w, http.StatusNotFound, ErrRoomNotFound)
+		return
+	}
+	waiting := false
+	if rooms, err := a.roomRepo.ListWaitingRooms(); err == nil {
+		if wr := rooms[room.Capacity]; wr != nil && wr.ID == room.ID {
+			waiting = true
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, a.roomView(room, waiting, true))
+}
+
+// roomView はルームを管理画面向けに変換する（withProblems なら各プレイヤーの問題も含める）
+func (a *AdminHandler) roomView(room *domain.Room, waiting bool, withProblems bool) AdminRoomView {
+	now := a.now()
+	view := AdminRoomView{
+		ID:           room.ID,
+		State:        roomState(room),
+		Capacity:     room.Capacity,
+		WinningScore: room.WinningScore,
+		Unrated:      room.Unrated,
+		Waiting:      waiting,
+		CreatedAt:    room.CreatedAt,
+		Players:      []AdminPlayerView{},
+	}
+	if !room.CreatedAt.IsZero() {
+		view.AgeSeconds = now.Sub(room.CreatedAt).Seconds()
+	}
+	if !room.LastActivityAt.IsZero() {
+		view.IdleSeconds = room.IdleFor(now).Seconds()
+	}
+
+	addPlayer := func(player *domain.Player, gameState *domain.GameState) {
+		if player == nil || player.ID == "" {
+			view.EmptySlots++
+			return
+		}
+		pv := AdminPlayerView{
+			ID:             player.ID,
+			Score:          player.Score,
+			Combo:          player.Combo,
+			Effect:         player.ActiveEffect(),
+			Connected:      len(a.wsManager.GetClientIDsByPlayerID(player.ID)) > 0,
+			FailedAttempts: player.FailedAttempts,
+		}
+		if pv.Effect != "" && !player.EffectExpiresAt.IsZero() {
+			expires := player.EffectExpiresAt
+			pv.EffectExpiresAt = &expires
+		}
+		if player.LockedUntil.After(now) {
+			locked := player.LockedUntil
+			pv.LockedUntil = &locked
+		}
+		if withProblems && gameState != nil {
+			pv.Target = gameState.Target
+			pv.Images = append([]string(nil), gameState.Images...)
+		}
+		view.Players = append(view.Players, pv)
+	}
+
+	addPlayer(room.Player1, room.GameState1)
+	addPlayer(room.Player2, room.GameState2)
+	for i, player := range room.ExtraPlayers {
+		var gameState *domain.GameState
+		if i < len(room.ExtraGameStates) {
+			gameState = room.ExtraGameStates[i]
+		}
+		addPlayer(player, gameState)
+	}
+	return view
+}
+
+func (a *AdminHandler) endRoom(w http.ResponseWriter, r *http.Request, roomID string) {
+	var body struct {
+		WinnerID string `json:"winner_id"`
+		Message  string `json:"message"`
+	}
+	if !decodeAdminBody(w, r, &body) {
+		return
+	}
+	switch err := a.wsHandler.ForceEndRoom(roomID, body.WinnerID, body.Message); {
+	case errors.Is(err, ErrRoomNotFound):
+		writeAdminError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrPlayerNotInRoom):
+		writeAdminError(w, http.StatusBadRequest, err)
+	case err != nil:
+		writeAdminError(w, http.StatusInternalServerError, err)
+	default:
+		writeAdminJSON(w, http.StatusOK, map[string]string{"room_id": roomID, "winner_id": body.WinnerID})
+	}
+}
+
+func (a *AdminHandler) kickPlayer(w http.ResponseWriter, r *http.Request, playerID string) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if !decodeAdminBody(w, r, &body) {
+		return
+	}
+	if err := a.wsHandler.KickPlayer(playerID, body.Reason); err != nil {
+		writeAdminError(w, http.StatusNotFound, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]string{"player_id": playerID})
+}
+
+func (a *AdminHandler) broadcastNotice(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Message string `json:"message"`
+		Level   string `json:"level"`
+	}
+	if !decodeAdminBody(w, r, &body) {
+		return
+	}
+	sent, err := a.wsHandler.BroadcastNotice(body.Message, body.Level)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]int{"recipients": sent})
+}
+
+// AdminDrainStatus は GET/POST /admin/drain の応答
+type AdminDrainStatus struct {
+	Draining    bool       `json:"draining"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+	ActiveRooms int        `json:"active_rooms"`
+}
+
+func (a *AdminHandler) drainStatus(w http.ResponseWriter) {
+	status := AdminDrainStatus{Draining: a.wsHandler.IsDraining(), ActiveRooms: a.wsHandler.ActiveRoomCount()}
+	if status.Draining {
+		deadline := a.wsHandler.DrainDeadline()
+		status.Deadline = &deadline
+	}
+	writeAdminJSON(w, http.StatusOK, status)
+}
+
+// startDrain はドレインを開始する（進行中のルームが終わるか期限に達するとサーバーは停止する）
+func (a *AdminHandler) startDrain(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		TimeoutSeconds int `json:"timeout_seconds"`
+	}
+	if !decodeAdminBody(w, r, &body) {
+		return
+	}
+	timeout := DefaultDrainTimeout
+	if body.TimeoutSeconds > 0 {
+		timeout = time.Duration(body.TimeoutSeconds) * time.Second
+	}
+	if a.wsHandler.StartDrain(timeout) {
+		a.logger.Warn("drain requested by admin", "timeout", timeout)
+	}
+	a.drainStatus(w)
+}
+
+// getConfig は起動時に読み込んだ実効設定を返す（秘密の値は伏せた状態で渡される）
+func (a *AdminHandler) getConfig(w http.ResponseWriter, _ *http.Request) {
+	if a.config == nil {
+		writeAdminError(w, http.StatusNotFound, errAdminNotFound)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, a.config)
+}
+
+func (a *AdminHandler) listMatches(w http.ResponseWriter, r *http.Request) {
+	if a.matchHistory == nil {
+		writeAdminError(w, http.StatusNotFound, errAdminNotFound)
+		return
+	}
+	limit := adminDefaultMatchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeAdminError(w, http.StatusBadRequest, errAdminBadRequest)
+			return
+		}
+		limit = n
+	}
+	records, err := a.matchHistory.ListRecent(limit)
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]AdminMatchView, 0, len(records))
+	for _, record := range records {
+		view := AdminMatchView{
+			RoomID:     record.RoomID,
+			WinnerID:   record.WinnerID,
+			Reason:     record.Reason,
+			Standings:  make([]AdminStandingView, 0, len(record.Standings)),
+			Unrated:    record.Unrated,
+			StartedAt:  record.StartedAt,
+			FinishedAt: record.FinishedAt,
+		}
+		for _, standing := range record.Standings {
+			view.Standings = append(view.Standings, AdminStandingView{PlayerID: standing.PlayerID, Score: standing.Score})
+		}
+		if !record.StartedAt.IsZero() {
+			view.DurationSeconds = record.FinishedAt.Sub(record.StartedAt).Seconds()
+		}
+		views = append(views, view)
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{"matches": views})
+}
+
+func (a *AdminHandler) getReplay(w http.ResponseWriter, roomID string) {
+	if a.replays == nil {
+		writeAdminError(w, http.StatusNotFound, errAdminNotFound)
+		return
+	}
+	events := a.replays.Replay(roomID)
+	if len(events) == 0 {
+		writeAdminError(w, http.StatusNotFound, ErrRoomNotFound)
+		return
+	}
+	views := make([]AdminReplayEvent, 0, len(events))
+	for _, event := range events {
+		views = append(views, AdminReplayEvent{Type: event.EventType(), OccurredAt: event.Meta().OccurredAt, Event: event})
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{"room_id": roomID, "events": views})
+}
+
+func (a *AdminHandler) listSuspicion(w http.ResponseWriter, _ *http.Request) {
+	records, err := a.botDetectionUC.ListFlagged()
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]AdminSuspicionView, 0, len(records))
+	for _, record := range records {
+		views = append(views, AdminSuspicionView{
+			PlayerID:    record.PlayerID,
+			Score:       record.Score,
+			Flagged:     record.Flagged,
+			Quarantined: record.Quarantined,
+			Reasons:     record.Reasons,
+			RemoteIP:    record.RemoteIP,
+			UpdatedAt:   record.UpdatedAt,
+		})
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{"players": views})
+}
+
+func (a *AdminHandler) clearSuspicion(w http.ResponseWriter, playerID string) {
+	if err := a.botDetectionUC.Clear(playerID); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	a.logger.Info("suspicion cleared by admin", "player_id", playerID)
+	writeAdminJSON(w, http.StatusOK, map[string]string{"player_id": playerID})
+}
+
+// decodeAdminBody はJSONボディを読み込む（空のボディはゼロ値として扱う）
+func decodeAdminBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, adminMaxBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		writeAdminError(w, http.StatusBadRequest, errAdminBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"recaptchgame-backend/config"
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/infrastructure"
+	"recaptchgame-backend/matchmaker"
+	"recaptchgame-backend/protocol"
+	"recaptchgame-backend/usecase"
+)
+
+// TestAdminHandler は管理APIのテスト
+func TestAdminHandler(t *testing.T) {
+	env := newTestHandlerEnv()
+	roomRepo, suspicionRepo, wsHandler := env.roomRepo, env.suspicionRepo, env.wsHandler
+	admin := NewAdminHandler("secret", wsHandler, env.wsManager, roomRepo, env.botDetectionUC, env.matchHistory, env.replays, config.Default(), nil)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, r)
+		return w
+	}
+
+	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
+	room.Player1.Score = 3
+	roomRepo.Save(room)
+	roomRepo.Save(domain.NewRoom("room2", "player3", "", 5, 2))
+	roomRepo.SetWaitingRoom(2, domain.NewRoom("room2", "player3", "", 5, 2))
+
+	// テスト1: トークンがない・誤っている場合は拒否
+	if w := do("GET", "/admin/rooms", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", w.Code)
+	}
+	if w := do("GET", "/admin/rooms", "wrong", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with wrong token, got %d", w.Code)
+	}
+
+	// テスト2: ルーム一覧（待機ルームの枠を含む）
+	w := do("GET", "/admin/rooms", "secret", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var list AdminRoomList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode room list: %v", err)
+	}
+	if len(list.Rooms) != 2 || list.WaitingRooms[2] != "room2" {
+		t.Errorf("unexpected room list: %+v", list)
+	}
+
+	// テスト3: ルームの詳細（存在しないルームは404）
+	var view AdminRoomView
+	w = do("GET", "/admin/rooms/room1", "secret", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil || view.State != RoomStateReady || len(view.Players) != 2 || view.Players[0].Score != 3 {
+		t.Errorf("unexpected room view: %s", w.Body.String())
+	}
+	if w := do("GET", "/admin/rooms/missing", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing room, got %d", w.Code)
+	}
+
+	// テスト4: メソッド違い
+	if w := do("GET", "/admin/rooms/room1/end", "secret", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+
+	// テスト5: ルームにいないプレイヤーを勝者にした強制終了は拒否し、正しい勝者なら終了してルームを削除
+	if w := do("POST", "/admin/rooms/room1/end", "secret", `{"winner_id":"player3"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for winner outside room, got %d", w.Code)
+	}
+	if w := do("POST", "/admin/rooms/room1/end", "secret", `{"winner_id":"player1"}`); w.Code != http.StatusOK {
+		t.Errorf("expected force end to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := roomRepo.FindByID("room1"); err == nil {
+		t.Errorf("expected room1 to be deleted after force end")
+	}
+
+	// テスト6: キックでプレイヤーがルームから外れる
+	roomRepo.Save(domain.NewRoom("room3", "player4", "player5", 5, 2))
+	if w := do("POST", "/admin/players/player4/kick", "secret", ""); w.Code != http.StatusOK {
+		t.Errorf("expected kick to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if r, err := roomRepo.FindByID("room3"); err != nil || r.GetPlayerByID("player4") != nil {
+		t.Errorf("expected player4 to be removed from room3")
+	}
+	if w := do("POST", "/admin/players/nobody/kick", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for player not in a room, got %d", w.Code)
+	}
+
+	// テスト7: 空のお知らせは拒否
+	if w := do("POST", "/admin/notice", "secret", `{"message":"  "}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for empty notice, got %d", w.Code)
+	}
+	if w := do("POST", "/admin/notice", "secret", `{"message":"maintenance in 5 minutes","level":"warning"}`); w.Code != http.StatusOK {
+		t.Errorf("expected notice to succeed, got %d", w.Code)
+	}
+
+	// テスト8: ボット疑いの一覧と解除
+	policy := domain.DefaultSuspicionPolicy()
+	record := domain.NewSuspicionRecord("bot1")
+	record.Score = policy.FlagThreshold
+	record.Flagged = true
+	suspicionRepo.Save(record)
+	w = do("GET", "/admin/suspicion", "secret", "")
+	if !strings.Contains(w.Body.String(), `"player_id":"bot1"`) {
+		t.Errorf("expected flagged player in list, got %s", w.Body.String())
+	}
+	do("POST", "/admin/suspicion/bot1/clear", "secret", "")
+	if cleared, _ := suspicionRepo.Find("bot1"); cleared == nil || cleared.Flagged {
+		t.Errorf("expected suspicion to be cleared")
+	}
+
+	// テスト9: 実効設定を返す
+	if w := do("GET", "/admin/config", "secret", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"send_buffer_size":32`) {
+		t.Errorf("expected effective config, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// テスト10: 終了した対戦の記録とリプレイを返す（記録のないルームは 404）
+	env.eventBus.Publish(
+		domain.GameStarted{EventMeta: domain.EventMeta{RoomID: "done", OccurredAt: time.Now()}},
+		domain.GameFinished{EventMeta: domain.EventMeta{RoomID: "done", OccurredAt: time.Now()}, WinnerID: "player1", Reason: domain.FinishReasonScore, Scores: map[string]int{"player1": 5}},
+	)
+	if w := do("GET", "/admin/matches?limit=5", "secret", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"room_id":"done"`) || !strings.Contains(w.Body.String(), `"player_id":"player1"`) {
+		t.Errorf("expected match history, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/admin/matches?limit=x", "secret", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid limit, got %d", w.Code)
+	}
+	if w := do("GET", "/admin/rooms/done/replay", "secret", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"type":"GameFinished"`) {
+		t.Errorf("expected replay, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/admin/rooms/unknown/replay", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown replay, got %d", w.Code)
+	}
+}
+
+// testHandlerEnv はメモリリポジトリで組み立てたハンドラー一式
+type testHandlerEnv struct {
+	roomRepo       domain.RoomRepository
+	suspicionRepo  *infrastructure.MemorySuspicionRepository
+	sessionRepo    domain.SessionRepository
+	botDetectionUC *usecase.BotDetectionUseCase
+	wsManager      *WebSocketManager
+	wsHandler      *WebSocketHandler
+	eventBus       *infrastructure.InProcessEventBus
+	matchHistory   *usecase.MatchHistoryRecorder
+	replays        *usecase.ReplayRecorder
+}
+
+func newTestHandlerEnv() *testHandlerEnv {
+	return newTestHandlerEnvWith(infrastructure.NewMemoryRoomRepository(), infrastructure.NewMemorySessionRepository())
+}
+
+// newTestHandlerEnvWith は指定したルーム・セッションの保存先でハンドラーを組み立てる（再起動のテスト用）
+func newTestHandlerEnvWith(roomRepo domain.RoomRepository, sessionRepo domain.SessionRepository) *testHandlerEnv {
+	clientRepo := infrastructure.NewMemoryClientRepository()
+	suspicionRepo := infrastructure.NewMemorySuspicionRepository()
+	roomGuard := usecase.NewRoomExecutionGuard()
+	problemGen := usecase.NewProblemGeneratorUseCase(domain.NewProblemFactory(domain.DefaultGameRules()), domain.GetAllTargets())
+	botDetectionUC := usecase.NewBotDetectionUseCase(suspicionRepo, roomRepo, roomGuard, domain.DefaultSuspicionPolicy(), nil)
+	matchmakerClient := matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), 0)
+	eventBus := infrastructure.NewInProcessEventBus(nil)
+	wsManager := NewWebSocketManager(0, nil, nil)
+	wsHandler := NewWebSocketHandler(
+		wsManager,
+		usecase.NewJoinRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, suspicionRepo, domain.DefaultGameRules(), domain.DefaultMatchmakingPolicy(), eventBus, nil),
+		usecase.NewVerifyAnswerUseCase(roomRepo, problemGen, nil, roomGuard, domain.DefaultVerifyPenaltyPolicy(), domain.DefaultGameRules(), domain.DefaultItemPolicy(), eventBus, nil),
+		usecase.NewStartGameUseCase(roomRepo, problemGen, roomGuard, eventBus, nil),
+		usecase.NewLeaveRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, eventBus, nil),
+		usecase.NewEndGameUseCase(roomRepo, matchmakerClient, roomGuard, eventBus, nil),
+		usecase.NewUseItemUseCase(roomRepo, roomGuard, domain.DefaultItemPolicy(), eventBus, nil),
+		usecase.NewObstructionTargetingUseCase(roomRepo, nil, roomGuard, domain.DefaultGameRules(), eventBus, nil),
+		botDetectionUC,
+		roomRepo,
+		sessionRepo,
+		NewAdmissionController(DefaultAdmissionPolicy(), nil),
+		DefaultConnectionSettings(),
+		nil,
+		nil,
+	)
+	matchHistory := usecase.NewMatchHistoryRecorder(infrastructure.NewMemoryMatchHistoryRepository(0), nil)
+	replays := usecase.NewReplayRecorder(0)
+	eventBus.Subscribe("websocket", wsHandler.HandleEvent)
+	eventBus.Subscribe("match_history", matchHistory.HandleEvent)
+	eventBus.Subscribe("replay", replays.HandleEvent)
+	return &testHandlerEnv{
+		roomRepo:       roomRepo,
+		suspicionRepo:  suspicionRepo,
+		sessionRepo:    sessionRepo,
+		botDetectionUC: botDetectionUC,
+		wsManager:      wsManager,
+		wsHandler:      wsHandler,
+		eventBus:       eventBus,
+		matchHistory:   matchHistory,
+		replays:        replays,
+	}
+}
+
+// captureReplies は clientID に送られるメッセージを記録する（実際の接続の代わりに中継先として登録する）
+func (env *testHandlerEnv) captureReplies(clientID string) func() []protocol.Message {
+	var mu sync.Mutex
+	var sent []protocol.Message
+	env.wsManager.AddRemoteClient(clientID, "test", "", nil)
+	env.wsManager.relay = func(_ string, to string, msg protocol.Message) error {
+		if to == clientID {
+			mu.Lock()
+			sent = append(sent, msg)
+			mu.Unlock()
+		}
+		return nil
+	}
+	return func() []protocol.Message {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]protocol.Message(nil), sent...)
+	}
+}
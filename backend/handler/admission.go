@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"recaptchgame-backend/config"
+	"recaptchgame-backend/logging"
+)
+
+// 接続拒否・切断の理由（メトリクスのラベルにも使う）
+const (
+	RejectOriginNotAllowed     = "origin_not_allowed"
+	RejectTooManyConnections   = "too_many_connections"
+	RejectTooManyConnectionsIP = "too_many_connections_per_ip"
+	RejectMessageTooLarge      = "message_too_large"
+	RejectReadTimeout          = "read_timeout"
+	RejectRateLimitAbuse       = "rate_limit_abuse"
+)
+
+const (
+	originWildcard    = "*"
+	rejectLogInterval = time.Second
+	rejectLogBurst    = 10
+)
+
+// AdmissionPolicy は /ws への接続受け入れ条件
+// AllowedOrigins が空の場合は Origin を検査しない（"*" も全許可）
+// MaxConnections / MaxConnectionsPerIP が 0 以下の場合は上限なし
+type AdmissionPolicy struct {
+	AllowedOrigins      []string
+	MaxConnections      int
+	MaxConnectionsPerIP int
+	MaxMessageBytes     int64
+	ReadTimeout         time.Duration
+}
+
+// DefaultAdmissionPolicy は既定の受け入れ条件
+func DefaultAdmissionPolicy() AdmissionPolicy {
+	return AdmissionPolicy{
+		MaxConnections:      config.DefaultMaxConnections,
+		MaxConnectionsPerIP: config.DefaultMaxConnectionsPerIP,
+		MaxMessageBytes:     config.DefaultMaxMessageBytes,
+		ReadTimeout:         config.DefaultReadTimeout,
+	}
+}
+
+// AdmissionPolicyFromConfig は設定ファイル・環境変数の受け入れ条件に変換する
+func AdmissionPolicyFromConfig(cfg *config.Config) AdmissionPolicy {
+	return AdmissionPolicy{
+		AllowedOrigins:      cfg.Admission.AllowedOrigins,
+		MaxConnections:      cfg.Admission.MaxConnections,
+		MaxConnectionsPerIP: cfg.Admission.MaxConnectionsPerIP,
+		MaxMessageBytes:     cfg.Admission.MaxMessageBytes,
+		ReadTimeout:         cfg.Admission.ReadTimeout.Std(),
+	}
+}
+
+// AdmissionController は接続数の上限と Origin の許可リストを管理し、拒否を理由別に数える
+type AdmissionController struct {
+	mu         sync.Mutex
+	policy     AdmissionPolicy
+	origins    map[string]bool
+	total      int
+	perIP      map[string]int
+	rejections map[string]uint64
+	logWindow  time.Time
+	logCount   int
+	logger     *slog.Logger
+}
+
+// NewAdmissionController は新しいAdmissionControllerを生成
+func NewAdmissionController(policy AdmissionPolicy, logger *slog.Logger) *AdmissionController {
+	origins := make(map[string]bool)
+	for _, o := range policy.AllowedOrigins {
+		o = strings.TrimRight(strings.TrimSpace(o), "/")
+		if o != "" {
+			origins[strings.ToLower(o)] = true
+		}
+	}
+	if policy.MaxMessageBytes <= 0 {
+		policy.MaxMessageBytes = config.DefaultMaxMessageBytes
+	}
+	if policy.ReadTimeout <= 0 {
+		policy.ReadTimeout = config.DefaultReadTimeout
+	}
+	return &AdmissionController{
+		policy:     policy,
+		origins:    origins,
+		perIP:      make(map[string]int),
+		rejections: make(map[string]uint64),
+		logger:     logging.OrDiscard(logger),
+	}
+}
+
+// Policy は受け入れ条件を返す
+func (a *AdmissionController) Policy() AdmissionPolicy {
+	return a.policy
+}
+
+// OriginAllowed はリクエストの Origin が許可リストに含まれるか判定する
+// Origin ヘッダーのないブラウザ以外のクライアントは許可する
+func (a *AdmissionController) OriginAllowed(r *http.Request) bool {
+	if len(a.origins) == 0 || a.origins[originWildcard] {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return a.origins[strings.ToLower(strings.TrimRight(origin, "/"))]
+}
+
+// Admit は接続を受け入れてよいか判定し、受け入れる場合は接続数を確保する
+// 拒否した場合は理由を返す。受け入れた接続は終了時に Release を呼ぶこと
+func (a *AdmissionController) Admit(r *http.Request, ip string) (string, bool) {
+	if !a.OriginAllowed(r) {
+		a.Reject(RejectOriginNotAllowed, ip, r.Header.Get("Origin"))
+		return RejectOriginNotAllowed, false
+	}
+
+	a.mu.Lock()
+	reason := ""
+	if a.policy.MaxConnections > 0 && a.total >= a.policy.MaxConnections {
+		reason = RejectTooManyConnections
+	} else if a.policy.MaxConnectionsPerIP > 0 && a.perIP[ip] >= a.policy.MaxConnectionsPerIP {
+		reason = RejectTooManyConnectionsIP
+	} else {
+		a.total++
+		a.perIP[ip]++
+	}
+	a.mu.Unlock()
+
+	if reason != "" {
+		a.Reject(reason, ip, "")
+		return reason, false
+	}
+	return "", true
+}
+
+// Release は Admit で確保した接続数を解放する
+func (a *AdmissionController) Release(ip string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.total > 0 {
+		a.total--
+	}
+	if a.perIP[ip] <= 1 {
+		delete(a.perIP, ip)
+		return
+	}
+	a.perIP[ip]--
+}
+
+// Reject は拒否・切断を理由別に数えてログに残す
+// 大量の拒否でログが溢れないよう1秒あたりの出力件数を制限する
+func (a *AdmissionController) Reject(reason string, ip string, detail string) {
+	a.mu.Lock()
+	a.rejections[reason]++
+	now := time.Now()
+	if now.Sub(a.logWindow) >= rejectLogInterval {
+		a.logWindow = now
+		a.logCount = 0
+	}
+	a.logCount++
+	shouldLog := a.logCount <= rejectLogBurst
+	a.mu.Unlock()
+
+	if !shouldLog {
+		return
+	}
+	a.logger.Warn("connection rejected", "remote_ip", ip, "reason", reason, "detail", detail)
+}
+
+// Rejections は理由別の拒否数を返す
+func (a *AdmissionController) Rejections() map[string]uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	counts := make(map[string]uint64, len(a.rejections))
+	for reason, n := range a.rejections {
+		counts[reason] = n
+	}
+	return counts
+}
+
+// ActiveConnections は受け入れ中の接続数を返す
+func (a *AdmissionController) ActiveConnections() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.total
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestAdmissionController は接続受け入れ制御のテスト
+func TestAdmissionController(t *testing.T) {
+	policy := DefaultAdmissionPolicy()
+	policy.AllowedOrigins = []string{"https://recaptchgame.example/", " http://localhost:5173"}
+	policy.MaxConnections = 3
+	policy.MaxConnectionsPerIP = 2
+	admission := NewAdmissionController(policy, nil)
+
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	// テスト1: 許可リストの Origin のみ受け入れる（末尾スラッシュ・大文字小文字は無視）
+	if !admission.OriginAllowed(request("https://RECAPTCHGAME.example")) {
+		t.Errorf("expected allowed origin to pass")
+	}
+	if reason, ok := admission.Admit(request("https://evil.example"), "10.0.0.1"); ok || reason != RejectOriginNotAllowed {
+		t.Errorf("expected origin rejection, got %q (ok=%v)", reason, ok)
+	}
+
+	// テスト2: IPごとの上限
+	for i := 0; i < 2; i++ {
+		if _, ok := admission.Admit(request("http://localhost:5173"), "10.0.0.1"); !ok {
+			t.Fatalf("expected connection %d to be admitted", i)
+		}
+	}
+	if reason, ok := admission.Admit(request(""), "10.0.0.1"); ok || reason != RejectTooManyConnectionsIP {
+		t.Errorf("expected per-IP rejection, got %q (ok=%v)", reason, ok)
+	}
+
+	// テスト3: 全体の上限
+	if _, ok := admission.Admit(request(""), "10.0.0.2"); !ok {
+		t.Fatalf("expected connection from another IP to be admitted")
+	}
+	if reason, ok := admission.Admit(request(""), "10.0.0.3"); ok || reason != RejectTooManyConnections {
+		t.Errorf("expected global rejection, got %q (ok=%v)", reason, ok)
+	}
+
+	// テスト4: 解放すると再び受け入れる
+	admission.Release("10.0.0.1")
+	if _, ok := admission.Admit(request(""), "10.0.0.1"); !ok {
+		t.Errorf("expected connection to be admitted after release")
+	}
+
+	// テスト5: 拒否は理由別に数えられる
+	rejections := admission.Rejections()
+	if rejections[RejectOriginNotAllowed] != 1 || rejections[RejectTooManyConnectionsIP] != 1 || rejections[RejectTooManyConnections] != 1 {
+		t.Errorf("unexpected rejection counts: %v", rejections)
+	}
+	if admission.ActiveConnections() != 3 {
+		t.Errorf("expected 3 active connections, got %d", admission.ActiveConnections())
+	}
+}
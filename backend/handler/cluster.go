@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"recaptchgame-backend/cluster"
+	"recaptchgame-backend/config"
+	"recaptchgame-backend/protocol"
+)
+
+// DefaultClusterHeartbeatInterval は所有しているルームのリースを更新する既定の間隔
+const DefaultClusterHeartbeatInterval = config.DefaultClusterHeartbeatInterval
+
+const (
+	// matchmakingLeaseKey はランダムマッチの待機ルームを決めるノードのリース
+	matchmakingLeaseKey = "matchmaking:random"
+	roomLeasePrefix     = "room:"
+)
+
+// ノード間でやり取りするエンベロープの種別
+const (
+	envelopeInbound    = "inbound"    // クライアントから受信したメッセージを所有ノードへ転送
+	envelopeDeliver    = "deliver"    // 所有ノードからクライアントへの送信を接続先ノードへ中継
+	envelopeDisconnect = "disconnect" // クライアントの切断を所有ノードへ通知
+)
+
+// clusterEnvelope はノード間のメッセージバスに流すメッセージ
+type clusterEnvelope struct {
+	Kind         string           `json:"kind"`
+	Origin       string           `json:"origin"`
+	ClientID     string           `json:"client_id"`
+	RemoteIP     string           `json:"remote_ip,omitempty"`
+	Capabilities []string         `json:"capabilities,omitempty"`
+	Message      protocol.Message `json:"message"`
+}
+
+// clusterNode はクラスタに参加しているときのこのノードの状態
+type clusterNode struct {
+	ownership   *cluster.Ownership
+	bus         cluster.Bus
+	unsubscribe func()
+
+	mu        sync.Mutex
+	routes    map[string]string          // clientID -> ルームを持たないメッセージの転送先ノード
+	forwarded map[string]map[string]bool // clientID -> 切断を通知するノード
+}
+
+func roomLeaseKey(roomID string) string {
+	return roomLeasePrefix + roomID
+}
+
+// JoinCluster はノード間のメッセージバスに参加する
+// 以降、他のノードが所有するルーム宛てのメッセージは所有ノードへ転送し、他のノードに接続しているクライアントへの送信は中継する
+// 呼ばなければ全てのルームをこのノードで処理する（単一ノード構成）。接続を受け付ける前に呼ぶこと
+func (h *WebSocketHandler) JoinCluster(ownership *cluster.Ownership, bus cluster.Bus) error {
+	node := &clusterNode{
+		ownership: ownership,
+		bus:       bus,
+		routes:    make(map[string]string),
+		forwarded: make(map[string]map[string]bool),
+	}
+	unsubscribe, err := bus.Subscribe(cluster.NodeTopic(ownership.NodeID()), h.handleClusterEnvelope)
+	if err != nil {
+		return err
+	}
+	node.unsubscribe = unsubscribe
+	h.cluster = node
+
+	h.wsManager.mu.Lock()
+	h.wsManager.relay = func(nodeID string, clientID string, msg protocol.Message) error {
+		return h.publishEnvelope(nodeID, clientEnvelope(envelopeDeliver, ownership.NodeID(), clientID, msg))
+	}
+	h.wsManager.mu.Unlock()
+	h.logger.Info("joined cluster", "node_id", ownership.NodeID())
+	return nil
+}
+
+// LeaveCluster はメッセージバスの購読をやめ、所有している全てのリースを手放す
+func (h *WebSocketHandler) LeaveCluster() {
+	c := h.cluster
+	if c == nil {
+		return
+	}
+	c.unsubscribe()
+	for _, key := range c.ownership.Owned() {
+		if err := c.ownership.Release(key); err != nil {
+			h.logger.Warn("failed to release lease", "key", key, "error", err)
+		}
+	}
+}
+
+// RunClusterHeartbeat は ctx が終わるまで interval ごとに所有しているルームのリースを更新する
+// 削除済みのルームのリースは手放し、期限切れで失ったリースは警告する
+func (h *WebSocketHandler) RunClusterHeartbeat(ctx context.Context, interval time.Duration) {
+	if h.cluster == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultClusterHeartbeatInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.renewLeases()
+		}
+	}
+}
+
+func (h *WebSocketHandler) renewLeases() {
+	ownership := h.cluster.ownership
+	for _, key := range ownership.Owned() {
+		roomID, ok := strings.CutPrefix(key, roomLeasePrefix)
+		if !ok {
+			continue
+		}
+		if room, err := h.roomRepo.FindByID(roomID); err != nil || room == nil {
+			_ = ownership.Release(key)
+		}
+	}
+	lost, err := ownership.Renew()
+	if err != nil {
+		h.logger.Error("failed to renew leases", "error", err)
+	}
+	for _, key := range lost {
+		// 他のノードに引き継がれた可能性があるため、このノードの状態はもう使われない
+		h.logger.Warn("lease lost", "key", key, "node_id", ownership.NodeID())
+	}
+}
+
+// claimRoom はこのノードで作ったルームの所有権を取得する
+func (h *WebSocketHandler) claimRoom(roomID string) {
+	c := h.cluster
+	if c == nil {
+		return
+	}
+	owner, err := c.ownership.Owner(roomLeaseKey(roomID))
+	if err != nil {
+		h.logger.Error("failed to claim room", "room_id", roomID, "error", err)
+		return
+	}
+	if owner != c.ownership.NodeID() {
+		h.logger.Warn("room is owned by another node", "room_id", roomID, "owner", owner)
+	}
+}
+
+// routingKey はメッセージの宛先ルームのリースキーを返す（ルームが決まらなければ空）
+// ペイロードの room_id を優先し、無ければこのノードで割り当て済みのルームを使う
+func (h *WebSocketHandler) routingKey(clientID string, msg protocol.Message) string {
+	var p struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &p); err == nil && p.RoomID != "" {
+		if p.RoomID == "RANDOM" {
+			return matchmakingLeaseKey
+		}
+		return roomLeaseKey(p.RoomID)
+	}
+	if roomID, ok := h.wsManager.GetRoomID(clientID); ok {
+		return roomLeaseKey(roomID)
+	}
+	return ""
+}
+
+// forwardToOwner は他のノードが所有するルーム宛てのメッセージを所有ノードへ転送し、転送したら true を返す
+// ルームを持たないメッセージ（SELECT_IMAGE など）は直前に転送したノードへ送る
+func (h *WebSocketHandler) forwardToOwner(clientID string, msg protocol.Message) bool {
+	c := h.cluster
+	if c == nil || msg.Type == protocol.TypePong {
+		return false
+	}
+	self := c.ownership.NodeID()
+
+	var owner string
+	if key := h.routingKey(clientID, msg); key != "" {
+		var err error
+		owner, err = c.ownership.Owner(key)
+		if err != nil {
+			// 所有者が分からなければこのノードで処理する
+			h.clientLogger(clientID, msg.Type).Error("failed to resolve room owner", "key", key, "error", err)
+			return false
+		}
+	} else {
+		c.mu.Lock()
+		owner = c.routes[clientID]
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	if owner == "" || owner == self {
+		delete(c.routes, clientID)
+		c.mu.Unlock()
+		return false
+	}
+	c.routes[clientID] = owner
+	if c.forwarded[clientID] == nil {
+		c.forwarded[clientID] = make(map[string]bool)
+	}
+	c.forwarded[clientID][owner] = true
+	c.mu.Unlock()
+
+	env := clientEnvelope(envelopeInbound, self, clientID, msg)
+	env.Capabilities = h.wsManager.Capabilities(clientID)
+	env.RemoteIP = h.clientRemoteIP(clientID)
+	if err := h.publishEnvelope(owner, env); err != nil {
+		h.clientLogger(clientID, msg.Type).Warn("failed to forward message to owner", "owner", owner, "error", err)
+	}
+	return true
+}
+
+// notifyOwnersOfDisconnect は転送先のノードにクライアントの切断を通知する
+func (h *WebSocketHandler) notifyOwnersOfDisconnect(clientID string) {
+	c := h.cluster
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	nodes := c.forwarded[clientID]
+	delete(c.forwarded, clientID)
+	delete(c.routes, clientID)
+	c.mu.Unlock()
+
+	for nodeID := range nodes {
+		if err := h.publishEnvelope(nodeID, clientEnvelope(envelopeDisconnect, c.ownership.NodeID(), clientID, protocol.Message{})); err != nil {
+			h.clientLogger(clientID, "").Warn("failed to notify owner of disconnect", "owner", nodeID, "error", err)
+		}
+	}
+}
+
+func clientEnvelope(kind string, origin string, clientID string, msg protocol.Message) clusterEnvelope {
+	return clusterEnvelope{Kind: kind, Origin: origin, ClientID: clientID, Message: msg}
+}
+
+func (h *WebSocketHandler) publishEnvelope(nodeID string, env clusterEnvelope) error {
+	b, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return h.cluster.bus.Publish(cluster.NodeTopic(nodeID), b)
+}
+
+// handleClusterEnvelope は他のノードから届いたエンベロープを処理する
+func (h *WebSocketHandler) handleClusterEnvelope(data []byte) {
+	var env clusterEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		h.logger.Warn("malformed cluster envelope", "error", err)
+		return
+	}
+
+	switch env.Kind {
+	case envelopeInbound:
+		// 所有ノードとして処理し、応答は接続先ノードへ中継する
+		h.wsManager.AddRemoteClient(env.ClientID, env.Origin, env.RemoteIP, env.Capabilities)
+		h.handleMessage(env.ClientID, nil, env.Message)
+	case envelopeDeliver:
+		_ = h.wsManager.sendLocal(env.ClientID, env.Message)
+	case envelopeDisconnect:
+		// ローカルの切断と同じく、明示的に退出していなければ猶予後に退出扱いにする
+		if playerID, err := h.getPlayerIDByClientID(env.ClientID); err == nil {
+			h.scheduleGracefulLeave(playerID)
+		}
+		h.releaseClient(env.ClientID)
+	default:
+		h.logger.Warn("unknown cluster envelope", "kind", env.Kind, "origin", env.Origin)
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"recaptchgame-backend/cluster"
+	"recaptchgame-backend/protocol"
+)
+
+// TestClusterRouting は他のノードが所有するルームへの転送と、クライアントへの中継のテスト
+func TestClusterRouting(t *testing.T) {
+	bus := cluster.NewMemoryBus()
+	leases := cluster.NewMemoryLeaseStore()
+	nodeA, nodeB := newTestHandlerEnv(), newTestHandlerEnv()
+	if err := nodeA.wsHandler.JoinCluster(cluster.NewOwnership("a", leases, 0), bus); err != nil {
+		t.Fatalf("failed to join cluster: %v", err)
+	}
+	if err := nodeB.wsHandler.JoinCluster(cluster.NewOwnership("b", leases, 0), bus); err != nil {
+		t.Fatalf("failed to join cluster: %v", err)
+	}
+
+	// ノードBの接続に中継されたメッセージを記録する
+	var mu sync.Mutex
+	var delivered []string
+	unsubscribe, _ := bus.Subscribe(cluster.NodeTopic("b"), func(data []byte) {
+		var env clusterEnvelope
+		_ = json.Unmarshal(data, &env)
+		if env.Kind == envelopeDeliver && env.ClientID == "clientB" {
+			mu.Lock()
+			delivered = append(delivered, env.Message.Type)
+			mu.Unlock()
+		}
+	})
+	defer unsubscribe()
+
+	// テスト1: 未所有のルームは最初に受信したノードが所有し、そのまま処理する
+	if nodeA.wsHandler.forwardToOwner("clientA", joinMessage("code1", "playerA")) {
+		t.Fatalf("expected unowned room to be handled locally")
+	}
+	nodeA.wsHandler.handleMessage("clientA", nil, joinMessage("code1", "playerA"))
+	if room, _ := nodeA.roomRepo.FindByID("code1"); room == nil {
+		t.Fatalf("expected room to be created on node a")
+	}
+
+	// テスト2: 他のノードが所有するルームへの参加は所有ノードで処理され、応答は接続先ノードへ中継される
+	if !nodeB.wsHandler.forwardToOwner("clientB", joinMessage("code1", "playerB")) {
+		t.Fatalf("expected join to be forwarded to the owner")
+	}
+	if room, _ := nodeA.roomRepo.FindByPlayerID("playerB"); room == nil || room.ID != "code1" {
+		t.Errorf("expected playerB to join code1 on node a")
+	}
+	if room, _ := nodeB.roomRepo.FindByID("code1"); room != nil {
+		t.Errorf("expected node b to keep no copy of the room")
+	}
+	mu.Lock()
+	got := append([]string(nil), delivered...)
+	mu.Unlock()
+	if !containsType(got, protocol.TypeRoomAssigned) || !containsType(got, protocol.TypeGameStart) {
+		t.Errorf("expected ROOM_ASSIGNED and GAME_START to be relayed to node b, got %v", got)
+	}
+
+	// テスト3: ルームを持たないメッセージも直前の転送先へ送る
+	if !nodeB.wsHandler.forwardToOwner("clientB", protocol.Message{Type: protocol.TypeRequestKeyframe}) {
+		t.Errorf("expected room-less message to follow the previous route")
+	}
+
+	// テスト4: 切断は所有ノードに通知され、中継先の登録と接続の記録を消して猶予後の退出を予約する
+	nodeA.wsHandler.requests.markSeen("clientB", "req1")
+	nodeB.wsHandler.notifyOwnersOfDisconnect("clientB")
+	if _, ok := nodeA.wsManager.GetPlayerID("clientB"); ok {
+		t.Errorf("expected remote client to be unregistered on disconnect")
+	}
+	if _, duplicate := nodeA.wsHandler.requests.markSeen("clientB", "req1"); duplicate {
+		t.Errorf("expected remote client's request ids to be forgotten on disconnect")
+	}
+	nodeA.wsHandler.sessionMu.Lock()
+	_, scheduled := nodeA.wsHandler.graceTimers["playerB"]
+	nodeA.wsHandler.sessionMu.Unlock()
+	if !scheduled {
+		t.Errorf("expected graceful leave to be scheduled on the owner")
+	}
+	nodeA.wsHandler.cancelGracefulLeave("playerB")
+
+	// テスト5: クラスタに参加していなければ全てこのノードで処理する
+	single := newTestHandlerEnv()
+	if single.wsHandler.forwardToOwner("client1", joinMessage("code1", "player1")) {
+		t.Errorf("expected single node to handle every message locally")
+	}
+}
+
+func joinMessage(roomID string, playerID string) protocol.Message {
+	b, _ := json.Marshal(protocol.JoinRoomPayload{RoomID: roomID, PlayerID: playerID, WinningScore: 5})
+	return protocol.Message{Type: protocol.TypeJoinRoom, Payload: b}
+}
+
+func containsType(types []string, want string) bool {
+	for _, t := range types {
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"recaptchgame-backend/protocol"
+)
+
+const (
+	// SubprotocolJSON はJSONテキストフレームを使うサブプロトコル（未指定時の既定）
+	SubprotocolJSON = "recaptchgame.json"
+	// SubprotocolMessagePack はMessagePackバイナリフレームを使うサブプロトコル
+	SubprotocolMessagePack = "recaptchgame.msgpack"
+)
+
+// Codec はWebSocketフレームとMessageの相互変換を担う
+// 接続ごとに WebSocket サブプロトコルで選択される
+type Codec interface {
+	// Subprotocol はネゴシエーションに使う Sec-WebSocket-Protocol の値
+	Subprotocol() string
+	// FrameType は書き込むフレームの種類（websocket.TextMessage / websocket.BinaryMessage）
+	FrameType() int
+	// Encode はメッセージをフレームのバイト列に変換する
+	Encode(msg protocol.Message) ([]byte, error)
+	// Decode はフレームのバイト列をメッセージに変換する
+	Decode(data []byte) (protocol.Message, error)
+}
+
+var codecs = []Codec{MessagePackCodec{}, JSONCodec{}}
+
+// Subprotocols はサーバーが対応するサブプロトコルを優先順に返す（Upgrader に設定する）
+func Subprotocols() []string {
+	names := make([]string, 0, len(codecs))
+	for _, c := range codecs {
+		names = append(names, c.Subprotocol())
+	}
+	return names
+}
+
+// CodecForSubprotocol はネゴシエートされたサブプロトコルに対応するCodecを返す
+// 未指定や未知の値の場合はJSONを使う
+func CodecForSubprotocol(subprotocol string) Codec {
+	for _, c := range codecs {
+		if c.Subprotocol() == subprotocol {
+			return c
+		}
+	}
+	return JSONCodec{}
+}
+
+// JSONCodec は従来どおりJSONテキストフレームでやり取りする
+type JSONCodec struct{}
+
+// Subprotocol はサブプロトコル名を返す
+func (JSONCodec) Subprotocol() string { return SubprotocolJSON }
+
+// FrameType はテキストフレームを返す
+func (JSONCodec) FrameType() int { return websocket.TextMessage }
+
+// Encode はメッセージをJSONに変換する
+func (JSONCodec) Encode(msg protocol.Message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+// Decode はJSONをメッセージに変換する
+func (JSONCodec) Decode(data []byte) (protocol.Message, error) {
+	var msg protocol.Message
+	err := json.Unmarshal(data, &msg)
+	return msg, err
+}
+
+// MessagePackCodec はMessagePackバイナリフレームでやり取りする
+// エンベロープとペイロードをまとめて1つのMessagePackマップとして符号化する
+type MessagePackCodec struct{}
+
+// Subprotocol はサブプロトコル名を返す
+func (MessagePackCodec) Subprotocol() string { return SubprotocolMessagePack }
+
+// FrameType はバイナリフレームを返す
+func (MessagePackCodec) FrameType() int { return websocket.BinaryMessage }
+
+// Encode はメッセージをMessagePackに変換する
+func (MessagePackCodec) Encode(msg protocol.Message) ([]byte, error) {
+	envelope := map[string]interface{}{"type": msg.Type}
+	if msg.ID != "" {
+		envelope["id"] = msg.ID
+	}
+	if msg.Seq != 0 {
+		envelope["seq"] = msg.Seq
+	}
+	if len(msg.Payload) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(msg.Payload))
+		dec.UseNumber()
+		var payload interface{}
+		if err := dec.Decode(&payload); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		envelope["payload"] = payload
+	}
+
+	var buf bytes.Buffer
+	if err := writeMsgpack(&buf, envelope); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode はMessagePackをメッセージに変換する
+func (MessagePackCodec) Decode(data []byte) (protocol.Message, error) {
+	var msg protocol.Message
+	value, rest, err := readMsgpack(data)
+	if err != nil {
+		return msg, err
+	}
+	if len(rest) > 0 {
+		return msg, fmt.Errorf("msgpack: %d trailing bytes", len(rest))
+	}
+	envelope, ok := value.(map[string]interface{})
+	if !ok {
+		return msg, fmt.Errorf("msgpack: message must be a map")
+	}
+
+	if msg.Type, ok = envelope["type"].(string); !ok {
+		return msg, fmt.Errorf("msgpack: message type must be a string")
+	}
+	if id, ok := envelope["id"].(string); ok {
+		msg.ID = id
+	}
+	switch seq := envelope["seq"].(type) {
+	case int64:
+		if seq > 0 {
+			msg.Seq = uint64(seq)
+		}
+	case uint64:
+		msg.Seq = seq
+	}
+	if payload, ok := envelope["payload"]; ok {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return msg, err
+		}
+		msg.Payload = b
+	}
+	return msg, nil
+}
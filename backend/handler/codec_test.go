@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"recaptchgame-backend/protocol"
+)
+
+func codecTestMessages(t *testing.T) []protocol.Message {
+	t.Helper()
+	opponentUpdate, err := json.Marshal(protocol.OpponentUpdatePayload{
+		Images: []string{"car_1", "kaidan_0", "/images/shingouki4.jpg#tile=3"},
+		Score:  3,
+		Combo:  1,
+		BROpponents: []protocol.BROpponentPayload{
+			{PlayerID: "player2", Target: "信号機", Images: []string{"car_2"}, Score: 2, Combo: 0, Effect: "ONION_RAIN", Selections: []int{}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal payload: %v", err)
+	}
+	verify, _ := json.Marshal(protocol.VerifyPayload{RoomID: "room1", PlayerID: "player1", Target: "車", SelectedIndices: []int{0, 4, 8}})
+
+	return []protocol.Message{
+		{Type: protocol.TypePing, Payload: json.RawMessage(`{}`)},
+		{Type: protocol.TypeVerify, ID: "req-1", Payload: verify},
+		{Type: protocol.TypeOpponentUpdate, Seq: 42, Payload: opponentUpdate},
+		{Type: protocol.TypeStatusUpdate, Seq: 1 << 40, Payload: json.RawMessage(`{"status":"waiting_for_opponent","negative":-129,"ratio":0.25,"big":18446744073709551615,"none":null,"ok":true}`)},
+		{Type: protocol.TypeLeaveRoom},
+	}
+}
+
+// assertSameMessage はペイロードをJSONの値として比較する（キー順や空白の違いは無視）
+func assertSameMessage(t *testing.T, codec string, want protocol.Message, got protocol.Message) {
+	t.Helper()
+	if got.Type != want.Type || got.ID != want.ID || got.Seq != want.Seq {
+		t.Errorf("%s: envelope mismatch: want %+v, got %+v", codec, want, got)
+	}
+	if len(want.Payload) == 0 {
+		if len(got.Payload) != 0 && string(got.Payload) != "null" {
+			t.Errorf("%s: expected empty payload, got %s", codec, got.Payload)
+		}
+		return
+	}
+	var wantValue, gotValue interface{}
+	if err := json.Unmarshal(want.Payload, &wantValue); err != nil {
+		t.Fatalf("%s: invalid test payload: %v", codec, err)
+	}
+	if err := json.Unmarshal(got.Payload, &gotValue); err != nil {
+		t.Fatalf("%s: decoded payload is not JSON: %v", codec, err)
+	}
+	if !reflect.DeepEqual(wantValue, gotValue) {
+		t.Errorf("%s: payload mismatch for %s:\nwant %s\ngot  %s", codec, want.Type, want.Payload, got.Payload)
+	}
+}
+
+// TestCodecRoundTrip はJSON/MessagePackの両Codecでエンコード→デコードが一致するかのテスト
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, MessagePackCodec{}} {
+		for _, msg := range codecTestMessages(t) {
+			data, err := codec.Encode(msg)
+			if err != nil {
+				t.Fatalf("%s: failed to encode %s: %v", codec.Subprotocol(), msg.Type, err)
+			}
+			decoded, err := codec.Decode(data)
+			if err != nil {
+				t.Fatalf("%s: failed to decode %s: %v", codec.Subprotocol(), msg.Type, err)
+			}
+			assertSameMessage(t, codec.Subprotocol(), msg, decoded)
+		}
+	}
+}
+
+// TestMessagePackIsSmaller はバイナリエンコーディングでフレームが小さくなることのテスト
+func TestMessagePackIsSmaller(t *testing.T) {
+	msg := codecTestMessages(t)[2]
+	jsonData, _ := JSONCodec{}.Encode(msg)
+	packed, err := MessagePackCodec{}.Encode(msg)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	if len(packed) >= len(jsonData) {
+		t.Errorf("expected msgpack (%d bytes) to be smaller than json (%d bytes)", len(packed), len(jsonData))
+	}
+}
+
+// TestMessagePackDecodeInvalid は不正なバイナリの拒否テスト
+func TestMessagePackDecodeInvalid(t *testing.T) {
+	invalid := [][]byte{
+		{},
+		{0x92, 0x01},                           // 要素数が足りない配列
+		{0x81, 0x01, 0x02},                     // 文字列以外のキー
+		{0xa4, 'P', 'I', 'N'},                  // 長さが足りない文字列
+		{0x81, 0xa4, 't', 'y', 'p', 'e', 0x01}, // type が文字列でない
+		{0xc1},                                 // 未使用の型コード
+	}
+	for _, data := range invalid {
+		if _, err := (MessagePackCodec{}).Decode(data); err == nil {
+			t.Errorf("expected error decoding % x", data)
+		}
+	}
+
+	if CodecForSubprotocol("").Subprotocol() != SubprotocolJSON {
+		t.Errorf("expected JSON codec by default")
+	}
+	if CodecForSubprotocol(SubprotocolMessagePack).FrameType() != (MessagePackCodec{}).FrameType() {
+		t.Errorf("expected msgpack codec for %s", SubprotocolMessagePack)
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"recaptchgame-backend/config"
+	"recaptchgame-backend/protocol"
+)
+
+// DefaultDrainTimeout は進行中のルームの終了を待つ既定の上限
+const DefaultDrainTimeout = config.DefaultDrainTimeout
+
+const (
+	drainJoinRejectedMessage = "Server is restarting; please try again shortly"
+	drainNoticeMessage       = "Server is restarting. Games in progress can finish; new matches are paused."
+	drainPollInterval        = 500 * time.Millisecond
+)
+
+// drainState はドレインモード（新規参加を止めて進行中のルームの終了を待つ状態）
+type drainState struct {
+	mu       sync.Mutex
+	draining bool
+	deadline time.Time
+	started  chan struct{}
+}
+
+func newDrainState() *drainState {
+	return &drainState{started: make(chan struct{})}
+}
+
+// StartDrain はドレインモードに入り、全接続に SERVER_DRAINING を送る
+// timeout は進行中のルームの終了を待つ上限。既にドレイン中なら何もせず false を返す
+func (h *WebSocketHandler) StartDrain(timeout time.Duration) bool {
+	h.drain.mu.Lock()
+	if h.drain.draining {
+		h.drain.mu.Unlock()
+		return false
+	}
+	h.drain.draining = true
+	h.drain.deadline = time.Now().Add(timeout)
+	deadline := h.drain.deadline
+	close(h.drain.started)
+	h.drain.mu.Unlock()
+
+	b, _ := json.Marshal(protocol.ServerDrainingPayload{Message: drainNoticeMessage, Deadline: deadline.UnixMilli()})
+	sent := h.wsManager.Broadcast(protocol.Message{Type: protocol.TypeServerDraining, Payload: b})
+	h.logger.Warn("drain started", "deadline", deadline, "recipients", sent, "active_rooms", h.ActiveRoomCount())
+	return true
+}
+
+// IsDraining はドレイン中かどうか
+func (h *WebSocketHandler) IsDraining() bool {
+	h.drain.mu.Lock()
+	defer h.drain.mu.Unlock()
+	return h.drain.draining
+}
+
+// DrainDeadline はドレインの期限を返す（ドレイン中でなければゼロ値）
+func (h *WebSocketHandler) DrainDeadline() time.Time {
+	h.drain.mu.Lock()
+	defer h.drain.mu.Unlock()
+	return h.drain.deadline
+}
+
+// DrainStarted はドレインが始まると閉じられるチャネルを返す（管理APIからの開始をmainで検知するため）
+func (h *WebSocketHandler) DrainStarted() <-chan struct{} {
+	return h.drain.started
+}
+
+// ActiveRoomCount は対戦中のルーム数を返す
+func (h *WebSocketHandler) ActiveRoomCount() int {
+	rooms, err := h.roomRepo.ListActive()
+	if err != nil {
+		return 0
+	}
+	return len(rooms)
+}
+
+// WaitForActiveRooms は対戦中のルームがなくなるまで待つ
+// ctx が先に終わった場合は ctx.Err() を返す
+func (h *WebSocketHandler) WaitForActiveRooms(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		if h.ActiveRoomCount() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
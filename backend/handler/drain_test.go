@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"recaptchgame-backend/domain"
+)
+
+// TestDrain はドレインモードのテスト
+func TestDrain(t *testing.T) {
+	env := newTestHandlerEnv()
+	roomRepo, wsHandler := env.roomRepo, env.wsHandler
+
+	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
+	room.Start()
+	roomRepo.Save(room)
+
+	// テスト1: ドレイン開始前
+	if wsHandler.IsDraining() {
+		t.Fatalf("expected handler not to be draining initially")
+	}
+	select {
+	case <-wsHandler.DrainStarted():
+		t.Fatalf("expected drain channel to be open")
+	default:
+	}
+
+	// テスト2: 開始すると通知チャネルが閉じ、二重開始は無視される
+	if !wsHandler.StartDrain(time.Minute) {
+		t.Fatalf("expected drain to start")
+	}
+	deadline := wsHandler.DrainDeadline()
+	if wsHandler.StartDrain(time.Hour) || !wsHandler.DrainDeadline().Equal(deadline) {
+		t.Errorf("expected second drain request to be ignored")
+	}
+	select {
+	case <-wsHandler.DrainStarted():
+	default:
+		t.Errorf("expected drain channel to be closed")
+	}
+
+	// テスト3: 対戦中のルームが残っている間は期限まで待つ
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := wsHandler.WaitForActiveRooms(ctx); err == nil {
+		t.Errorf("expected wait to time out while a room is active")
+	}
+
+	// テスト4: ルームが終われば待機も終わる
+	roomRepo.Delete("room1")
+	if err := wsHandler.WaitForActiveRooms(context.Background()); err != nil {
+		t.Errorf("expected wait to finish once rooms are gone, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"recaptchgame-backend/protocol"
+)
+
+// roomEvent はルームイベントログの1件
+// toPlayerID が空ならルーム全員宛、exceptPlayerID があればそのプレイヤーには配信しない
+type roomEvent struct {
+	seq            uint64
+	toPlayerID     string
+	exceptPlayerID string
+	msg            protocol.Message
+}
+
+func (e roomEvent) visibleTo(playerID string) bool {
+	if e.toPlayerID != "" && e.toPlayerID != playerID {
+		return false
+	}
+	return e.exceptPlayerID == "" || e.exceptPlayerID != playerID
+}
+
+type roomEventStream struct {
+	lastSeq uint64
+	events  []roomEvent
+}
+
+// roomEventLog はルームごとに単調増加するシーケンス番号を払い出し、直近のイベントを保持する
+// 再接続したクライアントは最後に受け取ったシーケンス番号以降のイベントを再送してもらえる
+type roomEventLog struct {
+	mu       sync.Mutex
+	capacity int
+	rooms    map[string]*roomEventStream
+}
+
+func newRoomEventLog(capacity int) *roomEventLog {
+	if capacity <= 0 {
+		capacity = 256
+	}
+	return &roomEventLog{
+		capacity: capacity,
+		rooms:    make(map[string]*roomEventStream),
+	}
+}
+
+// record はイベントを採番して記録し、シーケンス番号を付与したメッセージを返す
+func (l *roomEventLog) record(roomID string, toPlayerID string, exceptPlayerID string, msg protocol.Message) protocol.Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stream, ok := l.rooms[roomID]
+	if !ok {
+		stream = &roomEventStream{}
+		l.rooms[roomID] = stream
+	}
+	stream.lastSeq++
+	msg.Seq = stream.lastSeq
+	stream.events = append(stream.events, roomEvent{
+		seq:            msg.Seq,
+		toPlayerID:     toPlayerID,
+		exceptPlayerID: exceptPlayerID,
+		msg:            msg,
+	})
+	if len(stream.events) > l.capacity {
+		stream.events = stream.events[len(stream.events)-l.capacity:]
+	}
+	return msg
+}
+
+// latestSeq はルームで最後に払い出したシーケンス番号を返す
+func (l *roomEventLog) latestSeq(roomID string) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if stream, ok := l.rooms[roomID]; ok {
+		return stream.lastSeq
+	}
+	return 0
+}
+
+// since は lastSeq より後にプレイヤー宛に配信されたイベントを返す
+// ログが切り詰められていて欠落を埋められない場合は false を返す
+func (l *roomEventLog) since(roomID string, playerID string, lastSeq uint64) ([]protocol.Message, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stream, ok := l.rooms[roomID]
+	if !ok || lastSeq > stream.lastSeq {
+		return nil, false
+	}
+	if lastSeq == stream.lastSeq {
+		return []protocol.Message{}, true
+	}
+	if len(stream.events) == 0 || stream.events[0].seq > lastSeq+1 {
+		return nil, false
+	}
+
+	missed := make([]protocol.Message, 0)
+	for _, e := range stream.events {
+		if e.seq <= lastSeq || !e.visibleTo(playerID) {
+			continue
+		}
+		missed = append(missed, e.msg)
+	}
+	return missed, true
+}
+
+// retire は終了したルームのログを猶予時間の経過後に破棄する
+// 猶予時間内に同じルームIDで新しいイベントが記録された場合は破棄しない
+func (l *roomEventLog) retire(roomID string, after time.Duration) {
+	l.mu.Lock()
+	stream, ok := l.rooms[roomID]
+	if !ok {
+		l.mu.Unlock()
+		return
+	}
+	retiredAt := stream.lastSeq
+	l.mu.Unlock()
+
+	time.AfterFunc(after, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if current, ok := l.rooms[roomID]; ok && current == stream && current.lastSeq == retiredAt {
+			delete(l.rooms, roomID)
+		}
+	})
+}
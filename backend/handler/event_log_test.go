@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"testing"
+
+	"recaptchgame-backend/protocol"
+)
+
+// TestRoomEventLog はイベントログの採番と再送範囲のテスト
+func TestRoomEventLog(t *testing.T) {
+	log := newRoomEventLog(3)
+
+	first := log.record("room1", "", "", protocol.Message{Type: protocol.TypeStatusUpdate})
+	if first.Seq != 1 {
+		t.Fatalf("expected seq 1, got %d", first.Seq)
+	}
+	log.record("room1", "player2", "", protocol.Message{Type: protocol.TypeOpponentUpdate})
+	log.record("room1", "", "player1", protocol.Message{Type: protocol.TypeOpponentSelect})
+
+	// テスト1: 自分宛・全員宛のイベントのみ再送される
+	missed, ok := log.since("room1", "player1", 1)
+	if !ok {
+		t.Fatalf("expected events to be replayable")
+	}
+	if len(missed) != 0 {
+		t.Errorf("expected no events for player1, got %d", len(missed))
+	}
+	missed, ok = log.since("room1", "player2", 1)
+	if !ok || len(missed) != 2 {
+		t.Fatalf("expected 2 events for player2, got %d (ok=%v)", len(missed), ok)
+	}
+	if missed[0].Seq != 2 || missed[1].Seq != 3 {
+		t.Errorf("expected seq 2,3, got %d,%d", missed[0].Seq, missed[1].Seq)
+	}
+
+	// テスト2: 最新まで受信済みなら空で成功
+	missed, ok = log.since("room1", "player2", 3)
+	if !ok || len(missed) != 0 {
+		t.Errorf("expected empty replay, got %d (ok=%v)", len(missed), ok)
+	}
+
+	// テスト3: 容量を超えて切り詰められた範囲は再送できない
+	log.record("room1", "", "", protocol.Message{Type: protocol.TypeStatusUpdate})
+	if _, ok := log.since("room1", "player2", 0); ok {
+		t.Errorf("expected gap to be detected after truncation")
+	}
+	if _, ok := log.since("room1", "player2", 1); !ok {
+		t.Errorf("expected seq 1 to still be resumable")
+	}
+
+	// テスト4: 未知のルームや未来のシーケンス番号はスナップショットにフォールバック
+	if _, ok := log.since("unknown", "player1", 0); ok {
+		t.Errorf("expected unknown room to fall back")
+	}
+	if _, ok := log.since("room1", "player1", 99); ok {
+		t.Errorf("expected future seq to fall back")
+	}
+	if log.latestSeq("room1") != 4 {
+		t.Errorf("expected latest seq 4, got %d", log.latestSeq("room1"))
+	}
+}
@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"encoding/json"
+	"time"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/protocol"
+	"recaptchgame-backend/usecase"
+)
+
+// ゲーム終了・退出の通知文言
+const (
+	gameWonMessage      = "You are Human!"
+	opponentLeftMessage = "Opponent Disconnected"
+)
+
+// HandleEvent はドメインイベントをルームのクライアントへのメッセージに変換して送る（イベントバスの購読者）
+func (h *WebSocketHandler) HandleEvent(event domain.Event) {
+	switch e := event.(type) {
+	case domain.GameStarted:
+		h.onGameStarted(e)
+	case domain.PlayerScored:
+		h.onPlayerScored(e)
+	case domain.AnswerRejected:
+		h.onAnswerRejected(e)
+	case domain.ComboObstructionFired:
+		h.onComboObstructionFired(e)
+	case domain.ItemAwarded:
+		h.onItemAwarded(e)
+	case domain.ItemUsed:
+		h.onItemUsed(e)
+	case domain.TargetChoiceRequested:
+		h.onTargetChoiceRequested(e)
+	case domain.GameFinished:
+		h.onGameFinished(e)
+	case domain.PlayerLeft:
+		h.onPlayerLeft(e)
+	case domain.RoomExpired:
+		b, _ := json.Marshal(protocol.KickedPayload{RoomID: e.RoomID, Reason: reaperWaitingExpiredReason})
+		h.broadcastToRoom(e.RoomID, protocol.Message{Type: protocol.TypeKicked, Payload: b})
+		h.cleanupFinishedRoom(e.RoomID, e.PlayerIDs)
+	}
+}
+
+// onGameStarted はプレイヤーごとに自分の問題と相手の状態を載せた GAME_START を送る
+func (h *WebSocketHandler) onGameStarted(e domain.GameStarted) {
+	room, err := h.roomRepo.FindByID(e.RoomID)
+	if err != nil || room == nil {
+		return
+	}
+	for _, playerID := range room.PlayerIDs() {
+		player := room.GetPlayerByID(playerID)
+		gamePayload := protocol.GameStartPayload{
+			WinningScore:   e.WinningScore,
+			MyCurrentCombo: player.Combo,
+			PlayerEffect:   player.ActiveEffect(),
+			MyItems:        player.ItemNames(),
+			BROpponents:    h.buildBROpponentSnapshots(room, playerID),
+		}
+		if gs := room.GetGameStateByPlayerID(playerID); gs != nil {
+			gamePayload.Target = gs.Target
+			gamePayload.Images = gs.Images
+		}
+		if len(gamePayload.BROpponents) > 0 {
+			gamePayload.OpponentImages = gamePayload.BROpponents[0].Images
+			gamePayload.OpponentCurrentScore = gamePayload.BROpponents[0].Score
+		}
+
+		b, _ := json.Marshal(gamePayload)
+		h.sendToPlayer(e.RoomID, playerID, protocol.Message{Type: protocol.TypeGameStart, Payload: b})
+	}
+}
+
+// onPlayerScored は回答者に新しい問題と現在のスコア/コンボを送り、相手に状態更新を送る
+// ゲームが終了した場合は GameFinished でまとめて通知する
+func (h *WebSocketHandler) onPlayerScored(e domain.PlayerScored) {
+	if e.GameOver {
+		return
+	}
+	updateMy := protocol.UpdatePatternPayload{
+		Target:       e.Target,
+		Images:       e.Images,
+		CurrentScore: e.Score,
+		CurrentCombo: e.Combo,
+	}
+	bMy, _ := json.Marshal(updateMy)
+	myMsg := h.events.record(e.RoomID, e.PlayerID, "", protocol.Message{Type: protocol.TypeUpdatePattern, ID: e.RequestID, Payload: bMy})
+	h.sendReply(e.ClientID, myMsg)
+
+	h.publishOpponentUpdate(e.RoomID, e.ClientID, e.PlayerID, e.Score, e.Combo, e.Images)
+}
+
+// onAnswerRejected は回答者に残りのロックアウト時間を通知する
+// 問題の差し替えや減点は状態変化なので記録し、相手にも反映する
+func (h *WebSocketHandler) onAnswerRejected(e domain.AnswerRejected) {
+	failed := protocol.VerifyFailedPayload{
+		LockedOut:          e.LockedOut,
+		LockoutRemainingMs: e.LockoutRemaining.Milliseconds(),
+		CurrentScore:       e.Score,
+		ScoreDeducted:      e.ScoreDeducted,
+		ProblemReplaced:    e.ProblemReplaced,
+	}
+	if e.ProblemReplaced {
+		failed.Target = e.Target
+		failed.Images = e.Images
+	}
+	b, _ := json.Marshal(failed)
+	if !e.ProblemReplaced && e.ScoreDeducted == 0 {
+		h.reply(e.ClientID, e.RequestID, protocol.TypeVerifyFailed, b)
+		return
+	}
+
+	failedMsg := h.events.record(e.RoomID, e.PlayerID, "", protocol.Message{Type: protocol.TypeVerifyFailed, ID: e.RequestID, Payload: b})
+	h.sendReply(e.ClientID, failedMsg)
+	h.publishOpponentUpdate(e.RoomID, e.ClientID, e.PlayerID, e.Score, e.Combo, e.Images)
+}
+
+// onComboObstructionFired はルーム全員に妨害を通知し、攻撃側に発動を確認させる
+func (h *WebSocketHandler) onComboObstructionFired(e domain.ComboObstructionFired) {
+	obs := protocol.ObstructionPayload{
+		Effect:     e.Effect,
+		AttackerID: e.AttackerID,
+		TargetID:   e.TargetID,
+		DurationMs: e.Duration.Milliseconds(),
+		ExpiresAt:  e.ExpiresAt.UnixMilli(),
+		Intensity:  e.Intensity,
+		Blocked:    e.Blocked,
+	}
+	if e.Blocked {
+		obs.ExpiresAt = 0
+	}
+	b, _ := json.Marshal(obs)
+	h.broadcastToRoom(e.RoomID, protocol.Message{Type: protocol.TypeObstruction, Payload: b})
+	h.sendToPlayer(e.RoomID, e.AttackerID, protocol.Message{Type: protocol.TypeObstructionFired, Payload: b})
+}
+
+// onTargetChoiceRequested は攻撃側に CHOOSE_TARGET を送り、期限までに選ばれなければ無作為に選んだ相手に送る
+func (h *WebSocketHandler) onTargetChoiceRequested(e domain.TargetChoiceRequested) {
+	choose := protocol.ChooseTargetPayload{
+		Candidates: make([]protocol.TargetCandidatePayload, 0, len(e.Candidates)),
+		TimeoutMs:  e.Deadline.Sub(e.OccurredAt).Milliseconds(),
+		Deadline:   e.Deadline.UnixMilli(),
+	}
+	for _, c := range e.Candidates {
+		choose.Candidates = append(choose.Candidates, protocol.TargetCandidatePayload{PlayerID: c.PlayerID, Score: c.Score})
+	}
+	b, _ := json.Marshal(choose)
+	h.sendToPlayer(e.RoomID, e.AttackerID, protocol.Message{Type: protocol.TypeChooseTarget, Payload: b})
+
+	// 選ばれた後・次の選択待ちに変わった後・終了したルームでは何もしない（Choose が ErrNoPendingTarget などを返す）
+	time.AfterFunc(time.Until(e.Deadline), func() {
+		input := usecase.ChooseTargetInput{RoomID: e.RoomID, PlayerID: e.AttackerID, Deadline: e.Deadline}
+		if err := h.targetingUC.Choose(input); err == nil {
+			h.logger.Debug("target choice timed out", "room_id", e.RoomID, "player_id", e.AttackerID)
+		}
+	})
+}
+
+// onItemAwarded は入手したアイテムと持ち物を本人に通知する
+func (h *WebSocketHandler) onItemAwarded(e domain.ItemAwarded) {
+	b, _ := json.Marshal(protocol.ItemAwardedPayload{Item: e.Item, Reason: e.Reason, Items: e.Items})
+	h.sendToPlayer(e.RoomID, e.PlayerID, protocol.Message{Type: protocol.TypeItemAwarded, Payload: b})
+}
+
+// onItemUsed はアイテムの使用結果をルーム全員に通知する
+// 妨害を跳ね返した場合は、使ったプレイヤーを攻撃側とする OBSTRUCTION も送る
+func (h *WebSocketHandler) onItemUsed(e domain.ItemUsed) {
+	used := protocol.ItemUsedPayload{
+		PlayerID: e.PlayerID,
+		Item:     e.Item,
+		Outcome:  e.Outcome,
+		TargetID: e.TargetID,
+		Effect:   e.Effect,
+		Blocked:  e.Blocked,
+		Items:    e.Items,
+	}
+	if !e.ExpiresAt.IsZero() {
+		used.ExpiresAt = e.ExpiresAt.UnixMilli()
+	}
+	b, _ := json.Marshal(used)
+	h.broadcastToRoom(e.RoomID, protocol.Message{Type: protocol.TypeItemUsed, Payload: b})
+
+	if e.Outcome != domain.ItemOutcomeReflected {
+		return
+	}
+	obs := protocol.ObstructionPayload{
+		Effect:     e.Effect,
+		AttackerID: e.PlayerID,
+		TargetID:   e.TargetID,
+		Intensity:  1,
+		Blocked:    e.Blocked,
+		Reflected:  true,
+	}
+	if !e.Blocked {
+		obs.DurationMs = e.ExpiresAt.Sub(e.OccurredAt).Milliseconds()
+		obs.ExpiresAt = e.ExpiresAt.UnixMilli()
+	}
+	bObs, _ := json.Marshal(obs)
+	h.broadcastToRoom(e.RoomID, protocol.Message{Type: protocol.TypeObstruction, Payload: bObs})
+}
+
+// onGameFinished は終了理由に応じた GAME_FINISHED を送り、ルームの接続・セッションを片付ける
+// 不戦勝は残った勝者にだけ通知する（退出したプレイヤーの接続はすでに外れている）
+func (h *WebSocketHandler) onGameFinished(e domain.GameFinished) {
+	var message string
+	switch e.Reason {
+	case domain.FinishReasonScore:
+		message = gameWonMessage
+	case domain.FinishReasonForfeit:
+		message = opponentLeftMessage
+	case domain.FinishReasonIdle:
+		message = reaperIdleMessage
+	default:
+		message = e.Note
+		if message == "" {
+			message = adminForceEndMessage
+		}
+	}
+	b, _ := json.Marshal(protocol.GameResultPayload{WinnerID: e.WinnerID, Message: message})
+	msg := protocol.Message{Type: protocol.TypeGameFinished, Payload: b}
+	if e.Reason == domain.FinishReasonForfeit {
+		h.sendToPlayer(e.RoomID, e.WinnerID, msg)
+	} else {
+		h.broadcastToRoom(e.RoomID, msg)
+	}
+	h.cleanupFinishedRoom(e.RoomID, e.PlayerIDs)
+}
+
+// onPlayerLeft は残りのプレイヤーに退出を通知する
+func (h *WebSocketHandler) onPlayerLeft(e domain.PlayerLeft) {
+	switch {
+	case e.RoomDeleted:
+		// 最後のプレイヤーが抜けてルームが削除された
+		h.events.retire(e.RoomID, h.settings.GracePeriod)
+	case e.Remaining >= 2:
+		status := protocol.StatusUpdatePayload{Message: opponentLeftMessage, PlayerID: e.PlayerID, RemainingPlayers: e.Remaining}
+		b, _ := json.Marshal(status)
+		h.broadcastToRoom(e.RoomID, protocol.Message{Type: protocol.TypeStatusUpdate, Payload: b})
+	}
+}
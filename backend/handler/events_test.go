@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/protocol"
+	"recaptchgame-backend/usecase"
+)
+
+// TestEventBroadcast はドメインイベントの購読によるクライアントへの通知のテスト
+func TestEventBroadcast(t *testing.T) {
+	env := newTestHandlerEnv()
+	wsHandler := env.wsHandler
+	wsHandler.handleMessage("clientA", nil, joinMessage("code1", "playerA"))
+	wsHandler.handleMessage("clientB", nil, joinMessage("code1", "playerB"))
+
+	sentTo := func(roomID string, playerID string) []string {
+		msgs, _ := wsHandler.events.since(roomID, playerID, 0)
+		types := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			types = append(types, msg.Type)
+		}
+		return types
+	}
+
+	// テスト1: GameStarted で全員に GAME_START を送る
+	if got := sentTo("code1", "playerA"); !containsType(got, protocol.TypeGameStart) {
+		t.Fatalf("expected GAME_START for playerA, got %v", got)
+	}
+
+	// テスト2: 対戦中に相手が抜けると残った勝者にだけ GAME_FINISHED を送り、ルームを片付ける
+	wsHandler.leaveAndNotify(usecase.LeaveRoomInput{PlayerID: "playerB"})
+	msgs, _ := wsHandler.events.since("code1", "playerA", 0)
+	var result protocol.GameResultPayload
+	for _, msg := range msgs {
+		if msg.Type == protocol.TypeGameFinished {
+			_ = json.Unmarshal(msg.Payload, &result)
+		}
+	}
+	if result.WinnerID != "playerA" || result.Message != opponentLeftMessage {
+		t.Errorf("expected forfeit win for playerA, got %+v", result)
+	}
+	if got := sentTo("code1", "playerB"); containsType(got, protocol.TypeGameFinished) {
+		t.Errorf("expected leaver not to receive GAME_FINISHED, got %v", got)
+	}
+	if room, _ := env.roomRepo.FindByID("code1"); room != nil {
+		t.Errorf("expected finished room to be removed")
+	}
+	if _, ok := env.wsManager.GetRoomID("clientA"); ok {
+		t.Errorf("expected winner to be detached from the finished room")
+	}
+
+	// テスト3: 対戦の記録とリプレイも同じイベントから残る
+	records, _ := env.matchHistory.ListRecent(10)
+	if len(records) != 1 || records[0].Reason != domain.FinishReasonForfeit || records[0].WinnerID != "playerA" {
+		t.Errorf("expected forfeit match record, got %+v", records)
+	}
+	replay := env.replays.Replay("code1")
+	if len(replay) < 2 || replay[0].EventType() != domain.EventRoomCreated || replay[1].EventType() != domain.EventGameStarted || replay[len(replay)-1].EventType() != domain.EventGameFinished {
+		t.Errorf("expected replay from RoomCreated to GameFinished, got %d events", len(replay))
+	}
+
+	// テスト4: 管理者による終了は指定の文言で全員に通知する
+	wsHandler.handleMessage("clientC", nil, joinMessage("code2", "playerC"))
+	wsHandler.handleMessage("clientD", nil, joinMessage("code2", "playerD"))
+	if err := wsHandler.ForceEndRoom("code2", "", "maintenance"); err != nil {
+		t.Fatalf("failed to force-end room: %v", err)
+	}
+	msgs, _ = wsHandler.events.since("code2", "playerD", 0)
+	last := msgs[len(msgs)-1]
+	_ = json.Unmarshal(last.Payload, &result)
+	if last.Type != protocol.TypeGameFinished || result.Message != "maintenance" || result.WinnerID != "" {
+		t.Errorf("expected admin GAME_FINISHED, got %s %+v", last.Type, result)
+	}
+
+	// テスト5: OBSTRUCTION に持続時間と解除時刻を載せる
+	wsHandler.handleMessage("clientE", nil, joinMessage("code3", "playerE"))
+	wsHandler.handleMessage("clientF", nil, joinMessage("code3", "playerF"))
+	expiresAt := time.Now().Add(5 * time.Second)
+	env.eventBus.Publish(domain.ComboObstructionFired{
+		EventMeta:  domain.EventMeta{RoomID: "code3"},
+		AttackerID: "playerE",
+		TargetID:   "playerF",
+		Effect:     string(domain.EffectOnionRain),
+		Duration:   5 * time.Second,
+		ExpiresAt:  expiresAt,
+		Intensity:  1.5,
+	})
+	msgs, _ = wsHandler.events.since("code3", "playerF", 0)
+	var obs protocol.ObstructionPayload
+	for _, msg := range msgs {
+		if msg.Type == protocol.TypeObstruction {
+			_ = json.Unmarshal(msg.Payload, &obs)
+		}
+	}
+	if obs.Effect != string(domain.EffectOnionRain) || obs.DurationMs != 5000 || obs.ExpiresAt != expiresAt.UnixMilli() || obs.Intensity != 1.5 {
+		t.Errorf("unexpected OBSTRUCTION payload: %+v", obs)
+	}
+
+	// テスト6: USE_ITEM は接続のプレイヤーのアイテムを使い、反射した妨害を OBSTRUCTION として全員に通知する
+	room, _ := env.roomRepo.FindByID("code3")
+	reflector := room.GetPlayerByID("playerF")
+	reflector.Items = []domain.ItemType{domain.ItemReflect}
+	reflector.ApplyEffect(string(domain.EffectBlur), time.Now().Add(3*time.Second))
+	reflector.EffectAttackerID = "playerE"
+	_ = env.roomRepo.Save(room)
+	b, _ := json.Marshal(protocol.UseItemPayload{Item: string(domain.ItemReflect)})
+	wsHandler.handleMessage("clientF", nil, protocol.Message{Type: protocol.TypeUseItem, ID: "use-1", Payload: b})
+	msgs, _ = wsHandler.events.since("code3", "playerE", 0)
+	var used protocol.ItemUsedPayload
+	obs = protocol.ObstructionPayload{}
+	for _, msg := range msgs {
+		switch msg.Type {
+		case protocol.TypeItemUsed:
+			_ = json.Unmarshal(msg.Payload, &used)
+		case protocol.TypeObstruction:
+			_ = json.Unmarshal(msg.Payload, &obs)
+		}
+	}
+	if used.PlayerID != "playerF" || used.Outcome != domain.ItemOutcomeReflected || used.TargetID != "playerE" || len(used.Items) != 0 {
+		t.Errorf("unexpected ITEM_USED payload: %+v", used)
+	}
+	if !obs.Reflected || obs.AttackerID != "playerF" || obs.TargetID != "playerE" || obs.Effect != string(domain.EffectBlur) || obs.DurationMs <= 0 {
+		t.Errorf("expected reflected OBSTRUCTION, got %+v", obs)
+	}
+	room, _ = env.roomRepo.FindByID("code3")
+	if room.GetPlayerByID("playerE").ActiveEffect() != string(domain.EffectBlur) || room.GetPlayerByID("playerF").ActiveEffect() != "" {
+		t.Errorf("expected effect to move to the attacker")
+	}
+
+	// テスト7: 相手を選ばせる場合は攻撃側に CHOOSE_TARGET を送り、期限までに選ばれなければ無作為に送る
+	before, _ := wsHandler.events.since("code3", "playerF", 0)
+	room, _ = env.roomRepo.FindByID("code3")
+	deadline := time.Now().Add(20 * time.Millisecond)
+	room.GetPlayerByID("playerE").PendingTargetUntil = deadline
+	_ = env.roomRepo.Save(room)
+	env.eventBus.Publish(domain.TargetChoiceRequested{
+		EventMeta:  domain.EventMeta{RoomID: "code3", OccurredAt: time.Now()},
+		AttackerID: "playerE",
+		Candidates: []domain.TargetCandidate{{PlayerID: "playerF", Score: 0}},
+		Deadline:   deadline,
+	})
+	msgs, _ = wsHandler.events.since("code3", "playerE", 0)
+	var choose protocol.ChooseTargetPayload
+	if last := msgs[len(msgs)-1]; last.Type == protocol.TypeChooseTarget {
+		_ = json.Unmarshal(last.Payload, &choose)
+	}
+	if len(choose.Candidates) != 1 || choose.Candidates[0].PlayerID != "playerF" || choose.Deadline != deadline.UnixMilli() {
+		t.Errorf("unexpected CHOOSE_TARGET payload: %+v", choose)
+	}
+	waitUntil := time.Now().Add(time.Second)
+	for {
+		msgs, _ = wsHandler.events.since("code3", "playerF", 0)
+		last := msgs[len(msgs)-1]
+		if len(msgs) > len(before) && last.Type == protocol.TypeObstruction {
+			_ = json.Unmarshal(last.Payload, &obs)
+			if obs.AttackerID != "playerE" || obs.TargetID != "playerF" {
+				t.Errorf("expected timed-out obstruction to playerF, got %+v", obs)
+			}
+			break
+		}
+		if time.Now().After(waitUntil) {
+			t.Fatalf("expected obstruction after the choice timed out, got %s", last.Type)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"log/slog"
+)
+
+// clientLogger はクライアントID・メッセージ種別と、紐づくルームID・プレイヤーIDを付与したロガーを返す
+func (h *WebSocketHandler) clientLogger(clientID string, msgType string) *slog.Logger {
+	attrs := []any{"client_id", clientID}
+	if msgType != "" {
+		attrs = append(attrs, "msg_type", msgType)
+	}
+	if roomID, ok := h.wsManager.GetRoomID(clientID); ok && roomID != "" {
+		attrs = append(attrs, "room_id", roomID)
+	}
+	if playerID, ok := h.wsManager.GetPlayerID(clientID); ok && playerID != "" {
+		attrs = append(attrs, "player_id", playerID)
+	}
+	return h.logger.With(attrs...)
+}
@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/metrics"
+)
+
+// VERIFY の結果（verify_total の outcome ラベル）
+const (
+	VerifyOutcomeCorrect   = "correct"
+	VerifyOutcomeWrong     = "wrong"
+	VerifyOutcomeLockedOut = "locked_out"
+	VerifyOutcomeStale     = "stale" // 古い問題への回答
+	VerifyOutcomeError     = "error"
+)
+
+// ルームの状態（rooms の state ラベル）
+const (
+	RoomStateWaiting = "waiting" // 参加者待ち
+	RoomStateReady   = "ready"   // 定員に達して開始待ち
+	RoomStatePlaying = "playing"
+)
+
+// Metrics はゲームサーバーの運用メトリクス
+// nil の場合は何も記録しない（テストや計測不要な構成向け）
+type Metrics struct {
+	verifies      *metrics.Counter
+	obstructions  *metrics.Counter
+	itemsUsed     *metrics.Counter
+	sendQueueFull *metrics.Counter
+	graceExpiries *metrics.Counter
+	rateLimited   *metrics.Counter
+	roomsReaped   *metrics.Counter
+	matchWait     *metrics.Histogram
+
+	mu        sync.Mutex
+	waitStart map[string]matchWaitEntry // playerID -> 参加時刻
+}
+
+type matchWaitEntry struct {
+	mode string
+	at   time.Time
+}
+
+// NewMetrics はメトリクスを registry に登録して生成
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		verifies:      registry.NewCounter("recaptchgame_verify_total", "VERIFY requests by outcome.", "outcome"),
+		obstructions:  registry.NewCounter("recaptchgame_obstructions_fired_total", "Combo obstructions fired by effect.", "effect"),
+		itemsUsed:     registry.NewCounter("recaptchgame_items_used_total", "Defensive items used by item and outcome.", "item", "outcome"),
+		sendQueueFull: registry.NewCounter("recaptchgame_send_queue_full_disconnects_total", "Clients disconnected because their send queue was full."),
+		graceExpiries: registry.NewCounter("recaptchgame_grace_period_expiries_total", "Disconnected players removed after the reconnect grace period expired."),
+		rateLimited:   registry.NewCounter("recaptchgame_rate_limited_messages_total", "Inbound messages rejected by the rate limiter by message type.", "type"),
+		roomsReaped:   registry.NewCounter("recaptchgame_rooms_reaped_total", "Abandoned rooms removed by the reaper by reason.", "reason"),
+		matchWait:     registry.NewHistogram("recaptchgame_matchmaking_wait_seconds", "Time from joining a room until the game starts.", metrics.DefaultBuckets, "mode"),
+		waitStart:     make(map[string]matchWaitEntry),
+	}
+}
+
+// RegisterStateCollectors は接続数・ルーム数・接続拒否数をスクレイプ時に集めるよう登録する
+func RegisterStateCollectors(registry *metrics.Registry, wsManager *WebSocketManager, roomRepo domain.RoomRepository, admission *AdmissionController) {
+	registry.NewGaugeFunc("recaptchgame_active_connections", "Open WebSocket connections.", nil, func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(wsManager.ConnectionCount())}}
+	})
+	registry.NewGaugeFunc("recaptchgame_rooms", "Rooms by state.", []string{"state"}, func() []metrics.Sample {
+		counts := map[string]int{RoomStateWaiting: 0, RoomStateReady: 0, RoomStatePlaying: 0}
+		if rooms, err := roomRepo.ListAll(); err == nil {
+			for _, room := range rooms {
+				counts[roomState(room)]++
+			}
+		}
+		samples := make([]metrics.Sample, 0, len(counts))
+		for state, n := range counts {
+			samples = append(samples, metrics.Sample{LabelValues: []string{state}, Value: float64(n)})
+		}
+		return samples
+	})
+	if admission == nil {
+		return
+	}
+	registry.NewCounterFunc("recaptchgame_connection_rejections_total", "Connections rejected or dropped by the admission controller by reason.", []string{"reason"}, func() []metrics.Sample {
+		rejections := admission.Rejections()
+		reasons := make([]string, 0, len(rejections))
+		for reason := range rejections {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		samples := make([]metrics.Sample, 0, len(reasons))
+		for _, reason := range reasons {
+			samples = append(samples, metrics.Sample{LabelValues: []string{reason}, Value: float64(rejections[reason])})
+		}
+		return samples
+	})
+}
+
+func roomState(room *domain.Room) string {
+	switch {
+	case room.IsActive:
+		return RoomStatePlaying
+	case room.IsReady():
+		return RoomStateReady
+	default:
+		return RoomStateWaiting
+	}
+}
+
+// matchMode はマッチング待ち時間のラベル（ランダムマッチか合言葉ルームか）
+func matchMode(roomID string) string {
+	if roomID == "RANDOM" {
+		return "random"
+	}
+	return "private"
+}
+
+// HandleEvent はドメインイベントから回答結果・妨害・マッチングの待ち時間を記録する（イベントバスの購読者）
+// 古い問題への回答やエラーはイベントにならないため、VERIFY の処理で記録する
+func (m *Metrics) HandleEvent(event domain.Event) {
+	if m == nil {
+		return
+	}
+	switch e := event.(type) {
+	case domain.GameStarted:
+		for _, playerID := range e.PlayerIDs {
+			m.matchStarted(playerID, e.OccurredAt)
+		}
+	case domain.PlayerScored:
+		m.verify(VerifyOutcomeCorrect)
+	case domain.AnswerRejected:
+		if e.LockedOut {
+			m.verify(VerifyOutcomeLockedOut)
+		} else {
+			m.verify(VerifyOutcomeWrong)
+		}
+	case domain.ComboObstructionFired:
+		m.obstructionFired(e.Effect)
+	case domain.ItemUsed:
+		m.itemUsed(e.Item, e.Outcome)
+	case domain.PlayerLeft:
+		m.matchAbandoned(e.PlayerID)
+	case domain.RoomExpired:
+		for _, playerID := range e.PlayerIDs {
+			m.matchAbandoned(playerID)
+		}
+	case domain.GameFinished:
+		if e.Reason == domain.FinishReasonIdle {
+			for _, playerID := range e.PlayerIDs {
+				m.matchAbandoned(playerID)
+			}
+		}
+	}
+}
+
+func (m *Metrics) verify(outcome string) {
+	if m == nil {
+		return
+	}
+	m.verifies.Inc(outcome)
+}
+
+func (m *Metrics) obstructionFired(effect string) {
+	if m == nil {
+		return
+	}
+	m.obstructions.Inc(effect)
+}
+
+func (m *Metrics) itemUsed(item string, outcome string) {
+	if m == nil {
+		return
+	}
+	m.itemsUsed.Inc(item, outcome)
+}
+
+func (m *Metrics) sendQueueFullDisconnect() {
+	if m == nil {
+		return
+	}
+	m.sendQueueFull.Inc()
+}
+
+func (m *Metrics) gracePeriodExpired() {
+	if m == nil {
+		return
+	}
+	m.graceExpiries.Inc()
+}
+
+func (m *Metrics) messageRateLimited(msgType string) {
+	if m == nil {
+		return
+	}
+	m.rateLimited.Inc(msgType)
+}
+
+// matchQueued はプレイヤーがルームに参加した時刻を記録する
+func (m *Metrics) matchQueued(playerID string, mode string, now time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.waitStart[playerID]; !ok {
+		m.waitStart[playerID] = matchWaitEntry{mode: mode, at: now}
+	}
+}
+
+// matchStarted はゲーム開始までの待ち時間を記録する
+func (m *Metrics) matchStarted(playerID string, now time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	entry, ok := m.waitStart[playerID]
+	delete(m.waitStart, playerID)
+	m.mu.Unlock()
+	if ok {
+		m.matchWait.Observe(now.Sub(entry.at).Seconds(), entry.mode)
+	}
+}
+
+// roomReaped は放置で片付けたルームを理由別に数える
+func (m *Metrics) roomReaped(reason string) {
+	if m == nil {
+		return
+	}
+	m.roomsReaped.Inc(reason)
+}
+
+// matchAbandoned はゲーム開始前に退出したプレイヤーの待ち時間を破棄する
+func (m *Metrics) matchAbandoned(playerID string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.waitStart, playerID)
+}
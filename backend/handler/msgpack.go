@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// MessagePack の最小実装
+// Message の符号化に必要な型（nil / bool / 数値 / 文字列 / 配列 / 文字列キーのマップ）のみ扱う
+
+func writeMsgpack(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case string:
+		writeMsgpackString(buf, v)
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			writeMsgpackInt(buf, i)
+		} else if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			writeMsgpackUint(buf, u)
+		} else if f, err := v.Float64(); err == nil {
+			writeMsgpackFloat(buf, f)
+		} else {
+			return fmt.Errorf("msgpack: invalid number %q", v)
+		}
+	case int:
+		writeMsgpackInt(buf, int64(v))
+	case int64:
+		writeMsgpackInt(buf, v)
+	case uint64:
+		writeMsgpackUint(buf, v)
+	case float64:
+		writeMsgpackFloat(buf, v)
+	case []interface{}:
+		writeMsgpackHeader(buf, len(v), 0x90, 16, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := writeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeMsgpackHeader(buf, len(keys), 0x80, 16, 0xde, 0xdf)
+		for _, k := range keys {
+			writeMsgpackString(buf, k)
+			if err := writeMsgpack(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+	return nil
+}
+
+// writeMsgpackHeader は配列・マップの要素数ヘッダーを書き込む
+func writeMsgpackHeader(buf *bytes.Buffer, n int, fixBase byte, fixLimit int, code16 byte, code32 byte) {
+	switch {
+	case n < fixLimit:
+		buf.WriteByte(fixBase | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+func writeMsgpackInt(buf *bytes.Buffer, i int64) {
+	if i >= 0 {
+		writeMsgpackUint(buf, uint64(i))
+		return
+	}
+	switch {
+	case i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		_ = binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		_ = binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+func writeMsgpackUint(buf *bytes.Buffer, u uint64) {
+	switch {
+	case u <= 0x7f:
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		_ = binary.Write(buf, binary.BigEndian, uint16(u))
+	case u <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		_ = binary.Write(buf, binary.BigEndian, uint32(u))
+	default:
+		buf.WriteByte(0xcf)
+		_ = binary.Write(buf, binary.BigEndian, u)
+	}
+}
+
+func writeMsgpackFloat(buf *bytes.Buffer, f float64) {
+	buf.WriteByte(0xcb)
+	_ = binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+}
+
+var errMsgpackShort = fmt.Errorf("msgpack: unexpected end of data")
+
+// readMsgpack は先頭の値を1つ読み取り、残りのバイト列を返す
+// 整数は int64（int64 に収まらない場合は uint64）、浮動小数は float64 として返す
+func readMsgpack(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errMsgpackShort
+	}
+	code, rest := data[0], data[1:]
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), rest, nil
+	case code >= 0xe0:
+		return int64(int8(code)), rest, nil
+	case code&0xe0 == 0xa0:
+		return readMsgpackString(rest, int(code&0x1f))
+	case code&0xf0 == 0x90:
+		return readMsgpackArray(rest, int(code&0x0f))
+	case code&0xf0 == 0x80:
+		return readMsgpackMap(rest, int(code&0x0f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, rest, nil
+	case 0xc2:
+		return false, rest, nil
+	case 0xc3:
+		return true, rest, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		size := 1 << (code - 0xcc)
+		u, rest, err := readMsgpackUint(rest, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		if u <= math.MaxInt64 {
+			return int64(u), rest, nil
+		}
+		return u, rest, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (code - 0xd0)
+		u, rest, err := readMsgpackUint(rest, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		shift := uint(64 - 8*size)
+		return int64(u<<shift) >> shift, rest, nil
+	case 0xca:
+		u, rest, err := readMsgpackUint(rest, 4)
+		if err != nil {
+			return nil, nil, err
+		}
+		return float64(math.Float32frombits(uint32(u))), rest, nil
+	case 0xcb:
+		u, rest, err := readMsgpackUint(rest, 8)
+		if err != nil {
+			return nil, nil, err
+		}
+		return math.Float64frombits(u), rest, nil
+	case 0xd9, 0xda, 0xdb, 0xc4, 0xc5, 0xc6:
+		// bin 型も文字列として扱う
+		sizes := map[byte]int{0xd9: 1, 0xda: 2, 0xdb: 4, 0xc4: 1, 0xc5: 2, 0xc6: 4}
+		n, rest, err := readMsgpackUint(rest, sizes[code])
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgpackString(rest, int(n))
+	case 0xdc, 0xdd:
+		n, rest, err := readMsgpackUint(rest, 2<<(code-0xdc))
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgpackArray(rest, int(n))
+	case 0xde, 0xdf:
+		n, rest, err := readMsgpackUint(rest, 2<<(code-0xde))
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgpackMap(rest, int(n))
+	}
+	return nil, nil, fmt.Errorf("msgpack: unsupported type code 0x%02x", code)
+}
+
+func readMsgpackUint(data []byte, size int) (uint64, []byte, error) {
+	if len(data) < size {
+		return 0, nil, errMsgpackShort
+	}
+	var u uint64
+	for _, b := range data[:size] {
+		u = u<<8 | uint64(b)
+	}
+	return u, data[size:], nil
+}
+
+func readMsgpackString(data []byte, n int) (interface{}, []byte, error) {
+	if n < 0 || len(data) < n {
+		return nil, nil, errMsgpackShort
+	}
+	return string(data[:n]), data[n:], nil
+}
+
+func readMsgpackArray(data []byte, n int) (interface{}, []byte, error) {
+	// 要素は最低1バイトなので、残りより多い要素数は不正
+	if n < 0 || n > len(data) {
+		return nil, nil, errMsgpackShort
+	}
+	items := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		item, rest, err := readMsgpack(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, item)
+		data = rest
+	}
+	return items, data, nil
+}
+
+func readMsgpackMap(data []byte, n int) (interface{}, []byte, error) {
+	if n < 0 || 2*n > len(data) {
+		return nil, nil, errMsgpackShort
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, rest, err := readMsgpack(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		k, ok := key.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("msgpack: map key must be a string")
+		}
+		value, rest, err := readMsgpack(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		m[k] = value
+		data = rest
+	}
+	return m, data, nil
+}
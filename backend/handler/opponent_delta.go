@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"sync"
+
+	"recaptchgame-backend/protocol"
+)
+
+// opponentViewTracker は各クライアントが最後に受け取った相手状態を記録し、変化したフィールドだけを送れるようにする
+// keyframeInterval 回の送信ごと、または要求があったときは全状態（キーフレーム）を送る
+type opponentViewTracker struct {
+	mu               sync.Mutex
+	keyframeInterval int
+	views            map[string]*opponentView // clientID -> 最後に送った相手状態
+}
+
+type opponentView struct {
+	opponents     map[string]protocol.BROpponentPayload
+	sinceKeyframe int
+}
+
+func newOpponentViewTracker(keyframeInterval int) *opponentViewTracker {
+	if keyframeInterval <= 0 {
+		keyframeInterval = 20
+	}
+	return &opponentViewTracker{
+		keyframeInterval: keyframeInterval,
+		views:            make(map[string]*opponentView),
+	}
+}
+
+// diff は前回送った状態との差分を返し、今回の状態を記録する
+func (t *opponentViewTracker) diff(clientID string, snapshots []protocol.BROpponentPayload) protocol.OpponentDeltaPayload {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	view, ok := t.views[clientID]
+	keyframe := !ok || view.sinceKeyframe >= t.keyframeInterval
+	if keyframe {
+		view = &opponentView{}
+		t.views[clientID] = view
+	}
+
+	delta := protocol.OpponentDeltaPayload{Keyframe: keyframe, Opponents: make([]protocol.BROpponentDelta, 0, len(snapshots))}
+	current := make(map[string]protocol.BROpponentPayload, len(snapshots))
+	for _, snap := range snapshots {
+		current[snap.PlayerID] = snap
+		prev, seen := view.opponents[snap.PlayerID]
+		if change, changed := diffOpponent(prev, snap, keyframe || !seen); changed {
+			delta.Opponents = append(delta.Opponents, change)
+		}
+	}
+	if !keyframe {
+		for playerID := range view.opponents {
+			if _, ok := current[playerID]; !ok {
+				delta.Removed = append(delta.Removed, playerID)
+			}
+		}
+	}
+	view.sinceKeyframe++
+	view.opponents = current
+	return delta
+}
+
+// observe はクライアントにスナップショットを送ったことを記録し、以降の差分の基準にする
+func (t *opponentViewTracker) observe(clientID string, snapshots []protocol.BROpponentPayload) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	view := &opponentView{opponents: make(map[string]protocol.BROpponentPayload, len(snapshots))}
+	for _, snap := range snapshots {
+		view.opponents[snap.PlayerID] = snap
+	}
+	t.views[clientID] = view
+}
+
+// reset は次回の送信をキーフレームにする
+func (t *opponentViewTracker) reset(clientID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.views, clientID)
+}
+
+// diffOpponent は1人分の差分を作る。full の場合は全フィールドを含める
+// 画像リストは出題が変わったときだけ含める
+func diffOpponent(prev protocol.BROpponentPayload, next protocol.BROpponentPayload, full bool) (protocol.BROpponentDelta, bool) {
+	change := protocol.BROpponentDelta{PlayerID: next.PlayerID}
+	changed := full
+	if full || prev.Score != next.Score {
+		score := next.Score
+		change.Score = &score
+		changed = true
+	}
+	if full || prev.Combo != next.Combo {
+		combo := next.Combo
+		change.Combo = &combo
+		changed = true
+	}
+	if full || prev.Effect != next.Effect {
+		effect := next.Effect
+		change.Effect = &effect
+		changed = true
+	}
+	if full || prev.Target != next.Target || !sameImages(prev.Images, next.Images) {
+		target := next.Target
+		change.Target = &target
+		change.Images = append([]string{}, next.Images...)
+		changed = true
+	}
+	return change, changed
+}
+
+func sameImages(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// excludePlayer はスナップショット一覧から指定プレイヤーを除いた一覧を返す
+func excludePlayer(snapshots []protocol.BROpponentPayload, playerID string) []protocol.BROpponentPayload {
+	result := make([]protocol.BROpponentPayload, 0, len(snapshots))
+	for _, snap := range snapshots {
+		if snap.PlayerID != playerID {
+			result = append(result, snap)
+		}
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"testing"
+
+	"recaptchgame-backend/protocol"
+)
+
+// TestOpponentViewTracker は相手状態の差分計算のテスト
+func TestOpponentViewTracker(t *testing.T) {
+	tracker := newOpponentViewTracker(3)
+	snaps := []protocol.BROpponentPayload{
+		{PlayerID: "player2", Target: "車", Images: []string{"car_1", "kaidan_0"}, Score: 1},
+		{PlayerID: "player3", Target: "階段", Images: []string{"kaidan_1"}, Score: 0},
+	}
+
+	// テスト1: 初回はキーフレーム
+	first := tracker.diff("client1", snaps)
+	if !first.Keyframe || len(first.Opponents) != 2 {
+		t.Fatalf("expected keyframe with 2 opponents, got %+v", first)
+	}
+	if first.Opponents[0].Images == nil || first.Opponents[0].Score == nil {
+		t.Errorf("expected keyframe to include all fields")
+	}
+
+	// テスト2: スコアだけ変わった場合は画像を含めない
+	snaps[0].Score = 2
+	second := tracker.diff("client1", snaps)
+	if second.Keyframe || len(second.Opponents) != 1 {
+		t.Fatalf("expected delta with 1 opponent, got %+v", second)
+	}
+	change := second.Opponents[0]
+	if change.PlayerID != "player2" || change.Score == nil || *change.Score != 2 {
+		t.Errorf("expected score delta for player2, got %+v", change)
+	}
+	if change.Images != nil || change.Target != nil || change.Combo != nil {
+		t.Errorf("expected unchanged fields to be omitted, got %+v", change)
+	}
+
+	// テスト3: 出題が変わったら画像を含め、抜けたプレイヤーは removed に入る
+	third := tracker.diff("client1", []protocol.BROpponentPayload{
+		{PlayerID: "player2", Target: "消火栓", Images: []string{"shoukasen_0"}, Score: 2},
+	})
+	if len(third.Opponents) != 1 || third.Opponents[0].Images == nil || *third.Opponents[0].Target != "消火栓" {
+		t.Errorf("expected new problem in delta, got %+v", third)
+	}
+	if len(third.Removed) != 1 || third.Removed[0] != "player3" {
+		t.Errorf("expected player3 to be removed, got %v", third.Removed)
+	}
+
+	// テスト4: 一定回数ごとにキーフレーム
+	if fourth := tracker.diff("client1", snaps); !fourth.Keyframe {
+		t.Errorf("expected periodic keyframe")
+	}
+
+	// テスト5: reset 後はキーフレーム、observe 後は差分
+	tracker.reset("client1")
+	if fifth := tracker.diff("client1", snaps); !fifth.Keyframe {
+		t.Errorf("expected keyframe after reset")
+	}
+	tracker.observe("client2", snaps)
+	if sixth := tracker.diff("client2", snaps); sixth.Keyframe || len(sixth.Opponents) != 0 {
+		t.Errorf("expected empty delta after observe, got %+v", sixth)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+
+	"recaptchgame-backend/protocol"
+)
+
+const (
+	// ProtocolVersionLegacy は HELLO を送らない既存クライアントとみなすバージョン
+	// 現行フロントエンドが読むペイロード形式（相手情報の互換フィールドを含む）をそのまま送る
+	ProtocolVersionLegacy = 1
+	// ProtocolVersionCurrent はサーバーが話す最新のプロトコルバージョン
+	// br_opponents と重複する互換フィールドを省いて送る
+	ProtocolVersionCurrent = 2
+	// MinProtocolVersion はサーバーが受け付ける最小のプロトコルバージョン
+	MinProtocolVersion = ProtocolVersionLegacy
+)
+
+// serverCapabilities はサーバーが対応している拡張機能
+var serverCapabilities = []string{"ack", "resume", "delta"}
+
+// payloadEncoder はペイロードを特定のプロトコルバージョンの形式に変換する
+type payloadEncoder func(payload json.RawMessage) (json.RawMessage, error)
+
+// payloadEncoders はプロトコルバージョンごとのメッセージ種別別エンコーダー
+// 登録されていない種別は内部形式のまま送る
+var payloadEncoders = map[int]map[string]payloadEncoder{
+	ProtocolVersionLegacy: {},
+	ProtocolVersionCurrent: {
+		protocol.TypeGameStart:      dropPayloadFields("opponent_images", "opponent_current_score"),
+		protocol.TypeOpponentUpdate: dropPayloadFields("images", "score", "combo"),
+	},
+}
+
+// encodeForVersion はメッセージを接続先のプロトコルバージョンに合わせて変換する
+func encodeForVersion(version int, msg protocol.Message) protocol.Message {
+	encoders, ok := payloadEncoders[version]
+	if !ok {
+		return msg
+	}
+	encode, ok := encoders[msg.Type]
+	if !ok {
+		return msg
+	}
+	payload, err := encode(msg.Payload)
+	if err != nil {
+		return msg
+	}
+	msg.Payload = payload
+	return msg
+}
+
+// dropPayloadFields は指定したフィールドをペイロードから取り除くエンコーダーを返す
+func dropPayloadFields(fields ...string) payloadEncoder {
+	return func(payload json.RawMessage) (json.RawMessage, error) {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &obj); err != nil {
+			return nil, err
+		}
+		for _, field := range fields {
+			delete(obj, field)
+		}
+		return json.Marshal(obj)
+	}
+}
+
+// negotiateProtocolVersion はクライアントの希望バージョンから使用するバージョンを決める
+// 最小バージョン未満の場合は false を返す（UPGRADE_REQUIRED）
+func negotiateProtocolVersion(requested int) (int, bool) {
+	if requested < MinProtocolVersion {
+		return 0, false
+	}
+	if requested > ProtocolVersionCurrent {
+		return ProtocolVersionCurrent, true
+	}
+	return requested, true
+}
+
+// negotiateCapabilities はクライアントとサーバーの双方が対応している拡張機能を返す
+func negotiateCapabilities(requested []string) []string {
+	supported := make(map[string]bool, len(serverCapabilities))
+	for _, c := range serverCapabilities {
+		supported[c] = true
+	}
+	agreed := make([]string, 0, len(requested))
+	for _, c := range requested {
+		if supported[c] {
+			agreed = append(agreed, c)
+			delete(supported, c)
+		}
+	}
+	return agreed
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"recaptchgame-backend/protocol"
+)
+
+// TestNegotiateProtocolVersion はバージョンネゴシエーションのテスト
+func TestNegotiateProtocolVersion(t *testing.T) {
+	cases := []struct {
+		requested int
+		expected  int
+		ok        bool
+	}{
+		{requested: 0, ok: false},
+		{requested: ProtocolVersionLegacy, expected: ProtocolVersionLegacy, ok: true},
+		{requested: ProtocolVersionCurrent, expected: ProtocolVersionCurrent, ok: true},
+		{requested: ProtocolVersionCurrent + 5, expected: ProtocolVersionCurrent, ok: true},
+	}
+	for _, c := range cases {
+		version, ok := negotiateProtocolVersion(c.requested)
+		if ok != c.ok || version != c.expected {
+			t.Errorf("requested %d: expected (%d, %v), got (%d, %v)", c.requested, c.expected, c.ok, version, ok)
+		}
+	}
+
+	agreed := negotiateCapabilities([]string{"resume", "unknown", "resume"})
+	if len(agreed) != 1 || agreed[0] != "resume" {
+		t.Errorf("expected only resume to be agreed, got %v", agreed)
+	}
+}
+
+// TestEncodeForVersion はバージョン別エンコーダーのテスト
+func TestEncodeForVersion(t *testing.T) {
+	b, _ := json.Marshal(protocol.GameStartPayload{
+		Target:         "車",
+		Images:         []string{"car_1"},
+		OpponentImages: []string{"kaidan_0"},
+		WinningScore:   5,
+	})
+	msg := protocol.Message{Type: protocol.TypeGameStart, Payload: b}
+
+	legacy := encodeForVersion(ProtocolVersionLegacy, msg)
+	var legacyPayload map[string]json.RawMessage
+	_ = json.Unmarshal(legacy.Payload, &legacyPayload)
+	if _, ok := legacyPayload["opponent_images"]; !ok {
+		t.Errorf("expected legacy payload to keep opponent_images")
+	}
+
+	current := encodeForVersion(ProtocolVersionCurrent, msg)
+	var currentPayload map[string]json.RawMessage
+	_ = json.Unmarshal(current.Payload, &currentPayload)
+	if _, ok := currentPayload["opponent_images"]; ok {
+		t.Errorf("expected current payload to drop opponent_images")
+	}
+	if _, ok := currentPayload["images"]; !ok {
+		t.Errorf("expected current payload to keep images")
+	}
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"recaptchgame-backend/config"
+	"recaptchgame-backend/protocol"
+)
+
+// RateLimit はトークンバケットの設定
+// Rate は1秒あたりの補充トークン数、Burst はバケットの容量
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitPolicy は受信メッセージのレート制限ポリシー
+// メッセージ種別ごとにクライアント単位とIP単位のバケットを持ち、未定義の種別は Default を使う
+// ViolationWindow 内に MaxViolations 回制限を超えたクライアントは切断する
+type RateLimitPolicy struct {
+	PerClient        map[string]RateLimit
+	PerIP            map[string]RateLimit
+	DefaultPerClient RateLimit
+	DefaultPerIP     RateLimit
+	MaxViolations    int
+	ViolationWindow  time.Duration
+}
+
+// DefaultRateLimitPolicy は通常のプレイでは引っかからない程度の既定ポリシー
+// VERIFY は総当たり（9枚の部分集合 512 通り）を現実的でない時間まで引き延ばす値にしている
+func DefaultRateLimitPolicy() RateLimitPolicy {
+	return RateLimitPolicy{
+		PerClient: map[string]RateLimit{
+			protocol.TypeVerify:          {Rate: 1, Burst: 3},
+			protocol.TypeSelectImage:     {Rate: 10, Burst: 20},
+			protocol.TypeJoinRoom:        {Rate: 1, Burst: 5},
+			protocol.TypeResume:          {Rate: 1, Burst: 5},
+			protocol.TypeLeaveRoom:       {Rate: 1, Burst: 5},
+			protocol.TypeHello:           {Rate: 1, Burst: 3},
+			protocol.TypeRequestKeyframe: {Rate: 1, Burst: 3},
+			protocol.TypeUseItem:         {Rate: 1, Burst: 3},
+			protocol.TypeSetTargeting:    {Rate: 1, Burst: 5},
+			protocol.TypeSelectTarget:    {Rate: 1, Burst: 3},
+		},
+		PerIP: map[string]RateLimit{
+			protocol.TypeVerify:      {Rate: 5, Burst: 15},
+			protocol.TypeSelectImage: {Rate: 50, Burst: 100},
+			protocol.TypeJoinRoom:    {Rate: 5, Burst: 20},
+		},
+		DefaultPerClient: rateLimitFromSpec(config.DefaultRateLimitPerClient),
+		DefaultPerIP:     rateLimitFromSpec(config.DefaultRateLimitPerIP),
+		MaxViolations:    config.DefaultMaxViolations,
+		ViolationWindow:  config.DefaultViolationWindow,
+	}
+}
+
+// RateLimitPolicyFromConfig は設定ファイル・環境変数のレート制限に変換する
+// 種別ごとの制限は既定のポリシーに重ね、設定にない種別は既定の制限のままにする
+func RateLimitPolicyFromConfig(cfg *config.Config) RateLimitPolicy {
+	policy := DefaultRateLimitPolicy()
+	for msgType, spec := range cfg.RateLimit.PerClient {
+		policy.PerClient[msgType] = rateLimitFromSpec(spec)
+	}
+	for msgType, spec := range cfg.RateLimit.PerIP {
+		policy.PerIP[msgType] = rateLimitFromSpec(spec)
+	}
+	policy.DefaultPerClient = rateLimitFromSpec(cfg.RateLimit.DefaultPerClient)
+	policy.DefaultPerIP = rateLimitFromSpec(cfg.RateLimit.DefaultPerIP)
+	policy.MaxViolations = cfg.RateLimit.MaxViolations
+	policy.ViolationWindow = cfg.RateLimit.ViolationWindow.Std()
+	return policy
+}
+
+func rateLimitFromSpec(spec config.RateLimitSpec) RateLimit {
+	return RateLimit{Rate: spec.Rate, Burst: spec.Burst}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take は経過時間分のトークンを補充してから1つ消費する
+// 足りない場合は次のトークンが貯まるまでの時間を返す
+func (b *tokenBucket) take(limit RateLimit, now time.Time) (bool, time.Duration) {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		return true, 0
+	}
+	b.tokens += now.Sub(b.last).Seconds() * limit.Rate
+	if b.tokens > float64(limit.Burst) {
+		b.tokens = float64(limit.Burst)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+type bucketKey struct {
+	owner   string
+	msgType string
+}
+
+// rateLimiter はクライアント単位・IP単位のトークンバケットを管理する
+// IP単位のバケットはそのIPからの接続がなくなった時点で破棄する
+type rateLimiter struct {
+	mu         sync.Mutex
+	policy     RateLimitPolicy
+	clients    map[bucketKey]*tokenBucket
+	ips        map[bucketKey]*tokenBucket
+	clientIP   map[string]string
+	ipConns    map[string]int
+	violations map[string][]time.Time
+}
+
+func newRateLimiter(policy RateLimitPolicy) *rateLimiter {
+	return &rateLimiter{
+		policy:     policy,
+		clients:    make(map[bucketKey]*tokenBucket),
+		ips:        make(map[bucketKey]*tokenBucket),
+		clientIP:   make(map[string]string),
+		ipConns:    make(map[string]int),
+		violations: make(map[string][]time.Time),
+	}
+}
+
+// addClient は接続をIPに紐付ける
+func (l *rateLimiter) addClient(clientID string, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clientIP[clientID] = ip
+	l.ipConns[ip]++
+}
+
+// clientAddress は接続に紐付けたIPを返す
+func (l *rateLimiter) clientAddress(clientID string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ip, ok := l.clientIP[clientID]
+	return ip, ok
+}
+
+// removeClient は接続のバケットを破棄する
+func (l *rateLimiter) removeClient(clientID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.clients {
+		if key.owner == clientID {
+			delete(l.clients, key)
+		}
+	}
+	delete(l.violations, clientID)
+
+	ip, ok := l.clientIP[clientID]
+	if !ok {
+		return
+	}
+	delete(l.clientIP, clientID)
+	l.ipConns[ip]--
+	if l.ipConns[ip] > 0 {
+		return
+	}
+	delete(l.ipConns, ip)
+	for key := range l.ips {
+		if key.owner == ip {
+			delete(l.ips, key)
+		}
+	}
+}
+
+// allow はメッセージを処理してよいかを判定する
+// 制限を超えた場合は再試行までの待ち時間を返す
+func (l *rateLimiter) allow(clientID string, msgType string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	clientLimit, ok := l.policy.PerClient[msgType]
+	if !ok {
+		clientLimit = l.policy.DefaultPerClient
+	}
+	if allowed, wait := l.bucket(l.clients, bucketKey{clientID, msgType}, clientLimit, now).take(clientLimit, now); !allowed {
+		return false, wait
+	}
+
+	ip, ok := l.clientIP[clientID]
+	if !ok {
+		return true, 0
+	}
+	ipLimit, ok := l.policy.PerIP[msgType]
+	if !ok {
+		ipLimit = l.policy.DefaultPerIP
+	}
+	return l.bucket(l.ips, bucketKey{ip, msgType}, ipLimit, now).take(ipLimit, now)
+}
+
+func (l *rateLimiter) bucket(buckets map[bucketKey]*tokenBucket, key bucketKey, limit RateLimit, now time.Time) *tokenBucket {
+	b, ok := buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		buckets[key] = b
+	}
+	return b
+}
+
+// recordViolation は制限超過を記録し、継続的な乱用として切断すべきなら true を返す
+func (l *rateLimiter) recordViolation(clientID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.policy.MaxViolations <= 0 {
+		return false
+	}
+
+	recent := l.violations[clientID][:0]
+	for _, t := range l.violations[clientID] {
+		if now.Sub(t) < l.policy.ViolationWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	l.violations[clientID] = recent
+	return len(recent) >= l.policy.MaxViolations
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"recaptchgame-backend/config"
+	"recaptchgame-backend/protocol"
+)
+
+// TestRateLimiter はトークンバケットによるレート制限のテスト
+func TestRateLimiter(t *testing.T) {
+	policy := RateLimitPolicy{
+		PerClient:        map[string]RateLimit{protocol.TypeVerify: {Rate: 1, Burst: 2}},
+		PerIP:            map[string]RateLimit{protocol.TypeVerify: {Rate: 1, Burst: 3}},
+		DefaultPerClient: RateLimit{Rate: 10, Burst: 10},
+		DefaultPerIP:     RateLimit{Rate: 10, Burst: 10},
+		MaxViolations:    3,
+		ViolationWindow:  10 * time.Second,
+	}
+	limiter := newRateLimiter(policy)
+	limiter.addClient("client1", "10.0.0.1")
+	limiter.addClient("client2", "10.0.0.1")
+	now := time.Now()
+
+	// テスト1: バースト分は通り、超過すると待ち時間が返る
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow("client1", protocol.TypeVerify, now); !ok {
+			t.Fatalf("expected message %d to be allowed", i)
+		}
+	}
+	ok, wait := limiter.allow("client1", protocol.TypeVerify, now)
+	if ok || wait <= 0 || wait > time.Second {
+		t.Errorf("expected rate limit with wait <= 1s, got ok=%v wait=%v", ok, wait)
+	}
+
+	// テスト2: 種別ごとに独立している
+	if ok, _ := limiter.allow("client1", protocol.TypeSelectImage, now); !ok {
+		t.Errorf("expected other message types to be unaffected")
+	}
+
+	// テスト3: 同じIPの別接続はIP単位のバケットを共有する
+	if ok, _ := limiter.allow("client2", protocol.TypeVerify, now); !ok {
+		t.Errorf("expected client2 to use the remaining IP token")
+	}
+	if ok, _ := limiter.allow("client2", protocol.TypeVerify, now); ok {
+		t.Errorf("expected IP bucket to be exhausted")
+	}
+
+	// テスト4: 時間経過で補充される
+	if ok, _ := limiter.allow("client1", protocol.TypeVerify, now.Add(1100*time.Millisecond)); !ok {
+		t.Errorf("expected token to be refilled after 1s")
+	}
+
+	// テスト5: 継続的な超過で切断判定
+	if limiter.recordViolation("client1", now) || limiter.recordViolation("client1", now) {
+		t.Errorf("expected no disconnect before MaxViolations")
+	}
+	if !limiter.recordViolation("client1", now) {
+		t.Errorf("expected disconnect at MaxViolations")
+	}
+	if limiter.recordViolation("client2", now.Add(-20*time.Second)) || limiter.recordViolation("client2", now) {
+		t.Errorf("expected violations outside the window to be ignored")
+	}
+
+	// テスト6: 接続がなくなるとIPのバケットも破棄される
+	limiter.removeClient("client1")
+	limiter.removeClient("client2")
+	if len(limiter.clients) != 0 || len(limiter.ips) != 0 || len(limiter.ipConns) != 0 {
+		t.Errorf("expected all buckets to be released, got %d/%d/%d", len(limiter.clients), len(limiter.ips), len(limiter.ipConns))
+	}
+}
+
+// TestRateLimitPolicyFromConfig は設定のレート制限が既定のポリシーに重なることのテスト
+func TestRateLimitPolicyFromConfig(t *testing.T) {
+	// テスト1: 既定の設定なら既定のポリシーと同じ
+	policy := RateLimitPolicyFromConfig(config.Default())
+	defaults := DefaultRateLimitPolicy()
+	if policy.DefaultPerClient != defaults.DefaultPerClient || policy.MaxViolations != defaults.MaxViolations || policy.PerClient[protocol.TypeVerify] != defaults.PerClient[protocol.TypeVerify] {
+		t.Errorf("expected default config to match the default policy, got %+v", policy)
+	}
+
+	// テスト2: 指定した種別としきい値だけを上書きする
+	cfg := config.Default()
+	cfg.RateLimit.PerClient = map[string]config.RateLimitSpec{protocol.TypeVerify: {Rate: 2, Burst: 6}}
+	cfg.RateLimit.MaxViolations = 5
+	policy = RateLimitPolicyFromConfig(cfg)
+	if policy.PerClient[protocol.TypeVerify] != (RateLimit{Rate: 2, Burst: 6}) || policy.MaxViolations != 5 {
+		t.Errorf("expected configured limits to override the defaults, got %+v", policy)
+	}
+	if policy.PerClient[protocol.TypeSelectImage] != defaults.PerClient[protocol.TypeSelectImage] || policy.PerIP[protocol.TypeVerify] != defaults.PerIP[protocol.TypeVerify] {
+		t.Errorf("expected unconfigured message types to keep the default limits, got %+v", policy)
+	}
+}
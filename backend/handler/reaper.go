@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"recaptchgame-backend/config"
+	"recaptchgame-backend/usecase"
+)
+
+// DefaultReaperInterval は放置されたルームを探す既定の間隔
+const DefaultReaperInterval = config.DefaultReaperInterval
+
+const (
+	reaperWaitingExpiredReason = "Room expired due to inactivity"
+	reaperIdleMessage          = "Ended due to inactivity"
+)
+
+// RoomReaper は一定間隔で放置されたルームを片付け、接続中のクライアントに通知する
+type RoomReaper struct {
+	wsHandler   *WebSocketHandler
+	reapRoomsUC *usecase.ReapRoomsUseCase
+	interval    time.Duration
+	metrics     *Metrics
+}
+
+// NewRoomReaper は新しいRoomReaperを生成
+// interval が 0 以下なら DefaultReaperInterval を使う
+func NewRoomReaper(wsHandler *WebSocketHandler, reapRoomsUC *usecase.ReapRoomsUseCase, interval time.Duration, metrics *Metrics) *RoomReaper {
+	if interval <= 0 {
+		interval = DefaultReaperInterval
+	}
+	return &RoomReaper{
+		wsHandler:   wsHandler,
+		reapRoomsUC: reapRoomsUC,
+		interval:    interval,
+		metrics:     metrics,
+	}
+}
+
+// Run は ctx が終わるまで interval ごとに Sweep を実行する
+func (r *RoomReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.Sweep(now)
+		}
+	}
+}
+
+// Sweep は期限切れのルームを片付け、片付けたルームを返す
+// クライアントへの通知（参加者待ちのルームには KICKED、対戦中のルームには首位を勝者とする GAME_FINISHED）は
+// ユースケースが発行する RoomExpired / GameFinished の購読で行う
+func (r *RoomReaper) Sweep(now time.Time) []usecase.ReapedRoom {
+	h := r.wsHandler
+	output, err := r.reapRoomsUC.Execute(now)
+	if err != nil {
+		h.logger.Error("room reaper failed", "error", err)
+	}
+	if output == nil {
+		return nil
+	}
+
+	for _, reaped := range output.Reaped {
+		r.metrics.roomReaped(reaped.Reason)
+	}
+	if len(output.Reaped) > 0 || output.ClearedWaitingSlot > 0 {
+		h.logger.Info("room reaper sweep", "reaped", len(output.Reaped), "cleared_waiting_slots", output.ClearedWaitingSlot)
+	}
+	return output.Reaped
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/infrastructure"
+	"recaptchgame-backend/matchmaker"
+	"recaptchgame-backend/usecase"
+)
+
+// TestRoomReaper は放置されたルームの片付けのテスト
+func TestRoomReaper(t *testing.T) {
+	env := newTestHandlerEnv()
+	roomRepo := env.roomRepo
+	reapRoomsUC := usecase.NewReapRoomsUseCase(roomRepo, matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), 0), usecase.NewRoomExecutionGuard(), domain.DefaultRoomExpiryPolicy(), env.eventBus, nil)
+	reaper := NewRoomReaper(env.wsHandler, reapRoomsUC, 0, nil)
+	now := time.Now()
+
+	waiting := domain.NewRoom("room1", "player1", "", 5, 2)
+	waiting.Touch(now.Add(-time.Hour))
+	roomRepo.Save(waiting)
+	roomRepo.SetWaitingRoom(2, waiting)
+
+	idle := domain.NewRoom("room2", "player2", "player3", 5, 2)
+	idle.Start()
+	idle.Player2.Score = 1
+	idle.Touch(now.Add(-time.Hour))
+	roomRepo.Save(idle)
+
+	// テスト1: 期限切れのルームを片付け、待機ルームの枠も空ける
+	reaped := reaper.Sweep(now)
+	if len(reaped) != 2 {
+		t.Fatalf("expected 2 reaped rooms, got %+v", reaped)
+	}
+	if rooms, _ := roomRepo.ListAll(); len(rooms) != 0 {
+		t.Errorf("expected all rooms to be removed, got %d", len(rooms))
+	}
+	if w, _ := roomRepo.GetWaitingRoom(2); w != nil {
+		t.Errorf("expected waiting slot to be cleared")
+	}
+
+	// テスト2: 片付けるものがなければ何もしない
+	if reaped := reaper.Sweep(now); len(reaped) != 0 {
+		t.Errorf("expected nothing to reap, got %+v", reaped)
+	}
+
+	// テスト3: Run は ctx が終わると戻る
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reaper.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("expected Run to return after cancel")
+	}
+}
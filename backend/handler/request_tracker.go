@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"sync"
+
+	"recaptchgame-backend/protocol"
+)
+
+// requestTracker はクライアント指定のメッセージIDと、そのIDへの応答を記録する
+// 再送による二重処理を防ぎ、応答を受け取れなかったクライアントに同じ応答を送り直す
+// キーごとに直近 limit 件のIDのみを保持する
+type requestTracker struct {
+	mu    sync.Mutex
+	seen  map[string]*recentRequestIDs
+	limit int
+}
+
+type recentRequestIDs struct {
+	order   []string
+	replies map[string][]protocol.Message // ID -> 送った応答（送った順）
+}
+
+func newRequestTracker(limit int) *requestTracker {
+	if limit <= 0 {
+		limit = 64
+	}
+	return &requestTracker{
+		seen:  make(map[string]*recentRequestIDs),
+		limit: limit,
+	}
+}
+
+// markSeen はIDを記録し、既に処理済みであれば true とその時に送った応答を返す
+func (t *requestTracker) markSeen(key string, requestID string) ([]protocol.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recent, ok := t.seen[key]
+	if !ok {
+		recent = &recentRequestIDs{replies: make(map[string][]protocol.Message)}
+		t.seen[key] = recent
+	}
+	if replies, ok := recent.replies[requestID]; ok {
+		return append([]protocol.Message(nil), replies...), true
+	}
+
+	recent.replies[requestID] = nil
+	recent.order = append(recent.order, requestID)
+	if len(recent.order) > t.limit {
+		oldest := recent.order[0]
+		recent.order = recent.order[1:]
+		delete(recent.replies, oldest)
+	}
+	return nil, false
+}
+
+// recordReply は記録済みのIDへの応答を覚える（記録していないIDは無視する）
+func (t *requestTracker) recordReply(key string, requestID string, msg protocol.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recent, ok := t.seen[key]
+	if !ok {
+		return
+	}
+	if replies, ok := recent.replies[requestID]; ok {
+		recent.replies[requestID] = append(replies, msg)
+	}
+}
+
+// forget はキーに紐づく記録を破棄する
+func (t *requestTracker) forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.seen, key)
+}
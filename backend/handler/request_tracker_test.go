@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/protocol"
+)
+
+// TestRequestDedupe はメッセージIDのエコー・ACK・再送の重複検出と応答の送り直しのテスト
+func TestRequestDedupe(t *testing.T) {
+	env := newTestHandlerEnv()
+	wsHandler := env.wsHandler
+	sentToA := env.captureReplies("clientA")
+	wsHandler.handleMessage("clientA", nil, joinMessage("code1", "playerA"))
+	wsHandler.handleMessage("clientB", nil, joinMessage("code1", "playerB"))
+
+	verify := func(clientID string, requestID string, playerID string, correct bool) []protocol.Message {
+		t.Helper()
+		room, _ := env.roomRepo.FindByID("code1")
+		gs := room.GetGameStateByPlayerID("playerA")
+		indices := domain.NewProblem(gs.Target, gs.Images).GetCorrectIndices()
+		if !correct {
+			indices = append(indices, len(gs.Images))
+		}
+		b, _ := json.Marshal(protocol.VerifyPayload{RoomID: "code1", PlayerID: playerID, Target: gs.Target, SelectedIndices: indices})
+		before := len(sentToA())
+		wsHandler.handleMessage(clientID, nil, protocol.Message{Type: protocol.TypeVerify, ID: requestID, Payload: b})
+		return sentToA()[before:]
+	}
+	ack := func(msgs []protocol.Message) protocol.AckPayload {
+		t.Helper()
+		var p protocol.AckPayload
+		if len(msgs) == 0 || msgs[0].Type != protocol.TypeAck {
+			t.Fatalf("expected ACK first, got %v", msgs)
+		}
+		_ = json.Unmarshal(msgs[0].Payload, &p)
+		return p
+	}
+
+	// テスト1: ACK と結果の応答にクライアント指定のIDをエコーする
+	first := verify("clientA", "req1", "playerA", false)
+	if a := ack(first); a.Duplicate || a.Type != protocol.TypeVerify || first[0].ID != "req1" {
+		t.Errorf("expected fresh ACK for req1, got %+v", first[0])
+	}
+	if len(first) != 2 || first[1].Type != protocol.TypeVerifyFailed || first[1].ID != "req1" {
+		t.Fatalf("expected VERIFY_FAILED for req1, got %v", first)
+	}
+
+	// テスト2: 同じIDの再送は処理せず、最初の応答を送り直す
+	again := verify("clientA", "req1", "playerA", false)
+	if a := ack(again); !a.Duplicate {
+		t.Errorf("expected duplicate ACK, got %+v", a)
+	}
+	if len(again) != 2 || again[1].Type != protocol.TypeVerifyFailed || string(again[1].Payload) != string(first[1].Payload) {
+		t.Errorf("expected original VERIFY_FAILED to be resent, got %v", again)
+	}
+	if room, _ := env.roomRepo.FindByID("code1"); room.Player1.FailedAttempts != 1 {
+		t.Errorf("expected duplicate not to be verified again, got %d failed attempts", room.Player1.FailedAttempts)
+	}
+
+	// テスト3: 正解の UPDATE_PATTERN も送り直し、スコアは1回だけ増える
+	room, _ := env.roomRepo.FindByID("code1")
+	room.Player1.LockedUntil = time.Time{}
+	env.roomRepo.Save(room)
+	scored := verify("clientA", "req2", "playerA", true)
+	if len(scored) != 2 || scored[1].Type != protocol.TypeUpdatePattern {
+		t.Fatalf("expected UPDATE_PATTERN for req2, got %v", scored)
+	}
+	again = verify("clientA", "req2", "playerA", true)
+	if len(again) != 2 || again[1].Type != protocol.TypeUpdatePattern || string(again[1].Payload) != string(scored[1].Payload) {
+		t.Errorf("expected original UPDATE_PATTERN to be resent, got %v", again)
+	}
+	if room, _ := env.roomRepo.FindByID("code1"); room.Player1.Score != 1 {
+		t.Errorf("expected score 1 after a duplicate, got %d", room.Player1.Score)
+	}
+
+	// テスト4: 重複はペイロードの player_id ではなく接続に紐付いたプレイヤーごとに判定する
+	if _, duplicate := wsHandler.requests.markSeen(wsHandler.requestKey("clientB"), "req2"); duplicate {
+		t.Errorf("expected another player's request id not to collide with playerA's")
+	}
+	if _, duplicate := wsHandler.requests.markSeen(wsHandler.requestKey("clientA"), "req2"); !duplicate {
+		t.Errorf("expected playerA's request id to be keyed on the bound player")
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"time"
+
+	"recaptchgame-backend/domain"
+)
+
+// bindSession はセッションをプレイヤーと参加中のルームに対応付けて保存する
+func (h *WebSocketHandler) bindSession(sessionID string, playerID string, roomID string) {
+	session := domain.NewSession(sessionID, playerID, time.Now())
+	session.RoomID = roomID
+	if err := h.sessions.Save(session); err != nil {
+		h.logger.Error("failed to save session", "session_id", sessionID, "player_id", playerID, "error", err)
+	}
+}
+
+func (h *WebSocketHandler) getSessionIDByPlayerID(playerID string) string {
+	session, err := h.sessions.FindByPlayerID(playerID)
+	if err != nil || session == nil {
+		return ""
+	}
+	return session.ID
+}
+
+func (h *WebSocketHandler) forgetSession(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	if err := h.sessions.Delete(sessionID); err != nil {
+		h.logger.Error("failed to delete session", "session_id", sessionID, "error", err)
+	}
+}
+
+// resolveSessionPlayer は再接続したクライアントのプレイヤーIDを決める
+// 指定のプレイヤーがルームにいなければ、保存されたセッションのプレイヤーがまだルームにいる場合にそちらを返す
+func (h *WebSocketHandler) resolveSessionPlayer(sessionID string, playerID string) string {
+	if room, err := h.roomRepo.FindByPlayerID(playerID); err == nil && room != nil {
+		return playerID
+	}
+	session, err := h.sessions.FindByID(sessionID)
+	if err != nil || session == nil || session.PlayerID == playerID {
+		return playerID
+	}
+	if room, err := h.roomRepo.FindByPlayerID(session.PlayerID); err == nil && room != nil {
+		return session.PlayerID
+	}
+	return playerID
+}
+
+// RestoreSessions は起動時に保存されたセッションを点検する
+// ルームに残っているプレイヤーは再接続を待つ猶予を与え、ルームがなくなったセッションは削除する
+// 猶予を与えたセッションの数を返す
+func (h *WebSocketHandler) RestoreSessions() int {
+	sessions, err := h.sessions.ListAll()
+	if err != nil {
+		h.logger.Error("failed to list sessions", "error", err)
+		return 0
+	}
+	restored := 0
+	for _, session := range sessions {
+		room, err := h.roomRepo.FindByPlayerID(session.PlayerID)
+		if err != nil || room == nil {
+			h.forgetSession(session.ID)
+			continue
+		}
+		h.scheduleGracefulLeave(session.PlayerID)
+		restored++
+	}
+	h.logger.Info("sessions restored", "restored", restored, "discarded", len(sessions)-restored)
+	return restored
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/infrastructure"
+	"recaptchgame-backend/protocol"
+)
+
+// TestSessionRebind は保存されたセッションによる再接続のテスト
+func TestSessionRebind(t *testing.T) {
+	env := newTestHandlerEnv()
+	roomRepo, sessionRepo, wsHandler := env.roomRepo, env.sessionRepo, env.wsHandler
+
+	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
+	room.Start()
+	roomRepo.Save(room)
+	sessionRepo.Save(&domain.Session{ID: "session1", PlayerID: "player1", RoomID: "room1", UpdatedAt: time.Now()})
+
+	// テスト1: 新しいプレイヤーIDで来ても、セッションのプレイヤーがルームにいればそちらに戻す
+	if got := wsHandler.resolveSessionPlayer("session1", "reloaded"); got != "player1" {
+		t.Errorf("expected session to resolve to player1, got %q", got)
+	}
+	// 指定のプレイヤーがルームにいる場合・未知のセッションの場合はそのまま
+	if got := wsHandler.resolveSessionPlayer("session1", "player2"); got != "player2" {
+		t.Errorf("expected player in a room to keep its id, got %q", got)
+	}
+	if got := wsHandler.resolveSessionPlayer("unknown", "reloaded"); got != "reloaded" {
+		t.Errorf("expected unknown session to keep the requested id, got %q", got)
+	}
+
+	// テスト2: RESUME でクライアントが元のプレイヤーとルームに割り当てられる
+	payload, _ := json.Marshal(protocol.ResumePayload{PlayerID: "reloaded", SessionID: "session1"})
+	wsHandler.handleResume("client1", "", payload)
+	if playerID, ok := env.wsManager.GetPlayerID("client1"); !ok || playerID != "player1" {
+		t.Errorf("expected client to be rebound to player1, got %q", playerID)
+	}
+	if roomID, ok := env.wsManager.GetRoomID("client1"); !ok || roomID != "room1" {
+		t.Errorf("expected client to be assigned to room1, got %q", roomID)
+	}
+
+	// テスト3: 起動時の点検でルームがなくなったセッションは削除し、残っているものは再接続を待つ
+	sessionRepo.Save(&domain.Session{ID: "session3", PlayerID: "player3", RoomID: "gone", UpdatedAt: time.Now()})
+	if restored := wsHandler.RestoreSessions(); restored != 1 {
+		t.Errorf("expected 1 restored session, got %d", restored)
+	}
+	if s, _ := sessionRepo.FindByID("session3"); s != nil {
+		t.Errorf("expected session without a room to be discarded")
+	}
+	wsHandler.cancelGracefulLeave("session1")
+}
+
+// TestSessionRestoreAfterRestart はルームとセッションをファイルに保存した場合の再起動後の再接続のテスト
+func TestSessionRestoreAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	openStores := func() (*infrastructure.FileRoomRepository, *infrastructure.FileSessionRepository) {
+		t.Helper()
+		rooms, err := infrastructure.NewFileRoomRepository(filepath.Join(dir, "rooms.json"))
+		if err != nil {
+			t.Fatalf("failed to open room store: %v", err)
+		}
+		sessions, err := infrastructure.NewFileSessionRepository(filepath.Join(dir, "sessions.json"))
+		if err != nil {
+			t.Fatalf("failed to open session store: %v", err)
+		}
+		return rooms, sessions
+	}
+
+	rooms, sessions := openStores()
+	before := newTestHandlerEnvWith(rooms, sessions)
+	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
+	room.Start()
+	room.Player1.Score = 2
+	rooms.Save(room)
+	before.wsHandler.bindSession("session1", "player1", "room1")
+	before.wsHandler.bindSession("session2", "player2", "room1")
+
+	// テスト1: 再起動後（保存先を開き直した新しいハンドラー）もルームが残り、セッションは再接続を待つ
+	rooms, sessions = openStores()
+	after := newTestHandlerEnvWith(rooms, sessions)
+	if restored := after.wsHandler.RestoreSessions(); restored != 2 {
+		t.Fatalf("expected 2 restored sessions, got %d", restored)
+	}
+	defer after.wsHandler.cancelGracefulLeave("session2")
+
+	// テスト2: 新しいプレイヤーIDで RESUME すると元のプレイヤーに戻り、スナップショットで同期する
+	replies := after.captureReplies("client1")
+	payload, _ := json.Marshal(protocol.ResumePayload{PlayerID: "reloaded", SessionID: "session1"})
+	after.wsHandler.handleResume("client1", "", payload)
+	if playerID, ok := after.wsManager.GetPlayerID("client1"); !ok || playerID != "player1" {
+		t.Errorf("expected client to be rebound to player1, got %q", playerID)
+	}
+	var types []string
+	var resumed protocol.ResumeResultPayload
+	for _, msg := range replies() {
+		types = append(types, msg.Type)
+		if msg.Type == protocol.TypeResumed {
+			_ = json.Unmarshal(msg.Payload, &resumed)
+		}
+	}
+	if !containsType(types, protocol.TypeGameStart) || resumed.Mode != "snapshot" || resumed.RoomID != "room1" {
+		t.Errorf("expected snapshot resync of room1, got %v %+v", types, resumed)
+	}
+	after.wsHandler.sessionMu.Lock()
+	_, pending := after.wsHandler.graceTimers["session1"]
+	after.wsHandler.sessionMu.Unlock()
+	if pending {
+		t.Errorf("expected graceful leave to be cancelled by the rebind")
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/protocol"
+)
+
+// TestVerifyUsesBoundPlayer は回答の判定とペナルティが接続に紐付いたプレイヤーに適用されることのテスト
+func TestVerifyUsesBoundPlayer(t *testing.T) {
+	env := newTestHandlerEnv()
+	wsHandler := env.wsHandler
+	wsHandler.handleMessage("clientA", nil, joinMessage("code1", "playerA"))
+	wsHandler.handleMessage("clientB", nil, joinMessage("code1", "playerB"))
+
+	wrongVerify := func(clientID string, playerID string) {
+		t.Helper()
+		room, _ := env.roomRepo.FindByID("code1")
+		gs := room.GetGameStateByPlayerID(playerID)
+		indices := append(domain.NewProblem(gs.Target, gs.Images).GetCorrectIndices(), len(gs.Images))
+		b, _ := json.Marshal(protocol.VerifyPayload{RoomID: "code1", PlayerID: playerID, Target: gs.Target, SelectedIndices: indices})
+		wsHandler.handleMessage(clientID, nil, protocol.Message{Type: protocol.TypeVerify, Payload: b})
+	}
+
+	// テスト1: 相手のIDを詐称した誤答は相手のペナルティにならない
+	wrongVerify("clientA", "playerB")
+	room, _ := env.roomRepo.FindByID("code1")
+	if room.Player2.FailedAttempts != 0 || !room.Player2.LockedUntil.IsZero() {
+		t.Errorf("expected playerB not to be penalized by a spoofed verify, got %d failed attempts", room.Player2.FailedAttempts)
+	}
+
+	// テスト2: プレイヤーに紐付いていない接続の回答は処理しない
+	wrongVerify("clientX", "playerB")
+	room, _ = env.roomRepo.FindByID("code1")
+	if room.Player2.FailedAttempts != 0 || !room.Player2.LockedUntil.IsZero() {
+		t.Errorf("expected verify from an unbound client to be ignored, got %d failed attempts", room.Player2.FailedAttempts)
+	}
+
+	// テスト3: 本人の誤答はペナルティになる
+	wrongVerify("clientB", "playerB")
+	room, _ = env.roomRepo.FindByID("code1")
+	if room.Player2.FailedAttempts != 1 {
+		t.Errorf("expected playerB's own wrong answer to count, got %d failed attempts", room.Player2.FailedAttempts)
+	}
+}
@@ -2,33 +2,82 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"recaptchgame-backend/config"
 	"recaptchgame-backend/domain"
+	"recaptchgame-backend/logging"
+	"recaptchgame-backend/protocol"
 	"recaptchgame-backend/usecase"
 )
 
+// ConnectionSettings は接続ごとの送信キューとハートビート・再接続猶予の設定
+// GracePeriod は切断からルーム退出扱いにするまでの猶予時間
+// 終了したルームのイベントログも同じ時間だけ保持し、猶予中に切断したプレイヤーが結果を受け取れるようにする
+type ConnectionSettings struct {
+	SendBufferSize    int
+	HeartbeatInterval time.Duration
+	PongTimeout       time.Duration
+	GracePeriod       time.Duration
+	RateLimit         RateLimitPolicy
+}
+
+// DefaultConnectionSettings は既定の接続設定
+func DefaultConnectionSettings() ConnectionSettings {
+	return ConnectionSettings{
+		SendBufferSize:    config.DefaultSendBufferSize,
+		HeartbeatInterval: config.DefaultHeartbeatInterval,
+		PongTimeout:       config.DefaultPongTimeout,
+		GracePeriod:       config.DefaultGracePeriod,
+		RateLimit:         DefaultRateLimitPolicy(),
+	}
+}
+
+// ConnectionSettingsFromConfig は設定ファイル・環境変数の接続設定に変換する
+func ConnectionSettingsFromConfig(cfg *config.Config) ConnectionSettings {
+	settings := DefaultConnectionSettings()
+	settings.SendBufferSize = cfg.WebSocket.SendBufferSize
+	settings.HeartbeatInterval = cfg.WebSocket.HeartbeatInterval.Std()
+	settings.PongTimeout = cfg.WebSocket.PongTimeout.Std()
+	settings.GracePeriod = cfg.WebSocket.GracePeriod.Std()
+	settings.RateLimit = RateLimitPolicyFromConfig(cfg)
+	return settings
+}
+
 var errSendQueueClosed = fmt.Errorf("send queue is closed")
 var errSendQueueFull = fmt.Errorf("send queue is full")
 
+// waitingStatusPayload は対戦相手待ちを通知する STATUS_UPDATE のペイロード
+var waitingStatusPayload, _ = json.Marshal(protocol.StatusUpdatePayload{Status: "waiting_for_opponent"})
+
 type clientConnection struct {
-	conn   *websocket.Conn
-	send   chan Message
-	mu     sync.Mutex
-	closed bool
+	conn            *websocket.Conn
+	codec           Codec
+	send            chan protocol.Message
+	mu              sync.Mutex
+	closed          bool
+	protocolVersion int
+	capabilities    map[string]bool
 }
 
-func newClientConnection(conn *websocket.Conn) *clientConnection {
+func newClientConnection(conn *websocket.Conn, sendBufferSize int) *clientConnection {
 	return &clientConnection{
-		conn: conn,
-		send: make(chan Message, 32),
+		conn:            conn,
+		codec:           CodecForSubprotocol(conn.Subprotocol()),
+		send:            make(chan protocol.Message, sendBufferSize),
+		protocolVersion: ProtocolVersionLegacy,
+		capabilities:    make(map[string]bool),
 	}
 }
 
-func (c *clientConnection) enqueue(msg Message) error {
+func (c *clientConnection) enqueue(msg protocol.Message) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -36,23 +85,33 @@ func (c *clientConnection) enqueue(msg Message) error {
 		return errSendQueueClosed
 	}
 	select {
-	case c.send <- msg:
+	case c.send <- encodeForVersion(c.protocolVersion, msg):
 		return nil
 	default:
 		return errSendQueueFull
 	}
 }
 
-func (c *clientConnection) close() {
+// drain は以降の送信を受け付けず、キューに残ったメッセージを書き出してから接続を閉じさせる
+func (c *clientConnection) drain() {
 	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.closed {
-		c.mu.Unlock()
 		return
 	}
 	c.closed = true
 	close(c.send)
-	c.mu.Unlock()
+}
 
+// remoteClient は他のノードに接続しているクライアント
+type remoteClient struct {
+	nodeID       string
+	remoteIP     string // 接続先ノードが伝えた接続元IP
+	capabilities map[string]bool
+}
+
+func (c *clientConnection) close() {
+	c.drain()
 	c.conn.Close()
 }
 
@@ -64,22 +123,35 @@ type WebSocketManager struct {
 	clientToRoom   map[string]string            // clientID -> roomID
 	roomToClients  map[string]map[string]bool   // roomID -> map[clientID]bool
 	lastPongAt     map[string]time.Time
+	remotes        map[string]*remoteClient // clientID -> 他のノードに接続しているクライアント
+	relay          func(nodeID string, clientID string, msg protocol.Message) error
+	sendBufferSize int
+	metrics        *Metrics
+	logger         *slog.Logger
 }
 
 // NewWebSocketManager は新しいWebSocketManagerを生成
-func NewWebSocketManager() *WebSocketManager {
+// sendBufferSize は接続ごとの送信キューの長さ（0 以下なら既定値）
+func NewWebSocketManager(sendBufferSize int, metrics *Metrics, logger *slog.Logger) *WebSocketManager {
+	if sendBufferSize <= 0 {
+		sendBufferSize = DefaultConnectionSettings().SendBufferSize
+	}
 	return &WebSocketManager{
+		sendBufferSize: sendBufferSize,
+		metrics:        metrics,
+		logger:         logging.OrDiscard(logger),
 		connections:    make(map[string]*clientConnection),
 		clientToPlayer: make(map[string]string),
 		clientToRoom:   make(map[string]string),
 		roomToClients:  make(map[string]map[string]bool),
 		lastPongAt:     make(map[string]time.Time),
+		remotes:        make(map[string]*remoteClient),
 	}
 }
 
 // RegisterConnection はコネクションを登録
 func (m *WebSocketManager) RegisterConnection(clientID string, conn *websocket.Conn) {
-	client := newClientConnection(conn)
+	client := newClientConnection(conn, m.sendBufferSize)
 
 	m.mu.Lock()
 	m.connections[clientID] = client
@@ -100,6 +172,7 @@ func (m *WebSocketManager) UnregisterConnection(clientID string) {
 	roomID := m.clientToRoom[clientID]
 	delete(m.clientToRoom, clientID)
 	delete(m.lastPongAt, clientID)
+	delete(m.remotes, clientID)
 
 	if roomID != "" && m.roomToClients[roomID] != nil {
 		delete(m.roomToClients[roomID], clientID)
@@ -198,6 +271,17 @@ func (m *WebSocketManager) GetPlayerID(clientID string) (string, bool) {
 	return playerID, ok
 }
 
+// RemoteClientIP は他のノードに接続しているクライアントの接続元IPを返す
+func (m *WebSocketManager) RemoteClientIP(clientID string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	remote, ok := m.remotes[clientID]
+	if !ok {
+		return "", false
+	}
+	return remote.remoteIP, true
+}
+
 // TouchPong はクライアントの最終PONG時刻を更新
 func (m *WebSocketManager) TouchPong(clientID string) {
 	m.mu.Lock()
@@ -218,8 +302,64 @@ func (m *WebSocketManager) IsPongTimedOut(clientID string, timeout time.Duration
 	return time.Since(last) > timeout
 }
 
+// SetProtocol はクライアントとネゴシエートしたプロトコルバージョンと拡張機能を記録する
+func (m *WebSocketManager) SetProtocol(clientID string, version int, capabilities []string) {
+	m.mu.RLock()
+	client, ok := m.connections[clientID]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.protocolVersion = version
+	client.capabilities = make(map[string]bool, len(capabilities))
+	for _, c := range capabilities {
+		client.capabilities[c] = true
+	}
+}
+
+// HasCapability はクライアントが拡張機能をネゴシエート済みか判定
+func (m *WebSocketManager) HasCapability(clientID string, capability string) bool {
+	m.mu.RLock()
+	client, ok := m.connections[clientID]
+	remote := m.remotes[clientID]
+	m.mu.RUnlock()
+	if !ok {
+		return remote != nil && remote.capabilities[capability]
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.capabilities[capability]
+}
+
+// CloseAfterFlush は送信キューに積まれたメッセージを書き出してから接続を閉じる
+func (m *WebSocketManager) CloseAfterFlush(clientID string) {
+	m.mu.RLock()
+	client, ok := m.connections[clientID]
+	m.mu.RUnlock()
+	if ok {
+		client.drain()
+	}
+}
+
 // SendToClient は指定クライアントにメッセージ送信キュー経由で送る
-func (m *WebSocketManager) SendToClient(clientID string, msg Message) error {
+// 他のノードに接続しているクライアントにはそのノードへ中継する
+func (m *WebSocketManager) SendToClient(clientID string, msg protocol.Message) error {
+	m.mu.RLock()
+	remote := m.remotes[clientID]
+	relay := m.relay
+	m.mu.RUnlock()
+	if remote != nil && relay != nil {
+		return relay(remote.nodeID, clientID, msg)
+	}
+	return m.sendLocal(clientID, msg)
+}
+
+// sendLocal はこのノードに接続しているクライアントにだけ送る
+func (m *WebSocketManager) sendLocal(clientID string, msg protocol.Message) error {
 	m.mu.RLock()
 	client, ok := m.connections[clientID]
 	m.mu.RUnlock()
@@ -229,6 +369,8 @@ func (m *WebSocketManager) SendToClient(clientID string, msg Message) error {
 
 	if err := client.enqueue(msg); err != nil {
 		if err == errSendQueueFull {
+			m.metrics.sendQueueFullDisconnect()
+			m.logger.Warn("send queue is full; disconnecting client", "client_id", clientID, "msg_type", msg.Type)
 			m.UnregisterConnection(clientID)
 		}
 		return err
@@ -236,8 +378,45 @@ func (m *WebSocketManager) SendToClient(clientID string, msg Message) error {
 	return nil
 }
 
+// AddRemoteClient は他のノードに接続しているクライアントを登録し、以降の送信をそのノードへ中継する
+func (m *WebSocketManager) AddRemoteClient(clientID string, nodeID string, remoteIP string, capabilities []string) {
+	remote := &remoteClient{nodeID: nodeID, remoteIP: remoteIP, capabilities: make(map[string]bool, len(capabilities))}
+	for _, c := range capabilities {
+		remote.capabilities[c] = true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remotes[clientID] = remote
+}
+
+// Capabilities はクライアントがネゴシエートした拡張機能を返す
+func (m *WebSocketManager) Capabilities(clientID string) []string {
+	m.mu.RLock()
+	client, ok := m.connections[clientID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	capabilities := make([]string, 0, len(client.capabilities))
+	for c := range client.capabilities {
+		capabilities = append(capabilities, c)
+	}
+	sort.Strings(capabilities)
+	return capabilities
+}
+
+// ConnectionCount は登録中の接続数を返す
+func (m *WebSocketManager) ConnectionCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.connections)
+}
+
 // SendToRoom はルーム内全員にメッセージ送信キュー経由で送る
-func (m *WebSocketManager) SendToRoom(roomID string, msg Message) {
+func (m *WebSocketManager) SendToRoom(roomID string, msg protocol.Message) {
 	m.mu.RLock()
 	var targetIDs []string
 	if clients, ok := m.roomToClients[roomID]; ok {
@@ -252,6 +431,24 @@ func (m *WebSocketManager) SendToRoom(roomID string, msg Message) {
 	}
 }
 
+// Broadcast は接続中の全クライアントにメッセージ送信キュー経由で送り、送信先の数を返す
+func (m *WebSocketManager) Broadcast(msg protocol.Message) int {
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.connections))
+	for id := range m.connections {
+		ids = append(ids, id)
+	}
+	m.mu.RUnlock()
+
+	sent := 0
+	for _, id := range ids {
+		if m.SendToClient(id, msg) == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
 // CloseAll は全ての接続をクローズして登録を解除します。
 func (m *WebSocketManager) CloseAll() {
 	m.mu.RLock()
@@ -268,7 +465,11 @@ func (m *WebSocketManager) CloseAll() {
 
 func (m *WebSocketManager) writePump(clientID string, client *clientConnection) {
 	for msg := range client.send {
-		if err := client.conn.WriteJSON(msg); err != nil {
+		data, err := client.codec.Encode(msg)
+		if err != nil {
+			continue
+		}
+		if err := client.conn.WriteMessage(client.codec.FrameType(), data); err != nil {
 			break
 		}
 	}
@@ -277,16 +478,29 @@ func (m *WebSocketManager) writePump(clientID string, client *clientConnection)
 
 // WebSocketHandler はWebSocket通信のハンドラー
 type WebSocketHandler struct {
-	wsManager       *WebSocketManager
-	joinRoomUC      *usecase.JoinRoomUseCase
-	verifyAnswerUC  *usecase.VerifyAnswerUseCase
-	startGameUC     *usecase.StartGameUseCase
-	leaveRoomUC     *usecase.LeaveRoomUseCase
-	roomRepo        domain.RoomRepository
-	sessionMu       sync.Mutex
-	sessionToPlayer map[string]string
-	playerToSession map[string]string
-	graceTimers     map[string]*time.Timer
+	wsManager      *WebSocketManager
+	joinRoomUC     *usecase.JoinRoomUseCase
+	verifyAnswerUC *usecase.VerifyAnswerUseCase
+	startGameUC    *usecase.StartGameUseCase
+	leaveRoomUC    *usecase.LeaveRoomUseCase
+	endGameUC      *usecase.EndGameUseCase
+	useItemUC      *usecase.UseItemUseCase
+	targetingUC    *usecase.ObstructionTargetingUseCase
+	botDetectionUC *usecase.BotDetectionUseCase
+	roomRepo       domain.RoomRepository
+	admission      *AdmissionController
+	sessions       domain.SessionRepository
+	sessionMu      sync.Mutex
+	graceTimers    map[string]*time.Timer
+	requests       *requestTracker
+	events         *roomEventLog
+	opponentViews  *opponentViewTracker
+	limiter        *rateLimiter
+	settings       ConnectionSettings
+	metrics        *Metrics
+	logger         *slog.Logger
+	drain          *drainState
+	cluster        *clusterNode
 }
 
 // NewWebSocketHandler は新しいWebSocketHandlerを生成
@@ -296,69 +510,292 @@ func NewWebSocketHandler(
 	verifyAnswerUC *usecase.VerifyAnswerUseCase,
 	startGameUC *usecase.StartGameUseCase,
 	leaveRoomUC *usecase.LeaveRoomUseCase,
+	endGameUC *usecase.EndGameUseCase,
+	useItemUC *usecase.UseItemUseCase,
+	targetingUC *usecase.ObstructionTargetingUseCase,
+	botDetectionUC *usecase.BotDetectionUseCase,
 	roomRepo domain.RoomRepository,
+	sessionRepo domain.SessionRepository,
+	admission *AdmissionController,
+	settings ConnectionSettings,
+	metrics *Metrics,
+	logger *slog.Logger,
 ) *WebSocketHandler {
 	return &WebSocketHandler{
-		wsManager:       wsManager,
-		joinRoomUC:      joinRoomUC,
-		verifyAnswerUC:  verifyAnswerUC,
-		startGameUC:     startGameUC,
-		leaveRoomUC:     leaveRoomUC,
-		roomRepo:        roomRepo,
-		sessionToPlayer: make(map[string]string),
-		playerToSession: make(map[string]string),
-		graceTimers:     make(map[string]*time.Timer),
+		wsManager:      wsManager,
+		joinRoomUC:     joinRoomUC,
+		verifyAnswerUC: verifyAnswerUC,
+		startGameUC:    startGameUC,
+		leaveRoomUC:    leaveRoomUC,
+		endGameUC:      endGameUC,
+		useItemUC:      useItemUC,
+		targetingUC:    targetingUC,
+		botDetectionUC: botDetectionUC,
+		roomRepo:       roomRepo,
+		admission:      admission,
+		sessions:       sessionRepo,
+		graceTimers:    make(map[string]*time.Timer),
+		requests:       newRequestTracker(64),
+		events:         newRoomEventLog(256),
+		opponentViews:  newOpponentViewTracker(20),
+		limiter:        newRateLimiter(settings.RateLimit),
+		settings:       settings,
+		metrics:        metrics,
+		logger:         logging.OrDiscard(logger),
+		drain:          newDrainState(),
 	}
 }
 
 // HandleConnection はWebSocket接続を処理
-func (h *WebSocketHandler) HandleConnection(clientID string, conn *websocket.Conn) {
+// remoteIP はIP単位のレート制限に使う
+func (h *WebSocketHandler) HandleConnection(clientID string, remoteIP string, conn *websocket.Conn) {
 	h.wsManager.RegisterConnection(clientID, conn)
+	h.limiter.addClient(clientID, remoteIP)
 	go h.heartbeatPump(clientID, conn)
+	h.logger.Debug("connection opened", "client_id", clientID, "remote_ip", remoteIP, "subprotocol", conn.Subprotocol())
 	// left フラグで重複退出処理を防ぐ
 	var left bool
+	var rejected bool
 	defer func() {
+		h.clientLogger(clientID, "").Debug("connection closed", "remote_ip", remoteIP, "left", left)
 		if !left {
 			playerID, err := h.getPlayerIDByClientID(clientID)
 			if err == nil {
 				h.scheduleGracefulLeave(playerID)
 			}
 		}
-		h.wsManager.UnregisterConnection(clientID)
+		h.notifyOwnersOfDisconnect(clientID)
+		h.releaseClient(clientID)
 	}()
 
+	// 巨大なフレームと無応答の接続を切る（PONG を含む受信のたびに期限を延長する）
+	policy := h.admission.Policy()
+	conn.SetReadLimit(policy.MaxMessageBytes)
+	_ = conn.SetReadDeadline(time.Now().Add(policy.ReadTimeout))
+
+	codec := CodecForSubprotocol(conn.Subprotocol())
 	for {
-		var msg Message
-		if err := conn.ReadJSON(&msg); err != nil {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			if errors.Is(err, websocket.ErrReadLimit) {
+				h.admission.Reject(RejectMessageTooLarge, remoteIP, clientID)
+			} else if errors.As(err, &netErr) && netErr.Timeout() {
+				h.admission.Reject(RejectReadTimeout, remoteIP, clientID)
+			}
 			break
 		}
+		_ = conn.SetReadDeadline(time.Now().Add(policy.ReadTimeout))
+		msg, err := codec.Decode(data)
+		if err != nil {
+			break
+		}
+		// 非対応バージョンとして拒否した接続は UPGRADE_REQUIRED の送信後に閉じられるまで読み捨てる
+		if rejected {
+			continue
+		}
+		// レート制限はディスパッチ前に適用し、継続的に超過する接続は切断する
+		if allowed, disconnect := h.checkRateLimit(clientID, remoteIP, msg); !allowed {
+			rejected = disconnect
+			continue
+		}
+		// HELLO はディスパッチ前に処理する
+		if msg.Type == protocol.TypeHello {
+			rejected = !h.handleHello(clientID, msg)
+			continue
+		}
 		// LEAVE_ROOMを明示的に受信した場合はフラグを立てる
-		if msg.Type == "LEAVE_ROOM" {
+		if msg.Type == protocol.TypeLeaveRoom {
 			left = true
 		}
+		// 他のノードが所有するルーム宛てのメッセージは所有ノードに転送する
+		if h.forwardToOwner(clientID, msg) {
+			continue
+		}
 		h.handleMessage(clientID, conn, msg)
 	}
 }
 
+// releaseClient は切断した接続に紐付いた状態を破棄する（他ノードの接続の切断でも使う）
+func (h *WebSocketHandler) releaseClient(clientID string) {
+	h.opponentViews.reset(clientID)
+	h.limiter.removeClient(clientID)
+	h.requests.forget(clientID)
+	h.wsManager.UnregisterConnection(clientID)
+}
+
+// checkRateLimit はメッセージがレート制限内かを判定し、超過時は rate_limited エラーを返す
+// 超過が続いた場合は disconnect=true を返し、エラーの送信後に接続を閉じる
+func (h *WebSocketHandler) checkRateLimit(clientID string, remoteIP string, msg protocol.Message) (allowed bool, disconnect bool) {
+	now := time.Now()
+	allowed, wait := h.limiter.allow(clientID, msg.Type, now)
+	if allowed {
+		return true, false
+	}
+
+	h.metrics.messageRateLimited(msg.Type)
+	disconnect = h.limiter.recordViolation(clientID, now)
+	h.clientLogger(clientID, msg.Type).Debug("message rate limited", "remote_ip", remoteIP, "retry_after", wait, "disconnect", disconnect)
+	errPayload := protocol.ErrorPayload{
+		Code:         protocol.ErrorCodeRateLimited,
+		Message:      "too many messages; slow down",
+		Type:         msg.Type,
+		RetryAfterMs: wait.Milliseconds(),
+	}
+	if disconnect {
+		errPayload.Message = "too many messages; disconnecting"
+		errPayload.RetryAfterMs = 0
+	}
+	b, _ := json.Marshal(errPayload)
+	h.reply(clientID, msg.ID, protocol.TypeError, b)
+	if disconnect {
+		h.admission.Reject(RejectRateLimitAbuse, remoteIP, clientID+" "+msg.Type)
+		h.wsManager.CloseAfterFlush(clientID)
+	}
+	return false, disconnect
+}
+
+// stateChangingMessages はACKを返す対象のメッセージ種別
+var stateChangingMessages = map[string]bool{
+	protocol.TypeJoinRoom:     true,
+	protocol.TypeResume:       true,
+	protocol.TypeLeaveRoom:    true,
+	protocol.TypeSelectImage:  true,
+	protocol.TypeVerify:       true,
+	protocol.TypeUseItem:      true,
+	protocol.TypeSetTargeting: true,
+	protocol.TypeSelectTarget: true,
+}
+
+// dedupedMessages は再送時に二重処理してはならないメッセージ種別
+// JOIN_ROOM / RESUME / LEAVE_ROOM は冪等なので再接続後の再送でも再処理する
+var dedupedMessages = map[string]bool{
+	protocol.TypeSelectImage:  true,
+	protocol.TypeVerify:       true,
+	protocol.TypeUseItem:      true,
+	protocol.TypeSelectTarget: true,
+}
+
+// handleHello はHELLOメッセージでプロトコルバージョンと拡張機能をネゴシエートする
+// サーバーより新しいバージョンは最新版にダウングレードし、最小バージョン未満は UPGRADE_REQUIRED を送って false を返す
+func (h *WebSocketHandler) handleHello(clientID string, msg protocol.Message) bool {
+	var p protocol.HelloPayload
+	if err := json.Unmarshal(msg.Payload, &p); err != nil {
+		p.Version = 0
+	}
+
+	version, ok := negotiateProtocolVersion(p.Version)
+	if !ok {
+		upgrade := protocol.UpgradeRequiredPayload{
+			MinVersion:     MinProtocolVersion,
+			CurrentVersion: ProtocolVersionCurrent,
+			Message:        "client protocol is no longer supported; please reload the page",
+		}
+		b, _ := json.Marshal(upgrade)
+		h.reply(clientID, msg.ID, protocol.TypeUpgradeRequired, b)
+		h.wsManager.CloseAfterFlush(clientID)
+		h.clientLogger(clientID, msg.Type).Info("unsupported protocol version; closing", "version", p.Version)
+		return false
+	}
+
+	capabilities := negotiateCapabilities(p.Capabilities)
+	h.wsManager.SetProtocol(clientID, version, capabilities)
+
+	ack := protocol.HelloAckPayload{
+		Version:      version,
+		MinVersion:   MinProtocolVersion,
+		MaxVersion:   ProtocolVersionCurrent,
+		Capabilities: capabilities,
+	}
+	b, _ := json.Marshal(ack)
+	h.reply(clientID, msg.ID, protocol.TypeHelloAck, b)
+	return true
+}
+
 // handleMessage はメッセージを処理
-func (h *WebSocketHandler) handleMessage(clientID string, conn *websocket.Conn, msg Message) {
+func (h *WebSocketHandler) handleMessage(clientID string, conn *websocket.Conn, msg protocol.Message) {
+	// ディスパッチ前にスキーマで検証し、不正なメッセージはハンドラーに渡さない
+	if err := protocol.ValidatePayload(msg.Type, msg.Payload); err != nil {
+		code := protocol.ErrorCodeInvalidPayload
+		if errors.Is(err, protocol.ErrUnknownType) {
+			code = protocol.ErrorCodeUnknownType
+		}
+		h.clientLogger(clientID, msg.Type).Info("message rejected by schema validation", "code", code, "error", err)
+		b, _ := json.Marshal(protocol.ErrorPayload{Code: code, Message: err.Error(), Type: msg.Type})
+		h.reply(clientID, msg.ID, protocol.TypeError, b)
+		return
+	}
+
+	if msg.ID != "" && stateChangingMessages[msg.Type] {
+		var replies []protocol.Message
+		duplicate := false
+		if dedupedMessages[msg.Type] {
+			replies, duplicate = h.requests.markSeen(h.requestKey(clientID), msg.ID)
+		}
+		ack := protocol.AckPayload{Type: msg.Type, Duplicate: duplicate}
+		b, _ := json.Marshal(ack)
+		h.reply(clientID, msg.ID, protocol.TypeAck, b)
+		if duplicate {
+			// 応答を受け取れずに再送したクライアントのため、最初に送った応答を送り直す
+			for _, reply := range replies {
+				_ = h.wsManager.SendToClient(clientID, reply)
+			}
+			h.clientLogger(clientID, msg.Type).Debug("duplicate request ignored", "request_id", msg.ID, "replies_resent", len(replies))
+			return
+		}
+	}
+
 	switch msg.Type {
-	case "JOIN_ROOM":
-		h.handleJoinRoom(clientID, conn, msg.Payload)
-	case "PONG":
+	case protocol.TypeJoinRoom:
+		h.handleJoinRoom(clientID, msg.ID, conn, msg.Payload)
+	case protocol.TypeResume:
+		h.handleResume(clientID, msg.ID, msg.Payload)
+	case protocol.TypeRequestKeyframe:
+		h.handleRequestKeyframe(clientID, msg.ID)
+	case protocol.TypePong:
 		h.wsManager.TouchPong(clientID)
-	case "LEAVE_ROOM":
+	case protocol.TypeLeaveRoom:
 		h.handleLeaveRoom(clientID, msg.Payload)
-	case "SELECT_IMAGE":
+	case protocol.TypeSelectImage:
 		h.handleSelectImage(clientID, msg.Payload)
-	case "VERIFY":
-		h.handleVerify(clientID, conn, msg.Payload)
+	case protocol.TypeVerify:
+		h.handleVerify(clientID, msg.ID, conn, msg.Payload)
+	case protocol.TypeUseItem:
+		h.handleUseItem(clientID, msg.ID, msg.Payload)
+	case protocol.TypeSetTargeting:
+		h.handleSetTargeting(clientID, msg.ID, msg.Payload)
+	case protocol.TypeSelectTarget:
+		h.handleSelectTarget(clientID, msg.ID, msg.Payload)
+	}
+}
+
+// requestKey は重複検出に使うキーを返す
+// 再接続でクライアントIDが変わっても検出できるよう、接続に紐付いたプレイヤーがいればそのIDを使う
+// （ペイロードの player_id はクライアントが自由に変えられるので使わない）
+func (h *WebSocketHandler) requestKey(clientID string) string {
+	if playerID, ok := h.wsManager.GetPlayerID(clientID); ok {
+		return playerID
+	}
+	return clientID
+}
+
+// reply はリクエストへの直接応答を送信し、クライアント指定のIDをエコーする
+func (h *WebSocketHandler) reply(clientID string, requestID string, msgType string, payload json.RawMessage) {
+	h.sendReply(clientID, protocol.Message{Type: msgType, ID: requestID, Payload: payload})
+}
+
+// sendReply は要求への応答（ID付きのメッセージ）を送り、重複した要求に送り直せるよう記録する
+// ACK は重複かどうかで内容が変わるので記録しない
+func (h *WebSocketHandler) sendReply(clientID string, msg protocol.Message) {
+	if msg.ID != "" && msg.Type != protocol.TypeAck {
+		h.requests.recordReply(h.requestKey(clientID), msg.ID, msg)
 	}
+	_ = h.wsManager.SendToClient(clientID, msg)
 }
 
 // handleJoinRoom はJOIN_ROOMメッセージを処理
-func (h *WebSocketHandler) handleJoinRoom(clientID string, conn *websocket.Conn, payload json.RawMessage) {
-	var p JoinRoomPayload
+func (h *WebSocketHandler) handleJoinRoom(clientID string, requestID string, conn *websocket.Conn, payload json.RawMessage) {
+	var p protocol.JoinRoomPayload
 	if err := json.Unmarshal(payload, &p); err != nil {
 		return
 	}
@@ -367,82 +804,45 @@ func (h *WebSocketHandler) handleJoinRoom(clientID string, conn *websocket.Conn,
 	if sessionID == "" {
 		sessionID = p.PlayerID
 	}
-	h.bindSession(sessionID, p.PlayerID)
 	h.cancelGracefulLeave(sessionID)
+	logger := h.logger.With("client_id", clientID, "msg_type", protocol.TypeJoinRoom, "player_id", p.PlayerID, "session_id", sessionID)
+	if playerID := h.resolveSessionPlayer(sessionID, p.PlayerID); playerID != p.PlayerID {
+		logger.Info("session rebound to previous player", "previous_player_id", playerID)
+		p.PlayerID = playerID
+	}
 
 	// 既存参加中のプレイヤーが同一セッションで再接続した場合は、参加処理を再実行せず復帰のみ行う
 	if room, err := h.roomRepo.FindByPlayerID(p.PlayerID); err == nil && room != nil {
+		logger.Info("player reconnected to room", "room_id", room.ID, "active", room.IsActive)
+		h.bindSession(sessionID, p.PlayerID, room.ID)
 		h.wsManager.AssignClientToPlayer(clientID, p.PlayerID)
 		h.wsManager.AssignClientToRoom(clientID, room.ID)
 
-		assigned := RoomAssignedPayload{RoomID: room.ID, PlayerID: p.PlayerID}
+		assigned := protocol.RoomAssignedPayload{RoomID: room.ID, PlayerID: p.PlayerID}
 		bAssigned, _ := json.Marshal(assigned)
-		_ = h.wsManager.SendToClient(clientID, Message{Type: "ROOM_ASSIGNED", Payload: bAssigned})
+		h.reply(clientID, requestID, protocol.TypeRoomAssigned, bAssigned)
 
 		// 復帰時はルーム状態を再送して同期
-		if room.IsReady() {
-			player := room.GetPlayerByID(p.PlayerID)
-			gameState := room.GetGameStateByPlayerID(p.PlayerID)
-			if player != nil && gameState != nil {
-				// get first opponent game state (if any)
-				opponentGS := room.GetOpponentGameState(p.PlayerID)
-				var opponentImages []string
-				var opponentScore int
-				if opponentGS != nil {
-					opponentImages = opponentGS.Images
-					// try to find opponent player to get score
-					// iterate players
-					if room.Player1 != nil && room.Player1.ID != p.PlayerID {
-						opponentScore = room.Player1.Score
-					} else if room.Player2 != nil && room.Player2.ID != p.PlayerID {
-						opponentScore = room.Player2.Score
-					} else {
-						for _, op := range room.ExtraPlayers {
-							if op != nil && op.ID != p.PlayerID {
-								opponentScore = op.Score
-								break
-							}
-						}
-					}
-				}
-
-				brOpponents := h.buildBROpponentSnapshots(room, player.ID)
-				if len(opponentImages) == 0 && len(brOpponents) > 0 {
-					opponentImages = brOpponents[0].Images
-					opponentScore = brOpponents[0].Score
-				}
-				gamePayload := GameStartPayload{
-					Target:               gameState.Target,
-					Images:               gameState.Images,
-					OpponentImages:       opponentImages,
-					WinningScore:         room.WinningScore,
-					MyCurrentScore:       player.Score,
-					MyCurrentCombo:       player.Combo,
-					OpponentCurrentScore: opponentScore,
-					PlayerEffect:         player.ActiveEffect(),
-					BROpponents:          brOpponents,
-				}
-				bGame, _ := json.Marshal(gamePayload)
-				_ = h.wsManager.SendToClient(clientID, Message{Type: "GAME_START", Payload: bGame})
-
-				oppPayload := OpponentUpdatePayload{Images: opponentImages, Score: opponentScore, Combo: 0, BROpponents: brOpponents}
-				if len(brOpponents) > 0 {
-					oppPayload.Score = brOpponents[0].Score
-					oppPayload.Combo = brOpponents[0].Combo
-				}
-				bOpp, _ := json.Marshal(oppPayload)
-				_ = h.wsManager.SendToClient(clientID, Message{Type: "OPPONENT_UPDATE", Payload: bOpp})
-				return
-			}
+		if h.sendResyncSnapshot(clientID, requestID, room, p.PlayerID) {
+			return
 		}
 
-		_ = h.wsManager.SendToClient(clientID, Message{Type: "STATUS_UPDATE", Payload: json.RawMessage(`{"status": "waiting_for_opponent"}`)})
+		h.reply(clientID, requestID, protocol.TypeStatusUpdate, waitingStatusPayload)
+		return
+	}
+
+	// ドレイン中は復帰以外の参加（ランダムマッチを含む）を受け付けない
+	if h.IsDraining() {
+		logger.Info("join rejected: server is draining", "requested_room_id", p.RoomID)
+		bErr, _ := json.Marshal(protocol.JoinFailedPayload{Message: drainJoinRejectedMessage})
+		h.reply(clientID, requestID, protocol.TypeJoinFailed, bErr)
 		return
 	}
 
 	input := usecase.JoinRoomInput{
 		ClientID:     clientID,
 		PlayerID:     p.PlayerID,
+		RemoteIP:     h.clientRemoteIP(clientID),
 		RoomID:       p.RoomID,
 		WinningScore: p.WinningScore,
 		Capacity:     p.Capacity,
@@ -450,108 +850,167 @@ func (h *WebSocketHandler) handleJoinRoom(clientID string, conn *websocket.Conn,
 
 	output, err := h.joinRoomUC.Execute(input)
 	if err != nil || output == nil {
+		logger.Info("join failed", "requested_room_id", p.RoomID, "error", err)
 		// join に失敗したことをクライアントへ通知してUIが固まらないようにする
-		errMsg := struct {
-			Message string `json:"message"`
-		}{Message: "JOIN_FAILED"}
-		bErr, _ := json.Marshal(errMsg)
-		_ = h.wsManager.SendToClient(clientID, Message{Type: "JOIN_FAILED", Payload: bErr})
+		bErr, _ := json.Marshal(protocol.JoinFailedPayload{Message: protocol.TypeJoinFailed})
+		h.reply(clientID, requestID, protocol.TypeJoinFailed, bErr)
 		return
 	}
 
-	// クライアントをルームに割り当て
+	// クライアントをルームに割り当て（ランダムマッチで作られたルームはこのノードが所有する）
+	h.claimRoom(output.ActualRoomID)
+	h.bindSession(sessionID, p.PlayerID, output.ActualRoomID)
 	h.wsManager.AssignClientToPlayer(clientID, p.PlayerID)
 	h.wsManager.AssignClientToRoom(clientID, output.ActualRoomID)
+	h.metrics.matchQueued(p.PlayerID, matchMode(p.RoomID), time.Now())
 
 	// ROOM_ASSIGNED メッセージを送信
-	assigned := RoomAssignedPayload{
+	assigned := protocol.RoomAssignedPayload{
 		RoomID:   output.ActualRoomID,
 		PlayerID: p.PlayerID,
 	}
 	b, _ := json.Marshal(assigned)
-	_ = h.wsManager.SendToClient(clientID, Message{Type: "ROOM_ASSIGNED", Payload: b})
+	h.reply(clientID, requestID, protocol.TypeRoomAssigned, b)
 
 	// ルームが参加可能人数に達したかチェック
 	if output.RoomSize >= output.RoomCapacity {
-		// ゲーム開始
+		// ゲーム開始（GAME_START は GameStarted の購読で全員に送る）
 		startInput := usecase.StartGameInput{RoomID: output.ActualRoomID}
-		startOutput, err := h.startGameUC.Execute(startInput)
-		if err != nil {
+		if _, err := h.startGameUC.Execute(startInput); err != nil {
 			// 部屋の準備ができていない場合はゲームを開始しない
-			return
-		}
-
-		// ルーム内全員にゲーム開始を通知
-		room, err := h.roomRepo.FindByID(output.ActualRoomID)
-		if err != nil {
-			return
-		}
-		// build players and gameStates slices (player1, player2, extra...)
-		players := []*domain.Player{room.Player1, room.Player2}
-		gameStates := []*domain.GameState{room.GameState1, room.GameState2}
-		for _, p := range room.ExtraPlayers {
-			players = append(players, p)
-		}
-		for _, gs := range room.ExtraGameStates {
-			gameStates = append(gameStates, gs)
+			logger.Debug("game not started", "room_id", output.ActualRoomID, "error", err)
 		}
+	} else {
+		// 相手を待機中
+		h.reply(clientID, requestID, protocol.TypeStatusUpdate, waitingStatusPayload)
+	}
+}
 
-		for i, player := range players {
-			if player == nil {
-				continue
-			}
-			// find an opponent's images (first other player's images)
-			var opponentImages []string
-			for j := range gameStates {
-				if j == i {
-					continue
-				}
-				if gameStates[j] != nil {
-					opponentImages = gameStates[j].Images
+// sendResyncSnapshot は再接続したクライアントに現在のルーム状態（GAME_START と OPPONENT_UPDATE）を送る
+// スナップショットにはルームの最新シーケンス番号を付与し、以降のイベントとの整合を取れるようにする
+// ゲーム開始前などスナップショットを送れない場合は false を返す
+func (h *WebSocketHandler) sendResyncSnapshot(clientID string, requestID string, room *domain.Room, playerID string) bool {
+	if !room.IsReady() {
+		return false
+	}
+	player := room.GetPlayerByID(playerID)
+	gameState := room.GetGameStateByPlayerID(playerID)
+	if player == nil || gameState == nil {
+		return false
+	}
+	seq := h.events.latestSeq(room.ID)
+
+	// get first opponent game state (if any)
+	opponentGS := room.GetOpponentGameState(playerID)
+	var opponentImages []string
+	var opponentScore int
+	if opponentGS != nil {
+		opponentImages = opponentGS.Images
+		// try to find opponent player to get score
+		// iterate players
+		if room.Player1 != nil && room.Player1.ID != playerID {
+			opponentScore = room.Player1.Score
+		} else if room.Player2 != nil && room.Player2.ID != playerID {
+			opponentScore = room.Player2.Score
+		} else {
+			for _, op := range room.ExtraPlayers {
+				if op != nil && op.ID != playerID {
+					opponentScore = op.Score
 					break
 				}
 			}
+		}
+	}
 
-			var myImages []string
-			if i < len(gameStates) && gameStates[i] != nil {
-				myImages = gameStates[i].Images
-			}
+	brOpponents := h.buildBROpponentSnapshots(room, player.ID)
+	if len(opponentImages) == 0 && len(brOpponents) > 0 {
+		opponentImages = brOpponents[0].Images
+		opponentScore = brOpponents[0].Score
+	}
+	gamePayload := protocol.GameStartPayload{
+		Target:               gameState.Target,
+		Images:               gameState.Images,
+		OpponentImages:       opponentImages,
+		WinningScore:         room.WinningScore,
+		MyCurrentScore:       player.Score,
+		MyCurrentCombo:       player.Combo,
+		OpponentCurrentScore: opponentScore,
+		PlayerEffect:         player.ActiveEffect(),
+		MyItems:              player.ItemNames(),
+		BROpponents:          brOpponents,
+	}
+	bGame, _ := json.Marshal(gamePayload)
+	_ = h.wsManager.SendToClient(clientID, protocol.Message{Type: protocol.TypeGameStart, ID: requestID, Seq: seq, Payload: bGame})
 
-			gamePayload := GameStartPayload{
-				Target:               "",
-				Images:               myImages,
-				OpponentImages:       opponentImages,
-				WinningScore:         startOutput.WinningScore,
-				MyCurrentScore:       0,
-				MyCurrentCombo:       player.Combo,
-				OpponentCurrentScore: 0,
-				PlayerEffect:         player.ActiveEffect(),
-				BROpponents:          h.buildBROpponentSnapshots(room, player.ID),
-			}
-			if len(gamePayload.BROpponents) > 0 {
-				gamePayload.OpponentImages = gamePayload.BROpponents[0].Images
-				gamePayload.OpponentCurrentScore = gamePayload.BROpponents[0].Score
-			}
-			// If we have a target for this slot, include it
-			if i < len(gameStates) && gameStates[i] != nil {
-				gamePayload.Target = gameStates[i].Target
-			}
+	oppPayload := protocol.OpponentUpdatePayload{Images: opponentImages, Score: opponentScore, Combo: 0, BROpponents: brOpponents}
+	if len(brOpponents) > 0 {
+		oppPayload.Score = brOpponents[0].Score
+		oppPayload.Combo = brOpponents[0].Combo
+	}
+	bOpp, _ := json.Marshal(oppPayload)
+	_ = h.wsManager.SendToClient(clientID, protocol.Message{Type: protocol.TypeOpponentUpdate, Seq: seq, Payload: bOpp})
+	h.opponentViews.observe(clientID, brOpponents)
+	return true
+}
 
-			b, _ := json.Marshal(gamePayload)
+// handleResume はRESUMEメッセージを処理
+// 最後に受信したシーケンス番号以降のイベントを再送し、欠落を埋められない場合はスナップショットで同期する
+func (h *WebSocketHandler) handleResume(clientID string, requestID string, payload json.RawMessage) {
+	var p protocol.ResumePayload
+	if err := json.Unmarshal(payload, &p); err != nil || p.PlayerID == "" {
+		return
+	}
 
-			for _, cID := range h.wsManager.GetClientIDsByPlayerID(player.ID) {
-				_ = h.wsManager.SendToClient(cID, Message{Type: "GAME_START", Payload: b})
-			}
+	sessionID := p.SessionID
+	if sessionID == "" {
+		sessionID = p.PlayerID
+	}
+	// ページの再読み込みなどでプレイヤーIDが変わっていても、保存されたセッションから元のプレイヤーに戻す
+	// この場合クライアントは何も受信していないので、再送ではなくスナップショットで同期する
+	rebound := false
+	if playerID := h.resolveSessionPlayer(sessionID, p.PlayerID); playerID != p.PlayerID {
+		h.clientLogger(clientID, protocol.TypeResume).Info("session rebound to previous player", "session_id", sessionID, "player_id", p.PlayerID, "previous_player_id", playerID)
+		p.PlayerID = playerID
+		rebound = true
+	}
+
+	roomID := p.RoomID
+	room, err := h.roomRepo.FindByPlayerID(p.PlayerID)
+	if err == nil && room != nil {
+		h.bindSession(sessionID, p.PlayerID, room.ID)
+		h.cancelGracefulLeave(sessionID)
+		h.wsManager.AssignClientToPlayer(clientID, p.PlayerID)
+		h.wsManager.AssignClientToRoom(clientID, room.ID)
+		roomID = room.ID
+		if rebound {
+			bAssigned, _ := json.Marshal(protocol.RoomAssignedPayload{RoomID: room.ID, PlayerID: p.PlayerID})
+			h.reply(clientID, requestID, protocol.TypeRoomAssigned, bAssigned)
+		}
+	}
+
+	result := protocol.ResumeResultPayload{RoomID: roomID, PlayerID: p.PlayerID, Mode: "none"}
+	if missed, ok := h.events.since(roomID, p.PlayerID, p.LastSeq); ok && !rebound {
+		for _, msg := range missed {
+			_ = h.wsManager.SendToClient(clientID, msg)
+		}
+		result.Mode = "replay"
+		result.Replayed = len(missed)
+	} else if room != nil {
+		if h.sendResyncSnapshot(clientID, requestID, room, p.PlayerID) {
+			result.Mode = "snapshot"
+		} else {
+			result.Mode = "waiting"
 		}
-	} else {
-		// 相手を待機中
-		_ = h.wsManager.SendToClient(clientID, Message{Type: "STATUS_UPDATE", Payload: json.RawMessage(`{"status": "waiting_for_opponent"}`)})
 	}
+	result.LastSeq = h.events.latestSeq(roomID)
+
+	b, _ := json.Marshal(result)
+	h.reply(clientID, requestID, protocol.TypeResumed, b)
 }
 
 // handleLeaveRoom はLEAVE_ROOMメッセージを処理
 func (h *WebSocketHandler) handleLeaveRoom(clientID string, payload json.RawMessage) {
-	var p LeaveRoomPayload
+	var p protocol.LeaveRoomPayload
 	if err := json.Unmarshal(payload, &p); err != nil {
 		return
 	}
@@ -561,19 +1020,24 @@ func (h *WebSocketHandler) handleLeaveRoom(clientID string, payload json.RawMess
 		PlayerID: p.PlayerID,
 	}
 
-	h.leaveAndNotify(input, "Opponent Disconnected")
+	h.leaveAndNotify(input)
 }
 
 // handleSelectImage はSELECT_IMAGEメッセージを処理
 func (h *WebSocketHandler) handleSelectImage(clientID string, payload json.RawMessage) {
-	var p SelectImagePayload
+	var p protocol.SelectImagePayload
 	if err := json.Unmarshal(payload, &p); err != nil {
 		return
 	}
+	// 選択の間隔をボット判定に使う（他人のIDを詐称して隔離させないよう接続に紐付いたIDで記録する）
+	if playerID, ok := h.wsManager.GetPlayerID(clientID); ok {
+		_, _ = h.botDetectionUC.ObserveSelection(playerID, h.clientRemoteIP(clientID))
+	}
 
 	// ルームの相手に通知
 	roomID, ok := h.wsManager.GetRoomID(clientID)
 	if ok {
+		msg := h.events.record(roomID, "", p.PlayerID, protocol.Message{Type: protocol.TypeOpponentSelect, Payload: payload})
 		for _, cID := range h.wsManager.GetClientIDsByRoomIDExcept(roomID, clientID) {
 			// 同一プレイヤーの別タブには送らない
 			if pid, ok := h.wsManager.GetPlayerID(cID); ok {
@@ -581,139 +1045,255 @@ func (h *WebSocketHandler) handleSelectImage(clientID string, payload json.RawMe
 					continue
 				}
 			}
-			_ = h.wsManager.SendToClient(cID, Message{Type: "OPPONENT_SELECT", Payload: payload})
+			_ = h.wsManager.SendToClient(cID, msg)
 		}
 	}
 }
 
 // handleVerify はVERIFYメッセージを処理
-func (h *WebSocketHandler) handleVerify(clientID string, conn *websocket.Conn, payload json.RawMessage) {
-	var p VerifyPayload
+// 他人のIDを詐称してロックアウトや減点をさせないよう、プレイヤーは接続に紐付いたIDで決める
+func (h *WebSocketHandler) handleVerify(clientID string, requestID string, conn *websocket.Conn, payload json.RawMessage) {
+	var p protocol.VerifyPayload
 	if err := json.Unmarshal(payload, &p); err != nil {
 		return
 	}
+	playerID, ok := h.wsManager.GetPlayerID(clientID)
+	if !ok {
+		h.metrics.verify(VerifyOutcomeError)
+		h.clientLogger(clientID, protocol.TypeVerify).Info("verify from a client not bound to a player", "room_id", p.RoomID, "player_id", p.PlayerID)
+		return
+	}
 
 	input := usecase.VerifyAnswerInput{
 		RoomID:          p.RoomID,
-		PlayerID:        p.PlayerID,
+		PlayerID:        playerID,
 		Target:          p.Target,
 		SelectedIndices: p.SelectedIndices,
+		ClientID:        clientID,
+		RequestID:       requestID,
 	}
 
+	// 結果の通知は PlayerScored / AnswerRejected などのイベントの購読で行う
 	output, err := h.verifyAnswerUC.Execute(input)
-	if err != nil || output == nil {
+	if err != nil {
+		h.metrics.verify(VerifyOutcomeError)
+		h.clientLogger(clientID, protocol.TypeVerify).Info("verify failed", "room_id", p.RoomID, "player_id", playerID, "error", err)
+		return
+	}
+	if output == nil {
+		h.metrics.verify(VerifyOutcomeStale)
 		return
 	}
-
 	if output.IsCorrect {
-		// ゲーム終了判定を最優先で行う
-		if output.IsGameOver {
-			res := GameResultPayload{
-				WinnerID: output.Winner,
-				Message:  "You are Human!",
-			}
-			b, _ := json.Marshal(res)
-			h.broadcastToRoom(p.RoomID, Message{Type: "GAME_FINISHED", Payload: b})
-			if room, err := h.roomRepo.FindByID(p.RoomID); err == nil && room != nil {
-				h.cleanupFinishedRoom(room)
-			}
-			return
-		}
+		// 出題から正解までの時間をボット判定に使う
+		_, _ = h.botDetectionUC.ObserveSolve(playerID, h.clientRemoteIP(clientID), output.SolveTime)
+	}
+}
 
-		// 正解：自分に新しい問題と現在のスコア/コンボを送信して同期
-		updateMy := UpdatePatternPayload{
-			Target:       output.NewTarget,
-			Images:       output.NewImages,
-			CurrentScore: output.CurrentScore,
-			CurrentCombo: output.CurrentCombo,
-		}
-		bMy, _ := json.Marshal(updateMy)
-		_ = h.wsManager.SendToClient(clientID, Message{Type: "UPDATE_PATTERN", Payload: bMy})
-
-		// 相手に状態更新を送信
-		roomID, _ := h.wsManager.GetRoomID(clientID)
-		if roomID != "" {
-			updateOpp := OpponentUpdatePayload{
-				Images:      output.NewImages,
-				Score:       output.CurrentScore,
-				Combo:       output.CurrentCombo,
-				BROpponents: make([]BROpponentPayload, 0, len(output.BROpponents)),
-			}
-			for _, opp := range output.BROpponents {
-				updateOpp.BROpponents = append(updateOpp.BROpponents, BROpponentPayload{
-					PlayerID: opp.PlayerID,
-					Target:   opp.Target,
-					Images:   append([]string(nil), opp.Images...),
-					Score:    opp.Score,
-					Combo:    opp.Combo,
-					Effect:   opp.Effect,
-				})
-			}
-			// 各受信者ごとに「その受信者から見た」BRスナップショットを再構築して送信する
-			for _, cID := range h.wsManager.GetClientIDsByRoomIDExcept(roomID, clientID) {
-				// 同一プレイヤーの別タブにはOPPONENT_UPDATEを送らない
-				if pid, ok := h.wsManager.GetPlayerID(cID); ok {
-					if pid == p.PlayerID {
-						continue
-					}
-				}
+// handleUseItem はUSE_ITEMメッセージを処理
+// 他人の持ち物を使えないよう、プレイヤーは接続に紐付いたIDで決める
+// 使用結果は ItemUsed の購読でルーム全員に通知し、使えなかった場合だけ ITEM_REJECTED を直接応答する
+func (h *WebSocketHandler) handleUseItem(clientID string, requestID string, payload json.RawMessage) {
+	var p protocol.UseItemPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return
+	}
+	playerID, ok := h.wsManager.GetPlayerID(clientID)
+	if !ok {
+		h.rejectItem(clientID, requestID, p.Item, itemRejectNotActive, nil)
+		return
+	}
+	if p.RoomID == "" {
+		p.RoomID, _ = h.wsManager.GetRoomID(clientID)
+	}
 
-				// 最新のルーム情報を取得して、受信者視点でスナップショットを作る
-				roomLatest, err := h.roomRepo.FindByID(roomID)
-				if err != nil || roomLatest == nil {
-					continue
-				}
+	output, err := h.useItemUC.Execute(usecase.UseItemInput{
+		RoomID:    p.RoomID,
+		PlayerID:  playerID,
+		Item:      p.Item,
+		ClientID:  clientID,
+		RequestID: requestID,
+	})
+	if err == nil {
+		return
+	}
+	h.clientLogger(clientID, protocol.TypeUseItem).Debug("item rejected", "room_id", p.RoomID, "player_id", playerID, "item", p.Item, "error", err)
+	h.rejectItem(clientID, requestID, p.Item, itemRejectReason(err), output)
+}
 
-				targetPlayerID, ok := h.wsManager.GetPlayerID(cID)
-				if !ok || targetPlayerID == "" {
-					continue
-				}
+// ITEM_REJECTED の理由
+const (
+	itemRejectNotHeld      = "not_held"
+	itemRejectCooldown     = "cooldown"
+	itemRejectNoEffect     = "no_effect"
+	itemRejectShieldActive = "shield_active"
+	itemRejectNoTarget     = "no_target"
+	itemRejectNotActive    = "not_active"
+)
 
-				snaps := h.buildBROpponentSnapshots(roomLatest, targetPlayerID)
+// itemRejectReason はアイテムを使えなかったエラーを ITEM_REJECTED の理由に変換する
+func itemRejectReason(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrItemCooldown):
+		return itemRejectCooldown
+	case errors.Is(err, domain.ErrNoActiveEffect):
+		return itemRejectNoEffect
+	case errors.Is(err, domain.ErrShieldActive):
+		return itemRejectShieldActive
+	case errors.Is(err, domain.ErrNoReflectTarget):
+		return itemRejectNoTarget
+	case errors.Is(err, domain.ErrItemNotHeld):
+		return itemRejectNotHeld
+	default:
+		return itemRejectNotActive
+	}
+}
 
-				updateOppForRecipient := OpponentUpdatePayload{
-					Images:      output.NewImages,
-					Score:       output.CurrentScore,
-					Combo:       output.CurrentCombo,
-					BROpponents: snaps,
-				}
-				bOppRec, _ := json.Marshal(updateOppForRecipient)
-				_ = h.wsManager.SendToClient(cID, Message{Type: "OPPONENT_UPDATE", Payload: bOppRec})
-			}
+func (h *WebSocketHandler) rejectItem(clientID string, requestID string, item string, reason string, output *usecase.UseItemOutput) {
+	rejected := protocol.ItemRejectedPayload{Item: item, Reason: reason, Items: []string{}}
+	if output != nil {
+		rejected.CooldownRemainingMs = output.CooldownRemaining.Milliseconds()
+		rejected.Items = output.Items
+	}
+	b, _ := json.Marshal(rejected)
+	h.reply(clientID, requestID, protocol.TypeItemRejected, b)
+}
 
-			// 妨害エフェクト送信: プレイヤー発の場合はランダムに1人の相手のみを標的にする
-			if output.SendObstruction {
-				if output.TargetPlayer != "" {
-					obs := ObstructionPayload{
-						Effect:     output.Effect,
-						AttackerID: p.PlayerID,
-						TargetID:   output.TargetPlayer,
-					}
-					bObs, _ := json.Marshal(obs)
-					h.broadcastToRoom(roomID, Message{Type: "OBSTRUCTION", Payload: bObs})
-					confirm := ObstructionPayload{
-						Effect:     output.Effect,
-						AttackerID: p.PlayerID,
-						TargetID:   output.TargetPlayer,
-					}
-					bConfirm, _ := json.Marshal(confirm)
-					for _, cID := range h.wsManager.GetClientIDsByPlayerID(p.PlayerID) {
-						_ = h.wsManager.SendToClient(cID, Message{Type: "OBSTRUCTION_FIRED", Payload: bConfirm})
-					}
-				}
+// handleSetTargeting はSET_TARGETINGメッセージで妨害を送る相手の選び方を設定する
+// 他人の設定を変えられないよう、プレイヤーは接続に紐付いたIDで決める
+func (h *WebSocketHandler) handleSetTargeting(clientID string, requestID string, payload json.RawMessage) {
+	var p protocol.SetTargetingPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return
+	}
+	playerID, _ := h.wsManager.GetPlayerID(clientID)
+	if p.RoomID == "" {
+		p.RoomID, _ = h.wsManager.GetRoomID(clientID)
+	}
+	err := h.targetingUC.SetPreference(usecase.SetTargetingInput{RoomID: p.RoomID, PlayerID: playerID, Mode: p.Mode, TargetID: p.TargetID})
+	if err != nil {
+		h.rejectTarget(clientID, requestID, protocol.TypeSetTargeting, err)
+	}
+}
+
+// handleSelectTarget はSELECT_TARGETメッセージで、選択を待っている妨害を選んだ相手に送る
+// 結果は ComboObstructionFired の購読で OBSTRUCTION として通知する
+func (h *WebSocketHandler) handleSelectTarget(clientID string, requestID string, payload json.RawMessage) {
+	var p protocol.SelectTargetPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return
+	}
+	playerID, _ := h.wsManager.GetPlayerID(clientID)
+	if p.RoomID == "" {
+		p.RoomID, _ = h.wsManager.GetRoomID(clientID)
+	}
+	err := h.targetingUC.Choose(usecase.ChooseTargetInput{RoomID: p.RoomID, PlayerID: playerID, TargetID: p.TargetID, ClientID: clientID, RequestID: requestID})
+	if err != nil {
+		h.rejectTarget(clientID, requestID, protocol.TypeSelectTarget, err)
+	}
+}
+
+func (h *WebSocketHandler) rejectTarget(clientID string, requestID string, msgType string, err error) {
+	h.clientLogger(clientID, msgType).Debug("targeting rejected", "error", err)
+	b, _ := json.Marshal(protocol.ErrorPayload{Code: protocol.ErrorCodeInvalidTarget, Message: err.Error(), Type: msgType})
+	h.reply(clientID, requestID, protocol.TypeError, b)
+}
+
+// publishOpponentUpdate は回答したプレイヤー以外のクライアントに相手状態の更新を配信する
+// ルームのスナップショットは一度だけ構築し、受信者ごとに自分を除いた一覧を送る
+// delta 拡張をネゴシエートしたクライアントには、前回から変化したフィールドだけを OPPONENT_DELTA で送る
+// images は回答者の問題がルームから取れない場合の旧形式の images
+func (h *WebSocketHandler) publishOpponentUpdate(roomID string, senderClientID string, senderPlayerID string, score int, combo int, images []string) {
+	room, err := h.roomRepo.FindByID(roomID)
+	if err != nil || room == nil {
+		return
+	}
+	all := h.buildBROpponentSnapshots(room, "")
+	// 旧形式の images は回答者の現在の問題（差し替えがなかった場合も空にしない）
+	senderImages := images
+	if gs := room.GetGameStateByPlayerID(senderPlayerID); gs != nil {
+		senderImages = gs.Images
+	}
+
+	// 同一プレイヤーの複数タブには同じイベント（同じシーケンス番号）を送る
+	views := make(map[string][]protocol.BROpponentPayload)
+	recorded := make(map[string]protocol.Message)
+	for _, cID := range h.wsManager.GetClientIDsByRoomIDExcept(roomID, senderClientID) {
+		// 同一プレイヤーの別タブにはOPPONENT_UPDATEを送らない
+		targetPlayerID, ok := h.wsManager.GetPlayerID(cID)
+		if !ok || targetPlayerID == "" || targetPlayerID == senderPlayerID {
+			continue
+		}
+
+		msg, ok := recorded[targetPlayerID]
+		if !ok {
+			views[targetPlayerID] = excludePlayer(all, targetPlayerID)
+			update := protocol.OpponentUpdatePayload{
+				Images:      senderImages,
+				Score:       score,
+				Combo:       combo,
+				BROpponents: views[targetPlayerID],
 			}
+			b, _ := json.Marshal(update)
+			msg = h.events.record(roomID, targetPlayerID, "", protocol.Message{Type: protocol.TypeOpponentUpdate, Payload: b})
+			recorded[targetPlayerID] = msg
 		}
-	} else {
-		// 不正解
-		_ = h.wsManager.SendToClient(clientID, Message{Type: "VERIFY_FAILED", Payload: json.RawMessage(`{}`)})
+
+		if h.wsManager.HasCapability(cID, "delta") {
+			delta := h.opponentViews.diff(cID, views[targetPlayerID])
+			b, _ := json.Marshal(delta)
+			_ = h.wsManager.SendToClient(cID, protocol.Message{Type: protocol.TypeOpponentDelta, Seq: msg.Seq, Payload: b})
+			continue
+		}
+		_ = h.wsManager.SendToClient(cID, msg)
+	}
+}
+
+// handleRequestKeyframe はREQUEST_KEYFRAMEメッセージを処理し、相手状態の全量を OPPONENT_DELTA で送る
+func (h *WebSocketHandler) handleRequestKeyframe(clientID string, requestID string) {
+	roomID, ok := h.wsManager.GetRoomID(clientID)
+	if !ok {
+		return
+	}
+	playerID, ok := h.wsManager.GetPlayerID(clientID)
+	if !ok {
+		return
 	}
+	room, err := h.roomRepo.FindByID(roomID)
+	if err != nil || room == nil {
+		return
+	}
+
+	h.opponentViews.reset(clientID)
+	delta := h.opponentViews.diff(clientID, h.buildBROpponentSnapshots(room, playerID))
+	b, _ := json.Marshal(delta)
+	_ = h.wsManager.SendToClient(clientID, protocol.Message{Type: protocol.TypeOpponentDelta, ID: requestID, Seq: h.events.latestSeq(roomID), Payload: b})
 }
 
 // broadcastToRoom はルーム内全員にメッセージを送信
-func (h *WebSocketHandler) broadcastToRoom(roomID string, msg Message) {
+// 送信前にルームイベントとして採番・記録し、切断中のプレイヤーが RESUME で受け取れるようにする
+func (h *WebSocketHandler) broadcastToRoom(roomID string, msg protocol.Message) {
+	msg = h.events.record(roomID, "", "", msg)
 	h.wsManager.SendToRoom(roomID, msg)
 }
 
+// sendToPlayer はプレイヤーの全クライアントにルームイベントを採番・記録して送信する
+func (h *WebSocketHandler) sendToPlayer(roomID string, playerID string, msg protocol.Message) {
+	msg = h.events.record(roomID, playerID, "", msg)
+	for _, cID := range h.wsManager.GetClientIDsByPlayerID(playerID) {
+		_ = h.wsManager.SendToClient(cID, msg)
+	}
+}
+
+// clientRemoteIP はクライアントの接続元IPを返す（他のノードの接続は転送元が伝えたIP。不明なら空）
+func (h *WebSocketHandler) clientRemoteIP(clientID string) string {
+	if ip, ok := h.limiter.clientAddress(clientID); ok {
+		return ip
+	}
+	ip, _ := h.wsManager.RemoteClientIP(clientID)
+	return ip
+}
+
 // getPlayerIDByClientID はクライアントIDからプレイヤーIDを取得（ここは改善可能）
 func (h *WebSocketHandler) getPlayerIDByClientID(clientID string) (string, error) {
 	if playerID, ok := h.wsManager.GetPlayerID(clientID); ok {
@@ -722,19 +1302,6 @@ func (h *WebSocketHandler) getPlayerIDByClientID(clientID string) (string, error
 	return "", fmt.Errorf("player id not found for client %s", clientID)
 }
 
-func (h *WebSocketHandler) bindSession(sessionID string, playerID string) {
-	h.sessionMu.Lock()
-	defer h.sessionMu.Unlock()
-	h.sessionToPlayer[sessionID] = playerID
-	h.playerToSession[playerID] = sessionID
-}
-
-func (h *WebSocketHandler) getSessionIDByPlayerID(playerID string) string {
-	h.sessionMu.Lock()
-	defer h.sessionMu.Unlock()
-	return h.playerToSession[playerID]
-}
-
 func (h *WebSocketHandler) cancelGracefulLeave(sessionID string) {
 	h.sessionMu.Lock()
 	defer h.sessionMu.Unlock()
@@ -747,15 +1314,17 @@ func (h *WebSocketHandler) cancelGracefulLeave(sessionID string) {
 func (h *WebSocketHandler) scheduleGracefulLeave(playerID string) {
 	sessionID := h.getSessionIDByPlayerID(playerID)
 	if sessionID == "" {
-		h.leaveAndNotify(usecase.LeaveRoomInput{ClientID: "", PlayerID: playerID}, "Opponent Disconnected")
+		h.logger.Info("player disconnected without session; removing immediately", "player_id", playerID)
+		h.leaveAndNotify(usecase.LeaveRoomInput{ClientID: "", PlayerID: playerID, Disconnected: true})
 		return
 	}
+	h.logger.Debug("player disconnected; waiting for reconnect", "player_id", playerID, "session_id", sessionID, "grace", h.settings.GracePeriod)
 
 	h.sessionMu.Lock()
 	if t, ok := h.graceTimers[sessionID]; ok {
 		t.Stop()
 	}
-	h.graceTimers[sessionID] = time.AfterFunc(10*time.Second, func() {
+	h.graceTimers[sessionID] = time.AfterFunc(h.settings.GracePeriod, func() {
 		if currentSessionID := h.getSessionIDByPlayerID(playerID); currentSessionID != sessionID {
 			h.sessionMu.Lock()
 			delete(h.graceTimers, sessionID)
@@ -768,7 +1337,9 @@ func (h *WebSocketHandler) scheduleGracefulLeave(playerID string) {
 			h.sessionMu.Unlock()
 			return
 		}
-		h.leaveAndNotify(usecase.LeaveRoomInput{ClientID: "", PlayerID: playerID}, "Opponent Disconnected")
+		h.metrics.gracePeriodExpired()
+		h.logger.Info("reconnect grace period expired; removing player", "player_id", playerID, "session_id", sessionID)
+		h.leaveAndNotify(usecase.LeaveRoomInput{ClientID: "", PlayerID: playerID, Disconnected: true})
 		h.sessionMu.Lock()
 		delete(h.graceTimers, sessionID)
 		h.sessionMu.Unlock()
@@ -776,108 +1347,51 @@ func (h *WebSocketHandler) scheduleGracefulLeave(playerID string) {
 	h.sessionMu.Unlock()
 }
 
-func (h *WebSocketHandler) leaveAndNotify(input usecase.LeaveRoomInput, message string) {
+// leaveAndNotify はプレイヤーをルームから退出させる
+// 残りのプレイヤーへの通知や不戦勝の判定は PlayerLeft / GameFinished の購読で行う
+func (h *WebSocketHandler) leaveAndNotify(input usecase.LeaveRoomInput) {
 	sessionID := h.getSessionIDByPlayerID(input.PlayerID)
 	h.cancelGracefulLeave(sessionID)
-	room, _ := h.roomRepo.FindByPlayerID(input.PlayerID)
-	var roomID string
-	if room != nil {
-		roomID = room.ID
-	}
-	clientIDs := h.wsManager.GetClientIDsByPlayerID(input.PlayerID)
-
-	h.leaveRoomUC.Execute(input)
+	h.logger.Info("removing player from room", "player_id", input.PlayerID, "client_id", input.ClientID)
 
-	for _, cID := range clientIDs {
+	// 退出するプレイヤー自身には退出の通知を送らないよう、先にルームとの紐付けを外す
+	for _, cID := range h.wsManager.GetClientIDsByPlayerID(input.PlayerID) {
 		h.wsManager.RemoveClientAssociation(cID)
 	}
+	h.leaveRoomUC.Execute(input)
+	h.requests.forget(input.PlayerID)
 
-	if roomID != "" {
-		updatedRoom, err := h.roomRepo.FindByID(roomID)
-		if err == nil && updatedRoom != nil {
-			remaining := updatedRoom.CountPlayers()
-			if remaining >= 2 {
-				status := struct {
-					Message          string `json:"message"`
-					PlayerID         string `json:"player_id"`
-					RemainingPlayers int    `json:"remaining_players"`
-				}{Message: message, PlayerID: input.PlayerID, RemainingPlayers: remaining}
-				b, _ := json.Marshal(status)
-				h.broadcastToRoom(roomID, Message{Type: "STATUS_UPDATE", Payload: b})
-			} else if remaining == 1 {
-				var winnerID string
-				if updatedRoom.Player1 != nil && updatedRoom.Player1.ID != "" {
-					winnerID = updatedRoom.Player1.ID
-				} else if updatedRoom.Player2 != nil && updatedRoom.Player2.ID != "" {
-					winnerID = updatedRoom.Player2.ID
-				} else {
-					for _, p := range updatedRoom.ExtraPlayers {
-						if p != nil && p.ID != "" {
-							winnerID = p.ID
-							break
-						}
-					}
-				}
-				if winnerID != "" && updatedRoom.IsActive {
-					res := GameResultPayload{WinnerID: winnerID, Message: message}
-					b, _ := json.Marshal(res)
-					for _, cID := range h.wsManager.GetClientIDsByPlayerID(winnerID) {
-						_ = h.wsManager.SendToClient(cID, Message{Type: "GAME_FINISHED", Payload: b})
-					}
-					h.cleanupFinishedRoom(updatedRoom)
-				}
-			}
-		}
-	}
-
-	// sessionToPlayer から削除（メモリリーク防止）
-	if sessionID != "" {
-		h.sessionMu.Lock()
-		delete(h.sessionToPlayer, sessionID)
-		delete(h.playerToSession, input.PlayerID)
-		h.sessionMu.Unlock()
-	}
+	// セッションを削除（退出後に同じセッションで戻っても元のルームには入らない）
+	h.forgetSession(sessionID)
 }
 
-func (h *WebSocketHandler) cleanupFinishedRoom(room *domain.Room) {
-	playerIDs := make([]string, 0, room.CountPlayers())
-	if room.Player1 != nil && room.Player1.ID != "" {
-		playerIDs = append(playerIDs, room.Player1.ID)
-	}
-	if room.Player2 != nil && room.Player2.ID != "" {
-		playerIDs = append(playerIDs, room.Player2.ID)
-	}
-	for _, p := range room.ExtraPlayers {
-		if p != nil && p.ID != "" {
-			playerIDs = append(playerIDs, p.ID)
-		}
-	}
-
+// cleanupFinishedRoom は終了・削除したルームのプレイヤーの接続とセッションを片付ける
+func (h *WebSocketHandler) cleanupFinishedRoom(roomID string, playerIDs []string) {
 	for _, playerID := range playerIDs {
 		clientIDs := h.wsManager.GetClientIDsByPlayerID(playerID)
 		for _, clientID := range clientIDs {
 			_ = h.leaveRoomUC.Execute(usecase.LeaveRoomInput{ClientID: clientID, PlayerID: playerID})
 			h.wsManager.RemoveClientAssociation(clientID)
 		}
+		h.requests.forget(playerID)
 		sessionID := h.getSessionIDByPlayerID(playerID)
 		if sessionID != "" {
 			h.cancelGracefulLeave(sessionID)
-			h.sessionMu.Lock()
-			delete(h.sessionToPlayer, sessionID)
-			delete(h.playerToSession, playerID)
-			h.sessionMu.Unlock()
+			h.forgetSession(sessionID)
 		}
 	}
-	_ = h.roomRepo.Delete(room.ID)
+	_ = h.roomRepo.Delete(roomID)
+	h.events.retire(roomID, h.settings.GracePeriod)
+	h.logger.Info("finished room cleaned up", "room_id", roomID, "players", playerIDs)
 }
 
-func (h *WebSocketHandler) buildBROpponentSnapshots(room *domain.Room, playerID string) []BROpponentPayload {
-	snapshots := make([]BROpponentPayload, 0, room.CountPlayers())
+func (h *WebSocketHandler) buildBROpponentSnapshots(room *domain.Room, playerID string) []protocol.BROpponentPayload {
+	snapshots := make([]protocol.BROpponentPayload, 0, room.CountPlayers())
 	appendSnapshot := func(player *domain.Player, gameState *domain.GameState) {
 		if player == nil || player.ID == "" || player.ID == playerID {
 			return
 		}
-		snapshot := BROpponentPayload{
+		snapshot := protocol.BROpponentPayload{
 			PlayerID:   player.ID,
 			Score:      player.Score,
 			Combo:      player.Combo,
@@ -905,99 +1419,14 @@ func (h *WebSocketHandler) buildBROpponentSnapshots(room *domain.Room, playerID
 }
 
 func (h *WebSocketHandler) heartbeatPump(clientID string, conn *websocket.Conn) {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(h.settings.HeartbeatInterval)
 	defer ticker.Stop()
-	const pongTimeout = 45 * time.Second
 
 	for range ticker.C {
-		if h.wsManager.IsPongTimedOut(clientID, pongTimeout) {
+		if h.wsManager.IsPongTimedOut(clientID, h.settings.PongTimeout) {
 			_ = conn.Close()
 			return
 		}
-		_ = h.wsManager.SendToClient(clientID, Message{Type: "PING", Payload: json.RawMessage(`{}`)})
+		_ = h.wsManager.SendToClient(clientID, protocol.Message{Type: protocol.TypePing, Payload: json.RawMessage(`{}`)})
 	}
 }
-
-// Message はWebSocketメッセージ
-type Message struct {
-	Type    string          `json:"type"`
-	Payload json.RawMessage `json:"payload"`
-}
-
-// DTO (Data Transfer Object) 定義
-type JoinRoomPayload struct {
-	RoomID       string `json:"room_id"`
-	PlayerID     string `json:"player_id"`
-	WinningScore int    `json:"winning_score"`
-	SessionID    string `json:"session_id"`
-	Capacity     int    `json:"capacity,omitempty"`
-}
-
-type LeaveRoomPayload struct {
-	PlayerID string `json:"player_id"`
-}
-
-type RoomAssignedPayload struct {
-	RoomID   string `json:"room_id"`
-	PlayerID string `json:"player_id"`
-}
-
-type GameStartPayload struct {
-	Target               string              `json:"target"`
-	Images               []string            `json:"images"`
-	OpponentImages       []string            `json:"opponent_images"`
-	WinningScore         int                 `json:"winning_score"`
-	MyCurrentScore       int                 `json:"my_current_score,omitempty"`
-	MyCurrentCombo       int                 `json:"my_current_combo,omitempty"`
-	OpponentCurrentScore int                 `json:"opponent_current_score,omitempty"`
-	PlayerEffect         string              `json:"player_effect,omitempty"`
-	BROpponents          []BROpponentPayload `json:"br_opponents,omitempty"`
-}
-
-type VerifyPayload struct {
-	RoomID          string `json:"room_id"`
-	PlayerID        string `json:"player_id"`
-	Target          string `json:"target"`
-	SelectedIndices []int  `json:"selected_indices"`
-}
-
-type UpdatePatternPayload struct {
-	Target       string   `json:"target"`
-	Images       []string `json:"images"`
-	CurrentScore int      `json:"current_score,omitempty"`
-	CurrentCombo int      `json:"current_combo,omitempty"`
-}
-
-type OpponentUpdatePayload struct {
-	Images      []string            `json:"images"`
-	Score       int                 `json:"score"`
-	Combo       int                 `json:"combo"`
-	BROpponents []BROpponentPayload `json:"br_opponents,omitempty"`
-}
-
-type BROpponentPayload struct {
-	PlayerID   string   `json:"player_id"`
-	Target     string   `json:"target"`
-	Images     []string `json:"images"`
-	Score      int      `json:"score"`
-	Combo      int      `json:"combo"`
-	Effect     string   `json:"effect,omitempty"`
-	Selections []int    `json:"selections"`
-}
-
-type SelectImagePayload struct {
-	RoomID     string `json:"room_id"`
-	PlayerID   string `json:"player_id"`
-	ImageIndex int    `json:"image_index"`
-}
-
-type ObstructionPayload struct {
-	Effect     string `json:"effect"`
-	AttackerID string `json:"attacker_id"`
-	TargetID   string `json:"target_id"`
-}
-
-type GameResultPayload struct {
-	WinnerID string `json:"winner_id"`
-	Message  string `json:"message"`
-}
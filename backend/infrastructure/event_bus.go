@@ -0,0 +1,56 @@
+package infrastructure
+
+import (
+	"log/slog"
+	"sync"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/logging"
+)
+
+type eventSubscriber struct {
+	name   string
+	handle func(domain.Event)
+}
+
+// InProcessEventBus はドメインイベントをプロセス内の購読者に配る EventPublisher
+// Publish を呼んだゴルーチンで購読順に同期的に配り、ある購読者の panic は他の購読者に影響させない
+type InProcessEventBus struct {
+	mu          sync.RWMutex
+	subscribers []eventSubscriber
+	logger      *slog.Logger
+}
+
+// NewInProcessEventBus は新しいInProcessEventBusを生成
+func NewInProcessEventBus(logger *slog.Logger) *InProcessEventBus {
+	return &InProcessEventBus{logger: logging.OrDiscard(logger)}
+}
+
+// Subscribe は全てのイベントを受け取る購読者を登録する（name はログ用）
+func (b *InProcessEventBus) Subscribe(name string, handle func(domain.Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, eventSubscriber{name: name, handle: handle})
+}
+
+// Publish はイベントを発行順に全ての購読者へ配る
+func (b *InProcessEventBus) Publish(events ...domain.Event) {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, event := range events {
+		for _, s := range subscribers {
+			b.deliver(s, event)
+		}
+	}
+}
+
+func (b *InProcessEventBus) deliver(s eventSubscriber, event domain.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.logger.Error("event subscriber panicked", "subscriber", s.name, "event", event.EventType(), "room_id", event.Meta().RoomID, "panic", r)
+		}
+	}()
+	s.handle(event)
+}
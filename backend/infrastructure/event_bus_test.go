@@ -0,0 +1,30 @@
+package infrastructure
+
+import (
+	"testing"
+
+	"recaptchgame-backend/domain"
+)
+
+// TestInProcessEventBus はイベントバスの配信順と購読者の独立性のテスト
+func TestInProcessEventBus(t *testing.T) {
+	bus := NewInProcessEventBus(nil)
+	var got []string
+	bus.Subscribe("broken", func(domain.Event) { panic("boom") })
+	bus.Subscribe("recorder", func(e domain.Event) { got = append(got, e.EventType()+":"+e.Meta().RoomID) })
+
+	// テスト1: 発行順に全ての購読者へ配り、panic した購読者があっても他の購読者には届く
+	bus.Publish(
+		domain.GameStarted{EventMeta: domain.EventMeta{RoomID: "room1"}},
+		domain.PlayerLeft{EventMeta: domain.EventMeta{RoomID: "room1"}},
+	)
+	if len(got) != 2 || got[0] != "GameStarted:room1" || got[1] != "PlayerLeft:room1" {
+		t.Errorf("expected events in publish order, got %v", got)
+	}
+
+	// テスト2: イベントがなければ何もしない
+	bus.Publish()
+	if len(got) != 2 {
+		t.Errorf("expected no delivery, got %v", got)
+	}
+}
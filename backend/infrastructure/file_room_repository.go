@@ -0,0 +1,121 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"recaptchgame-backend/domain"
+)
+
+// FileRoomRepository はJSONファイルに書き出すルームリポジトリ
+// 再起動後も保存されたセッションのプレイヤーをルームに戻せるように、ルームとマッチング待機ルームを保持する
+// FileSessionRepository と同じく変更のたびに全件を一時ファイルに書いてから rename する
+type FileRoomRepository struct {
+	*MemoryRoomRepository
+	path    string
+	flushMu sync.Mutex // 書き出しの順序を保つ（古い内容で新しい内容を上書きしない）
+}
+
+// roomStoreFile はファイルに書き出す内容
+type roomStoreFile struct {
+	Rooms        []*domain.Room       `json:"rooms"`
+	WaitingRooms map[int]*domain.Room `json:"waiting_rooms"`
+}
+
+// NewFileRoomRepository は path のファイルから既存のルームを読み込んで生成する
+// ファイルがなければ空の状態から始める
+func NewFileRoomRepository(path string) (*FileRoomRepository, error) {
+	r := &FileRoomRepository{MemoryRoomRepository: NewMemoryRoomRepository(), path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("room store: %w", err)
+	}
+	var stored roomStoreFile
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return nil, fmt.Errorf("room store: %s: %w", path, err)
+	}
+	for _, room := range stored.Rooms {
+		if room != nil {
+			r.rooms[room.ID] = room
+		}
+	}
+	for capacity, room := range stored.WaitingRooms {
+		if room != nil {
+			r.waitingRooms[capacity] = room
+		}
+	}
+	return r, nil
+}
+
+// Save はルームを保存してファイルに書き出す
+func (r *FileRoomRepository) Save(room *domain.Room) error {
+	r.MemoryRoomRepository.Save(room)
+	return r.flush()
+}
+
+// Delete はルームを削除してファイルに書き出す
+func (r *FileRoomRepository) Delete(roomID string) error {
+	r.MemoryRoomRepository.Delete(roomID)
+	return r.flush()
+}
+
+// SetWaitingRoom はマッチング待機ルームを設定してファイルに書き出す
+func (r *FileRoomRepository) SetWaitingRoom(capacity int, room *domain.Room) error {
+	r.MemoryRoomRepository.SetWaitingRoom(capacity, room)
+	return r.flush()
+}
+
+// ClearWaitingRoom はマッチング待機ルームをクリアしてファイルに書き出す
+func (r *FileRoomRepository) ClearWaitingRoom(capacity int) error {
+	r.MemoryRoomRepository.ClearWaitingRoom(capacity)
+	return r.flush()
+}
+
+// flush は現在の全件を書き出す
+func (r *FileRoomRepository) flush() error {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	r.mu.RLock()
+	stored := roomStoreFile{
+		Rooms:        make([]*domain.Room, 0, len(r.rooms)),
+		WaitingRooms: make(map[int]*domain.Room, len(r.waitingRooms)),
+	}
+	for _, room := range r.rooms {
+		stored.Rooms = append(stored.Rooms, room)
+	}
+	for capacity, room := range r.waitingRooms {
+		stored.WaitingRooms[capacity] = room
+	}
+	sort.Slice(stored.Rooms, func(i, j int) bool { return stored.Rooms[i].ID < stored.Rooms[j].ID })
+	b, err := json.Marshal(stored)
+	r.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("room store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("room store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("room store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("room store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("room store: %w", err)
+	}
+	return nil
+}
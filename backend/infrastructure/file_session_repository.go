@@ -0,0 +1,92 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"recaptchgame-backend/domain"
+)
+
+// FileSessionRepository はJSONファイルに書き出すセッションリポジトリ
+// 変更のたびにファイル全体を書き換える（一時ファイルに書いてから rename するため、途中で落ちても壊れない）
+// セッション数は同時接続数程度なので、全件の書き出しで十分とする
+type FileSessionRepository struct {
+	*MemorySessionRepository
+	path string
+}
+
+// NewFileSessionRepository は path のファイルから既存のセッションを読み込んで生成する
+// ファイルがなければ空の状態から始める
+func NewFileSessionRepository(path string) (*FileSessionRepository, error) {
+	r := &FileSessionRepository{MemorySessionRepository: NewMemorySessionRepository(), path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session store: %w", err)
+	}
+	var sessions []*domain.Session
+	if err := json.Unmarshal(b, &sessions); err != nil {
+		return nil, fmt.Errorf("session store: %s: %w", path, err)
+	}
+	for _, session := range sessions {
+		r.put(session)
+	}
+	return r, nil
+}
+
+// Save はセッションを保存してファイルに書き出す
+func (r *FileSessionRepository) Save(session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.put(session)
+	return r.flush()
+}
+
+// Delete はセッションを削除してファイルに書き出す
+func (r *FileSessionRepository) Delete(sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[sessionID]; !ok {
+		return nil
+	}
+	r.remove(sessionID)
+	return r.flush()
+}
+
+// flush は呼び出し側でロックを取った状態で全件を書き出す
+func (r *FileSessionRepository) flush() error {
+	sessions := make([]*domain.Session, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	b, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("session store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("session store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("session store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("session store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package infrastructure
+
+import (
+	"sync"
+
+	"recaptchgame-backend/domain"
+)
+
+// DefaultMatchHistoryCapacity はメモリに保持する対戦記録の既定の件数
+const DefaultMatchHistoryCapacity = 1000
+
+// MemoryMatchHistoryRepository はメモリベースの対戦記録リポジトリ
+// capacity を超えたら古い記録から捨てる
+type MemoryMatchHistoryRepository struct {
+	mu       sync.RWMutex
+	records  []*domain.MatchRecord
+	capacity int
+}
+
+// NewMemoryMatchHistoryRepository は新しいMemoryMatchHistoryRepositoryを生成
+// capacity が 0 以下なら DefaultMatchHistoryCapacity を使う
+func NewMemoryMatchHistoryRepository(capacity int) *MemoryMatchHistoryRepository {
+	if capacity <= 0 {
+		capacity = DefaultMatchHistoryCapacity
+	}
+	return &MemoryMatchHistoryRepository{capacity: capacity}
+}
+
+// Save は対戦の記録を保存
+func (r *MemoryMatchHistoryRepository) Save(record *domain.MatchRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, copyMatchRecord(record))
+	if over := len(r.records) - r.capacity; over > 0 {
+		r.records = append([]*domain.MatchRecord(nil), r.records[over:]...)
+	}
+	return nil
+}
+
+// ListRecent は新しい順に最大 limit 件の記録をリスト
+func (r *MemoryMatchHistoryRepository) ListRecent(limit int) ([]*domain.MatchRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if limit <= 0 || limit > len(r.records) {
+		limit = len(r.records)
+	}
+	records := make([]*domain.MatchRecord, 0, limit)
+	for i := len(r.records) - 1; i >= 0 && len(records) < limit; i-- {
+		records = append(records, copyMatchRecord(r.records[i]))
+	}
+	return records, nil
+}
+
+func copyMatchRecord(src *domain.MatchRecord) *domain.MatchRecord {
+	dst := *src
+	dst.Standings = append([]domain.MatchStanding(nil), src.Standings...)
+	return &dst
+}
@@ -85,6 +85,18 @@ func (r *MemoryRoomRepository) ListActive() ([]*domain.Room, error) {
 	return active, nil
 }
 
+// ListAll は全てのルームをリスト
+func (r *MemoryRoomRepository) ListAll() ([]*domain.Room, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rooms := make([]*domain.Room, 0, len(r.rooms))
+	for _, room := range r.rooms {
+		rooms = append(rooms, copyRoom(room))
+	}
+	return rooms, nil
+}
+
 // GetWaitingRoom はマッチング待機中のルームを取得
 func (r *MemoryRoomRepository) GetWaitingRoom(capacity int) (*domain.Room, error) {
 	r.mu.RLock()
@@ -104,28 +116,31 @@ func copyRoom(src *domain.Room) *domain.Room {
 		return nil
 	}
 	dst := &domain.Room{
-		ID:           src.ID,
-		WinningScore: src.WinningScore,
-		IsActive:     src.IsActive,
-		Capacity:     src.Capacity,
+		ID:             src.ID,
+		WinningScore:   src.WinningScore,
+		IsActive:       src.IsActive,
+		Capacity:       src.Capacity,
+		Unrated:        src.Unrated,
+		CreatedAt:      src.CreatedAt,
+		LastActivityAt: src.LastActivityAt,
 	}
 	if src.Player1 != nil {
-		dst.Player1 = &domain.Player{ID: src.Player1.ID, Score: src.Player1.Score, Combo: src.Player1.Combo, CurrentEffect: src.Player1.CurrentEffect, EffectExpiresAt: src.Player1.EffectExpiresAt}
+		dst.Player1 = copyPlayer(src.Player1)
 	}
 	if src.Player2 != nil {
-		dst.Player2 = &domain.Player{ID: src.Player2.ID, Score: src.Player2.Score, Combo: src.Player2.Combo, CurrentEffect: src.Player2.CurrentEffect, EffectExpiresAt: src.Player2.EffectExpiresAt}
+		dst.Player2 = copyPlayer(src.Player2)
 	}
 	if src.GameState1 != nil {
-		dst.GameState1 = &domain.GameState{Target: src.GameState1.Target, Images: append([]string{}, src.GameState1.Images...)}
+		dst.GameState1 = copyGameState(src.GameState1)
 	}
 	if src.GameState2 != nil {
-		dst.GameState2 = &domain.GameState{Target: src.GameState2.Target, Images: append([]string{}, src.GameState2.Images...)}
+		dst.GameState2 = copyGameState(src.GameState2)
 	}
 	if len(src.ExtraPlayers) > 0 {
 		dst.ExtraPlayers = make([]*domain.Player, len(src.ExtraPlayers))
 		for i, p := range src.ExtraPlayers {
 			if p != nil {
-				dst.ExtraPlayers[i] = &domain.Player{ID: p.ID, Score: p.Score, Combo: p.Combo, CurrentEffect: p.CurrentEffect, EffectExpiresAt: p.EffectExpiresAt}
+				dst.ExtraPlayers[i] = copyPlayer(p)
 			}
 		}
 	}
@@ -133,13 +148,46 @@ func copyRoom(src *domain.Room) *domain.Room {
 		dst.ExtraGameStates = make([]*domain.GameState, len(src.ExtraGameStates))
 		for i, gs := range src.ExtraGameStates {
 			if gs != nil {
-				dst.ExtraGameStates[i] = &domain.GameState{Target: gs.Target, Images: append([]string{}, gs.Images...)}
+				dst.ExtraGameStates[i] = copyGameState(gs)
 			}
 		}
 	}
 	return dst
 }
 
+// copyPlayer はプレイヤーを複製する（Player にフィールドを追加した場合はここにも追加すること）
+func copyPlayer(src *domain.Player) *domain.Player {
+	return &domain.Player{
+		ID:              src.ID,
+		Score:           src.Score,
+		Combo:           src.Combo,
+		CurrentEffect:   src.CurrentEffect,
+		EffectExpiresAt: src.EffectExpiresAt,
+		FailedAttempts:  src.FailedAttempts,
+		LockedUntil:     src.LockedUntil,
+
+		Streak:            src.Streak,
+		Milestones:        src.Milestones,
+		Items:             append([]domain.ItemType(nil), src.Items...),
+		Shielded:          src.Shielded,
+		ItemCooldownUntil: src.ItemCooldownUntil,
+		EffectAttackerID:  src.EffectAttackerID,
+
+		Targeting:          src.Targeting,
+		TargetingPlayerID:  src.TargetingPlayerID,
+		PendingTargetUntil: src.PendingTargetUntil,
+	}
+}
+
+// copyGameState はゲーム状態を複製する
+func copyGameState(src *domain.GameState) *domain.GameState {
+	return &domain.GameState{
+		Target:   src.Target,
+		Images:   append([]string{}, src.Images...),
+		IssuedAt: src.IssuedAt,
+	}
+}
+
 // SetWaitingRoom はマッチング待機ルームを設定
 func (r *MemoryRoomRepository) SetWaitingRoom(capacity int, room *domain.Room) error {
 	r.mu.Lock()
@@ -152,6 +200,20 @@ func (r *MemoryRoomRepository) SetWaitingRoom(capacity int, room *domain.Room) e
 	return nil
 }
 
+// ListWaitingRooms は定員ごとのマッチング待機ルームをリスト
+func (r *MemoryRoomRepository) ListWaitingRooms() (map[int]*domain.Room, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	waiting := make(map[int]*domain.Room, len(r.waitingRooms))
+	for capacity, room := range r.waitingRooms {
+		if room != nil {
+			waiting[capacity] = copyRoom(room)
+		}
+	}
+	return waiting, nil
+}
+
 // ClearWaitingRoom はマッチング待機ルームをクリア
 func (r *MemoryRoomRepository) ClearWaitingRoom(capacity int) error {
 	r.mu.Lock()
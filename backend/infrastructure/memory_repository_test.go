@@ -2,6 +2,7 @@ package infrastructure
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	"recaptchgame-backend/domain"
@@ -102,6 +103,10 @@ func TestMemoryRoomRepository(t *testing.T) {
 	if _, err := repo.GetWaitingRoom(4); err != nil {
 		t.Errorf("expected capacity 4 waiting room to remain")
 	}
+	waiting, _ := repo.ListWaitingRooms()
+	if len(waiting) != 1 || waiting[4] == nil {
+		t.Errorf("expected only the capacity 4 waiting room to be listed, got %v", waiting)
+	}
 
 	// テスト7: アクティブなルームをリスト
 	room2 := domain.NewRoom("room2", "player4", "player5", 5, 2)
@@ -122,6 +127,15 @@ func TestMemoryRoomRepository(t *testing.T) {
 	if active[0].ID != "room1" {
 		t.Errorf("expected room1 to be active, got %s", active[0].ID)
 	}
+
+	// テスト8: 全てのルームをリスト
+	all, err := repo.ListAll()
+	if err != nil {
+		t.Fatalf("failed to list rooms: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected 2 rooms, got %d", len(all))
+	}
 }
 
 // TestMemoryClientRepository クライアントリポジトリのテスト
@@ -250,3 +264,74 @@ func TestRepositoryIsolation(t *testing.T) {
 		t.Errorf("expected repo1 to have room1")
 	}
 }
+
+// TestMemoryMatchHistoryRepository 対戦記録リポジトリのテスト
+func TestMemoryMatchHistoryRepository(t *testing.T) {
+	repo := NewMemoryMatchHistoryRepository(2)
+	for i := 1; i <= 3; i++ {
+		repo.Save(&domain.MatchRecord{RoomID: fmt.Sprintf("room%d", i), Standings: []domain.MatchStanding{{PlayerID: "player1", Score: i}}})
+	}
+
+	// テスト1: 新しい順に返し、上限を超えた古い記録は捨てる
+	records, _ := repo.ListRecent(0)
+	if len(records) != 2 || records[0].RoomID != "room3" || records[1].RoomID != "room2" {
+		t.Fatalf("expected room3, room2, got %+v", records)
+	}
+
+	// テスト2: limit 件までに絞り、返した記録を変更しても保存済みの記録は変わらない
+	records, _ = repo.ListRecent(1)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	records[0].Standings[0].Score = 100
+	if again, _ := repo.ListRecent(1); again[0].Standings[0].Score != 3 {
+		t.Errorf("expected stored record to be isolated, got %d", again[0].Standings[0].Score)
+	}
+}
+
+// TestFileRoomRepository はファイルに書き出すルームリポジトリのテスト
+func TestFileRoomRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rooms.json")
+	repo, err := NewFileRoomRepository(path)
+	if err != nil {
+		t.Fatalf("failed to open room store: %v", err)
+	}
+
+	// テスト1: 対戦中のルームと待機ルームを開き直して読み込める
+	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
+	room.Start()
+	room.Player1.Score = 3
+	room.Player1.Items = []domain.ItemType{domain.ItemShield}
+	if err := repo.Save(room); err != nil {
+		t.Fatalf("failed to save room: %v", err)
+	}
+	waiting := domain.NewRoom("room2", "player3", "", 5, 3)
+	repo.Save(waiting)
+	repo.SetWaitingRoom(3, waiting)
+	repo.Save(domain.NewRoom("room3", "player4", "player5", 5, 2))
+	if err := repo.Delete("room3"); err != nil {
+		t.Fatalf("failed to delete room: %v", err)
+	}
+
+	reopened, err := NewFileRoomRepository(path)
+	if err != nil {
+		t.Fatalf("failed to reopen room store: %v", err)
+	}
+	found, err := reopened.FindByPlayerID("player1")
+	if err != nil || found.ID != "room1" || !found.IsActive || found.Player1.Score != 3 || len(found.Player1.Items) != 1 {
+		t.Errorf("expected room to survive reopen, got %+v %v", found, err)
+	}
+	if w, err := reopened.GetWaitingRoom(3); err != nil || w.ID != "room2" {
+		t.Errorf("expected waiting room to survive reopen, got %+v %v", w, err)
+	}
+	if _, err := reopened.FindByID("room3"); err == nil {
+		t.Errorf("expected deleted room to stay deleted")
+	}
+
+	// テスト2: 待機ルームのクリアも書き出す
+	reopened.ClearWaitingRoom(3)
+	again, _ := NewFileRoomRepository(path)
+	if _, err := again.GetWaitingRoom(3); err == nil {
+		t.Errorf("expected cleared waiting room to stay cleared")
+	}
+}
@@ -0,0 +1,100 @@
+package infrastructure
+
+import (
+	"sync"
+
+	"recaptchgame-backend/domain"
+)
+
+// MemorySessionRepository はメモリベースのセッションリポジトリ
+type MemorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[string]*domain.Session // sessionID -> session
+	byPlayer map[string]string          // playerID -> sessionID
+}
+
+// NewMemorySessionRepository は新しいMemorySessionRepositoryを生成
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{
+		sessions: make(map[string]*domain.Session),
+		byPlayer: make(map[string]string),
+	}
+}
+
+// FindByID はセッションIDからセッションを取得
+func (r *MemorySessionRepository) FindByID(sessionID string) (*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *session
+	return &copied, nil
+}
+
+// FindByPlayerID はプレイヤーIDからセッションを取得
+func (r *MemorySessionRepository) FindByPlayerID(playerID string) (*domain.Session, error) {
+	r.mu.RLock()
+	sessionID, ok := r.byPlayer[playerID]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	return r.FindByID(sessionID)
+}
+
+// Save はセッションを保存
+func (r *MemorySessionRepository) Save(session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.put(session)
+	return nil
+}
+
+// Delete はセッションを削除
+func (r *MemorySessionRepository) Delete(sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(sessionID)
+	return nil
+}
+
+// ListAll は全てのセッションをリスト
+func (r *MemorySessionRepository) ListAll() ([]*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := make([]*domain.Session, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		copied := *session
+		sessions = append(sessions, &copied)
+	}
+	return sessions, nil
+}
+
+// put は呼び出し側でロックを取った状態で保存する
+// 同じセッションの古いプレイヤー、同じプレイヤーの古いセッションの対応は外す
+func (r *MemorySessionRepository) put(session *domain.Session) {
+	if old, ok := r.sessions[session.ID]; ok && old.PlayerID != session.PlayerID {
+		delete(r.byPlayer, old.PlayerID)
+	}
+	if oldID, ok := r.byPlayer[session.PlayerID]; ok && oldID != session.ID {
+		delete(r.sessions, oldID)
+	}
+	copied := *session
+	r.sessions[session.ID] = &copied
+	r.byPlayer[session.PlayerID] = session.ID
+}
+
+func (r *MemorySessionRepository) remove(sessionID string) {
+	if old, ok := r.sessions[sessionID]; ok {
+		if r.byPlayer[old.PlayerID] == sessionID {
+			delete(r.byPlayer, old.PlayerID)
+		}
+		delete(r.sessions, sessionID)
+	}
+}
@@ -0,0 +1,63 @@
+package infrastructure
+
+import (
+	"sort"
+	"sync"
+
+	"recaptchgame-backend/domain"
+)
+
+// MemorySuspicionRepository はメモリベースのボット疑いスコアリポジトリ
+type MemorySuspicionRepository struct {
+	mu      sync.RWMutex
+	records map[string]*domain.SuspicionRecord
+}
+
+// NewMemorySuspicionRepository は新しいMemorySuspicionRepositoryを生成
+func NewMemorySuspicionRepository() *MemorySuspicionRepository {
+	return &MemorySuspicionRepository{
+		records: make(map[string]*domain.SuspicionRecord),
+	}
+}
+
+// Find はプレイヤーの疑いスコアを取得
+func (r *MemorySuspicionRepository) Find(playerID string) (*domain.SuspicionRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.records[playerID]
+	if !ok {
+		return nil, nil
+	}
+	return copySuspicionRecord(record), nil
+}
+
+// Save は疑いスコアを保存
+func (r *MemorySuspicionRepository) Save(record *domain.SuspicionRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records[record.PlayerID] = copySuspicionRecord(record)
+	return nil
+}
+
+// ListFlagged はフラグまたは隔離されたプレイヤーをスコアの高い順にリスト
+func (r *MemorySuspicionRepository) ListFlagged() ([]*domain.SuspicionRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var flagged []*domain.SuspicionRecord
+	for _, record := range r.records {
+		if record.Flagged || record.Quarantined {
+			flagged = append(flagged, copySuspicionRecord(record))
+		}
+	}
+	sort.Slice(flagged, func(i, j int) bool { return flagged[i].Score > flagged[j].Score })
+	return flagged, nil
+}
+
+func copySuspicionRecord(src *domain.SuspicionRecord) *domain.SuspicionRecord {
+	dst := *src
+	dst.Reasons = append([]string(nil), src.Reasons...)
+	return &dst
+}
@@ -0,0 +1,59 @@
+package infrastructure
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"recaptchgame-backend/domain"
+)
+
+// TestSessionRepositories はセッションリポジトリのテスト
+func TestSessionRepositories(t *testing.T) {
+	now := time.Now()
+
+	// テスト1: 同じプレイヤーの新しいセッションは古いセッションを置き換える
+	repo := NewMemorySessionRepository()
+	repo.Save(domain.NewSession("s1", "player1", now))
+	repo.Save(domain.NewSession("s2", "player1", now))
+	if s, _ := repo.FindByID("s1"); s != nil {
+		t.Errorf("expected old session to be replaced")
+	}
+	if s, _ := repo.FindByPlayerID("player1"); s == nil || s.ID != "s2" {
+		t.Errorf("expected player1 to map to s2, got %+v", s)
+	}
+
+	// テスト2: 返り値を変更しても保存内容は変わらない
+	s, _ := repo.FindByID("s2")
+	s.RoomID = "mutated"
+	if stored, _ := repo.FindByID("s2"); stored.RoomID != "" {
+		t.Errorf("expected stored session to be isolated from callers")
+	}
+
+	// テスト3: ファイルに書き出した内容を開き直して読み込める
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	fileRepo, err := NewFileSessionRepository(path)
+	if err != nil {
+		t.Fatalf("failed to open session store: %v", err)
+	}
+	session := domain.NewSession("s1", "player1", now)
+	session.RoomID = "room1"
+	if err := fileRepo.Save(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	fileRepo.Save(domain.NewSession("s2", "player2", now))
+	if err := fileRepo.Delete("s2"); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+
+	reopened, err := NewFileSessionRepository(path)
+	if err != nil {
+		t.Fatalf("failed to reopen session store: %v", err)
+	}
+	if s, _ := reopened.FindByPlayerID("player1"); s == nil || s.ID != "s1" || s.RoomID != "room1" {
+		t.Errorf("expected session to survive reopen, got %+v", s)
+	}
+	if all, _ := reopened.ListAll(); len(all) != 1 {
+		t.Errorf("expected deleted session to stay deleted, got %d sessions", len(all))
+	}
+}
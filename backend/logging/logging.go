@@ -0,0 +1,15 @@
+// Package logging は各層で共有するロガーの小さな補助関数を提供する
+package logging
+
+import (
+	"io"
+	"log/slog"
+)
+
+// OrDiscard は logger が未指定（nil）の場合に出力しないロガーを返す
+func OrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return logger
+}
@@ -3,19 +3,27 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"recaptchgame-backend/cluster"
+	"recaptchgame-backend/config"
 	"recaptchgame-backend/domain"
 	"recaptchgame-backend/handler"
 	"recaptchgame-backend/infrastructure"
+	"recaptchgame-backend/matchmaker"
+	"recaptchgame-backend/metrics"
+	"recaptchgame-backend/protocol"
 	"recaptchgame-backend/usecase"
+	"recaptchgame-backend/webhook"
 )
 
 func init() {
@@ -31,53 +39,139 @@ func getEnv(key, fallback string) string {
 
 var (
 	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
+		// Origin は admission で検査済み（ここでは許可リストの判定のみ行う）
+		CheckOrigin: func(r *http.Request) bool { return admission.OriginAllowed(r) },
+		// クライアントが Sec-WebSocket-Protocol で選んだエンコーディング（未指定ならJSON）
+		Subprotocols: handler.Subprotocols(),
 	}
 
+	cfg    *config.Config
+	logger *slog.Logger
+
 	// Application層のインスタンス（DI）
 	wsManager          *handler.WebSocketManager
 	wsHandler          *handler.WebSocketHandler
+	admission          *handler.AdmissionController
+	metricsRegistry    *metrics.Registry
 	roomRepo           domain.RoomRepository
 	clientRepo         domain.ClientRepository
+	suspicionRepo      domain.SuspicionRepository
+	sessionRepo        domain.SessionRepository
 	joinRoomUC         *usecase.JoinRoomUseCase
 	verifyAnswerUC     *usecase.VerifyAnswerUseCase
 	startGameUC        *usecase.StartGameUseCase
 	leaveRoomUC        *usecase.LeaveRoomUseCase
+	endGameUC          *usecase.EndGameUseCase
+	useItemUC          *usecase.UseItemUseCase
+	targetingUC        *usecase.ObstructionTargetingUseCase
 	problemGeneratorUC *usecase.ProblemGeneratorUseCase
+	botDetectionUC     *usecase.BotDetectionUseCase
+	matchHistory       *usecase.MatchHistoryRecorder
+	replays            *usecase.ReplayRecorder
+	roomReaper         *handler.RoomReaper
+	webhooks           *webhook.Dispatcher
 )
 
 func init() {
+	// 設定の読み込み（CONFIG_FILE のJSONに環境変数を重ねる。不正な値があれば起動しない）
+	var err error
+	cfg, err = config.Load(getEnv("CONFIG_FILE", ""), os.LookupEnv)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// ロガーの初期化（標準 log パッケージの出力も同じハンドラーに流す）
+	logger = newLogger(cfg.Server)
+	slog.SetDefault(logger)
+
 	// インフラストラクチャの初期化
-	roomRepo = infrastructure.NewMemoryRoomRepository()
+	roomRepo = newRoomRepository(cfg.Rooms.StorePath)
 	clientRepo = infrastructure.NewMemoryClientRepository()
+	suspicionRepo = infrastructure.NewMemorySuspicionRepository()
+	sessionRepo = newSessionRepository(cfg.Sessions.StorePath)
 	// IDGenerator の初期化（DI）
 	idGenerator := infrastructure.NewTimeBasedIDGenerator()
+	matchmakerClient := newMatchmakerClient(cfg.Matchmaking, idGenerator)
+
+	// ドメインイベントのバス（購読者はハンドラー層まで組み立ててから登録する）
+	eventBus := infrastructure.NewInProcessEventBus(logger.With("component", "event_bus"))
 
 	// ドメインサービスの初期化
-	problemFactory := domain.NewProblemFactory()
+	problemFactory := domain.NewProblemFactory(cfg.GameRules())
+
+	// 妨害エフェクトの定義（設定の検証で組み立てられることを確認済み）
+	effects, err := cfg.EffectRegistry()
+	if err != nil {
+		logger.Error("invalid effect definitions", "error", err)
+		os.Exit(1)
+	}
 
 	// ユースケース層の初期化（新フォーマット）
 	roomGuard := usecase.NewRoomExecutionGuard()
 	problemGeneratorUC = usecase.NewProblemGeneratorUseCase(problemFactory, domain.GetAllTargets())
-	joinRoomUC = usecase.NewJoinRoomUseCase(roomRepo, clientRepo, idGenerator, roomGuard)
-	verifyAnswerUC = usecase.NewVerifyAnswerUseCase(roomRepo, problemGeneratorUC, domain.GetAllEffects(), roomGuard)
-	startGameUC = usecase.NewStartGameUseCase(roomRepo, problemGeneratorUC, roomGuard)
-	leaveRoomUC = usecase.NewLeaveRoomUseCase(roomRepo, clientRepo, roomGuard)
+	joinRoomUC = usecase.NewJoinRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, suspicionRepo, cfg.GameRules(), cfg.MatchmakingPolicy(), eventBus, logger.With("component", "join_room"))
+	verifyAnswerUC = usecase.NewVerifyAnswerUseCase(roomRepo, problemGeneratorUC, effects, roomGuard, cfg.VerifyPenaltyPolicy(), cfg.GameRules(), cfg.ItemPolicy(), eventBus, logger.With("component", "verify_answer"))
+	startGameUC = usecase.NewStartGameUseCase(roomRepo, problemGeneratorUC, roomGuard, eventBus, logger.With("component", "start_game"))
+	leaveRoomUC = usecase.NewLeaveRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, eventBus, logger.With("component", "leave_room"))
+	endGameUC = usecase.NewEndGameUseCase(roomRepo, matchmakerClient, roomGuard, eventBus, logger.With("component", "end_game"))
+	useItemUC = usecase.NewUseItemUseCase(roomRepo, roomGuard, cfg.ItemPolicy(), eventBus, logger.With("component", "use_item"))
+	targetingUC = usecase.NewObstructionTargetingUseCase(roomRepo, effects, roomGuard, cfg.GameRules(), eventBus, logger.With("component", "targeting"))
+	botDetectionUC = usecase.NewBotDetectionUseCase(suspicionRepo, roomRepo, roomGuard, cfg.SuspicionPolicy(), logger.With("component", "bot_detection"))
 
 	// ハンドラー層の初期化
-	wsManager = handler.NewWebSocketManager()
+	if len(cfg.Admission.AllowedOrigins) == 0 {
+		logger.Warn("ALLOWED_ORIGINS is not set; accepting WebSocket connections from any origin")
+	}
+	admission = handler.NewAdmissionController(handler.AdmissionPolicyFromConfig(cfg), logger.With("component", "admission"))
+	metricsRegistry = metrics.NewRegistry()
+	serverMetrics := handler.NewMetrics(metricsRegistry)
+	wsManager = handler.NewWebSocketManager(cfg.WebSocket.SendBufferSize, serverMetrics, logger.With("component", "ws_manager"))
 	wsHandler = handler.NewWebSocketHandler(
 		wsManager,
 		joinRoomUC,
 		verifyAnswerUC,
 		startGameUC,
 		leaveRoomUC,
+		endGameUC,
+		useItemUC,
+		targetingUC,
+		botDetectionUC,
 		roomRepo,
+		sessionRepo,
+		admission,
+		handler.ConnectionSettingsFromConfig(cfg),
+		serverMetrics,
+		logger.With("component", "ws_handler"),
 	)
+	reapRoomsUC := usecase.NewReapRoomsUseCase(roomRepo, matchmakerClient, roomGuard, cfg.RoomExpiryPolicy(), eventBus, logger.With("component", "room_reaper"))
+	roomReaper = handler.NewRoomReaper(wsHandler, reapRoomsUC, cfg.Rooms.ReaperInterval.Std(), serverMetrics)
+	// ドメインイベントの購読者（通知・計測・対戦の記録・リプレイはそれぞれ独立して受け取る）
+	matchHistory = usecase.NewMatchHistoryRecorder(infrastructure.NewMemoryMatchHistoryRepository(0), logger.With("component", "match_history"))
+	replays = usecase.NewReplayRecorder(0)
+	eventBus.Subscribe("websocket", wsHandler.HandleEvent)
+	eventBus.Subscribe("metrics", serverMetrics.HandleEvent)
+	eventBus.Subscribe("match_history", matchHistory.HandleEvent)
+	eventBus.Subscribe("replay", replays.HandleEvent)
+	// 外部への webhook 通知（通知先が設定されている場合のみ）
+	if subs := cfg.WebhookSubscriptions(); len(subs) > 0 {
+		webhooks = webhook.NewDispatcher(subs, cfg.WebhookOptions(), logger.With("component", "webhook"))
+		eventBus.Subscribe("webhook", webhooks.HandleEvent)
+		logger.Info("webhooks enabled", "subscriptions", len(subs))
+	}
+	// 再起動前のセッションのうち、ルームに残っているプレイヤーの再接続を待つ
+	wsHandler.RestoreSessions()
+	// ルームの所有権（共有ストアとメッセージバスを差し込むまでは単一ノードとして全てのルームを所有する）
+	ownership := cluster.NewOwnership(nodeID(cfg.Cluster.NodeID), cluster.NewMemoryLeaseStore(), cfg.Cluster.LeaseTTL.Std())
+	if err := wsHandler.JoinCluster(ownership, cluster.NewMemoryBus()); err != nil {
+		logger.Error("failed to join cluster", "error", err)
+		os.Exit(1)
+	}
+	handler.RegisterStateCollectors(metricsRegistry, wsManager, roomRepo, admission)
 }
 
 func main() {
-	port := getEnv("PORT", "8080")
+	port := cfg.Server.Port
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -85,24 +179,64 @@ func main() {
 		w.Write([]byte("Backend Running"))
 	})
 
+	http.HandleFunc("/healthz", serveHealthz)
+	http.HandleFunc("/readyz", serveReadyz)
 	http.HandleFunc("/ws", serveWebSocket)
+	http.HandleFunc("/protocol/schema.json", serveProtocolSchema)
+	http.Handle("/metrics", metricsRegistry)
+	// 管理API（ADMIN_TOKEN 未設定なら全てのリクエストを拒否する）
+	if cfg.Server.AdminToken == "" {
+		logger.Warn("ADMIN_TOKEN is not set; /admin API is disabled")
+	}
+	adminHandler := handler.NewAdminHandler(cfg.Server.AdminToken, wsHandler, wsManager, roomRepo, botDetectionUC, matchHistory, replays, cfg.Redacted(), logger.With("component", "admin"))
+	http.Handle("/admin/", adminHandler)
 
 	srv := &http.Server{Addr: ":" + port}
 
 	go func() {
-		log.Printf("Server starting on port %s", port)
+		logger.Info("server starting", "port", port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+			logger.Error("listen failed", "error", err)
+			os.Exit(1)
 		}
 	}()
 
-	// シグナル待ち（Graceful shutdown）
+	// 放置されたルームの片付け（ドレイン中も動かし、放置された対戦が終了待ちを引き延ばさないようにする）
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	go roomReaper.Run(reaperCtx)
+	go wsHandler.RunClusterHeartbeat(reaperCtx, cfg.Cluster.HeartbeatInterval.Std())
+	if webhooks != nil {
+		go webhooks.Run(reaperCtx)
+	}
+
+	// シグナルまたは管理APIからのドレイン開始を待つ（Graceful shutdown）
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
+	select {
+	case <-quit:
+	case <-wsHandler.DrainStarted():
+	}
 
-	log.Println("Shutting down server...")
-	// まず WebSocket 接続を全て閉じる
+	// 新規参加を止め、進行中のルームが終わるか期限に達するまで待つ（2回目のシグナルで打ち切る）
+	wsHandler.StartDrain(cfg.Server.DrainTimeout.Std())
+	drainCtx, cancelDrain := context.WithDeadline(context.Background(), wsHandler.DrainDeadline())
+	go func() {
+		select {
+		case <-quit:
+			logger.Warn("second signal received; skipping drain")
+			cancelDrain()
+		case <-drainCtx.Done():
+		}
+	}()
+	if err := wsHandler.WaitForActiveRooms(drainCtx); err != nil {
+		logger.Warn("drain ended before all rooms finished", "active_rooms", wsHandler.ActiveRoomCount())
+	}
+	cancelDrain()
+
+	stopReaper()
+	wsHandler.LeaveCluster()
+	logger.Info("shutting down server")
+	// 残っている WebSocket 接続を全て閉じる
 	if wsManager != nil {
 		wsManager.CloseAll()
 	}
@@ -110,14 +244,140 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		logger.Error("server forced to shutdown", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("server exiting")
+}
+
+// serveHealthz はプロセスが応答できるかを返す（liveness）
+func serveHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// serveReadyz は新しい対戦を受け付けられるかを返す（readiness）
+// ドレイン中は 503 を返してロードバランサーに新規接続を送らせない
+func serveReadyz(w http.ResponseWriter, r *http.Request) {
+	if wsHandler.IsDraining() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ready"))
+}
+
+// serveProtocolSchema はメッセージ契約のJSON Schemaを返す
+func serveProtocolSchema(w http.ResponseWriter, r *http.Request) {
+	b, err := protocol.SchemaJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	log.Println("Server exiting")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(b)
+}
+
+// clientIP は接続元IPを返す
+// server.trust_proxy_headers（TRUST_PROXY_HEADERS）が true の場合はリバースプロキシが付与する X-Forwarded-For の末尾を使う
+// 先頭側はクライアントが自由に書けるため、IP単位の制限や隔離の判定には使わない
+func clientIP(r *http.Request) string {
+	if cfg.Server.TrustProxyHeaders {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			forwarded := values[len(values)-1]
+			if i := strings.LastIndex(forwarded, ","); i >= 0 {
+				forwarded = forwarded[i+1:]
+			}
+			if ip := strings.TrimSpace(forwarded); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// nodeID はクラスタ内でこのノードを識別するID（未設定ならホスト名）
+func nodeID(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "node-1"
+	}
+	return host
+}
+
+// newMatchmakerClient はランダムマッチの割り当てを決めるマッチメーカーを返す（URLが空ならプロセス内）
+func newMatchmakerClient(mm config.MatchmakingConfig, idGenerator domain.IDGenerator) matchmaker.Client {
+	if mm.URL == "" {
+		return matchmaker.NewService(idGenerator, mm.TicketTTL.Std())
+	}
+	logger.Info("using remote matchmaker", "url", mm.URL)
+	return matchmaker.NewHTTPClient(mm.URL, nil)
+}
+
+// newRoomRepository はルームの保存先を返す（パスが空ならメモリ）
+func newRoomRepository(path string) domain.RoomRepository {
+	if path == "" {
+		return infrastructure.NewMemoryRoomRepository()
+	}
+	repo, err := infrastructure.NewFileRoomRepository(path)
+	if err != nil {
+		logger.Error("failed to open room store", "path", path, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("room store opened", "path", path)
+	return repo
+}
+
+// newSessionRepository はセッションの保存先を返す（パスが空ならメモリ）
+func newSessionRepository(path string) domain.SessionRepository {
+	if path == "" {
+		return infrastructure.NewMemorySessionRepository()
+	}
+	repo, err := infrastructure.NewFileSessionRepository(path)
+	if err != nil {
+		logger.Error("failed to open session store", "path", path, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("session store opened", "path", path)
+	return repo
+}
+
+// newLogger は server.log_level（debug/info/warn/error）と server.log_format（text/json）からロガーを生成する
+func newLogger(server config.ServerConfig) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(server.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	if server.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
 }
 
 func serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	ip := clientIP(r)
+	if reason, ok := admission.Admit(r, ip); !ok {
+		status := http.StatusServiceUnavailable
+		if reason == handler.RejectOriginNotAllowed {
+			status = http.StatusForbidden
+		}
+		http.Error(w, reason, status)
+		return
+	}
+	defer admission.Release(ip)
+
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		logger.Debug("websocket upgrade failed", "remote_ip", ip, "error", err)
 		return
 	}
 
@@ -125,5 +385,5 @@ func serveWebSocket(w http.ResponseWriter, r *http.Request) {
 	clientID := fmt.Sprintf("client_%d", time.Now().UnixNano())
 
 	// ハンドラーに処理を委譲
-	wsHandler.HandleConnection(clientID, ws)
+	wsHandler.HandleConnection(clientID, ip, ws)
 }
@@ -0,0 +1,169 @@
+package matchmaker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIの経路
+//
+//	POST   /v1/tickets              登録（Request）→ Ticket
+//	GET    /v1/tickets/{player_id}  状態 → Ticket（無ければ 404）
+//	DELETE /v1/tickets/{player_id}  取り消し → 204
+const ticketsPath = "/v1/tickets"
+
+const maxRequestBytes = 4 << 10
+
+var errMethodNotAllowed = errors.New("method not allowed")
+
+// HTTPHandler はマッチメーカーをHTTP APIとして公開する
+type HTTPHandler struct {
+	client Client
+}
+
+// NewHTTPHandler は新しいHTTPHandlerを生成
+func NewHTTPHandler(client Client) *HTTPHandler {
+	return &HTTPHandler{client: client}
+}
+
+// ServeHTTP は /v1/tickets 以下のリクエストを処理する
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutPrefix(r.URL.Path, ticketsPath)
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrTicketNotFound)
+		return
+	}
+	playerID := strings.Trim(rest, "/")
+
+	switch {
+	case playerID == "" && r.Method == http.MethodPost:
+		var req Request
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, ErrInvalidRequest)
+			return
+		}
+		ticket, err := h.client.Enqueue(req)
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, ticket)
+	case playerID != "" && r.Method == http.MethodGet:
+		ticket, err := h.client.Status(playerID)
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, ticket)
+	case playerID != "" && r.Method == http.MethodDelete:
+		if err := h.client.Cancel(playerID); err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrTicketNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// HTTPClient は別プロセスのマッチメーカーをHTTP APIで呼び出す Client
+type HTTPClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTTPClient は新しいHTTPClientを生成
+// httpClient が nil なら5秒でタイムアウトするクライアントを使う
+func NewHTTPClient(baseURL string, httpClient *http.Client) *HTTPClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &HTTPClient{baseURL: strings.TrimRight(baseURL, "/"), httpClient: httpClient}
+}
+
+// Enqueue はプレイヤーを待ち行列に登録する
+func (c *HTTPClient) Enqueue(req Request) (*Ticket, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var ticket Ticket
+	if err := c.do(http.MethodPost, ticketsPath, body, &ticket); err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// Cancel は登録を取り消す
+func (c *HTTPClient) Cancel(playerID string) error {
+	return c.do(http.MethodDelete, ticketsPath+"/"+url.PathEscape(playerID), nil, nil)
+}
+
+// Status はチケットの状態を返す
+func (c *HTTPClient) Status(playerID string) (*Ticket, error) {
+	var ticket Ticket
+	if err := c.do(http.MethodGet, ticketsPath+"/"+url.PathEscape(playerID), nil, &ticket); err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+func (c *HTTPClient) do(method string, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("matchmaker: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrTicketNotFound
+	case resp.StatusCode == http.StatusBadRequest:
+		return ErrInvalidRequest
+	case resp.StatusCode >= 300:
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("matchmaker: %s %s: %d %s", method, path, resp.StatusCode, e.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
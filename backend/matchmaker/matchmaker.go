@@ -0,0 +1,217 @@
+// Package matchmaker はランダムマッチの待ち行列（どのルームに入るかの決定）を担う
+// ゲートウェイは Client インターフェース経由で呼び出し、単一プロセスでは Service を、別プロセスでは HTTPClient を使う
+package matchmaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"recaptchgame-backend/domain"
+)
+
+// DefaultTicketTTL は待機中・割り当て済みのチケットを保持する既定の時間
+const DefaultTicketTTL = 10 * time.Minute
+
+// チケットの状態
+const (
+	StatusWaiting = "waiting" // ルームの定員に達していない
+	StatusMatched = "matched" // ルームの定員に達した
+)
+
+var (
+	// ErrTicketNotFound はチケットが存在しない（未登録・取り消し済み・期限切れ）
+	ErrTicketNotFound = errors.New("ticket not found")
+	// ErrInvalidRequest は登録内容が不正
+	ErrInvalidRequest = errors.New("invalid matchmaking request")
+)
+
+// Request はランダムマッチへの登録内容
+// WinningScore はルームを新しく作る場合にだけ使う
+// ExcludeRoomIDs は参加できなかったルーム（開始済み・満員）で、募集中ならそのルームの募集を打ち切る
+type Request struct {
+	PlayerID       string   `json:"player_id"`
+	Capacity       int      `json:"capacity"`
+	WinningScore   int      `json:"winning_score"`
+	ExcludeRoomIDs []string `json:"exclude_room_ids,omitempty"`
+}
+
+// Ticket はランダムマッチの登録と割り当て先のルーム
+type Ticket struct {
+	PlayerID     string    `json:"player_id"`
+	RoomID       string    `json:"room_id"`
+	Capacity     int       `json:"capacity"`
+	WinningScore int       `json:"winning_score"`
+	Players      int       `json:"players"` // 同じルームに割り当て済みの人数
+	Status       string    `json:"status"`
+	EnqueuedAt   time.Time `json:"enqueued_at"`
+}
+
+// Client はマッチメーカーの呼び出し口
+type Client interface {
+	// Enqueue はプレイヤーを待ち行列に登録し、割り当て先のルームを返す
+	// 募集中のルームに登録済みなら同じチケットを返す（定員に達した後の登録は次の対戦として扱う）
+	Enqueue(req Request) (*Ticket, error)
+
+	// Cancel は登録を取り消す（未登録なら何もしない）
+	Cancel(playerID string) error
+
+	// Status はチケットの状態を返す（無ければ ErrTicketNotFound）
+	Status(playerID string) (*Ticket, error)
+}
+
+// group は定員に達するまでプレイヤーを集めるルーム
+type group struct {
+	roomID       string
+	capacity     int
+	winningScore int
+	players      map[string]bool
+	updatedAt    time.Time
+	matched      bool
+}
+
+// Service はプロセス内で動くマッチメーカー
+// 定員ごとに募集中のルームを1つ持ち、定員に達したら次の登録から新しいルームを割り当てる
+type Service struct {
+	idGenerator domain.IDGenerator
+	ttl         time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	open    map[int]*group    // 定員 -> 募集中のルーム
+	tickets map[string]*group // プレイヤーID -> 割り当て先
+	enqueue map[string]time.Time
+}
+
+// NewService は新しいServiceを生成
+// ttl が 0 以下なら DefaultTicketTTL を使う
+func NewService(idGenerator domain.IDGenerator, ttl time.Duration) *Service {
+	if ttl <= 0 {
+		ttl = DefaultTicketTTL
+	}
+	return &Service{
+		idGenerator: idGenerator,
+		ttl:         ttl,
+		now:         time.Now,
+		open:        make(map[int]*group),
+		tickets:     make(map[string]*group),
+		enqueue:     make(map[string]time.Time),
+	}
+}
+
+// Enqueue はプレイヤーを募集中のルームに割り当てる
+func (s *Service) Enqueue(req Request) (*Ticket, error) {
+	if req.PlayerID == "" || req.Capacity < 2 {
+		return nil, ErrInvalidRequest
+	}
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+
+	if g, ok := s.tickets[req.PlayerID]; ok {
+		if !g.matched {
+			return s.ticketLocked(req.PlayerID, g), nil
+		}
+		s.removeLocked(req.PlayerID, g)
+	}
+
+	g := s.open[req.Capacity]
+	if g != nil {
+		for _, roomID := range req.ExcludeRoomIDs {
+			if g.roomID == roomID {
+				// ゲートウェイ側で使えないルームの募集は打ち切り、新しいルームを割り当てる
+				g.matched = true
+				delete(s.open, g.capacity)
+				g = nil
+				break
+			}
+		}
+	}
+	if g == nil {
+		g = &group{
+			roomID:       s.idGenerator.GenerateRoomID(),
+			capacity:     req.Capacity,
+			winningScore: req.WinningScore,
+			players:      make(map[string]bool),
+		}
+		s.open[req.Capacity] = g
+	}
+	g.players[req.PlayerID] = true
+	g.updatedAt = now
+	if len(g.players) >= g.capacity {
+		g.matched = true
+		delete(s.open, g.capacity)
+	}
+	s.tickets[req.PlayerID] = g
+	s.enqueue[req.PlayerID] = now
+	return s.ticketLocked(req.PlayerID, g), nil
+}
+
+// Cancel は登録を取り消し、募集中のルームなら枠を空ける
+func (s *Service) Cancel(playerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.tickets[playerID]
+	if !ok {
+		return nil
+	}
+	s.removeLocked(playerID, g)
+	return nil
+}
+
+// Status はチケットの状態を返す
+func (s *Service) Status(playerID string) (*Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(s.now())
+
+	g, ok := s.tickets[playerID]
+	if !ok {
+		return nil, ErrTicketNotFound
+	}
+	return s.ticketLocked(playerID, g), nil
+}
+
+func (s *Service) ticketLocked(playerID string, g *group) *Ticket {
+	status := StatusWaiting
+	if g.matched {
+		status = StatusMatched
+	}
+	return &Ticket{
+		PlayerID:     playerID,
+		RoomID:       g.roomID,
+		Capacity:     g.capacity,
+		WinningScore: g.winningScore,
+		Players:      len(g.players),
+		Status:       status,
+		EnqueuedAt:   s.enqueue[playerID],
+	}
+}
+
+func (s *Service) removeLocked(playerID string, g *group) {
+	delete(s.tickets, playerID)
+	delete(s.enqueue, playerID)
+	if g.matched {
+		return
+	}
+	delete(g.players, playerID)
+	if len(g.players) == 0 && s.open[g.capacity] == g {
+		delete(s.open, g.capacity)
+	}
+}
+
+// pruneLocked は ttl の間更新のないルームのチケットを捨てる（放置された待機ルームと開始済みのルーム）
+func (s *Service) pruneLocked(now time.Time) {
+	for playerID, g := range s.tickets {
+		if now.Sub(g.updatedAt) > s.ttl {
+			delete(s.tickets, playerID)
+			delete(s.enqueue, playerID)
+			if s.open[g.capacity] == g {
+				delete(s.open, g.capacity)
+			}
+		}
+	}
+}
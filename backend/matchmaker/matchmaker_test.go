@@ -0,0 +1,95 @@
+package matchmaker
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type sequenceIDGenerator struct{ n int }
+
+func (g *sequenceIDGenerator) GenerateRoomID() string {
+	g.n++
+	return string(rune('A' + g.n - 1))
+}
+
+// TestService は待ち行列への登録・取り消し・割り当ての更新のテスト
+func TestService(t *testing.T) {
+	now := time.Now()
+	s := NewService(&sequenceIDGenerator{}, time.Minute)
+	s.now = func() time.Time { return now }
+
+	// テスト1: 定員に達するまで同じルームに割り当て、達したら次は新しいルーム
+	t1, _ := s.Enqueue(Request{PlayerID: "p1", Capacity: 2, WinningScore: 5})
+	t2, _ := s.Enqueue(Request{PlayerID: "p2", Capacity: 2, WinningScore: 9})
+	t3, _ := s.Enqueue(Request{PlayerID: "p3", Capacity: 2, WinningScore: 9})
+	if t1.RoomID != "A" || t2.RoomID != "A" || t3.RoomID != "B" {
+		t.Errorf("unexpected rooms: %s %s %s", t1.RoomID, t2.RoomID, t3.RoomID)
+	}
+	if t2.Status != StatusMatched || t2.WinningScore != 5 || t3.Status != StatusWaiting {
+		t.Errorf("unexpected tickets: %+v %+v", t2, t3)
+	}
+	// 定員ごとに別のルーム
+	if t4, _ := s.Enqueue(Request{PlayerID: "p4", Capacity: 3}); t4.RoomID != "C" {
+		t.Errorf("expected separate room per capacity, got %s", t4.RoomID)
+	}
+
+	// テスト2: 募集中の再登録は同じチケット、定員に達した後の再登録は次の対戦
+	if again, _ := s.Enqueue(Request{PlayerID: "p3", Capacity: 2}); again.RoomID != "B" {
+		t.Errorf("expected idempotent enqueue, got %s", again.RoomID)
+	}
+	if next, _ := s.Enqueue(Request{PlayerID: "p1", Capacity: 2}); next.RoomID != "B" || next.Status != StatusMatched {
+		t.Errorf("expected matched player to join the next room, got %+v", next)
+	}
+
+	// テスト3: 取り消すと募集中のルームの枠が空き、状態は見つからなくなる
+	_ = s.Cancel("p4")
+	if _, err := s.Status("p4"); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("expected cancelled ticket to be gone, got %v", err)
+	}
+	if t5, _ := s.Enqueue(Request{PlayerID: "p5", Capacity: 3}); t5.Players != 1 || t5.RoomID != "D" {
+		t.Errorf("expected empty group to be dropped, got %+v", t5)
+	}
+
+	// テスト4: 使えなかったルームを除外すると新しいルームを割り当てる
+	if t6, _ := s.Enqueue(Request{PlayerID: "p6", Capacity: 3, ExcludeRoomIDs: []string{"D"}}); t6.RoomID != "E" {
+		t.Errorf("expected excluded room to be skipped, got %s", t6.RoomID)
+	}
+
+	// テスト5: 期限を過ぎたチケットは捨てる
+	now = now.Add(2 * time.Minute)
+	if _, err := s.Status("p6"); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("expected expired ticket to be pruned, got %v", err)
+	}
+	if _, err := s.Enqueue(Request{PlayerID: "", Capacity: 2}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected invalid request error, got %v", err)
+	}
+}
+
+// TestHTTPClient はHTTP API経由で Service と同じ結果になることのテスト
+func TestHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPHandler(NewService(&sequenceIDGenerator{}, 0)))
+	defer srv.Close()
+	client := NewHTTPClient(srv.URL, nil)
+
+	// テスト1: 登録と状態の取得
+	ticket, err := client.Enqueue(Request{PlayerID: "p1", Capacity: 2, WinningScore: 5})
+	if err != nil || ticket.RoomID != "A" || ticket.Status != StatusWaiting {
+		t.Fatalf("unexpected enqueue result: %+v %v", ticket, err)
+	}
+	if got, err := client.Status("p1"); err != nil || got.RoomID != "A" {
+		t.Errorf("unexpected status: %+v %v", got, err)
+	}
+
+	// テスト2: 取り消し後は ErrTicketNotFound、不正な登録は ErrInvalidRequest
+	if err := client.Cancel("p1"); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	if _, err := client.Status("p1"); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("expected ErrTicketNotFound, got %v", err)
+	}
+	if _, err := client.Enqueue(Request{PlayerID: "p2", Capacity: 1}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected ErrInvalidRequest, got %v", err)
+	}
+}
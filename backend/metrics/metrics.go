@@ -0,0 +1,347 @@
+// Package metrics は Prometheus テキスト形式のメトリクスを外部依存なしで提供する
+// カウンター・ゲージ・ヒストグラムと、出力時に値を集める Collector のみをサポートする
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Sample は Collector が返す1系列分の値
+// LabelValues は登録時のラベル名と同じ順序で指定する
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry はメトリクスを登録順に保持し、テキスト形式で出力する
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// NewRegistry は新しいRegistryを生成
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s is already registered", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo は全メトリクスをテキスト形式で書き出す
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP は /metrics エンドポイントとして出力する
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// desc はメトリクスの名前・説明・ラベル名
+type desc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+func (d desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// labels は {a="x",b="y"} 形式のラベル文字列を返す（extra は le などの追加ラベル）
+func (d desc) labels(labelValues []string, extra ...string) string {
+	if len(d.labelNames) == 0 && len(extra) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(d.labelNames)+len(extra)/2)
+	for i, name := range d.labelNames {
+		parts = append(parts, name+`="`+escapeLabel(labelValues[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// vec はラベル値ごとの値を保持する
+type vec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newVec(name, help, typ string, labelNames []string) *vec {
+	return &vec{desc: desc{name: name, help: help, typ: typ, labelNames: labelNames}, series: make(map[string]*series)}
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	s.value += delta
+}
+
+func (v *vec) set(value float64, labelValues []string) {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.series[key] = &series{labelValues: append([]string(nil), labelValues...), value: value}
+}
+
+func (v *vec) get(labelValues []string) float64 {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	samples := make([]Sample, 0, len(v.series))
+	for _, s := range v.series {
+		samples = append(samples, Sample{LabelValues: s.labelValues, Value: s.value})
+	}
+	v.mu.Unlock()
+	writeSamples(w, v.desc, samples)
+}
+
+func writeSamples(w *bufio.Writer, d desc, samples []Sample) {
+	d.writeHeader(w)
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].LabelValues, "\xff") < strings.Join(samples[j].LabelValues, "\xff")
+	})
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", d.name, d.labels(s.LabelValues), formatFloat(s.Value))
+	}
+}
+
+// Counter は単調増加する値
+type Counter struct{ *vec }
+
+// NewCounter はカウンターを登録する
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{newVec(name, help, "counter", labelNames)}
+	r.register(name, c)
+	return c
+}
+
+// Inc は1増やす
+func (c *Counter) Inc(labelValues ...string) { c.add(1, labelValues) }
+
+// Add は値を増やす（負の値は無視する）
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.add(delta, labelValues)
+}
+
+// Value は現在の値を返す
+func (c *Counter) Value(labelValues ...string) float64 { return c.get(labelValues) }
+
+// Gauge は増減する値
+type Gauge struct{ *vec }
+
+// NewGauge はゲージを登録する
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{newVec(name, help, "gauge", labelNames)}
+	r.register(name, g)
+	return g
+}
+
+// Set は値を設定する
+func (g *Gauge) Set(value float64, labelValues ...string) { g.set(value, labelValues) }
+
+// Add は値を増減する
+func (g *Gauge) Add(delta float64, labelValues ...string) { g.add(delta, labelValues) }
+
+// Value は現在の値を返す
+func (g *Gauge) Value(labelValues ...string) float64 { return g.get(labelValues) }
+
+// collector は出力のたびに関数を呼んで値を集める
+type collector struct {
+	desc
+	collect func() []Sample
+}
+
+func (c *collector) write(w *bufio.Writer) {
+	writeSamples(w, c.desc, c.collect())
+}
+
+// NewGaugeFunc は出力時に値を集めるゲージを登録する（接続数やルーム数など他の構造が持つ値用）
+func (r *Registry) NewGaugeFunc(name, help string, labelNames []string, collect func() []Sample) {
+	r.register(name, &collector{desc: desc{name: name, help: help, typ: "gauge", labelNames: labelNames}, collect: collect})
+}
+
+// NewCounterFunc は出力時に値を集めるカウンターを登録する
+func (r *Registry) NewCounterFunc(name, help string, labelNames []string, collect func() []Sample) {
+	r.register(name, &collector{desc: desc{name: name, help: help, typ: "counter", labelNames: labelNames}, collect: collect})
+}
+
+// Histogram は観測値の分布（累積バケット・合計・件数）
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// DefaultBuckets はマッチング待ち時間（秒）向けの既定バケット
+var DefaultBuckets = []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+
+// NewHistogram はヒストグラムを登録する（buckets は昇順の上限値）
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{
+		desc:    desc{name: name, help: help, typ: "histogram", labelNames: labelNames},
+		buckets: append([]float64(nil), buckets...),
+		series:  make(map[string]*histogramSeries),
+	}
+	sort.Float64s(h.buckets)
+	r.register(name, h)
+	return h
+}
+
+// Observe は値を1件記録する
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// Count は記録された件数を返す
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(s.labelValues, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(s.labelValues), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(s.labelValues), s.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(v string) string { return labelEscaper.Replace(v) }
+
+func escapeHelp(v string) string { return helpEscaper.Replace(v) }
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRegistryExposition はテキスト形式の出力のテスト
+func TestRegistryExposition(t *testing.T) {
+	registry := NewRegistry()
+	verifies := registry.NewCounter("verify_total", "VERIFY requests by outcome.", "outcome")
+	disconnects := registry.NewCounter("disconnects_total", "Disconnects.")
+	wait := registry.NewHistogram("wait_seconds", "Wait time.", []float64{1, 5}, "mode")
+	registry.NewGaugeFunc("rooms", "Rooms by state.", []string{"state"}, func() []Sample {
+		return []Sample{{LabelValues: []string{"waiting"}, Value: 2}, {LabelValues: []string{"playing"}, Value: 1}}
+	})
+
+	verifies.Inc("wrong")
+	verifies.Inc("correct")
+	verifies.Add(2, "correct")
+	verifies.Add(-1, "correct")
+	disconnects.Inc()
+	wait.Observe(0.5, "random")
+	wait.Observe(3, "random")
+	wait.Observe(10, "random")
+
+	var buf bytes.Buffer
+	if _, err := registry.WriteTo(&buf); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	out := buf.String()
+
+	// テスト1: HELP / TYPE と系列がラベル順に出力される
+	for _, want := range []string{
+		"# HELP verify_total VERIFY requests by outcome.\n# TYPE verify_total counter\nverify_total{outcome=\"correct\"} 3\nverify_total{outcome=\"wrong\"} 1\n",
+		"# TYPE disconnects_total counter\ndisconnects_total 1\n",
+		"# TYPE rooms gauge\nrooms{state=\"playing\"} 1\nrooms{state=\"waiting\"} 2\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// テスト2: ヒストグラムは累積バケット・合計・件数を出力する
+	for _, want := range []string{
+		"wait_seconds_bucket{mode=\"random\",le=\"1\"} 1\n",
+		"wait_seconds_bucket{mode=\"random\",le=\"5\"} 2\n",
+		"wait_seconds_bucket{mode=\"random\",le=\"+Inf\"} 3\n",
+		"wait_seconds_sum{mode=\"random\"} 13.5\n",
+		"wait_seconds_count{mode=\"random\"} 3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// テスト3: ラベル値はエスケープされる
+	verifies.Inc("a\"b\\c\nd")
+	buf.Reset()
+	registry.WriteTo(&buf)
+	if !strings.Contains(buf.String(), `verify_total{outcome="a\"b\\c\nd"} 1`) {
+		t.Errorf("expected escaped label value, got:\n%s", buf.String())
+	}
+
+	// テスト4: HTTP で Prometheus のテキスト形式として返す
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "disconnects_total 1") {
+		t.Errorf("expected metrics in response body")
+	}
+}
+
+// TestRegistryDuplicateName は同名メトリクスの二重登録のテスト
+func TestRegistryDuplicateName(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("dup_total", "first")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic on duplicate registration")
+		}
+	}()
+	registry.NewGauge("dup_total", "second")
+}
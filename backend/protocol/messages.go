@@ -0,0 +1,345 @@
+package protocol
+
+import "encoding/json"
+
+// ========== メッセージ種別 ==========
+// クライアント → サーバー
+const (
+	TypeHello           = "HELLO"
+	TypeJoinRoom        = "JOIN_ROOM"
+	TypeResume          = "RESUME"
+	TypeLeaveRoom       = "LEAVE_ROOM"
+	TypeSelectImage     = "SELECT_IMAGE"
+	TypeVerify          = "VERIFY"
+	TypePong            = "PONG"
+	TypeRequestKeyframe = "REQUEST_KEYFRAME"
+	TypeUseItem         = "USE_ITEM"
+	TypeSetTargeting    = "SET_TARGETING"
+	TypeSelectTarget    = "SELECT_TARGET"
+)
+
+// サーバー → クライアント
+const (
+	TypeHelloAck         = "HELLO_ACK"
+	TypeUpgradeRequired  = "UPGRADE_REQUIRED"
+	TypeAck              = "ACK"
+	TypeError            = "ERROR"
+	TypePing             = "PING"
+	TypeRoomAssigned     = "ROOM_ASSIGNED"
+	TypeStatusUpdate     = "STATUS_UPDATE"
+	TypeJoinFailed       = "JOIN_FAILED"
+	TypeGameStart        = "GAME_START"
+	TypeUpdatePattern    = "UPDATE_PATTERN"
+	TypeVerifyFailed     = "VERIFY_FAILED"
+	TypeOpponentUpdate   = "OPPONENT_UPDATE"
+	TypeOpponentDelta    = "OPPONENT_DELTA"
+	TypeOpponentSelect   = "OPPONENT_SELECT"
+	TypeObstruction      = "OBSTRUCTION"
+	TypeObstructionFired = "OBSTRUCTION_FIRED"
+	TypeGameFinished     = "GAME_FINISHED"
+	TypeResumed          = "RESUMED"
+	TypeServerNotice     = "SERVER_NOTICE"
+	TypeKicked           = "KICKED"
+	TypeServerDraining   = "SERVER_DRAINING"
+	TypeItemAwarded      = "ITEM_AWARDED"
+	TypeItemUsed         = "ITEM_USED"
+	TypeItemRejected     = "ITEM_REJECTED"
+	TypeChooseTarget     = "CHOOSE_TARGET"
+)
+
+// ========== エラーコード ==========
+const (
+	ErrorCodeInvalidPayload = "invalid_payload"
+	ErrorCodeUnknownType    = "unknown_type"
+	ErrorCodeRateLimited    = "rate_limited"
+	ErrorCodeInvalidTarget  = "invalid_target"
+)
+
+// Message はWebSocketメッセージ
+// ID はクライアントが任意で付与するリクエストIDで、直接応答とACKにそのままエコーされる
+// Seq はルームイベントに付与される単調増加のシーケンス番号で、RESUME の起点に使う
+type Message struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Seq     uint64          `json:"seq,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// DTO (Data Transfer Object) 定義
+// 必須フィールドには `protocol:"required"` タグを付け、スキーマの required に反映する
+
+// EmptyPayload はペイロードを持たないメッセージ用
+type EmptyPayload struct{}
+
+// HelloPayload は接続直後にクライアントが送るプロトコルバージョンと対応拡張機能
+type HelloPayload struct {
+	Version      int      `json:"version" protocol:"required"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// HelloAckPayload はネゴシエート結果
+type HelloAckPayload struct {
+	Version      int      `json:"version"`
+	MinVersion   int      `json:"min_version"`
+	MaxVersion   int      `json:"max_version"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// UpgradeRequiredPayload はクライアントのプロトコルが古すぎる場合に送る
+type UpgradeRequiredPayload struct {
+	MinVersion     int    `json:"min_version"`
+	CurrentVersion int    `json:"current_version"`
+	Message        string `json:"message"`
+}
+
+// ErrorPayload はリクエストを処理できなかったことを通知する
+// RetryAfterMs はレート制限時に次のメッセージを受け付けるまでの目安
+type ErrorPayload struct {
+	Code         string `json:"code"`
+	Message      string `json:"message"`
+	Type         string `json:"type,omitempty"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+type JoinRoomPayload struct {
+	RoomID       string `json:"room_id" protocol:"required"`
+	PlayerID     string `json:"player_id" protocol:"required"`
+	WinningScore int    `json:"winning_score"`
+	SessionID    string `json:"session_id"`
+	Capacity     int    `json:"capacity,omitempty"`
+}
+
+// JoinFailedPayload はルーム参加失敗の通知
+type JoinFailedPayload struct {
+	Message string `json:"message"`
+}
+
+// StatusUpdatePayload は待機状態や他プレイヤーの離脱の通知
+type StatusUpdatePayload struct {
+	Status           string `json:"status,omitempty"`
+	Message          string `json:"message,omitempty"`
+	PlayerID         string `json:"player_id,omitempty"`
+	RemainingPlayers int    `json:"remaining_players,omitempty"`
+}
+
+// ResumePayload は再接続時に欠落イベントの再送を要求する
+type ResumePayload struct {
+	RoomID    string `json:"room_id"`
+	PlayerID  string `json:"player_id" protocol:"required"`
+	SessionID string `json:"session_id"`
+	LastSeq   uint64 `json:"last_seq"`
+}
+
+// ResumeResultPayload はRESUMEの結果
+// Mode は replay（欠落イベントを再送）、snapshot（全状態を再送）、waiting（ゲーム開始前）、none（復帰先なし）のいずれか
+type ResumeResultPayload struct {
+	RoomID   string `json:"room_id"`
+	PlayerID string `json:"player_id,omitempty"` // 復帰先のプレイヤー（保存されたセッションから戻した場合は要求と異なる）
+	Mode     string `json:"mode"`
+	LastSeq  uint64 `json:"last_seq"`
+	Replayed int    `json:"replayed,omitempty"`
+}
+
+type LeaveRoomPayload struct {
+	PlayerID string `json:"player_id" protocol:"required"`
+}
+
+type RoomAssignedPayload struct {
+	RoomID   string `json:"room_id"`
+	PlayerID string `json:"player_id"`
+}
+
+type GameStartPayload struct {
+	Target               string              `json:"target"`
+	Images               []string            `json:"images"`
+	OpponentImages       []string            `json:"opponent_images"`
+	WinningScore         int                 `json:"winning_score"`
+	MyCurrentScore       int                 `json:"my_current_score,omitempty"`
+	MyCurrentCombo       int                 `json:"my_current_combo,omitempty"`
+	OpponentCurrentScore int                 `json:"opponent_current_score,omitempty"`
+	PlayerEffect         string              `json:"player_effect,omitempty"`
+	MyItems              []string            `json:"my_items,omitempty"`
+	BROpponents          []BROpponentPayload `json:"br_opponents,omitempty"`
+}
+
+type VerifyPayload struct {
+	RoomID          string `json:"room_id" protocol:"required"`
+	PlayerID        string `json:"player_id" protocol:"required"`
+	Target          string `json:"target"`
+	SelectedIndices []int  `json:"selected_indices" protocol:"required"`
+}
+
+// VerifyFailedPayload は不正解またはロックアウト中で判定しなかったことの通知
+// LockoutRemainingMs が 0 より大きい間は次の VERIFY も受け付けない
+// ProblemReplaced の場合は回答回数の上限に達したため Target / Images の新しい問題に差し替わっている
+type VerifyFailedPayload struct {
+	LockedOut          bool     `json:"locked_out,omitempty"`
+	LockoutRemainingMs int64    `json:"lockout_remaining_ms"`
+	CurrentScore       int      `json:"current_score"`
+	ScoreDeducted      int      `json:"score_deducted,omitempty"`
+	ProblemReplaced    bool     `json:"problem_replaced,omitempty"`
+	Target             string   `json:"target,omitempty"`
+	Images             []string `json:"images,omitempty"`
+}
+
+type UpdatePatternPayload struct {
+	Target       string   `json:"target"`
+	Images       []string `json:"images"`
+	CurrentScore int      `json:"current_score,omitempty"`
+	CurrentCombo int      `json:"current_combo,omitempty"`
+}
+
+type OpponentUpdatePayload struct {
+	Images      []string            `json:"images"`
+	Score       int                 `json:"score"`
+	Combo       int                 `json:"combo"`
+	BROpponents []BROpponentPayload `json:"br_opponents,omitempty"`
+}
+
+// OpponentDeltaPayload は前回送信分からの相手状態の差分
+// Keyframe が true の場合は全相手の全フィールドを含み、クライアントは一覧を置き換える
+type OpponentDeltaPayload struct {
+	Keyframe  bool              `json:"keyframe,omitempty"`
+	Opponents []BROpponentDelta `json:"opponents"`
+	Removed   []string          `json:"removed,omitempty"`
+}
+
+// BROpponentDelta は相手1人分の差分。変化のないフィールドは省略される
+// Images は出題が変わったときだけ含まれる
+type BROpponentDelta struct {
+	PlayerID string   `json:"player_id"`
+	Target   *string  `json:"target,omitempty"`
+	Images   []string `json:"images,omitempty"`
+	Score    *int     `json:"score,omitempty"`
+	Combo    *int     `json:"combo,omitempty"`
+	Effect   *string  `json:"effect,omitempty"`
+}
+
+type BROpponentPayload struct {
+	PlayerID   string   `json:"player_id"`
+	Target     string   `json:"target"`
+	Images     []string `json:"images"`
+	Score      int      `json:"score"`
+	Combo      int      `json:"combo"`
+	Effect     string   `json:"effect,omitempty"`
+	Selections []int    `json:"selections"`
+}
+
+type SelectImagePayload struct {
+	RoomID     string `json:"room_id"`
+	PlayerID   string `json:"player_id" protocol:"required"`
+	ImageIndex int    `json:"image_index" protocol:"required"`
+}
+
+// ObstructionPayload は妨害の発動
+// ExpiresAt（UNIXミリ秒）はサーバーがエフェクトを解除する時刻で、重ねがけで延びた場合は DurationMs より後になる
+// Intensity は演出の強さ（1 が標準）
+// Blocked は対象のシールドが防いだこと（エフェクトはかからない）、Reflected は REFLECT で跳ね返された妨害であることを表す
+type ObstructionPayload struct {
+	Effect     string  `json:"effect"`
+	AttackerID string  `json:"attacker_id"`
+	TargetID   string  `json:"target_id"`
+	DurationMs int64   `json:"duration_ms"`
+	ExpiresAt  int64   `json:"expires_at"`
+	Intensity  float64 `json:"intensity"`
+	Blocked    bool    `json:"blocked,omitempty"`
+	Reflected  bool    `json:"reflected,omitempty"`
+}
+
+// UseItemPayload は持ち物のアイテムを使う要求（SHIELD / CLEANSE / REFLECT）
+// 使うプレイヤーは接続に紐付いたIDで決まり、RoomID を省略した場合も接続のルームを使う
+type UseItemPayload struct {
+	RoomID string `json:"room_id"`
+	Item   string `json:"item" protocol:"required"`
+}
+
+// ItemAwardedPayload はアイテムを手に入れたことの本人への通知
+// Reason は streak（ミスなしの連続正解）か milestone（スコアの節目）、Items は入手後の持ち物
+type ItemAwardedPayload struct {
+	Item   string   `json:"item"`
+	Reason string   `json:"reason"`
+	Items  []string `json:"items"`
+}
+
+// ItemUsedPayload はアイテムを使ったことのルーム全体への通知
+// Outcome は shielded / cleansed / reflected。reflected の場合は TargetID に妨害が移った（Blocked なら相手のシールドが防いだ）
+// Items は使ったプレイヤーの使用後の持ち物
+type ItemUsedPayload struct {
+	PlayerID  string   `json:"player_id"`
+	Item      string   `json:"item"`
+	Outcome   string   `json:"outcome"`
+	TargetID  string   `json:"target_id,omitempty"`
+	Effect    string   `json:"effect,omitempty"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+	Blocked   bool     `json:"blocked,omitempty"`
+	Items     []string `json:"items"`
+}
+
+// ItemRejectedPayload はアイテムを使えなかったことの通知（アイテムは消費しない）
+// Reason は not_held / cooldown / no_effect / shield_active / no_target / not_active のいずれか
+type ItemRejectedPayload struct {
+	Item                string   `json:"item"`
+	Reason              string   `json:"reason"`
+	CooldownRemainingMs int64    `json:"cooldown_remaining_ms,omitempty"`
+	Items               []string `json:"items"`
+}
+
+type GameResultPayload struct {
+	WinnerID string `json:"winner_id"`
+	Message  string `json:"message"`
+}
+
+// ServerNoticePayload は運営から全接続へのお知らせ（メンテナンス予告など）
+type ServerNoticePayload struct {
+	Message string `json:"message"`
+	Level   string `json:"level"` // info / warning
+}
+
+// KickedPayload は運営によってルームから退出させられたことの通知
+type KickedPayload struct {
+	RoomID string `json:"room_id"`
+	Reason string `json:"reason"`
+}
+
+// ServerDrainingPayload はサーバーが再起動のため新規マッチの受付を止めたことの通知
+// Deadline（UNIXミリ秒）までは進行中のゲームを続けられる
+type ServerDrainingPayload struct {
+	Message  string `json:"message"`
+	Deadline int64  `json:"deadline"`
+}
+
+// SetTargetingPayload はコンボで妨害を送る相手の選び方の設定（次の発動から使う）
+// Mode は random / leader / closest_behind / player / choose のいずれかで、player の場合は TargetID を狙う
+// choose の場合は発動のたびに CHOOSE_TARGET が届く。使うプレイヤーは接続に紐付いたIDで決まる
+type SetTargetingPayload struct {
+	RoomID   string `json:"room_id"`
+	Mode     string `json:"mode" protocol:"required"`
+	TargetID string `json:"target_id,omitempty"`
+}
+
+// ChooseTargetPayload は妨害を送る相手を選ばせる攻撃側への通知
+// Deadline（UNIXミリ秒）までに SELECT_TARGET がなければサーバーが無作為に選んで送る
+type ChooseTargetPayload struct {
+	Candidates []TargetCandidatePayload `json:"candidates"`
+	TimeoutMs  int64                    `json:"timeout_ms"`
+	Deadline   int64                    `json:"deadline"`
+}
+
+// TargetCandidatePayload は妨害を送れる相手
+type TargetCandidatePayload struct {
+	PlayerID string `json:"player_id"`
+	Score    int    `json:"score"`
+}
+
+// SelectTargetPayload は CHOOSE_TARGET への応答（TargetID が空なら無作為に選ぶ）
+type SelectTargetPayload struct {
+	RoomID   string `json:"room_id"`
+	TargetID string `json:"target_id"`
+}
+
+// AckPayload は状態を変更するメッセージの受理通知
+// Duplicate が true の場合は同じIDのリクエストを既に処理済みのため再処理していない
+type AckPayload struct {
+	Type      string `json:"type"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
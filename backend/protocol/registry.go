@@ -0,0 +1,69 @@
+package protocol
+
+// Direction はメッセージの送信方向
+type Direction string
+
+const (
+	ClientToServer Direction = "client"
+	ServerToClient Direction = "server"
+)
+
+// MessageSpec はメッセージ種別とペイロード型の対応
+type MessageSpec struct {
+	Type      string
+	Direction Direction
+	// Payload はペイロード型のゼロ値（スキーマ生成に使う）
+	Payload interface{}
+}
+
+// Messages はプロトコルに含まれる全メッセージの定義
+// 新しいメッセージを追加する場合はここに登録する（スキーマ・入力検証・型生成の対象になる）
+var Messages = []MessageSpec{
+	{Type: TypeHello, Direction: ClientToServer, Payload: HelloPayload{}},
+	{Type: TypeJoinRoom, Direction: ClientToServer, Payload: JoinRoomPayload{}},
+	{Type: TypeResume, Direction: ClientToServer, Payload: ResumePayload{}},
+	{Type: TypeLeaveRoom, Direction: ClientToServer, Payload: LeaveRoomPayload{}},
+	{Type: TypeSelectImage, Direction: ClientToServer, Payload: SelectImagePayload{}},
+	{Type: TypeVerify, Direction: ClientToServer, Payload: VerifyPayload{}},
+	{Type: TypePong, Direction: ClientToServer, Payload: EmptyPayload{}},
+	{Type: TypeRequestKeyframe, Direction: ClientToServer, Payload: EmptyPayload{}},
+	{Type: TypeUseItem, Direction: ClientToServer, Payload: UseItemPayload{}},
+	{Type: TypeSetTargeting, Direction: ClientToServer, Payload: SetTargetingPayload{}},
+	{Type: TypeSelectTarget, Direction: ClientToServer, Payload: SelectTargetPayload{}},
+
+	{Type: TypeHelloAck, Direction: ServerToClient, Payload: HelloAckPayload{}},
+	{Type: TypeUpgradeRequired, Direction: ServerToClient, Payload: UpgradeRequiredPayload{}},
+	{Type: TypeAck, Direction: ServerToClient, Payload: AckPayload{}},
+	{Type: TypeError, Direction: ServerToClient, Payload: ErrorPayload{}},
+	{Type: TypePing, Direction: ServerToClient, Payload: EmptyPayload{}},
+	{Type: TypeRoomAssigned, Direction: ServerToClient, Payload: RoomAssignedPayload{}},
+	{Type: TypeStatusUpdate, Direction: ServerToClient, Payload: StatusUpdatePayload{}},
+	{Type: TypeJoinFailed, Direction: ServerToClient, Payload: JoinFailedPayload{}},
+	{Type: TypeGameStart, Direction: ServerToClient, Payload: GameStartPayload{}},
+	{Type: TypeUpdatePattern, Direction: ServerToClient, Payload: UpdatePatternPayload{}},
+	{Type: TypeVerifyFailed, Direction: ServerToClient, Payload: VerifyFailedPayload{}},
+	{Type: TypeOpponentUpdate, Direction: ServerToClient, Payload: OpponentUpdatePayload{}},
+	{Type: TypeOpponentDelta, Direction: ServerToClient, Payload: OpponentDeltaPayload{}},
+	{Type: TypeOpponentSelect, Direction: ServerToClient, Payload: SelectImagePayload{}},
+	{Type: TypeObstruction, Direction: ServerToClient, Payload: ObstructionPayload{}},
+	{Type: TypeObstructionFired, Direction: ServerToClient, Payload: ObstructionPayload{}},
+	{Type: TypeGameFinished, Direction: ServerToClient, Payload: GameResultPayload{}},
+	{Type: TypeResumed, Direction: ServerToClient, Payload: ResumeResultPayload{}},
+	{Type: TypeServerNotice, Direction: ServerToClient, Payload: ServerNoticePayload{}},
+	{Type: TypeKicked, Direction: ServerToClient, Payload: KickedPayload{}},
+	{Type: TypeServerDraining, Direction: ServerToClient, Payload: ServerDrainingPayload{}},
+	{Type: TypeItemAwarded, Direction: ServerToClient, Payload: ItemAwardedPayload{}},
+	{Type: TypeItemUsed, Direction: ServerToClient, Payload: ItemUsedPayload{}},
+	{Type: TypeItemRejected, Direction: ServerToClient, Payload: ItemRejectedPayload{}},
+	{Type: TypeChooseTarget, Direction: ServerToClient, Payload: ChooseTargetPayload{}},
+}
+
+// Lookup は指定方向のメッセージ定義を取得
+func Lookup(direction Direction, msgType string) (MessageSpec, bool) {
+	for _, spec := range Messages {
+		if spec.Direction == direction && spec.Type == msgType {
+			return spec, true
+		}
+	}
+	return MessageSpec{}, false
+}
@@ -0,0 +1,276 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SchemaNode は JSON Schema (draft 2020-12) のうちプロトコルで使う部分集合
+type SchemaNode struct {
+	Ref         string                 `json:"$ref,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Const       string                 `json:"const,omitempty"`
+	Properties  map[string]*SchemaNode `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *SchemaNode            `json:"items,omitempty"`
+	Minimum     *float64               `json:"minimum,omitempty"`
+	OneOf       []*SchemaNode          `json:"oneOf,omitempty"`
+	Description string                 `json:"description,omitempty"`
+}
+
+// SchemaDocument はプロトコル全体のスキーマ
+// $defs にペイロード型と ClientMessage / ServerMessage のユニオンを持つ
+type SchemaDocument struct {
+	Schema string                 `json:"$schema"`
+	ID     string                 `json:"$id"`
+	Title  string                 `json:"title"`
+	OneOf  []*SchemaNode          `json:"oneOf"`
+	Defs   map[string]*SchemaNode `json:"$defs"`
+}
+
+var (
+	schemaOnce sync.Once
+	schemaDoc  *SchemaDocument
+)
+
+// Schema はメッセージ定義から生成したスキーマを返す（生成は初回のみ）
+func Schema() *SchemaDocument {
+	schemaOnce.Do(func() {
+		schemaDoc = buildSchema()
+	})
+	return schemaDoc
+}
+
+// SchemaJSON はスキーマをインデント付きJSONで返す
+func SchemaJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(Schema()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PayloadName はペイロード型のスキーマ上の名前（$defs のキー）を返す
+func PayloadName(payload interface{}) string {
+	return reflect.TypeOf(payload).Name()
+}
+
+func buildSchema() *SchemaDocument {
+	doc := &SchemaDocument{
+		Schema: "https://json-schema.org/draft/2020-12/schema",
+		ID:     "https://recaptchgame/protocol.schema.json",
+		Title:  "recaptchgame WebSocket protocol",
+		Defs:   make(map[string]*SchemaNode),
+	}
+
+	unions := map[Direction]*SchemaNode{
+		ClientToServer: {Description: "クライアントからサーバーへのメッセージ"},
+		ServerToClient: {Description: "サーバーからクライアントへのメッセージ"},
+	}
+	for _, spec := range Messages {
+		payloadRef := schemaForType(reflect.TypeOf(spec.Payload), doc.Defs)
+		envelope := &SchemaNode{
+			Type: "object",
+			Properties: map[string]*SchemaNode{
+				"type":    {Type: "string", Const: spec.Type},
+				"id":      {Type: "string"},
+				"seq":     {Type: "integer", Minimum: zero()},
+				"payload": payloadRef,
+			},
+			Required: []string{"type"},
+		}
+		union := unions[spec.Direction]
+		union.OneOf = append(union.OneOf, envelope)
+	}
+	doc.Defs["ClientMessage"] = unions[ClientToServer]
+	doc.Defs["ServerMessage"] = unions[ServerToClient]
+	doc.OneOf = []*SchemaNode{{Ref: "#/$defs/ClientMessage"}, {Ref: "#/$defs/ServerMessage"}}
+	return doc
+}
+
+func zero() *float64 {
+	v := 0.0
+	return &v
+}
+
+// schemaForType はGoの型からスキーマを作る。構造体は $defs に登録して参照を返す
+func schemaForType(t reflect.Type, defs map[string]*SchemaNode) *SchemaNode {
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return &SchemaNode{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaForType(t.Elem(), defs)
+	case reflect.String:
+		return &SchemaNode{Type: "string"}
+	case reflect.Bool:
+		return &SchemaNode{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &SchemaNode{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &SchemaNode{Type: "integer", Minimum: zero()}
+	case reflect.Float32, reflect.Float64:
+		return &SchemaNode{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &SchemaNode{Type: "array", Items: schemaForType(t.Elem(), defs)}
+	case reflect.Struct:
+		ref := &SchemaNode{Ref: "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		node := &SchemaNode{Type: "object", Properties: make(map[string]*SchemaNode)}
+		defs[t.Name()] = node
+		for _, f := range Fields(t) {
+			node.Properties[f.Name] = schemaForType(f.Type, defs)
+			if f.Required {
+				node.Required = append(node.Required, f.Name)
+			}
+		}
+		sort.Strings(node.Required)
+		return ref
+	}
+	return &SchemaNode{}
+}
+
+// Field はペイロード構造体のJSONフィールド
+type Field struct {
+	Name      string
+	Type      reflect.Type
+	Required  bool
+	OmitEmpty bool
+}
+
+// Fields は構造体のJSONフィールドを定義順に返す（json:"-" と非公開フィールドは除く）
+func Fields(t reflect.Type) []Field {
+	fields := make([]Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, Field{
+			Name:      name,
+			Type:      f.Type,
+			Required:  f.Tag.Get("protocol") == "required",
+			OmitEmpty: strings.Contains(opts, "omitempty"),
+		})
+	}
+	return fields
+}
+
+// ValidatePayload はクライアントから受信したペイロードをスキーマで検証する
+// 未知のメッセージ種別は ErrUnknownType を返す
+func ValidatePayload(msgType string, payload json.RawMessage) error {
+	spec, ok := Lookup(ClientToServer, msgType)
+	if !ok {
+		return ErrUnknownType
+	}
+
+	var value interface{}
+	if len(bytes.TrimSpace(payload)) == 0 || string(bytes.TrimSpace(payload)) == "null" {
+		value = map[string]interface{}{}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		dec.UseNumber()
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("payload is not valid JSON: %w", err)
+		}
+	}
+
+	doc := Schema()
+	return validateNode(doc, doc.Defs[PayloadName(spec.Payload)], value, "payload")
+}
+
+// ErrUnknownType は未定義のメッセージ種別を受信したことを示す
+var ErrUnknownType = fmt.Errorf("unknown message type")
+
+func validateNode(doc *SchemaDocument, node *SchemaNode, value interface{}, path string) error {
+	if node == nil {
+		return nil
+	}
+	if node.Ref != "" {
+		return validateNode(doc, doc.Defs[strings.TrimPrefix(node.Ref, "#/$defs/")], value, path)
+	}
+
+	switch node.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		for _, name := range node.Required {
+			if v, ok := obj[name]; !ok || v == nil {
+				return fmt.Errorf("%s.%s: required", path, name)
+			}
+		}
+		for name, v := range obj {
+			prop, ok := node.Properties[name]
+			// 未知のフィールドは前方互換のため許容し、null は省略と同じ扱いにする
+			if !ok || v == nil {
+				continue
+			}
+			if err := validateNode(doc, prop, v, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		for i, item := range items {
+			if err := validateNode(doc, node.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+		if node.Const != "" && s != node.Const {
+			return fmt.Errorf("%s: expected %q", path, node.Const)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected %s", path, node.Type)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: invalid number", path)
+		}
+		if node.Type == "integer" {
+			if _, err := strconv.ParseInt(n.String(), 10, 64); err != nil {
+				if _, err := strconv.ParseUint(n.String(), 10, 64); err != nil {
+					return fmt.Errorf("%s: expected integer", path)
+				}
+			}
+		}
+		if node.Minimum != nil && f < *node.Minimum {
+			return fmt.Errorf("%s: must be >= %v", path, *node.Minimum)
+		}
+	}
+	return nil
+}
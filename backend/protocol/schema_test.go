@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// TestSchema はメッセージ定義からのスキーマ生成のテスト
+func TestSchema(t *testing.T) {
+	doc := Schema()
+
+	// テスト1: すべてのメッセージが方向ごとのユニオンに含まれる
+	client, server := doc.Defs["ClientMessage"], doc.Defs["ServerMessage"]
+	if client == nil || server == nil {
+		t.Fatalf("expected ClientMessage and ServerMessage unions")
+	}
+	if len(client.OneOf)+len(server.OneOf) != len(Messages) {
+		t.Errorf("expected %d messages, got %d", len(Messages), len(client.OneOf)+len(server.OneOf))
+	}
+
+	// テスト2: 必須フィールドが required に入る
+	verify := doc.Defs["VerifyPayload"]
+	if verify == nil {
+		t.Fatalf("expected VerifyPayload definition")
+	}
+	required := map[string]bool{}
+	for _, name := range verify.Required {
+		required[name] = true
+	}
+	if !required["room_id"] || !required["player_id"] || !required["selected_indices"] || required["target"] {
+		t.Errorf("unexpected required fields: %v", verify.Required)
+	}
+
+	// テスト3: JSONとして出力できる
+	b, err := SchemaJSON()
+	if err != nil || !json.Valid(b) {
+		t.Errorf("expected valid schema JSON, err=%v", err)
+	}
+}
+
+// TestValidatePayload は受信メッセージのスキーマ検証のテスト
+func TestValidatePayload(t *testing.T) {
+	cases := []struct {
+		name    string
+		msgType string
+		payload string
+		ok      bool
+	}{
+		{name: "valid verify", msgType: TypeVerify, payload: `{"room_id":"room1","player_id":"player1","target":"車","selected_indices":[0,3]}`, ok: true},
+		{name: "unknown fields are allowed", msgType: TypeJoinRoom, payload: `{"room_id":"room1","player_id":"player1","extra":true}`, ok: true},
+		{name: "empty payload", msgType: TypePong, payload: ``, ok: true},
+		{name: "missing required", msgType: TypeVerify, payload: `{"room_id":"room1","selected_indices":[0]}`, ok: false},
+		{name: "wrong type", msgType: TypeSelectImage, payload: `{"player_id":"player1","image_index":"3"}`, ok: false},
+		{name: "non integer", msgType: TypeSelectImage, payload: `{"player_id":"player1","image_index":1.5}`, ok: false},
+		{name: "wrong item type", msgType: TypeVerify, payload: `{"room_id":"room1","player_id":"player1","selected_indices":["a"]}`, ok: false},
+		{name: "not an object", msgType: TypeLeaveRoom, payload: `[]`, ok: false},
+		{name: "server message from client", msgType: TypeGameStart, payload: `{}`, ok: false},
+	}
+	for _, c := range cases {
+		err := ValidatePayload(c.msgType, json.RawMessage(c.payload))
+		if (err == nil) != c.ok {
+			t.Errorf("%s: expected ok=%v, got err=%v", c.name, c.ok, err)
+		}
+	}
+
+	if err := ValidatePayload("UNKNOWN", nil); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("expected ErrUnknownType, got %v", err)
+	}
+}
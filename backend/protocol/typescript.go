@@ -0,0 +1,124 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+//go:generate go run ../cmd/tsgen -out ../../frontend/src/types/protocol.d.ts
+
+// TypeScriptDefinitions はメッセージ定義から TypeScript の型定義（.d.ts）を生成する
+// ペイロード型ごとの interface と、方向ごとの判別可能ユニオン ClientMessage / ServerMessage を出力する
+func TypeScriptDefinitions() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by go generate in backend/protocol; DO NOT EDIT.\n")
+	buf.WriteString("// メッセージ契約を変更した場合は backend で `go generate ./protocol` を実行すること\n\n")
+
+	// ペイロード型（ネストした構造体を含む）を登場順に集める
+	var structs []reflect.Type
+	seen := make(map[reflect.Type]bool)
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || seen[t] {
+			return
+		}
+		seen[t] = true
+		structs = append(structs, t)
+		for _, f := range Fields(t) {
+			collect(f.Type)
+		}
+	}
+	for _, spec := range Messages {
+		collect(reflect.TypeOf(spec.Payload))
+	}
+
+	for _, t := range structs {
+		writeInterface(&buf, t)
+	}
+
+	writeUnion(&buf, "ClientMessage", ClientToServer)
+	writeUnion(&buf, "ServerMessage", ServerToClient)
+	buf.WriteString("export type ClientMessageType = ClientMessage['type'];\n")
+	buf.WriteString("export type ServerMessageType = ServerMessage['type'];\n\n")
+	buf.WriteString("/** type から対応するメッセージを取り出す */\n")
+	buf.WriteString("export type ClientMessageOf<T extends ClientMessageType> = Extract<ClientMessage, { type: T }>;\n")
+	buf.WriteString("export type ServerMessageOf<T extends ServerMessageType> = Extract<ServerMessage, { type: T }>;\n")
+	return buf.Bytes()
+}
+
+func writeInterface(buf *bytes.Buffer, t reflect.Type) {
+	fields := Fields(t)
+	if len(fields) == 0 {
+		fmt.Fprintf(buf, "export type %s = Record<string, never>;\n\n", t.Name())
+		return
+	}
+	fmt.Fprintf(buf, "export interface %s {\n", t.Name())
+	for _, f := range fields {
+		optional := ""
+		if isOptionalField(t, f) {
+			optional = "?"
+		}
+		fmt.Fprintf(buf, "    %s%s: %s;\n", f.Name, optional, tsType(f.Type))
+	}
+	buf.WriteString("}\n\n")
+}
+
+// isOptionalField はフィールドが省略されうるかを判定する
+// サーバー送信分は omitempty とポインタ、クライアント送信分は required 以外を省略可能とする
+func isOptionalField(owner reflect.Type, f Field) bool {
+	if f.OmitEmpty || f.Type.Kind() == reflect.Ptr {
+		return true
+	}
+	for _, spec := range Messages {
+		if spec.Direction == ClientToServer && reflect.TypeOf(spec.Payload) == owner {
+			return !f.Required
+		}
+	}
+	return false
+}
+
+func writeUnion(buf *bytes.Buffer, name string, direction Direction) {
+	fmt.Fprintf(buf, "export type %s =\n", name)
+	for _, spec := range Messages {
+		if spec.Direction != direction {
+			continue
+		}
+		payloadType := reflect.TypeOf(spec.Payload)
+		payload := "payload: " + payloadType.Name()
+		if len(Fields(payloadType)) == 0 {
+			payload = "payload?: " + payloadType.Name()
+		}
+		fmt.Fprintf(buf, "    | { type: '%s'; id?: string; seq?: number; %s }\n", spec.Type, payload)
+	}
+	buf.WriteString(";\n\n")
+}
+
+func tsType(t reflect.Type) string {
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return "unknown"
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return tsType(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return tsType(t.Elem()) + "[]"
+	case reflect.Map:
+		return "Record<string, " + tsType(t.Elem()) + ">"
+	case reflect.Struct:
+		return t.Name()
+	}
+	return "unknown"
+}
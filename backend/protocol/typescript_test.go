@@ -0,0 +1,44 @@
+package protocol
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestTypeScriptDefinitions はTypeScript型定義生成のテスト
+func TestTypeScriptDefinitions(t *testing.T) {
+	out := string(TypeScriptDefinitions())
+
+	// テスト1: JSONタグ名と省略可能性が反映される
+	for _, want := range []string{
+		"export interface GameStartPayload {",
+		"    opponent_images: string[];",
+		"    br_opponents?: BROpponentPayload[];",
+		"    selected_indices: number[];",
+		"    target?: string;",
+		"export type EmptyPayload = Record<string, never>;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+
+	// テスト2: 方向ごとのユニオンに各メッセージが入る
+	if !strings.Contains(out, "| { type: 'VERIFY'; id?: string; seq?: number; payload: VerifyPayload }") {
+		t.Errorf("expected VERIFY in ClientMessage union")
+	}
+	if !strings.Contains(out, "| { type: 'PING'; id?: string; seq?: number; payload?: EmptyPayload }") {
+		t.Errorf("expected PING in ServerMessage union")
+	}
+
+	// テスト3: フロントエンドに置かれた生成物が最新である（go generate の実行漏れ検出）
+	current, err := os.ReadFile("../../frontend/src/types/protocol.d.ts")
+	if err != nil {
+		t.Skipf("generated file not found: %v", err)
+	}
+	if !bytes.Equal(current, []byte(out)) {
+		t.Errorf("frontend/src/types/protocol.d.ts is stale; run `go generate ./protocol`")
+	}
+}
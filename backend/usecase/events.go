@@ -0,0 +1,131 @@
+package usecase
+
+import (
+	"log/slog"
+	"sync"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/logging"
+)
+
+type discardPublisher struct{}
+
+func (discardPublisher) Publish(...domain.Event) {}
+
+// publisherOrDiscard は publisher が未指定（nil）の場合にイベントを捨てる発行先を返す
+func publisherOrDiscard(publisher domain.EventPublisher) domain.EventPublisher {
+	if publisher == nil {
+		return discardPublisher{}
+	}
+	return publisher
+}
+
+// MatchHistoryRecorder は GameFinished を対戦の記録として保存するイベント購読者
+// GameStarted の時刻を覚えておき、記録の開始時刻にする
+type MatchHistoryRecorder struct {
+	historyRepo domain.MatchHistoryRepository
+	logger      *slog.Logger
+
+	mu        sync.Mutex
+	startedAt map[string]domain.GameStarted // roomID -> 開始イベント
+}
+
+// NewMatchHistoryRecorder は新しいMatchHistoryRecorderを生成
+func NewMatchHistoryRecorder(historyRepo domain.MatchHistoryRepository, logger *slog.Logger) *MatchHistoryRecorder {
+	return &MatchHistoryRecorder{
+		historyRepo: historyRepo,
+		logger:      logging.OrDiscard(logger),
+		startedAt:   make(map[string]domain.GameStarted),
+	}
+}
+
+// HandleEvent はドメインイベントを受け取る
+func (r *MatchHistoryRecorder) HandleEvent(event domain.Event) {
+	switch e := event.(type) {
+	case domain.GameStarted:
+		r.mu.Lock()
+		r.startedAt[e.RoomID] = e
+		r.mu.Unlock()
+	case domain.RoomExpired:
+		r.mu.Lock()
+		delete(r.startedAt, e.RoomID)
+		r.mu.Unlock()
+	case domain.GameFinished:
+		r.mu.Lock()
+		started, ok := r.startedAt[e.RoomID]
+		delete(r.startedAt, e.RoomID)
+		r.mu.Unlock()
+		if !ok {
+			// 開始していないルームの終了（管理者による待機ルームの終了など）は対戦として残さない
+			return
+		}
+		if err := r.historyRepo.Save(domain.NewMatchRecord(e, started.OccurredAt)); err != nil {
+			r.logger.Error("failed to save match record", "room_id", e.RoomID, "error", err)
+		}
+	}
+}
+
+// ListRecent は新しい順に最大 limit 件の対戦の記録を返す
+func (r *MatchHistoryRecorder) ListRecent(limit int) ([]*domain.MatchRecord, error) {
+	return r.historyRepo.ListRecent(limit)
+}
+
+// DefaultReplayRoomLimit はリプレイを保持するルーム数の既定値
+const DefaultReplayRoomLimit = 200
+
+// ReplayRecorder はルームごとのドメインイベントを発生順に記録するイベント購読者
+// 終了したルームも roomLimit 件までは保持し、超えたら古く終わったルームから捨てる
+type ReplayRecorder struct {
+	roomLimit int
+
+	mu       sync.Mutex
+	timeline map[string][]domain.Event // roomID -> イベント列
+	finished []string                  // 終了した順のルームID
+}
+
+// NewReplayRecorder は新しいReplayRecorderを生成
+// roomLimit が 0 以下なら DefaultReplayRoomLimit を使う
+func NewReplayRecorder(roomLimit int) *ReplayRecorder {
+	if roomLimit <= 0 {
+		roomLimit = DefaultReplayRoomLimit
+	}
+	return &ReplayRecorder{
+		roomLimit: roomLimit,
+		timeline:  make(map[string][]domain.Event),
+	}
+}
+
+// HandleEvent はドメインイベントを受け取る
+func (r *ReplayRecorder) HandleEvent(event domain.Event) {
+	roomID := event.Meta().RoomID
+	if roomID == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.timeline[roomID] = append(r.timeline[roomID], event)
+	switch e := event.(type) {
+	case domain.GameFinished, domain.RoomExpired:
+		r.finishLocked(roomID)
+	case domain.PlayerLeft:
+		if e.RoomDeleted {
+			r.finishLocked(roomID)
+		}
+	}
+}
+
+// Replay はルームのイベント列を発生順に返す（記録がなければ nil）
+func (r *ReplayRecorder) Replay(roomID string) []domain.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.Event(nil), r.timeline[roomID]...)
+}
+
+func (r *ReplayRecorder) finishLocked(roomID string) {
+	r.finished = append(r.finished, roomID)
+	for len(r.finished) > r.roomLimit {
+		delete(r.timeline, r.finished[0])
+		r.finished = r.finished[1:]
+	}
+}
@@ -1,12 +1,16 @@
 package usecase
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"sync"
 	"time"
 
 	"recaptchgame-backend/domain"
+	"recaptchgame-backend/logging"
+	"recaptchgame-backend/matchmaker"
 )
 
 // RoomExecutionGuard はルーム単位で処理を直列化する
@@ -80,26 +84,41 @@ func (uc *ProblemGeneratorUseCase) selectDifferentTarget(prevTarget string) stri
 
 // JoinRoomUseCase はプレイヤーがルームに参加するユースケース
 type JoinRoomUseCase struct {
-	roomRepo    domain.RoomRepository
-	clientRepo  domain.ClientRepository
-	idGenerator domain.IDGenerator
-	roomGuard   *RoomExecutionGuard
+	roomRepo      domain.RoomRepository
+	clientRepo    domain.ClientRepository
+	matchmaker    matchmaker.Client
+	roomGuard     *RoomExecutionGuard
+	suspicionRepo domain.SuspicionRepository
+	rules         domain.GameRules
+	matchmaking   domain.MatchmakingPolicy
+	events        domain.EventPublisher
+	logger        *slog.Logger
 }
 
 // NewJoinRoomUseCase は新しいJoinRoomUseCaseを生成
-func NewJoinRoomUseCase(roomRepo domain.RoomRepository, clientRepo domain.ClientRepository, idGenerator domain.IDGenerator, roomGuard *RoomExecutionGuard) *JoinRoomUseCase {
+// ランダムマッチの割り当て先は matchmakerClient が決める
+func NewJoinRoomUseCase(roomRepo domain.RoomRepository, clientRepo domain.ClientRepository, matchmakerClient matchmaker.Client, roomGuard *RoomExecutionGuard, suspicionRepo domain.SuspicionRepository, rules domain.GameRules, matchmaking domain.MatchmakingPolicy, events domain.EventPublisher, logger *slog.Logger) *JoinRoomUseCase {
 	return &JoinRoomUseCase{
-		roomRepo:    roomRepo,
-		clientRepo:  clientRepo,
-		idGenerator: idGenerator,
-		roomGuard:   roomGuard,
+		roomRepo:      roomRepo,
+		clientRepo:    clientRepo,
+		matchmaker:    matchmakerClient,
+		roomGuard:     roomGuard,
+		suspicionRepo: suspicionRepo,
+		rules:         rules,
+		matchmaking:   matchmaking,
+		events:        publisherOrDiscard(events),
+		logger:        logging.OrDiscard(logger),
 	}
 }
 
+// ErrQuarantined は隔離中のプレイヤーがランダムマッチに参加しようとしたことを示す
+var ErrQuarantined = fmt.Errorf("player is quarantined from random matchmaking")
+
 // JoinRoomInput はJoinRoomの入力
 type JoinRoomInput struct {
 	ClientID     string
 	PlayerID     string
+	RemoteIP     string // 接続元IP（隔離の判定に使う）
 	RoomID       string
 	WinningScore int
 	Capacity     int
@@ -113,44 +132,41 @@ type JoinRoomOutput struct {
 	RoomCapacity  int
 }
 
-// Execute はルーム参加を実行
+// Execute はルーム参加を実行し、ルームを新しく作った場合はロック解放後に RoomCreated を発行する
 func (uc *JoinRoomUseCase) Execute(input JoinRoomInput) (*JoinRoomOutput, error) {
 	actualRoomID := input.RoomID
-	const maxRandomRetries = 3
+	logger := uc.logger.With("player_id", input.PlayerID, "client_id", input.ClientID, "requested_room_id", input.RoomID)
 	randomRetries := 0
 	capacity := input.Capacity
 	if capacity <= 0 {
-		capacity = 2
+		capacity = uc.rules.DefaultCapacity
+	}
+	winningScore := input.WinningScore
+	if winningScore <= 0 {
+		winningScore = uc.rules.DefaultWinningScore
 	}
 
-	// RANDOMの場合はまずグローバルロックで待機ルームを決定/IDを生成する
+	// 隔離中のプレイヤーはランダムマッチから除外し、フレンド対戦のルームは記録対象外にする
+	quarantined := isQuarantined(uc.suspicionRepo, input.PlayerID, input.RemoteIP)
+	if quarantined && input.RoomID == "RANDOM" {
+		logger.Warn("join rejected: player is quarantined")
+		return nil, ErrQuarantined
+	}
+
+	// RANDOMの場合はマッチメーカーに割り当て先のルームを決めてもらう
 	if input.RoomID == "RANDOM" {
-		globalUnlock := uc.roomGuard.Lock("GLOBAL_RANDOM_LOCK")
-		waitingRoom, _ := uc.roomRepo.GetWaitingRoom(capacity)
-		isAvailable := false
-		if waitingRoom != nil {
-			// 個別ルームの状態を読む前に、そのルーム専用のロックを取得してデータ競合を防止する
-			roomUnlock := uc.roomGuard.Lock(waitingRoom.ID)
-			isAvailable = !waitingRoom.IsActive && waitingRoom.CountPlayers() < waitingRoom.Capacity
-			roomUnlock()
-		}
-		if !isAvailable {
-			_ = uc.roomRepo.ClearWaitingRoom(capacity)
-			// 新しいルームIDを生成（実際の保存は個別ルームロック下で行う）
-			actualRoomID = uc.idGenerator.GenerateRoomID()
-			room := domain.NewRoom(actualRoomID, input.PlayerID, "", input.WinningScore, capacity)
-			// mark as public since created via RANDOM flow
-			room.IsPublic = true
-			_ = uc.roomRepo.Save(room)
-			_ = uc.roomRepo.SetWaitingRoom(room.Capacity, room)
-		} else {
-			actualRoomID = waitingRoom.ID
+		ticket, err := uc.matchmaker.Enqueue(matchmaker.Request{PlayerID: input.PlayerID, Capacity: capacity, WinningScore: winningScore})
+		if err != nil {
+			logger.Error("matchmaker enqueue failed", "error", err)
+			return nil, err
 		}
-		globalUnlock()
+		actualRoomID = ticket.RoomID
+		winningScore = ticket.WinningScore
 	}
 
 	// 個別ルームのロックを取りつつ、満員競合が起きた場合はRANDOMなら再試行する
 	var joinErr error
+	var created *domain.RoomCreated
 	for {
 		// 保護ブロック内でロックを取得し、必ず defer で解除することで
 		// panic 発生時のロックリークを防ぐ（スコープも限定する）
@@ -162,13 +178,21 @@ func (uc *JoinRoomUseCase) Execute(input JoinRoomInput) (*JoinRoomOutput, error)
 			room, err := uc.roomRepo.FindByID(actualRoomID)
 			if err != nil {
 				// ルームが存在しない場合（新規生成フロー）、個別ロック下で作成・保存
-				room = domain.NewRoom(actualRoomID, input.PlayerID, "", input.WinningScore, capacity)
+				room = domain.NewRoom(actualRoomID, input.PlayerID, "", winningScore, capacity)
+				room.Unrated = quarantined
 				if input.RoomID == "RANDOM" {
 					// mark as public when created from RANDOM
 					room.IsPublic = true
 					uc.roomRepo.SetWaitingRoom(room.Capacity, room)
 				}
 				uc.roomRepo.Save(room)
+				created = &domain.RoomCreated{
+					EventMeta:    domain.EventMeta{RoomID: room.ID, OccurredAt: time.Now(), ClientID: input.ClientID},
+					CreatorID:    input.PlayerID,
+					Capacity:     room.Capacity,
+					WinningScore: room.WinningScore,
+					Public:       room.IsPublic,
+				}
 				joinedOrStopped = true
 				return
 			}
@@ -184,11 +208,16 @@ func (uc *JoinRoomUseCase) Execute(input JoinRoomInput) (*JoinRoomOutput, error)
 					joinErr = fmt.Errorf("room is active")
 					return
 				}
+				logger.Debug("waiting room already started; retrying", "room_id", room.ID)
 				return
 			}
 
 			// 空きスロットがあれば参加
 			joined := false
+			if quarantined {
+				room.Unrated = true
+			}
+			room.Touch(time.Now())
 			if room.Player1 == nil || room.Player1.ID == "" {
 				room.Player1 = domain.NewPlayer(input.PlayerID)
 				uc.roomRepo.Save(room)
@@ -220,42 +249,34 @@ func (uc *JoinRoomUseCase) Execute(input JoinRoomInput) (*JoinRoomOutput, error)
 			break
 		}
 		if joinErr != nil {
+			logger.Info("join rejected", "room_id", actualRoomID, "error", joinErr)
 			return nil, joinErr
 		}
 
 		if input.RoomID == "RANDOM" {
-			if randomRetries >= maxRandomRetries {
+			if randomRetries >= uc.matchmaking.MaxRandomRetries {
+				logger.Warn("join rejected: random room join retries exceeded", "retries", randomRetries)
 				return nil, fmt.Errorf("random room join retries exceeded")
 			}
 			randomRetries++
-			// 再試行: グローバルロック下で待機ルームを再確認
-			globalUnlock := uc.roomGuard.Lock("GLOBAL_RANDOM_LOCK")
-			waitingRoom, _ := uc.roomRepo.GetWaitingRoom(capacity)
-			if waitingRoom != nil {
-				// 待機ルームの最新状態を読むため、当該ルームのロックを取得してから判定する
-				roomUnlock := uc.roomGuard.Lock(waitingRoom.ID)
-				stillAvailable := !waitingRoom.IsActive && waitingRoom.CountPlayers() < waitingRoom.Capacity
-				roomUnlock()
-
-				if stillAvailable {
-					actualRoomID = waitingRoom.ID
-					globalUnlock()
-					continue
-				}
-				// 待機ルームが既に満員に変わっていたらクリアして新規作成へ
-				uc.roomRepo.ClearWaitingRoom(waitingRoom.Capacity)
-				actualRoomID = uc.idGenerator.GenerateRoomID()
-				globalUnlock()
-				continue
+			// 割り当てられたルームが使えなかったので、そのルームを除外して割り当て直してもらう
+			_ = uc.matchmaker.Cancel(input.PlayerID)
+			ticket, err := uc.matchmaker.Enqueue(matchmaker.Request{PlayerID: input.PlayerID, Capacity: capacity, WinningScore: winningScore, ExcludeRoomIDs: []string{actualRoomID}})
+			if err != nil {
+				logger.Error("matchmaker enqueue failed", "error", err)
+				return nil, err
 			}
-			// 待機ルームが無ければ新規作成して再試行
-			actualRoomID = uc.idGenerator.GenerateRoomID()
-			globalUnlock()
+			actualRoomID = ticket.RoomID
+			winningScore = ticket.WinningScore
 			continue
 		}
 
+		logger.Info("join rejected: room is full", "room_id", actualRoomID)
 		return nil, fmt.Errorf("room is full")
 	}
+	if created != nil {
+		uc.events.Publish(*created)
+	}
 
 	// ルームを最新の状態で取得
 	room, err := uc.roomRepo.FindByID(actualRoomID)
@@ -274,6 +295,7 @@ func (uc *JoinRoomUseCase) Execute(input JoinRoomInput) (*JoinRoomOutput, error)
 			uc.roomRepo.ClearWaitingRoom(room.Capacity)
 		}
 	}
+	logger.Info("player joined room", "room_id", actualRoomID, "room_size", roomSize, "capacity", room.Capacity, "unrated", room.Unrated)
 
 	return &JoinRoomOutput{
 		ActualRoomID:  actualRoomID,
@@ -285,28 +307,45 @@ func (uc *JoinRoomUseCase) Execute(input JoinRoomInput) (*JoinRoomOutput, error)
 
 // VerifyAnswerUseCase は回答検証のユースケース
 type VerifyAnswerUseCase struct {
-	roomRepo    domain.RoomRepository
-	problemGen  *ProblemGeneratorUseCase
-	effectTypes []string
-	roomGuard   *RoomExecutionGuard
+	roomRepo   domain.RoomRepository
+	problemGen *ProblemGeneratorUseCase
+	effects    *domain.EffectRegistry
+	roomGuard  *RoomExecutionGuard
+	penalty    domain.VerifyPenaltyPolicy
+	rules      domain.GameRules
+	items      domain.ItemPolicy
+	events     domain.EventPublisher
+	logger     *slog.Logger
 }
 
 // NewVerifyAnswerUseCase は新しいVerifyAnswerUseCaseを生成
-func NewVerifyAnswerUseCase(roomRepo domain.RoomRepository, problemGen *ProblemGeneratorUseCase, effectTypes []string, roomGuard *RoomExecutionGuard) *VerifyAnswerUseCase {
+// effects が nil なら rules.EffectDuration を既定の持続時間とする既定のエフェクトを使う
+func NewVerifyAnswerUseCase(roomRepo domain.RoomRepository, problemGen *ProblemGeneratorUseCase, effects *domain.EffectRegistry, roomGuard *RoomExecutionGuard, penalty domain.VerifyPenaltyPolicy, rules domain.GameRules, items domain.ItemPolicy, events domain.EventPublisher, logger *slog.Logger) *VerifyAnswerUseCase {
+	if effects == nil {
+		effects = domain.DefaultEffectRegistry(rules.EffectDuration)
+	}
 	return &VerifyAnswerUseCase{
-		roomRepo:    roomRepo,
-		problemGen:  problemGen,
-		effectTypes: effectTypes,
-		roomGuard:   roomGuard,
+		roomRepo:   roomRepo,
+		problemGen: problemGen,
+		effects:    effects,
+		roomGuard:  roomGuard,
+		penalty:    penalty,
+		rules:      rules,
+		items:      items,
+		events:     publisherOrDiscard(events),
+		logger:     logging.OrDiscard(logger),
 	}
 }
 
 // VerifyAnswerInput はVerifyAnswerの入力
+// ClientID / RequestID は発行するイベントに載せ、応答の宛先にする
 type VerifyAnswerInput struct {
 	RoomID          string
 	PlayerID        string
 	Target          string
 	SelectedIndices []int
+	ClientID        string
+	RequestID       string
 }
 
 // BROpponentSnapshot はバトロワ同期用の相手状態スナップショット
@@ -328,37 +367,79 @@ type VerifyAnswerOutput struct {
 	CurrentCombo    int
 	IsGameOver      bool
 	Winner          string
+	SolveTime       time.Duration // 出題から正解までの時間（ボット判定に使う）
 	SendObstruction bool
 	Effect          string
+	EffectDuration  time.Duration
+	EffectExpiresAt time.Time // 対象のエフェクトが解除される時刻
+	EffectIntensity float64
+	EffectBlocked   bool // 対象のシールドが妨害を防いだ
 	TargetPlayer    string
 	BROpponents     []BROpponentSnapshot
+	// 攻撃側に送る相手を選ばせている（妨害は ObstructionTargetingUseCase.Choose で送る）
+	TargetChoicePending bool
+	// 不正解時のペナルティ
+	LockedOut        bool          // ロックアウト中のため判定しなかった
+	LockoutRemaining time.Duration // 次に回答できるまでの時間
+	ProblemReplaced  bool          // 回答回数の上限に達して問題を差し替えた（NewTarget / NewImages に新しい問題）
+	ScoreDeducted    int           // 減点されたスコア
 }
 
-const obstructionEffectDuration = 3 * time.Second
-
-// Execute は回答を検証
+// Execute は回答を検証し、結果のイベント（PlayerScored / AnswerRejected など）をロック解放後に発行する
 func (uc *VerifyAnswerUseCase) Execute(input VerifyAnswerInput) (*VerifyAnswerOutput, error) {
+	output, events, err := uc.verify(input)
+	uc.events.Publish(events...)
+	return output, err
+}
+
+func (uc *VerifyAnswerUseCase) verify(input VerifyAnswerInput) (*VerifyAnswerOutput, []domain.Event, error) {
 	unlock := uc.roomGuard.Lock(input.RoomID)
 	defer unlock()
+	logger := uc.logger.With("room_id", input.RoomID, "player_id", input.PlayerID)
 
 	room, err := uc.roomRepo.FindByID(input.RoomID)
 	if err != nil {
-		return nil, err
+		logger.Info("verify rejected: room not found")
+		return nil, nil, err
 	}
 
 	player := room.GetPlayerByID(input.PlayerID)
 	if player == nil {
-		return nil, fmt.Errorf("player not found")
+		logger.Info("verify rejected: player not in room")
+		return nil, nil, fmt.Errorf("player not found")
 	}
 
 	gameState := room.GetGameStateByPlayerID(input.PlayerID)
 	if gameState == nil {
-		return nil, fmt.Errorf("game state not found")
+		logger.Info("verify rejected: game state not found")
+		return nil, nil, fmt.Errorf("game state not found")
 	}
 	if input.Target != "" && input.Target != gameState.Target {
-		return nil, nil
+		logger.Debug("verify ignored: answer for a previous problem", "target", input.Target, "current_target", gameState.Target)
+		return nil, nil, nil
 	}
 
+	now := time.Now()
+	meta := domain.EventMeta{RoomID: room.ID, OccurredAt: now, ClientID: input.ClientID, RequestID: input.RequestID}
+	if remaining := player.LockoutRemaining(now); remaining > 0 {
+		logger.Debug("verify rejected: player is locked out", "lockout_remaining", remaining)
+		rejected := domain.AnswerRejected{
+			EventMeta:        meta,
+			PlayerID:         player.ID,
+			LockedOut:        true,
+			LockoutRemaining: remaining,
+			Score:            player.Score,
+			Combo:            player.Combo,
+		}
+		return &VerifyAnswerOutput{
+			LockedOut:        true,
+			LockoutRemaining: remaining,
+			CurrentScore:     player.Score,
+			CurrentCombo:     player.Combo,
+		}, []domain.Event{rejected}, nil
+	}
+
+	room.Touch(now)
 	problem := domain.NewProblem(gameState.Target, gameState.Images)
 
 	isCorrect := problem.VerifyAnswer(input.SelectedIndices)
@@ -370,68 +451,167 @@ func (uc *VerifyAnswerUseCase) Execute(input VerifyAnswerInput) (*VerifyAnswerOu
 	}
 
 	if isCorrect {
+		output.SolveTime = gameState.SolveTime(now)
 		player.IncreaseScore()
 		player.IncreaseCombo()
+		player.IncreaseStreak()
 		output.CurrentScore = player.Score
 		output.CurrentCombo = player.Combo
 
-		// ゲーム終了判定
+		// ゲーム終了判定（終了したルームは削除し、以降の回答・退出では扱わない）
 		if player.Score >= room.WinningScore {
 			output.IsGameOver = true
 			output.Winner = player.ID
-			uc.roomRepo.Save(room)
-			return output, nil
+			logger.Info("game finished", "winner_id", player.ID, "score", player.Score, "unrated", room.Unrated)
+			_ = uc.roomRepo.Delete(room.ID)
+			return output, []domain.Event{
+				domain.PlayerScored{EventMeta: meta, PlayerID: player.ID, Score: player.Score, Combo: player.Combo, SolveTime: output.SolveTime, GameOver: true},
+				finishedEvent(meta, room, player.ID, domain.FinishReasonScore),
+			}, nil
 		}
 
 		// 新しい問題を生成
 		newProblem, _ := uc.problemGen.Execute(gameState.Target)
 		gameState.UpdateState(newProblem.Target, newProblem.Images)
+		player.ResetVerifyAttempts()
 		output.NewTarget = newProblem.Target
 		output.NewImages = newProblem.Images
+		awards := player.EarnItems(uc.items, rand.Intn)
 
 		// ✅ ドメインメソッドに委譲（ビジネスルール判定）
-		shouldObstruct := room.EvaluateComboAndApplyObstruction(input.PlayerID)
+		shouldObstruct := room.EvaluateComboAndApplyObstruction(input.PlayerID, uc.rules.ComboThreshold)
 		if shouldObstruct {
 			// リセット後の値を反映
 			output.CurrentCombo = player.Combo
-			var candidates []string
-			if room.Player1 != nil && room.Player1.ID != input.PlayerID {
-				candidates = append(candidates, room.Player1.ID)
-			}
-			if room.Player2 != nil && room.Player2.ID != input.PlayerID {
-				candidates = append(candidates, room.Player2.ID)
-			}
-			for _, ex := range room.ExtraPlayers {
-				if ex != nil && ex.ID != "" && ex.ID != input.PlayerID {
-					candidates = append(candidates, ex.ID)
-				}
-			}
-			if len(candidates) > 0 {
+			candidates := room.ObstructionCandidates(player.ID)
+			switch {
+			case len(candidates) == 0:
+			case player.Targeting == domain.TargetChoose && len(candidates) > 1 && uc.rules.TargetChoiceTimeout > 0 && !player.HasPendingTarget(now):
+				// 相手の選択を待つ（選択を待っている間に再び発動した場合は待たずに送る）
+				player.PendingTargetUntil = now.Add(uc.rules.TargetChoiceTimeout)
+				output.TargetChoicePending = true
+				logger.Info("obstruction waiting for target choice", "deadline", player.PendingTargetUntil)
+			default:
+				fired := fireObstruction(uc.effects, room, player.ID, room.PickObstructionTarget(player.ID, rand.Intn), now, logger)
 				output.SendObstruction = true
-				output.TargetPlayer = candidates[rand.Intn(len(candidates))]
-				// ← エフェクト選択は「戦術的」なのでユースケース層に残す
-				if len(uc.effectTypes) == 0 {
-					output.Effect = string(domain.EffectShake)
-				} else {
-					output.Effect = uc.effectTypes[rand.Intn(len(uc.effectTypes))]
-				}
-				if targetPlayer := room.GetPlayerByID(output.TargetPlayer); targetPlayer != nil {
-					targetPlayer.ApplyEffect(output.Effect, time.Now().Add(obstructionEffectDuration))
-				}
+				output.TargetPlayer = fired.TargetID
+				output.Effect = fired.Effect
+				output.EffectDuration = fired.Duration
+				output.EffectExpiresAt = fired.ExpiresAt
+				output.EffectIntensity = fired.Intensity
+				output.EffectBlocked = fired.Blocked
 			}
 		}
+		logger.Debug("verify correct", "score", output.CurrentScore, "combo", output.CurrentCombo, "solve_time", output.SolveTime)
 		output.BROpponents = buildBROpponentSnapshots(room, input.PlayerID)
 
 		uc.roomRepo.Save(room)
+		events := []domain.Event{domain.PlayerScored{
+			EventMeta: meta,
+			PlayerID:  player.ID,
+			Score:     output.CurrentScore,
+			Combo:     output.CurrentCombo,
+			SolveTime: output.SolveTime,
+			Target:    output.NewTarget,
+			Images:    output.NewImages,
+		}}
+		if output.SendObstruction {
+			events = append(events, domain.ComboObstructionFired{
+				EventMeta:  meta,
+				AttackerID: player.ID,
+				TargetID:   output.TargetPlayer,
+				Effect:     output.Effect,
+				Duration:   output.EffectDuration,
+				ExpiresAt:  output.EffectExpiresAt,
+				Intensity:  output.EffectIntensity,
+				Blocked:    output.EffectBlocked,
+			})
+		}
+		if output.TargetChoicePending {
+			events = append(events, domain.TargetChoiceRequested{
+				EventMeta:  meta,
+				AttackerID: player.ID,
+				Candidates: room.ObstructionCandidates(player.ID),
+				Deadline:   player.PendingTargetUntil,
+			})
+		}
+		for _, award := range awards {
+			logger.Info("item awarded", "item", award.Item, "reason", award.Reason)
+			events = append(events, domain.ItemAwarded{
+				EventMeta: meta,
+				PlayerID:  player.ID,
+				Item:      string(award.Item),
+				Reason:    award.Reason,
+				Items:     player.ItemNames(),
+			})
+		}
+		return output, events, nil
+	}
+
+	// 不正解：ロックアウト・減点を適用し、回答回数の上限に達したら問題を差し替える
+	scoreBefore := player.Score
+	if player.RecordFailedVerify(uc.penalty, now) {
+		newProblem, _ := uc.problemGen.Execute(gameState.Target)
+		gameState.UpdateState(newProblem.Target, newProblem.Images)
+		output.ProblemReplaced = true
+		output.NewTarget = newProblem.Target
+		output.NewImages = newProblem.Images
+	}
+	output.ScoreDeducted = scoreBefore - player.Score
+	output.LockoutRemaining = player.LockoutRemaining(now)
+	output.CurrentScore = player.Score
+	output.CurrentCombo = player.Combo
+	output.BROpponents = buildBROpponentSnapshots(room, input.PlayerID)
+	uc.roomRepo.Save(room)
+	logger.Debug("verify wrong", "failed_attempts", player.FailedAttempts, "lockout", output.LockoutRemaining, "score_deducted", output.ScoreDeducted, "problem_replaced", output.ProblemReplaced)
+
+	return output, []domain.Event{domain.AnswerRejected{
+		EventMeta:        meta,
+		PlayerID:         player.ID,
+		LockoutRemaining: output.LockoutRemaining,
+		ScoreDeducted:    output.ScoreDeducted,
+		ProblemReplaced:  output.ProblemReplaced,
+		Target:           output.NewTarget,
+		Images:           output.NewImages,
+		Score:            output.CurrentScore,
+		Combo:            output.CurrentCombo,
+	}}, nil
+}
+
+// fireObstruction は targetID の相手にレジストリの重みで抽選したエフェクトを送る（相手のシールドがあれば防がれる）
+// 返すイベントの EventMeta は呼び出し側で埋める
+func fireObstruction(effects *domain.EffectRegistry, room *domain.Room, attackerID string, targetID string, now time.Time, logger *slog.Logger) domain.ComboObstructionFired {
+	// ← エフェクト選択は「戦術的」なのでユースケース層に残す（レジストリの重みで抽選）
+	spec := effects.Pick(rand.Intn)
+	fired := domain.ComboObstructionFired{
+		AttackerID: attackerID,
+		TargetID:   targetID,
+		Effect:     string(spec.ID),
+		Duration:   spec.Duration,
+		ExpiresAt:  now.Add(spec.Duration),
+		Intensity:  spec.Intensity,
+	}
+	if target := room.GetPlayerByID(targetID); target != nil {
+		fired.ExpiresAt, fired.Blocked = target.ReceiveObstruction(spec, attackerID, now)
+	}
+	if fired.Blocked {
+		logger.Info("obstruction blocked by shield", "target_id", targetID, "effect", fired.Effect)
 	} else {
-		// 不正解
-		player.ResetCombo()
-		output.CurrentCombo = player.Combo
-		output.BROpponents = buildBROpponentSnapshots(room, input.PlayerID)
-		uc.roomRepo.Save(room)
+		logger.Info("obstruction fired", "target_id", targetID, "effect", fired.Effect, "expires_in", fired.ExpiresAt.Sub(now))
 	}
+	return fired
+}
 
-	return output, nil
+// finishedEvent は削除前のルームの状態から GameFinished を作る
+func finishedEvent(meta domain.EventMeta, room *domain.Room, winnerID string, reason string) domain.GameFinished {
+	return domain.GameFinished{
+		EventMeta: meta,
+		WinnerID:  winnerID,
+		Reason:    reason,
+		PlayerIDs: room.PlayerIDs(),
+		Scores:    room.Scores(),
+		Unrated:   room.Unrated,
+	}
 }
 
 func buildBROpponentSnapshots(room *domain.Room, playerID string) []BROpponentSnapshot {
@@ -471,14 +651,18 @@ type StartGameUseCase struct {
 	roomRepo   domain.RoomRepository
 	problemGen *ProblemGeneratorUseCase
 	roomGuard  *RoomExecutionGuard
+	events     domain.EventPublisher
+	logger     *slog.Logger
 }
 
 // NewStartGameUseCase は新しいStartGameUseCaseを生成
-func NewStartGameUseCase(roomRepo domain.RoomRepository, problemGen *ProblemGeneratorUseCase, roomGuard *RoomExecutionGuard) *StartGameUseCase {
+func NewStartGameUseCase(roomRepo domain.RoomRepository, problemGen *ProblemGeneratorUseCase, roomGuard *RoomExecutionGuard, events domain.EventPublisher, logger *slog.Logger) *StartGameUseCase {
 	return &StartGameUseCase{
 		roomRepo:   roomRepo,
 		problemGen: problemGen,
 		roomGuard:  roomGuard,
+		events:     publisherOrDiscard(events),
+		logger:     logging.OrDiscard(logger),
 	}
 }
 
@@ -492,24 +676,38 @@ type StartGameOutput struct {
 	WinningScore int
 }
 
-// Execute はゲーム開始を実行
+// Execute はゲーム開始を実行し、ロック解放後に GameStarted を発行する
 func (uc *StartGameUseCase) Execute(input StartGameInput) (*StartGameOutput, error) {
+	output, started, err := uc.start(input)
+	if err != nil {
+		return nil, err
+	}
+	uc.events.Publish(started)
+	return output, nil
+}
+
+func (uc *StartGameUseCase) start(input StartGameInput) (*StartGameOutput, domain.Event, error) {
 	unlock := uc.roomGuard.Lock(input.RoomID)
 	defer unlock()
 
 	room, err := uc.roomRepo.FindByID(input.RoomID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	logger := uc.logger.With("room_id", input.RoomID)
 	if !room.IsReady() {
-		return nil, fmt.Errorf("room is not ready")
+		logger.Info("start skipped: room is not ready", "players", room.CountPlayers(), "capacity", room.Capacity)
+		return nil, nil, fmt.Errorf("room is not ready")
 	}
 	if room.IsActive {
-		return nil, fmt.Errorf("game already started")
+		logger.Debug("start skipped: game already started")
+		return nil, nil, fmt.Errorf("game already started")
 	}
 
+	now := time.Now()
 	room.Start()
+	room.Touch(now)
 
 	// Generate problems for each player slot (player1, player2, extra players)
 	// and reset scores
@@ -538,51 +736,74 @@ func (uc *StartGameUseCase) Execute(input StartGameInput) (*StartGameOutput, err
 	if room.Player1 != nil {
 		room.Player1.Score = 0
 		room.Player1.Combo = 0
+		room.Player1.ResetVerifyAttempts()
 	}
 	if room.Player2 != nil {
 		room.Player2.Score = 0
 		room.Player2.Combo = 0
+		room.Player2.ResetVerifyAttempts()
 	}
 	for _, p := range room.ExtraPlayers {
 		if p != nil {
 			p.Score = 0
 			p.Combo = 0
+			p.ResetVerifyAttempts()
 		}
 	}
 
 	uc.roomRepo.Save(room)
+	logger.Info("game started", "players", room.CountPlayers(), "winning_score", room.WinningScore, "unrated", room.Unrated)
 
+	started := domain.GameStarted{
+		EventMeta:    domain.EventMeta{RoomID: room.ID, OccurredAt: now},
+		PlayerIDs:    room.PlayerIDs(),
+		WinningScore: room.WinningScore,
+		Unrated:      room.Unrated,
+	}
 	return &StartGameOutput{
 		WinningScore: room.WinningScore,
-	}, nil
+	}, started, nil
 }
 
 // LeaveRoomUseCase はプレイヤーがルームを退出するユースケース
 type LeaveRoomUseCase struct {
 	roomRepo   domain.RoomRepository
 	clientRepo domain.ClientRepository
+	matchmaker matchmaker.Client
 	roomGuard  *RoomExecutionGuard
+	events     domain.EventPublisher
+	logger     *slog.Logger
 }
 
 // NewLeaveRoomUseCase は新しいLeaveRoomUseCaseを生成
-func NewLeaveRoomUseCase(roomRepo domain.RoomRepository, clientRepo domain.ClientRepository, roomGuard *RoomExecutionGuard) *LeaveRoomUseCase {
+func NewLeaveRoomUseCase(roomRepo domain.RoomRepository, clientRepo domain.ClientRepository, matchmakerClient matchmaker.Client, roomGuard *RoomExecutionGuard, events domain.EventPublisher, logger *slog.Logger) *LeaveRoomUseCase {
 	return &LeaveRoomUseCase{
 		roomRepo:   roomRepo,
 		clientRepo: clientRepo,
+		matchmaker: matchmakerClient,
 		roomGuard:  roomGuard,
+		events:     publisherOrDiscard(events),
+		logger:     logging.OrDiscard(logger),
 	}
 }
 
 // LeaveRoomInput はLeaveRoomの入力
+// Disconnected は明示的な退出ではなく、切断して戻らなかったことによる退出
 type LeaveRoomInput struct {
-	ClientID string
-	PlayerID string
+	ClientID     string
+	PlayerID     string
+	Disconnected bool
 }
 
-// Execute はルーム退出を実行
+// Execute はルーム退出を実行し、ロック解放後に PlayerLeft を発行する
+// 対戦中に残りが1人になった場合は、その1人を勝者としてゲームを終了する（GameFinished）
 func (uc *LeaveRoomUseCase) Execute(input LeaveRoomInput) error {
 	uc.clientRepo.RemoveClient(input.ClientID)
+	uc.events.Publish(uc.leave(input)...)
+	return nil
+}
 
+func (uc *LeaveRoomUseCase) leave(input LeaveRoomInput) []domain.Event {
 	// 事前にルームを検索
 	room, err := uc.roomRepo.FindByPlayerID(input.PlayerID)
 	if err != nil {
@@ -612,15 +833,37 @@ func (uc *LeaveRoomUseCase) Execute(input LeaveRoomInput) error {
 		}
 	}
 
+	logger := uc.logger.With("room_id", room.ID, "player_id", input.PlayerID, "client_id", input.ClientID)
+
+	// ランダムマッチで待機中なら、マッチメーカーの枠も空ける
+	if room.IsPublic {
+		if err := uc.matchmaker.Cancel(input.PlayerID); err != nil {
+			logger.Warn("matchmaker cancel failed", "error", err)
+		}
+	}
+
+	meta := domain.EventMeta{RoomID: room.ID, OccurredAt: time.Now(), ClientID: input.ClientID}
+	left := domain.PlayerLeft{EventMeta: meta, PlayerID: input.PlayerID, Remaining: room.CountPlayers(), WasActive: room.IsActive, Disconnected: input.Disconnected}
+
 	// ルームが空になったら削除
-	if room.CountPlayers() == 0 {
+	switch {
+	case room.CountPlayers() == 0:
+		logger.Info("player left; room is empty and deleted")
 		uc.roomRepo.Delete(room.ID)
 		// 削除対象が現在の待機ルームと一致する場合のみクリア
 		waitingRoom, _ := uc.roomRepo.GetWaitingRoom(room.Capacity)
 		if waitingRoom != nil && waitingRoom.ID == room.ID {
 			uc.roomRepo.ClearWaitingRoom(room.Capacity)
 		}
-	} else {
+		left.RoomDeleted = true
+	case room.IsActive && room.CountPlayers() == 1:
+		// 残った1人の不戦勝（終了したルームは削除する）
+		winnerID := room.PlayerIDs()[0]
+		logger.Info("player left; last remaining player wins by default", "winner_id", winnerID)
+		uc.roomRepo.Delete(room.ID)
+		return []domain.Event{left, finishedEvent(meta, room, winnerID, domain.FinishReasonForfeit)}
+	default:
+		logger.Info("player left room", "remaining_players", room.CountPlayers(), "active", room.IsActive)
 		uc.roomRepo.Save(room)
 		if !room.IsActive && room.IsPublic {
 			waitingRoom, _ := uc.roomRepo.GetWaitingRoom(room.Capacity)
@@ -630,5 +873,535 @@ func (uc *LeaveRoomUseCase) Execute(input LeaveRoomInput) error {
 		}
 	}
 
+	return []domain.Event{left}
+}
+
+// BotDetectionUseCase は回答・画像選択のタイミングからボットの疑いを判定するユースケース
+type BotDetectionUseCase struct {
+	suspicionRepo domain.SuspicionRepository
+	roomRepo      domain.RoomRepository
+	roomGuard     *RoomExecutionGuard
+	policy        domain.SuspicionPolicy
+	logger        *slog.Logger
+	mu            sync.Mutex
+}
+
+// NewBotDetectionUseCase は新しいBotDetectionUseCaseを生成
+func NewBotDetectionUseCase(suspicionRepo domain.SuspicionRepository, roomRepo domain.RoomRepository, roomGuard *RoomExecutionGuard, policy domain.SuspicionPolicy, logger *slog.Logger) *BotDetectionUseCase {
+	return &BotDetectionUseCase{
+		suspicionRepo: suspicionRepo,
+		roomRepo:      roomRepo,
+		roomGuard:     roomGuard,
+		policy:        policy,
+		logger:        logging.OrDiscard(logger),
+	}
+}
+
+// ObserveSolve は正解までの時間を記録する
+// remoteIP は接続元IP（隔離をIDの付け替えで逃れられないよう記録に残す。不明なら空）
+func (uc *BotDetectionUseCase) ObserveSolve(playerID string, remoteIP string, solveTime time.Duration) (*domain.SuspicionRecord, error) {
+	return uc.observe(playerID, remoteIP, func(record *domain.SuspicionRecord, now time.Time) bool {
+		return record.ObserveSolve(solveTime, uc.policy, now)
+	})
+}
+
+// ObserveSelection は画像選択の時刻を記録する
+func (uc *BotDetectionUseCase) ObserveSelection(playerID string, remoteIP string) (*domain.SuspicionRecord, error) {
+	return uc.observe(playerID, remoteIP, func(record *domain.SuspicionRecord, now time.Time) bool {
+		return record.ObserveSelection(uc.policy, now)
+	})
+}
+
+// IsQuarantined はプレイヤー、またはその接続元IPが隔離中かどうか
+func (uc *BotDetectionUseCase) IsQuarantined(playerID string, remoteIP string) bool {
+	return isQuarantined(uc.suspicionRepo, playerID, remoteIP)
+}
+
+// isQuarantined はプレイヤーの記録か、同じ接続元IPの隔離中の記録があるかを返す
+func isQuarantined(repo domain.SuspicionRepository, playerID string, remoteIP string) bool {
+	if record, err := repo.Find(playerID); err == nil && record != nil && record.Quarantined {
+		return true
+	}
+	if remoteIP == "" {
+		return false
+	}
+	records, err := repo.ListFlagged()
+	if err != nil {
+		return false
+	}
+	for _, record := range records {
+		if record.QuarantinesAddress(remoteIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListFlagged は管理者向けにフラグ・隔離されたプレイヤーと理由を返す
+func (uc *BotDetectionUseCase) ListFlagged() ([]*domain.SuspicionRecord, error) {
+	return uc.suspicionRepo.ListFlagged()
+}
+
+// Clear は管理者の判断でプレイヤーの疑いを解除する
+func (uc *BotDetectionUseCase) Clear(playerID string) error {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	record, err := uc.suspicionRepo.Find(playerID)
+	if err != nil || record == nil {
+		return err
+	}
+	record.Clear()
+	uc.logger.Info("suspicion cleared", "player_id", playerID)
+	return uc.suspicionRepo.Save(record)
+}
+
+func (uc *BotDetectionUseCase) observe(playerID string, remoteIP string, apply func(record *domain.SuspicionRecord, now time.Time) bool) (*domain.SuspicionRecord, error) {
+	uc.mu.Lock()
+	record, err := uc.suspicionRepo.Find(playerID)
+	if err != nil {
+		uc.mu.Unlock()
+		return nil, err
+	}
+	if record == nil {
+		record = domain.NewSuspicionRecord(playerID)
+	}
+	if remoteIP != "" {
+		record.RemoteIP = remoteIP
+	}
+	newlyQuarantined := apply(record, time.Now())
+	err = uc.suspicionRepo.Save(record)
+	uc.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// 対戦中に隔離された場合、そのルームの結果は記録対象外にする
+	if newlyQuarantined {
+		uc.logger.Warn("player quarantined", "player_id", playerID, "remote_ip", record.RemoteIP, "score", record.Score, "reasons", record.Reasons)
+		uc.markRoomUnrated(playerID)
+	}
+	return record, nil
+}
+
+func (uc *BotDetectionUseCase) markRoomUnrated(playerID string) {
+	room, err := uc.roomRepo.FindByPlayerID(playerID)
+	if err != nil {
+		return
+	}
+	unlock := uc.roomGuard.Lock(room.ID)
+	defer unlock()
+
+	room, err = uc.roomRepo.FindByID(room.ID)
+	if err != nil || room.GetPlayerByID(playerID) == nil {
+		return
+	}
+	room.Unrated = true
+	uc.roomRepo.Save(room)
+}
+
+// 放置されたルームを片付けた理由
+const (
+	ReapReasonWaitingExpired = "waiting_expired" // 参加者待ちのまま期限切れ
+	ReapReasonIdle           = "idle"            // 対戦中に誰も回答しないまま期限切れ
+)
+
+// ReapRoomsUseCase は放置されたルームと古い待機ルームの枠を片付けるユースケース
+type ReapRoomsUseCase struct {
+	roomRepo   domain.RoomRepository
+	matchmaker matchmaker.Client
+	roomGuard  *RoomExecutionGuard
+	policy     domain.RoomExpiryPolicy
+	events     domain.EventPublisher
+	logger     *slog.Logger
+}
+
+// NewReapRoomsUseCase は新しいReapRoomsUseCaseを生成
+func NewReapRoomsUseCase(roomRepo domain.RoomRepository, matchmakerClient matchmaker.Client, roomGuard *RoomExecutionGuard, policy domain.RoomExpiryPolicy, events domain.EventPublisher, logger *slog.Logger) *ReapRoomsUseCase {
+	return &ReapRoomsUseCase{
+		roomRepo:   roomRepo,
+		matchmaker: matchmakerClient,
+		roomGuard:  roomGuard,
+		policy:     policy,
+		events:     publisherOrDiscard(events),
+		logger:     logging.OrDiscard(logger),
+	}
+}
+
+// ReapedRoom は片付けたルーム（削除前の状態）
+// 対戦中だったルームは WinnerID に首位のプレイヤー（同点なら空文字で引き分け）が入る
+type ReapedRoom struct {
+	Room     *domain.Room
+	Reason   string
+	WinnerID string
+}
+
+// ReapRoomsOutput はReapRoomsの出力
+type ReapRoomsOutput struct {
+	Reaped             []ReapedRoom
+	ClearedWaitingSlot int // 存在しない・開始済み・満員のルームを指していた待機ルームの枠の数
+}
+
+// Execute は期限切れのルームを削除し、待機ルームの枠を整理する
+// 削除したルームごとに RoomExpired（参加者待ち）または GameFinished（対戦中）を発行する
+func (uc *ReapRoomsUseCase) Execute(now time.Time) (*ReapRoomsOutput, error) {
+	rooms, err := uc.roomRepo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	output := &ReapRoomsOutput{}
+	for _, candidate := range rooms {
+		if reaped, ok := uc.reap(candidate.ID, now); ok {
+			output.Reaped = append(output.Reaped, reaped)
+			uc.events.Publish(reapedEvent(reaped, now))
+		}
+	}
+
+	waitingRooms, err := uc.roomRepo.ListWaitingRooms()
+	if err != nil {
+		return output, err
+	}
+	for capacity, waiting := range waitingRooms {
+		room, err := uc.roomRepo.FindByID(waiting.ID)
+		if err == nil && room != nil && !room.IsActive && room.CountPlayers() < room.Capacity {
+			continue
+		}
+		uc.logger.Info("stale waiting room slot cleared", "room_id", waiting.ID, "capacity", capacity)
+		_ = uc.roomRepo.ClearWaitingRoom(capacity)
+		output.ClearedWaitingSlot++
+	}
+	return output, nil
+}
+
+func reapedEvent(reaped ReapedRoom, now time.Time) domain.Event {
+	meta := domain.EventMeta{RoomID: reaped.Room.ID, OccurredAt: now}
+	if reaped.Reason == ReapReasonWaitingExpired {
+		return domain.RoomExpired{EventMeta: meta, PlayerIDs: reaped.Room.PlayerIDs()}
+	}
+	return finishedEvent(meta, reaped.Room, reaped.WinnerID, domain.FinishReasonIdle)
+}
+
+func (uc *ReapRoomsUseCase) reap(roomID string, now time.Time) (ReapedRoom, bool) {
+	unlock := uc.roomGuard.Lock(roomID)
+	defer unlock()
+
+	// ロック取得後に再度取得（一覧の取得後に回答や退出があった可能性があるため）
+	room, err := uc.roomRepo.FindByID(roomID)
+	if err != nil || room == nil {
+		return ReapedRoom{}, false
+	}
+	reaped := ReapedRoom{Room: room}
+	switch {
+	case uc.policy.WaitingExpired(room, now):
+		reaped.Reason = ReapReasonWaitingExpired
+	case uc.policy.IdleExpired(room, now):
+		reaped.Reason = ReapReasonIdle
+		reaped.WinnerID = room.Leader()
+	default:
+		return ReapedRoom{}, false
+	}
+
+	uc.logger.Info("room reaped", "room_id", room.ID, "reason", reaped.Reason, "idle", room.IdleFor(now), "players", room.CountPlayers(), "winner_id", reaped.WinnerID)
+	_ = uc.roomRepo.Delete(room.ID)
+	// 期限切れの待機ルームに割り当てられたチケットを取り消し、次の登録で新しいルームを割り当てさせる
+	if reaped.Reason == ReapReasonWaitingExpired && room.IsPublic {
+		for _, playerID := range room.PlayerIDs() {
+			_ = uc.matchmaker.Cancel(playerID)
+		}
+	}
+	return reaped, true
+}
+
+// EndGameUseCase はルームのゲームを外部から終了させるユースケース（管理者による強制終了）
+type EndGameUseCase struct {
+	roomRepo   domain.RoomRepository
+	matchmaker matchmaker.Client
+	roomGuard  *RoomExecutionGuard
+	events     domain.EventPublisher
+	logger     *slog.Logger
+}
+
+// NewEndGameUseCase は新しいEndGameUseCaseを生成
+func NewEndGameUseCase(roomRepo domain.RoomRepository, matchmakerClient matchmaker.Client, roomGuard *RoomExecutionGuard, events domain.EventPublisher, logger *slog.Logger) *EndGameUseCase {
+	return &EndGameUseCase{
+		roomRepo:   roomRepo,
+		matchmaker: matchmakerClient,
+		roomGuard:  roomGuard,
+		events:     publisherOrDiscard(events),
+		logger:     logging.OrDiscard(logger),
+	}
+}
+
+// EndGameInput はEndGameの入力
+// WinnerID が空なら勝者なし、Note は終了の説明としてイベントに載せる
+type EndGameInput struct {
+	RoomID   string
+	WinnerID string
+	Note     string
+}
+
+var (
+	// ErrRoomNotFound はルームが存在しない
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrPlayerNotInRoom はプレイヤーがルームにいない
+	ErrPlayerNotInRoom = errors.New("player is not in the room")
+)
+
+// Execute はルームを削除し、ロック解放後に GameFinished を発行する
+func (uc *EndGameUseCase) Execute(input EndGameInput) error {
+	finished, err := uc.end(input)
+	if err != nil {
+		return err
+	}
+	uc.events.Publish(finished)
 	return nil
 }
+
+func (uc *EndGameUseCase) end(input EndGameInput) (domain.Event, error) {
+	unlock := uc.roomGuard.Lock(input.RoomID)
+	defer unlock()
+
+	room, err := uc.roomRepo.FindByID(input.RoomID)
+	if err != nil || room == nil {
+		return nil, ErrRoomNotFound
+	}
+	if input.WinnerID != "" && room.GetPlayerByID(input.WinnerID) == nil {
+		return nil, ErrPlayerNotInRoom
+	}
+
+	uc.logger.Info("game ended", "room_id", room.ID, "winner_id", input.WinnerID, "active", room.IsActive, "note", input.Note)
+	_ = uc.roomRepo.Delete(room.ID)
+	if waitingRoom, _ := uc.roomRepo.GetWaitingRoom(room.Capacity); waitingRoom != nil && waitingRoom.ID == room.ID {
+		_ = uc.roomRepo.ClearWaitingRoom(room.Capacity)
+	}
+	if room.IsPublic {
+		for _, playerID := range room.PlayerIDs() {
+			_ = uc.matchmaker.Cancel(playerID)
+		}
+	}
+	finished := finishedEvent(domain.EventMeta{RoomID: room.ID, OccurredAt: time.Now()}, room, input.WinnerID, domain.FinishReasonAdmin)
+	finished.Note = input.Note
+	return finished, nil
+}
+
+// UseItemUseCase はプレイヤーが持ち物のアイテムを使うユースケース
+type UseItemUseCase struct {
+	roomRepo  domain.RoomRepository
+	roomGuard *RoomExecutionGuard
+	policy    domain.ItemPolicy
+	events    domain.EventPublisher
+	logger    *slog.Logger
+}
+
+// NewUseItemUseCase は新しいUseItemUseCaseを生成
+func NewUseItemUseCase(roomRepo domain.RoomRepository, roomGuard *RoomExecutionGuard, policy domain.ItemPolicy, events domain.EventPublisher, logger *slog.Logger) *UseItemUseCase {
+	return &UseItemUseCase{
+		roomRepo:  roomRepo,
+		roomGuard: roomGuard,
+		policy:    policy,
+		events:    publisherOrDiscard(events),
+		logger:    logging.OrDiscard(logger),
+	}
+}
+
+// UseItemInput はUseItemの入力
+// ClientID / RequestID は発行するイベントに載せ、応答の宛先にする
+type UseItemInput struct {
+	RoomID    string
+	PlayerID  string
+	Item      string
+	ClientID  string
+	RequestID string
+}
+
+// UseItemOutput はUseItemの出力
+// CooldownRemaining は ErrItemCooldown で使えなかった場合の、次に使えるまでの時間
+type UseItemOutput struct {
+	Use               domain.ItemUse
+	Items             []string
+	CooldownRemaining time.Duration
+}
+
+// ErrGameNotActive はゲームが始まっていない（または終わった）ルームへの操作
+var ErrGameNotActive = errors.New("game is not active")
+
+// Execute はアイテムを使い、ロック解放後に ItemUsed を発行する
+// 使えなかった場合は domain のエラー（ErrItemNotHeld / ErrItemCooldown など）を返し、アイテムは消費しない
+func (uc *UseItemUseCase) Execute(input UseItemInput) (*UseItemOutput, error) {
+	output, used, err := uc.use(input)
+	if err != nil {
+		return output, err
+	}
+	uc.events.Publish(used)
+	return output, nil
+}
+
+func (uc *UseItemUseCase) use(input UseItemInput) (*UseItemOutput, domain.Event, error) {
+	unlock := uc.roomGuard.Lock(input.RoomID)
+	defer unlock()
+	logger := uc.logger.With("room_id", input.RoomID, "player_id", input.PlayerID, "item", input.Item)
+
+	if !uc.policy.Enabled() || !domain.IsItem(input.Item) {
+		return nil, nil, domain.ErrItemNotHeld
+	}
+	room, err := uc.roomRepo.FindByID(input.RoomID)
+	if err != nil || room == nil {
+		return nil, nil, ErrRoomNotFound
+	}
+	player := room.GetPlayerByID(input.PlayerID)
+	if player == nil {
+		return nil, nil, ErrPlayerNotInRoom
+	}
+	if !room.IsActive {
+		return nil, nil, ErrGameNotActive
+	}
+
+	now := time.Now()
+	use, err := room.UseItem(player.ID, domain.ItemType(input.Item), uc.policy, now)
+	if err != nil {
+		logger.Debug("item rejected", "error", err)
+		return &UseItemOutput{Items: player.ItemNames(), CooldownRemaining: player.ItemCooldownRemaining(now)}, nil, err
+	}
+	uc.roomRepo.Save(room)
+	logger.Info("item used", "outcome", use.Outcome, "target_id", use.TargetID, "blocked", use.Blocked)
+
+	items := player.ItemNames()
+	return &UseItemOutput{Use: use, Items: items}, domain.ItemUsed{
+		EventMeta: domain.EventMeta{RoomID: room.ID, OccurredAt: now, ClientID: input.ClientID, RequestID: input.RequestID},
+		PlayerID:  player.ID,
+		Item:      string(use.Item),
+		Outcome:   use.Outcome,
+		TargetID:  use.TargetID,
+		Effect:    use.Effect,
+		ExpiresAt: use.ExpiresAt,
+		Blocked:   use.Blocked,
+		Items:     items,
+	}, nil
+}
+
+// ObstructionTargetingUseCase は妨害を送る相手の選び方の設定と、相手の選択（CHOOSE_TARGET への応答）を扱うユースケース
+type ObstructionTargetingUseCase struct {
+	roomRepo  domain.RoomRepository
+	effects   *domain.EffectRegistry
+	roomGuard *RoomExecutionGuard
+	events    domain.EventPublisher
+	logger    *slog.Logger
+}
+
+// NewObstructionTargetingUseCase は新しいObstructionTargetingUseCaseを生成
+// effects が nil なら rules.EffectDuration を既定の持続時間とする既定のエフェクトを使う
+func NewObstructionTargetingUseCase(roomRepo domain.RoomRepository, effects *domain.EffectRegistry, roomGuard *RoomExecutionGuard, rules domain.GameRules, events domain.EventPublisher, logger *slog.Logger) *ObstructionTargetingUseCase {
+	if effects == nil {
+		effects = domain.DefaultEffectRegistry(rules.EffectDuration)
+	}
+	return &ObstructionTargetingUseCase{
+		roomRepo:  roomRepo,
+		effects:   effects,
+		roomGuard: roomGuard,
+		events:    publisherOrDiscard(events),
+		logger:    logging.OrDiscard(logger),
+	}
+}
+
+// SetTargetingInput は選び方の設定の入力
+// TargetID は Mode が player の場合に狙うプレイヤー
+type SetTargetingInput struct {
+	RoomID   string
+	PlayerID string
+	Mode     string
+	TargetID string
+}
+
+// SetPreference はプレイヤーの妨害を送る相手の選び方を設定する（次の発動から使う）
+func (uc *ObstructionTargetingUseCase) SetPreference(input SetTargetingInput) error {
+	unlock := uc.roomGuard.Lock(input.RoomID)
+	defer unlock()
+
+	room, err := uc.roomRepo.FindByID(input.RoomID)
+	if err != nil || room == nil {
+		return ErrRoomNotFound
+	}
+	player := room.GetPlayerByID(input.PlayerID)
+	if player == nil {
+		return ErrPlayerNotInRoom
+	}
+	mode := domain.TargetingMode(input.Mode)
+	if mode == domain.TargetPlayer && !room.IsObstructionCandidate(player.ID, input.TargetID) {
+		return domain.ErrInvalidTarget
+	}
+	if err := player.SetTargeting(mode, input.TargetID); err != nil {
+		return err
+	}
+	uc.roomRepo.Save(room)
+	uc.logger.Debug("targeting updated", "room_id", room.ID, "player_id", player.ID, "mode", mode, "target_id", player.TargetingPlayerID)
+	return nil
+}
+
+// ChooseTargetInput は相手の選択の入力
+// TargetID が空の場合や期限を過ぎた場合は無作為に選ぶ（時間切れの処理にも使う）
+// Deadline は時間切れの処理が対象の選択待ちの期限を渡す（別の選択待ちに変わっていれば何もしない）
+type ChooseTargetInput struct {
+	RoomID    string
+	PlayerID  string
+	TargetID  string
+	Deadline  time.Time
+	ClientID  string
+	RequestID string
+}
+
+// Choose は相手の選択を待っている妨害を送り、ロック解放後に ComboObstructionFired を発行する
+// 待っている妨害がなければ domain.ErrNoPendingTarget、送れない相手なら domain.ErrInvalidTarget（待ったまま）を返す
+func (uc *ObstructionTargetingUseCase) Choose(input ChooseTargetInput) error {
+	fired, err := uc.choose(input)
+	if err != nil {
+		return err
+	}
+	uc.events.Publish(fired)
+	return nil
+}
+
+func (uc *ObstructionTargetingUseCase) choose(input ChooseTargetInput) (domain.Event, error) {
+	unlock := uc.roomGuard.Lock(input.RoomID)
+	defer unlock()
+	logger := uc.logger.With("room_id", input.RoomID, "player_id", input.PlayerID)
+
+	room, err := uc.roomRepo.FindByID(input.RoomID)
+	if err != nil || room == nil {
+		return nil, ErrRoomNotFound
+	}
+	player := room.GetPlayerByID(input.PlayerID)
+	if player == nil {
+		return nil, ErrPlayerNotInRoom
+	}
+	if !room.IsActive || player.PendingTargetUntil.IsZero() {
+		return nil, domain.ErrNoPendingTarget
+	}
+	if !input.Deadline.IsZero() && !input.Deadline.Equal(player.PendingTargetUntil) {
+		// 選ばれた後に次の選択待ちが始まっている（古い時間切れ）
+		return nil, domain.ErrNoPendingTarget
+	}
+
+	now := time.Now()
+	targetID := input.TargetID
+	if !player.HasPendingTarget(now) {
+		targetID = ""
+	}
+	if targetID != "" && !room.IsObstructionCandidate(player.ID, targetID) {
+		return nil, domain.ErrInvalidTarget
+	}
+	if targetID == "" {
+		targetID = room.PickObstructionTarget(player.ID, rand.Intn)
+		logger.Debug("target chosen at random", "target_id", targetID)
+	}
+	player.PendingTargetUntil = time.Time{}
+	if targetID == "" {
+		// 相手が全員抜けた
+		uc.roomRepo.Save(room)
+		return nil, domain.ErrNoPendingTarget
+	}
+
+	fired := fireObstruction(uc.effects, room, player.ID, targetID, now, logger)
+	fired.EventMeta = domain.EventMeta{RoomID: room.ID, OccurredAt: now, ClientID: input.ClientID, RequestID: input.RequestID}
+	uc.roomRepo.Save(room)
+	return fired, nil
+}
@@ -1,15 +1,21 @@
 package usecase
 
 import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
 	"testing"
+	"time"
 
 	"recaptchgame-backend/domain"
 	"recaptchgame-backend/infrastructure"
+	"recaptchgame-backend/matchmaker"
 )
 
 // TestProblemGenerator は問題生成機能のテスト
 func TestProblemGenerator(t *testing.T) {
-	factory := domain.NewProblemFactory()
+	factory := domain.NewProblemFactory(domain.DefaultGameRules())
 	gen := NewProblemGeneratorUseCase(
 		factory,
 		domain.GetAllTargets(),
@@ -47,12 +53,12 @@ func TestProblemGenerator(t *testing.T) {
 func TestVerifyAnswer(t *testing.T) {
 	// セットアップ
 	roomRepo := infrastructure.NewMemoryRoomRepository()
-	factory := domain.NewProblemFactory()
+	factory := domain.NewProblemFactory(domain.DefaultGameRules())
 	problemGen := NewProblemGeneratorUseCase(
 		factory,
 		domain.GetAllTargets(),
 	)
-	verifyUC := NewVerifyAnswerUseCase(roomRepo, problemGen, domain.GetAllEffects(), NewRoomExecutionGuard())
+	verifyUC := NewVerifyAnswerUseCase(roomRepo, problemGen, nil, NewRoomExecutionGuard(), domain.DefaultVerifyPenaltyPolicy(), domain.DefaultGameRules(), domain.DefaultItemPolicy(), nil, nil)
 
 	// テスト用ルームを作成
 	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
@@ -107,14 +113,90 @@ func TestVerifyAnswer(t *testing.T) {
 	}
 }
 
+// TestVerifyPenalty は不正解時のロックアウト・回答回数上限・減点のテスト
+func TestVerifyPenalty(t *testing.T) {
+	roomRepo := infrastructure.NewMemoryRoomRepository()
+	problemGen := NewProblemGeneratorUseCase(domain.NewProblemFactory(domain.DefaultGameRules()), domain.GetAllTargets())
+	policy := domain.VerifyPenaltyPolicy{
+		LockoutDuration:       time.Minute,
+		MaxAttemptsPerProblem: 2,
+		ScorePenalty:          1,
+	}
+	verifyUC := NewVerifyAnswerUseCase(roomRepo, problemGen, nil, NewRoomExecutionGuard(), policy, domain.DefaultGameRules(), domain.DefaultItemPolicy(), nil, nil)
+
+	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
+	problem, _ := problemGen.Execute("")
+	room.GameState1.UpdateState(problem.Target, problem.Images)
+	room.Player1.Score = 2
+	room.Start()
+	roomRepo.Save(room)
+
+	wrongInput := func() VerifyAnswerInput {
+		current, _ := roomRepo.FindByID("room1")
+		p := domain.NewProblem(current.GameState1.Target, current.GameState1.Images)
+		correct := make(map[int]bool)
+		for _, i := range p.GetCorrectIndices() {
+			correct[i] = true
+		}
+		var wrong []int
+		for i := range current.GameState1.Images {
+			if !correct[i] {
+				wrong = append(wrong, i)
+			}
+		}
+		return VerifyAnswerInput{RoomID: "room1", PlayerID: "player1", SelectedIndices: wrong}
+	}
+	clearLockout := func() {
+		current, _ := roomRepo.FindByID("room1")
+		current.Player1.LockedUntil = time.Time{}
+		roomRepo.Save(current)
+	}
+
+	// テスト1: 不正解でロックアウトと減点
+	output, err := verifyUC.Execute(wrongInput())
+	if err != nil || output == nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if output.IsCorrect || output.LockoutRemaining <= 0 || output.ScoreDeducted != 1 || output.CurrentScore != 1 {
+		t.Errorf("expected lockout and 1 point deduction, got %+v", output)
+	}
+	if output.ProblemReplaced {
+		t.Errorf("expected problem to be kept after first failure")
+	}
+
+	// テスト2: ロックアウト中は判定しない（正解でも受け付けない）
+	locked, _ := verifyUC.Execute(VerifyAnswerInput{RoomID: "room1", PlayerID: "player1", SelectedIndices: problem.GetCorrectIndices()})
+	if !locked.LockedOut || locked.IsCorrect || locked.LockoutRemaining <= 0 {
+		t.Errorf("expected verify to be rejected during lockout, got %+v", locked)
+	}
+
+	// テスト3: 回答回数の上限で問題が差し替わる
+	clearLockout()
+	output, _ = verifyUC.Execute(wrongInput())
+	if !output.ProblemReplaced || output.NewTarget == "" || len(output.NewImages) == 0 {
+		t.Errorf("expected problem to be replaced, got %+v", output)
+	}
+	updated, _ := roomRepo.FindByID("room1")
+	if updated.GameState1.Target != output.NewTarget || updated.Player1.FailedAttempts != 0 {
+		t.Errorf("expected new problem to be stored with attempts reset")
+	}
+
+	// テスト4: スコアは0未満にならない
+	clearLockout()
+	output, _ = verifyUC.Execute(wrongInput())
+	if output.CurrentScore != 0 || output.ScoreDeducted != 0 {
+		t.Errorf("expected score to stay at 0, got %+v", output)
+	}
+}
+
 // TestJoinRoom はルーム参加のテスト
 func TestJoinRoom(t *testing.T) {
 	// セットアップ
 	roomRepo := infrastructure.NewMemoryRoomRepository()
 	clientRepo := infrastructure.NewMemoryClientRepository()
-	idGen := infrastructure.NewTimeBasedIDGenerator()
+	matchmakerClient := matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), 0)
 	roomGuard := NewRoomExecutionGuard()
-	joinRoomUC := NewJoinRoomUseCase(roomRepo, clientRepo, idGen, roomGuard)
+	joinRoomUC := NewJoinRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, infrastructure.NewMemorySuspicionRepository(), domain.DefaultGameRules(), domain.DefaultMatchmakingPolicy(), nil, nil)
 
 	// テスト1: 最初のプレイヤーがルームに参加
 	input1 := JoinRoomInput{
@@ -160,9 +242,9 @@ func TestJoinRoomRandom(t *testing.T) {
 	// セットアップ
 	roomRepo := infrastructure.NewMemoryRoomRepository()
 	clientRepo := infrastructure.NewMemoryClientRepository()
-	idGen := infrastructure.NewTimeBasedIDGenerator()
+	matchmakerClient := matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), 0)
 	roomGuard := NewRoomExecutionGuard()
-	joinRoomUC := NewJoinRoomUseCase(roomRepo, clientRepo, idGen, roomGuard)
+	joinRoomUC := NewJoinRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, infrastructure.NewMemorySuspicionRepository(), domain.DefaultGameRules(), domain.DefaultMatchmakingPolicy(), nil, nil)
 
 	// テスト: RANDOM参加（新規ルーム作成）
 	input1 := JoinRoomInput{
@@ -185,18 +267,46 @@ func TestJoinRoomRandom(t *testing.T) {
 	if output1.RoomSize != 1 {
 		t.Errorf("expected room size 1 for new random room, got %d", output1.RoomSize)
 	}
+
+	// テスト: マッチメーカーが割り当てた同じルームに2人目が入り、待機ルームの枠が空く
+	output2, err := joinRoomUC.Execute(JoinRoomInput{ClientID: "client2", PlayerID: "player2", RoomID: "RANDOM", WinningScore: 9})
+	if err != nil {
+		t.Fatalf("failed to join random room: %v", err)
+	}
+	if output2.ActualRoomID != output1.ActualRoomID || output2.RoomSize != 2 {
+		t.Errorf("expected second player to join %s, got %+v", output1.ActualRoomID, output2)
+	}
+	if w, _ := roomRepo.GetWaitingRoom(2); w != nil {
+		t.Errorf("expected waiting slot to be cleared once the room is full")
+	}
+
+	// テスト: 割り当てられたルームが開始済みなら、除外して別のルームを割り当て直してもらう
+	room, _ := roomRepo.FindByID(output1.ActualRoomID)
+	room.Start()
+	roomRepo.Save(room)
+	stale, _ := matchmakerClient.Enqueue(matchmaker.Request{PlayerID: "other", Capacity: 2, WinningScore: 5})
+	blocking := domain.NewRoom(stale.RoomID, "x", "y", 5, 2)
+	blocking.Start()
+	roomRepo.Save(blocking)
+	output3, err := joinRoomUC.Execute(JoinRoomInput{ClientID: "client3", PlayerID: "player3", RoomID: "RANDOM", WinningScore: 5})
+	if err != nil {
+		t.Fatalf("failed to join random room: %v", err)
+	}
+	if output3.ActualRoomID == stale.RoomID || output3.RoomSize != 1 {
+		t.Errorf("expected a fresh room instead of %s, got %+v", stale.RoomID, output3)
+	}
 }
 
 // TestStartGame はゲーム開始のテスト
 func TestStartGame(t *testing.T) {
 	// セットアップ
 	roomRepo := infrastructure.NewMemoryRoomRepository()
-	factory := domain.NewProblemFactory()
+	factory := domain.NewProblemFactory(domain.DefaultGameRules())
 	problemGen := NewProblemGeneratorUseCase(
 		factory,
 		domain.GetAllTargets(),
 	)
-	startGameUC := NewStartGameUseCase(roomRepo, problemGen, NewRoomExecutionGuard())
+	startGameUC := NewStartGameUseCase(roomRepo, problemGen, NewRoomExecutionGuard(), nil, nil)
 
 	// ルームを作成
 	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
@@ -244,7 +354,7 @@ func TestLeaveRoom(t *testing.T) {
 	roomRepo := infrastructure.NewMemoryRoomRepository()
 	clientRepo := infrastructure.NewMemoryClientRepository()
 	roomGuard := NewRoomExecutionGuard()
-	leaveRoomUC := NewLeaveRoomUseCase(roomRepo, clientRepo, roomGuard)
+	leaveRoomUC := NewLeaveRoomUseCase(roomRepo, clientRepo, matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), 0), roomGuard, nil, nil)
 
 	// ルームを作成
 	room := domain.NewRoom("room1", "player1", "player2", 5, 4)
@@ -286,16 +396,112 @@ func TestLeaveRoom(t *testing.T) {
 	}
 }
 
+// TestReapRooms は放置されたルームの片付けのテスト
+func TestReapRooms(t *testing.T) {
+	// セットアップ
+	roomRepo := infrastructure.NewMemoryRoomRepository()
+	roomGuard := NewRoomExecutionGuard()
+	policy := domain.RoomExpiryPolicy{WaitingTimeout: 10 * time.Minute, IdleTimeout: 5 * time.Minute}
+	reapRoomsUC := NewReapRoomsUseCase(roomRepo, matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), 0), roomGuard, policy, nil, nil)
+	now := time.Now()
+
+	waiting := domain.NewRoom("waiting", "player1", "", 5, 2)
+	waiting.Touch(now.Add(-11 * time.Minute))
+	roomRepo.Save(waiting)
+	roomRepo.SetWaitingRoom(2, waiting)
+
+	fresh := domain.NewRoom("fresh", "player2", "", 5, 3)
+	roomRepo.Save(fresh)
+	roomRepo.SetWaitingRoom(3, fresh)
+
+	idle := domain.NewRoom("idle", "player3", "player4", 5, 2)
+	idle.Start()
+	idle.Player1.Score = 2
+	idle.Touch(now.Add(-6 * time.Minute))
+	roomRepo.Save(idle)
+
+	tied := domain.NewRoom("tied", "player5", "player6", 5, 2)
+	tied.Start()
+	tied.Touch(now.Add(-6 * time.Minute))
+	roomRepo.Save(tied)
+
+	playing := domain.NewRoom("playing", "player7", "player8", 5, 2)
+	playing.Start()
+	roomRepo.Save(playing)
+
+	// 開始済みのルームを指したままの待機ルームの枠
+	roomRepo.SetWaitingRoom(4, playing)
+
+	output, err := reapRoomsUC.Execute(now)
+	if err != nil {
+		t.Fatalf("failed to reap rooms: %v", err)
+	}
+
+	// テスト1: 期限切れのルームだけを削除し、対戦中だったルームは首位（同点なら空）を勝者にする
+	reasons := make(map[string]ReapedRoom)
+	for _, reaped := range output.Reaped {
+		reasons[reaped.Room.ID] = reaped
+	}
+	if len(reasons) != 3 {
+		t.Fatalf("expected 3 reaped rooms, got %+v", output.Reaped)
+	}
+	if reasons["waiting"].Reason != ReapReasonWaitingExpired {
+		t.Errorf("expected waiting room to expire, got %q", reasons["waiting"].Reason)
+	}
+	if reasons["idle"].Reason != ReapReasonIdle || reasons["idle"].WinnerID != "player3" {
+		t.Errorf("expected idle room to end with leader as winner, got %+v", reasons["idle"])
+	}
+	if reasons["tied"].WinnerID != "" {
+		t.Errorf("expected tied room to end in a draw, got %q", reasons["tied"].WinnerID)
+	}
+	for _, id := range []string{"waiting", "idle", "tied"} {
+		if _, err := roomRepo.FindByID(id); err == nil {
+			t.Errorf("expected room %s to be deleted", id)
+		}
+	}
+	for _, id := range []string{"fresh", "playing"} {
+		if _, err := roomRepo.FindByID(id); err != nil {
+			t.Errorf("expected room %s to remain", id)
+		}
+	}
+
+	// テスト2: 削除したルームや開始済みのルームを指す待機ルームの枠をクリアし、有効な枠は残す
+	if output.ClearedWaitingSlot != 2 {
+		t.Errorf("expected 2 cleared waiting slots, got %d", output.ClearedWaitingSlot)
+	}
+	if w, _ := roomRepo.GetWaitingRoom(2); w != nil {
+		t.Errorf("expected waiting slot for expired room to be cleared")
+	}
+	if w, _ := roomRepo.GetWaitingRoom(3); w == nil || w.ID != "fresh" {
+		t.Errorf("expected valid waiting slot to remain")
+	}
+
+	// テスト3: 回答があればアクティビティが更新され、期限切れにならない
+	problemGen := NewProblemGeneratorUseCase(domain.NewProblemFactory(domain.DefaultGameRules()), domain.GetAllTargets())
+	verifyUC := NewVerifyAnswerUseCase(roomRepo, problemGen, nil, roomGuard, domain.DefaultVerifyPenaltyPolicy(), domain.DefaultGameRules(), domain.DefaultItemPolicy(), nil, nil)
+	stale := domain.NewRoom("stale", "player9", "player10", 5, 2)
+	stale.Start()
+	stale.Touch(now.Add(-6 * time.Minute))
+	roomRepo.Save(stale)
+	if _, err := verifyUC.Execute(VerifyAnswerInput{RoomID: "stale", PlayerID: "player9", SelectedIndices: []int{0}}); err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	output, _ = reapRoomsUC.Execute(time.Now())
+	if len(output.Reaped) != 0 {
+		t.Errorf("expected room with recent verify to survive, got %+v", output.Reaped)
+	}
+}
+
 // TestComboAndObstruction はコンボと妨害のテスト
 func TestComboAndObstruction(t *testing.T) {
 	// セットアップ
 	roomRepo := infrastructure.NewMemoryRoomRepository()
-	factory := domain.NewProblemFactory()
+	factory := domain.NewProblemFactory(domain.DefaultGameRules())
 	problemGen := NewProblemGeneratorUseCase(
 		factory,
 		domain.GetAllTargets(),
 	)
-	verifyUC := NewVerifyAnswerUseCase(roomRepo, problemGen, domain.GetAllEffects(), NewRoomExecutionGuard())
+	verifyUC := NewVerifyAnswerUseCase(roomRepo, problemGen, nil, NewRoomExecutionGuard(), domain.DefaultVerifyPenaltyPolicy(), domain.DefaultGameRules(), domain.DefaultItemPolicy(), nil, nil)
 
 	// ルームを作成
 	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
@@ -350,6 +556,183 @@ func TestComboAndObstruction(t *testing.T) {
 	if output2.Effect == "" {
 		t.Errorf("expected effect to be set")
 	}
+
+	// エフェクトの持続時間はレジストリの定義に従い、対象の解除時刻と一致する
+	spec, _ := domain.DefaultEffectRegistry(domain.DefaultGameRules().EffectDuration).Lookup(output2.Effect)
+	if output2.EffectDuration != spec.Duration || output2.EffectIntensity != spec.Intensity {
+		t.Errorf("expected duration %s and intensity %v for %s, got %s %v", spec.Duration, spec.Intensity, output2.Effect, output2.EffectDuration, output2.EffectIntensity)
+	}
+	updatedRoom, _ = roomRepo.FindByID("room1")
+	if target := updatedRoom.GetPlayerByID("player2"); target.CurrentEffect != output2.Effect || !target.EffectExpiresAt.Equal(output2.EffectExpiresAt) {
+		t.Errorf("expected target effect to expire at %v, got %s %v", output2.EffectExpiresAt, target.CurrentEffect, target.EffectExpiresAt)
+	}
+}
+
+// TestItems はアイテムの入手・シールドによる妨害の防御・使用の制限のテスト
+func TestItems(t *testing.T) {
+	roomRepo := infrastructure.NewMemoryRoomRepository()
+	problemGen := NewProblemGeneratorUseCase(domain.NewProblemFactory(domain.DefaultGameRules()), domain.GetAllTargets())
+	roomGuard := NewRoomExecutionGuard()
+	policy := domain.ItemPolicy{InventorySize: 2, StreakInterval: 2, StreakItems: []domain.ItemType{domain.ItemShield}, Cooldown: time.Minute}
+	verifyUC := NewVerifyAnswerUseCase(roomRepo, problemGen, nil, roomGuard, domain.DefaultVerifyPenaltyPolicy(), domain.DefaultGameRules(), policy, nil, nil)
+	useItemUC := NewUseItemUseCase(roomRepo, roomGuard, policy, nil, nil)
+
+	room := domain.NewRoom("room1", "player1", "player2", 10, 2)
+	for _, gs := range []*domain.GameState{room.GameState1, room.GameState2} {
+		problem, _ := problemGen.Execute("")
+		gs.UpdateState(problem.Target, problem.Images)
+	}
+	room.Start()
+	roomRepo.Save(room)
+	answer := func(playerID string) *VerifyAnswerOutput {
+		t.Helper()
+		current, _ := roomRepo.FindByID("room1")
+		gs := current.GetGameStateByPlayerID(playerID)
+		output, err := verifyUC.Execute(VerifyAnswerInput{RoomID: "room1", PlayerID: playerID, SelectedIndices: domain.NewProblem(gs.Target, gs.Images).GetCorrectIndices()})
+		if err != nil {
+			t.Fatalf("verify failed: %v", err)
+		}
+		return output
+	}
+
+	// テスト1: ミスなしの連続正解でアイテムを手に入れる
+	answer("player2")
+	answer("player2")
+	current, _ := roomRepo.FindByID("room1")
+	if items := current.GetPlayerByID("player2").Items; len(items) != 1 || items[0] != domain.ItemShield {
+		t.Fatalf("expected a shield after 2 correct answers, got %v", items)
+	}
+
+	// テスト2: シールドを張ると次の妨害を1回防ぎ、待ち時間の間は次のアイテムを使えない
+	output, err := useItemUC.Execute(UseItemInput{RoomID: "room1", PlayerID: "player2", Item: string(domain.ItemShield)})
+	if err != nil || output.Use.Outcome != domain.ItemOutcomeShielded || len(output.Items) != 0 {
+		t.Fatalf("expected shield to be used, got %+v %v", output, err)
+	}
+	answer("player1")
+	if fired := answer("player1"); !fired.SendObstruction || !fired.EffectBlocked {
+		t.Errorf("expected obstruction to be blocked by the shield, got %+v", fired)
+	}
+	current, _ = roomRepo.FindByID("room1")
+	if target := current.GetPlayerByID("player2"); target.CurrentEffect != "" || target.Shielded {
+		t.Errorf("expected shield to be consumed without an effect, got %+v", target)
+	}
+	target := current.GetPlayerByID("player2")
+	target.Items = []domain.ItemType{domain.ItemShield}
+	roomRepo.Save(current)
+	output, err = useItemUC.Execute(UseItemInput{RoomID: "room1", PlayerID: "player2", Item: string(domain.ItemShield)})
+	if !errors.Is(err, domain.ErrItemCooldown) || output.CooldownRemaining <= 0 || len(output.Items) != 1 {
+		t.Errorf("expected cooldown rejection without consuming the item, got %+v %v", output, err)
+	}
+
+	// テスト3: 持っていないアイテムやルームにいないプレイヤーは使えない
+	if _, err := useItemUC.Execute(UseItemInput{RoomID: "room1", PlayerID: "player1", Item: string(domain.ItemReflect)}); !errors.Is(err, domain.ErrItemNotHeld) {
+		t.Errorf("expected ErrItemNotHeld, got %v", err)
+	}
+	if _, err := useItemUC.Execute(UseItemInput{RoomID: "room1", PlayerID: "stranger", Item: string(domain.ItemShield)}); !errors.Is(err, ErrPlayerNotInRoom) {
+		t.Errorf("expected ErrPlayerNotInRoom, got %v", err)
+	}
+}
+
+// TestObstructionTargeting は妨害を送る相手の選択（CHOOSE_TARGET）と時間切れの処理のテスト
+func TestObstructionTargeting(t *testing.T) {
+	roomRepo := infrastructure.NewMemoryRoomRepository()
+	problemGen := NewProblemGeneratorUseCase(domain.NewProblemFactory(domain.DefaultGameRules()), domain.GetAllTargets())
+	roomGuard := NewRoomExecutionGuard()
+	rules := domain.DefaultGameRules()
+	rules.TargetChoiceTimeout = 50 * time.Millisecond
+	events := &recordingPublisher{}
+	verifyUC := NewVerifyAnswerUseCase(roomRepo, problemGen, nil, roomGuard, domain.DefaultVerifyPenaltyPolicy(), rules, domain.DefaultItemPolicy(), events, nil)
+	targetingUC := NewObstructionTargetingUseCase(roomRepo, nil, roomGuard, rules, events, nil)
+
+	room := domain.NewRoom("room1", "player1", "player2", 20, 3)
+	room.ExtraPlayers[0] = domain.NewPlayer("player3")
+	for _, playerID := range room.PlayerIDs() {
+		problem, _ := problemGen.Execute("")
+		room.GetGameStateByPlayerID(playerID).UpdateState(problem.Target, problem.Images)
+	}
+	room.Start()
+	roomRepo.Save(room)
+	comboUp := func() *VerifyAnswerOutput {
+		t.Helper()
+		var output *VerifyAnswerOutput
+		for i := 0; i < rules.ComboThreshold; i++ {
+			current, _ := roomRepo.FindByID("room1")
+			gs := current.GetGameStateByPlayerID("player1")
+			output, _ = verifyUC.Execute(VerifyAnswerInput{RoomID: "room1", PlayerID: "player1", SelectedIndices: domain.NewProblem(gs.Target, gs.Images).GetCorrectIndices()})
+		}
+		return output
+	}
+
+	// テスト1: choose の場合は妨害を送らずに相手の選択を待ち、候補を載せた TargetChoiceRequested を発行する
+	if err := targetingUC.SetPreference(SetTargetingInput{RoomID: "room1", PlayerID: "player1", Mode: "choose"}); err != nil {
+		t.Fatalf("failed to set targeting: %v", err)
+	}
+	output := comboUp()
+	if !output.TargetChoicePending || output.SendObstruction {
+		t.Fatalf("expected obstruction to wait for a target choice, got %+v", output)
+	}
+	requested, ok := events.events[len(events.events)-1].(domain.TargetChoiceRequested)
+	if !ok || requested.AttackerID != "player1" || len(requested.Candidates) != 2 {
+		t.Fatalf("expected TargetChoiceRequested with 2 candidates, got %v", events.types())
+	}
+
+	// テスト2: 送れない相手は選べず、選んだ相手に送ったら待っている妨害はなくなる
+	if err := targetingUC.Choose(ChooseTargetInput{RoomID: "room1", PlayerID: "player1", TargetID: "player1"}); !errors.Is(err, domain.ErrInvalidTarget) {
+		t.Errorf("expected ErrInvalidTarget, got %v", err)
+	}
+	if err := targetingUC.Choose(ChooseTargetInput{RoomID: "room1", PlayerID: "player1", TargetID: "player3"}); err != nil {
+		t.Fatalf("failed to choose target: %v", err)
+	}
+	if fired, ok := events.events[len(events.events)-1].(domain.ComboObstructionFired); !ok || fired.TargetID != "player3" || fired.AttackerID != "player1" {
+		t.Errorf("expected obstruction to the chosen target, got %v", events.types())
+	}
+	if err := targetingUC.Choose(ChooseTargetInput{RoomID: "room1", PlayerID: "player1", TargetID: "player2"}); !errors.Is(err, domain.ErrNoPendingTarget) {
+		t.Errorf("expected ErrNoPendingTarget after choosing, got %v", err)
+	}
+
+	// テスト3: 期限を過ぎた選択は無作為に選んだ相手に送る
+	comboUp()
+	time.Sleep(2 * rules.TargetChoiceTimeout)
+	if err := targetingUC.Choose(ChooseTargetInput{RoomID: "room1", PlayerID: "player1", TargetID: "unknown"}); err != nil {
+		t.Fatalf("expected late choice to fall back to random, got %v", err)
+	}
+	if fired, ok := events.events[len(events.events)-1].(domain.ComboObstructionFired); !ok || (fired.TargetID != "player2" && fired.TargetID != "player3") {
+		t.Errorf("expected obstruction to a random opponent, got %v", events.types())
+	}
+
+	// テスト4: 狙う相手を決めておけば選択を待たずに送る
+	if err := targetingUC.SetPreference(SetTargetingInput{RoomID: "room1", PlayerID: "player1", Mode: "player", TargetID: "player2"}); err != nil {
+		t.Fatalf("failed to set targeting: %v", err)
+	}
+	if output := comboUp(); !output.SendObstruction || output.TargetPlayer != "player2" {
+		t.Errorf("expected obstruction to the preferred player, got %+v", output)
+	}
+	if err := targetingUC.SetPreference(SetTargetingInput{RoomID: "room1", PlayerID: "player1", Mode: "player", TargetID: "stranger"}); !errors.Is(err, domain.ErrInvalidTarget) {
+		t.Errorf("expected ErrInvalidTarget for a player outside the room, got %v", err)
+	}
+
+	// テスト5: 選んだ後に次の選択待ちが始まっても、前の選択待ちの時間切れでは送らない
+	_ = targetingUC.SetPreference(SetTargetingInput{RoomID: "room1", PlayerID: "player1", Mode: "choose"})
+	comboUp()
+	first := events.events[len(events.events)-1].(domain.TargetChoiceRequested)
+	if err := targetingUC.Choose(ChooseTargetInput{RoomID: "room1", PlayerID: "player1", TargetID: "player2"}); err != nil {
+		t.Fatalf("failed to choose target: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	comboUp()
+	second, ok := events.events[len(events.events)-1].(domain.TargetChoiceRequested)
+	if !ok || second.Deadline.Equal(first.Deadline) {
+		t.Fatalf("expected a new target choice, got %v", events.types())
+	}
+	if err := targetingUC.Choose(ChooseTargetInput{RoomID: "room1", PlayerID: "player1", Deadline: first.Deadline}); !errors.Is(err, domain.ErrNoPendingTarget) {
+		t.Errorf("expected stale timeout to be ignored, got %v", err)
+	}
+	if err := targetingUC.Choose(ChooseTargetInput{RoomID: "room1", PlayerID: "player1", TargetID: "player3"}); err != nil {
+		t.Errorf("expected the new choice to still be pending, got %v", err)
+	}
+	if fired, ok := events.events[len(events.events)-1].(domain.ComboObstructionFired); !ok || fired.TargetID != "player3" {
+		t.Errorf("expected obstruction to the chosen target, got %v", events.types())
+	}
 }
 
 // TestDomainModels ドメインモデルの基本動作テスト
@@ -413,7 +796,7 @@ func TestDomainModels(t *testing.T) {
 
 // TestProblemVerification 問題検証の詳細テスト
 func TestProblemVerification(t *testing.T) {
-	factory := domain.NewProblemFactory()
+	factory := domain.NewProblemFactory(domain.DefaultGameRules())
 	gen := NewProblemGeneratorUseCase(
 		factory,
 		domain.GetAllTargets(),
@@ -441,3 +824,269 @@ func TestProblemVerification(t *testing.T) {
 		}
 	}
 }
+
+// TestBotDetection はボット判定と隔離のテスト
+func TestBotDetection(t *testing.T) {
+	roomRepo := infrastructure.NewMemoryRoomRepository()
+	suspicionRepo := infrastructure.NewMemorySuspicionRepository()
+	roomGuard := NewRoomExecutionGuard()
+	policy := domain.DefaultSuspicionPolicy()
+	policy.FlagThreshold = 3
+	policy.QuarantineThreshold = 5.5
+	botUC := NewBotDetectionUseCase(suspicionRepo, roomRepo, roomGuard, policy, nil)
+	joinRoomUC := NewJoinRoomUseCase(roomRepo, infrastructure.NewMemoryClientRepository(), matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), 0), roomGuard, suspicionRepo, domain.DefaultGameRules(), domain.DefaultMatchmakingPolicy(), nil, nil)
+
+	room := domain.NewRoom("room1", "player1", "player2", 5, 2)
+	room.Start()
+	roomRepo.Save(room)
+
+	// テスト1: 人間らしい速さの正解は加点されない
+	record, _ := botUC.ObserveSolve("player1", "203.0.113.1", 2*time.Second)
+	if record.Score != 0 || record.Flagged {
+		t.Errorf("expected no suspicion for a normal solve, got %+v", record)
+	}
+
+	// テスト2: 不自然に速い正解でフラグ、さらに続くと隔離
+	record, _ = botUC.ObserveSolve("player1", "203.0.113.1", 150*time.Millisecond)
+	if !record.Flagged || record.Quarantined || len(record.Reasons) != 1 {
+		t.Errorf("expected player to be flagged with a reason, got %+v", record)
+	}
+	record, _ = botUC.ObserveSolve("player1", "203.0.113.1", 150*time.Millisecond)
+	if !record.Quarantined || !botUC.IsQuarantined("player1", "") {
+		t.Errorf("expected player to be quarantined, got %+v", record)
+	}
+
+	// テスト3: 隔離されたプレイヤーのルームは記録対象外になる
+	updated, _ := roomRepo.FindByID("room1")
+	if !updated.Unrated {
+		t.Errorf("expected room to be marked unrated")
+	}
+	flagged, _ := botUC.ListFlagged()
+	if len(flagged) != 1 || flagged[0].PlayerID != "player1" {
+		t.Errorf("expected player1 in flagged list, got %v", flagged)
+	}
+
+	// テスト4: 隔離中はランダムマッチに参加できない
+	if _, err := joinRoomUC.Execute(JoinRoomInput{ClientID: "client1", PlayerID: "player1", RoomID: "RANDOM", WinningScore: 5}); err != ErrQuarantined {
+		t.Errorf("expected ErrQuarantined, got %v", err)
+	}
+
+	// テスト5: 短い間隔の画像選択は加点される
+	botUC.ObserveSelection("player3", "")
+	record, _ = botUC.ObserveSelection("player3", "")
+	if record.Score == 0 {
+		t.Errorf("expected rapid selections to add suspicion")
+	}
+
+	// テスト6: プレイヤーIDを変えても同じ接続元IPからは隔離を逃れられない
+	if !botUC.IsQuarantined("renamed", "203.0.113.1") || botUC.IsQuarantined("renamed", "198.51.100.7") {
+		t.Errorf("expected quarantine to follow the remote address only")
+	}
+	if _, err := joinRoomUC.Execute(JoinRoomInput{ClientID: "client9", PlayerID: "renamed", RemoteIP: "203.0.113.1", RoomID: "RANDOM", WinningScore: 5}); err != ErrQuarantined {
+		t.Errorf("expected ErrQuarantined for a new id from the same address, got %v", err)
+	}
+
+	// テスト7: 解除後はランダムマッチに参加できる
+	if err := botUC.Clear("player1"); err != nil || botUC.IsQuarantined("player1", "203.0.113.1") {
+		t.Errorf("expected quarantine to be cleared, err=%v", err)
+	}
+	if _, err := joinRoomUC.Execute(JoinRoomInput{ClientID: "client1", PlayerID: "player1", RemoteIP: "203.0.113.1", RoomID: "RANDOM", WinningScore: 5}); err != nil {
+		t.Errorf("expected cleared player to join random match, got %v", err)
+	}
+
+	// テスト8: IP単位の隔離を止めたポリシーでは、隔離はプレイヤーIDにだけ適用する
+	policy.QuarantineAddress = false
+	playerOnlyUC := NewBotDetectionUseCase(infrastructure.NewMemorySuspicionRepository(), roomRepo, roomGuard, policy, nil)
+	playerOnlyUC.ObserveSolve("player8", "192.0.2.8", 150*time.Millisecond)
+	playerOnlyUC.ObserveSolve("player8", "192.0.2.8", 150*time.Millisecond)
+	if !playerOnlyUC.IsQuarantined("player8", "") || playerOnlyUC.IsQuarantined("neighbor", "192.0.2.8") {
+		t.Errorf("expected quarantine to apply to the player only")
+	}
+}
+
+// TestUseCaseLogging は判断ポイントのログにルーム・プレイヤーの文脈が付くことのテスト
+func TestUseCaseLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	roomRepo := infrastructure.NewMemoryRoomRepository()
+	clientRepo := infrastructure.NewMemoryClientRepository()
+	roomGuard := NewRoomExecutionGuard()
+	matchmakerClient := matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), 0)
+	joinRoomUC := NewJoinRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, infrastructure.NewMemorySuspicionRepository(), domain.DefaultGameRules(), domain.DefaultMatchmakingPolicy(), nil, logger)
+	leaveRoomUC := NewLeaveRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, nil, logger)
+
+	// テスト1: 参加のログに client_id / player_id / room_id が付く
+	if _, err := joinRoomUC.Execute(JoinRoomInput{ClientID: "client1", PlayerID: "player1", RoomID: "room1", WinningScore: 5}); err != nil {
+		t.Fatalf("failed to join room: %v", err)
+	}
+	for _, want := range []string{`"msg":"player joined room"`, `"client_id":"client1"`, `"player_id":"player1"`, `"room_id":"room1"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected join log to contain %s, got %s", want, buf.String())
+		}
+	}
+
+	// テスト2: 退出のログ
+	buf.Reset()
+	leaveRoomUC.Execute(LeaveRoomInput{ClientID: "client1", PlayerID: "player1"})
+	if !strings.Contains(buf.String(), `"room_id":"room1"`) || !strings.Contains(buf.String(), "room is empty") {
+		t.Errorf("expected leave log with room context, got %s", buf.String())
+	}
+}
+
+// recordingPublisher は発行されたイベントを順に記録する
+type recordingPublisher struct {
+	events []domain.Event
+}
+
+func (p *recordingPublisher) Publish(events ...domain.Event) {
+	p.events = append(p.events, events...)
+}
+
+func (p *recordingPublisher) types() []string {
+	types := make([]string, 0, len(p.events))
+	for _, e := range p.events {
+		types = append(types, e.EventType())
+	}
+	return types
+}
+
+// TestDomainEvents はユースケースが発行するドメインイベントのテスト
+func TestDomainEvents(t *testing.T) {
+	roomRepo := infrastructure.NewMemoryRoomRepository()
+	clientRepo := infrastructure.NewMemoryClientRepository()
+	roomGuard := NewRoomExecutionGuard()
+	matchmakerClient := matchmaker.NewService(infrastructure.NewTimeBasedIDGenerator(), 0)
+	problemGen := NewProblemGeneratorUseCase(domain.NewProblemFactory(domain.DefaultGameRules()), domain.GetAllTargets())
+	events := &recordingPublisher{}
+	startGameUC := NewStartGameUseCase(roomRepo, problemGen, roomGuard, events, nil)
+	verifyUC := NewVerifyAnswerUseCase(roomRepo, problemGen, nil, roomGuard, domain.DefaultVerifyPenaltyPolicy(), domain.DefaultGameRules(), domain.DefaultItemPolicy(), events, nil)
+	leaveRoomUC := NewLeaveRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, events, nil)
+	endGameUC := NewEndGameUseCase(roomRepo, matchmakerClient, roomGuard, events, nil)
+
+	// テスト1: 開始で GameStarted、正解で PlayerScored（回答したクライアントとリクエストIDを載せる）
+	roomRepo.Save(domain.NewRoom("room1", "player1", "player2", 2, 2))
+	if _, err := startGameUC.Execute(StartGameInput{RoomID: "room1"}); err != nil {
+		t.Fatalf("failed to start game: %v", err)
+	}
+	room, _ := roomRepo.FindByID("room1")
+	answer := domain.NewProblem(room.GameState1.Target, room.GameState1.Images).GetCorrectIndices()
+	if _, err := verifyUC.Execute(VerifyAnswerInput{RoomID: "room1", PlayerID: "player1", SelectedIndices: answer, ClientID: "client1", RequestID: "req1"}); err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if got := events.types(); len(got) != 2 || got[0] != domain.EventGameStarted || got[1] != domain.EventPlayerScored {
+		t.Fatalf("expected GameStarted and PlayerScored, got %v", got)
+	}
+	scored := events.events[1].(domain.PlayerScored)
+	if scored.PlayerID != "player1" || scored.Score != 1 || scored.ClientID != "client1" || scored.RequestID != "req1" || scored.GameOver {
+		t.Errorf("unexpected PlayerScored: %+v", scored)
+	}
+
+	// テスト2: 勝利スコアに到達すると GameFinished を発行し、ルームを削除する
+	events.events = nil
+	room, _ = roomRepo.FindByID("room1")
+	answer = domain.NewProblem(room.GameState1.Target, room.GameState1.Images).GetCorrectIndices()
+	verifyUC.Execute(VerifyAnswerInput{RoomID: "room1", PlayerID: "player1", SelectedIndices: answer})
+	if got := events.types(); len(got) != 2 || got[1] != domain.EventGameFinished {
+		t.Fatalf("expected PlayerScored and GameFinished, got %v", got)
+	}
+	finished := events.events[1].(domain.GameFinished)
+	if finished.WinnerID != "player1" || finished.Reason != domain.FinishReasonScore || finished.Scores["player1"] != 2 || len(finished.PlayerIDs) != 2 {
+		t.Errorf("unexpected GameFinished: %+v", finished)
+	}
+	if r, _ := roomRepo.FindByID("room1"); r != nil {
+		t.Errorf("expected finished room to be deleted")
+	}
+
+	// テスト3: 不正解で AnswerRejected
+	roomRepo.Save(domain.NewRoom("room2", "player3", "player4", 5, 2))
+	startGameUC.Execute(StartGameInput{RoomID: "room2"})
+	events.events = nil
+	verifyUC.Execute(VerifyAnswerInput{RoomID: "room2", PlayerID: "player3", SelectedIndices: []int{-1}})
+	if got := events.types(); len(got) != 1 || got[0] != domain.EventAnswerRejected {
+		t.Errorf("expected AnswerRejected, got %v", got)
+	}
+
+	// テスト4: 対戦中に残りが1人になると PlayerLeft と不戦勝の GameFinished
+	events.events = nil
+	leaveRoomUC.Execute(LeaveRoomInput{PlayerID: "player4"})
+	if got := events.types(); len(got) != 2 || got[0] != domain.EventPlayerLeft || got[1] != domain.EventGameFinished {
+		t.Fatalf("expected PlayerLeft and GameFinished, got %v", got)
+	}
+	if f := events.events[1].(domain.GameFinished); f.WinnerID != "player3" || f.Reason != domain.FinishReasonForfeit {
+		t.Errorf("unexpected forfeit: %+v", f)
+	}
+	if r, _ := roomRepo.FindByID("room2"); r != nil {
+		t.Errorf("expected forfeited room to be deleted")
+	}
+
+	// テスト5: ルームを新しく作った参加だけ RoomCreated、切断による退出は Disconnected 付きの PlayerLeft（最後の1人なら RoomDeleted）
+	joinRoomUC := NewJoinRoomUseCase(roomRepo, clientRepo, matchmakerClient, roomGuard, infrastructure.NewMemorySuspicionRepository(), domain.DefaultGameRules(), domain.DefaultMatchmakingPolicy(), events, nil)
+	events.events = nil
+	joinRoomUC.Execute(JoinRoomInput{ClientID: "client5", PlayerID: "player5", RoomID: "room3", WinningScore: 5, Capacity: 2})
+	if created, ok := events.events[0].(domain.RoomCreated); !ok || created.RoomID != "room3" || created.CreatorID != "player5" || created.Capacity != 2 || created.Public {
+		t.Errorf("expected RoomCreated, got %+v", events.events)
+	}
+	events.events = nil
+	leaveRoomUC.Execute(LeaveRoomInput{PlayerID: "player5", Disconnected: true})
+	if left, ok := events.events[0].(domain.PlayerLeft); !ok || !left.RoomDeleted || left.WasActive || !left.Disconnected {
+		t.Errorf("expected PlayerLeft with RoomDeleted, got %+v", events.events)
+	}
+
+	// テスト6: 管理者による終了は Note を載せた GameFinished、存在しないルーム・プレイヤーはエラー
+	roomRepo.Save(domain.NewRoom("room4", "player6", "player7", 5, 2))
+	events.events = nil
+	if err := endGameUC.Execute(EndGameInput{RoomID: "room4", WinnerID: "nobody"}); err != ErrPlayerNotInRoom {
+		t.Errorf("expected ErrPlayerNotInRoom, got %v", err)
+	}
+	if err := endGameUC.Execute(EndGameInput{RoomID: "room4", Note: "maintenance"}); err != nil {
+		t.Fatalf("failed to end game: %v", err)
+	}
+	if f, ok := events.events[0].(domain.GameFinished); !ok || f.Reason != domain.FinishReasonAdmin || f.Note != "maintenance" || f.WinnerID != "" {
+		t.Errorf("unexpected admin GameFinished: %+v", events.events)
+	}
+	if err := endGameUC.Execute(EndGameInput{RoomID: "room4"}); err != ErrRoomNotFound {
+		t.Errorf("expected ErrRoomNotFound, got %v", err)
+	}
+}
+
+// TestEventRecorders は対戦の記録とリプレイの購読者のテスト
+func TestEventRecorders(t *testing.T) {
+	history := NewMatchHistoryRecorder(infrastructure.NewMemoryMatchHistoryRepository(0), nil)
+	replays := NewReplayRecorder(1)
+	start := time.Now()
+	publish := func(events ...domain.Event) {
+		for _, e := range events {
+			history.HandleEvent(e)
+			replays.HandleEvent(e)
+		}
+	}
+
+	publish(
+		domain.GameStarted{EventMeta: domain.EventMeta{RoomID: "room1", OccurredAt: start}, PlayerIDs: []string{"player1", "player2"}},
+		domain.PlayerScored{EventMeta: domain.EventMeta{RoomID: "room1", OccurredAt: start.Add(time.Second)}, PlayerID: "player2", Score: 1},
+		domain.GameFinished{EventMeta: domain.EventMeta{RoomID: "room1", OccurredAt: start.Add(time.Minute)}, WinnerID: "player2", Reason: domain.FinishReasonScore, Scores: map[string]int{"player1": 0, "player2": 1}},
+	)
+
+	// テスト1: 開始した対戦の終了を順位付きで記録する
+	records, _ := history.ListRecent(10)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 match record, got %d", len(records))
+	}
+	if r := records[0]; r.WinnerID != "player2" || !r.StartedAt.Equal(start) || len(r.Standings) != 2 || r.Standings[0].PlayerID != "player2" {
+		t.Errorf("unexpected match record: %+v", r)
+	}
+
+	// テスト2: 開始していないルームの終了は記録しない
+	publish(domain.GameFinished{EventMeta: domain.EventMeta{RoomID: "room2", OccurredAt: start}, Reason: domain.FinishReasonAdmin})
+	if records, _ := history.ListRecent(10); len(records) != 1 {
+		t.Errorf("expected unstarted room not to be recorded, got %d", len(records))
+	}
+
+	// テスト3: リプレイはルームのイベントを発生順に返し、上限を超えたら古く終わったルームから捨てる
+	if replay := replays.Replay("room2"); len(replay) != 1 {
+		t.Errorf("expected replay of room2, got %d events", len(replay))
+	}
+	if replay := replays.Replay("room1"); replay != nil {
+		t.Errorf("expected oldest finished replay to be evicted, got %d events", len(replay))
+	}
+}
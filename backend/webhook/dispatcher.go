@@ -0,0 +1,223 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"recaptchgame-backend/domain"
+	"recaptchgame-backend/logging"
+)
+
+// 配送の既定値
+const (
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Minute
+	DefaultTimeout        = 5 * time.Second
+	DefaultQueueSize      = 256
+)
+
+// Options は配送の設定（0 の項目は既定値を使う）
+// MaxAttempts は最初の送信を含む回数で、再試行の間隔は InitialBackoff から倍々に MaxBackoff まで延ばす
+type Options struct {
+	HTTPClient     *http.Client
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration // HTTPClient を指定しない場合の1回の送信の期限
+	QueueSize      int           // 送信待ちのイベント数の上限（超えたら捨てる）
+}
+
+// delivery は1つの通知先への1件の配送
+type delivery struct {
+	sub     Subscription
+	id      string
+	event   string
+	body    []byte
+	attempt int // 送信済みの回数
+	nextAt  time.Time
+}
+
+// Dispatcher はドメインイベントを通知先へ配送するイベント購読者
+// HandleEvent はキューに積むだけで、送信と再試行は Run を動かすゴルーチンが1つずつ行う
+type Dispatcher struct {
+	subs   []Subscription
+	opts   Options
+	client *http.Client
+	logger *slog.Logger
+
+	queue chan *delivery
+	seq   atomic.Uint64
+}
+
+// NewDispatcher は新しいDispatcherを生成
+func NewDispatcher(subs []Subscription, opts Options, logger *slog.Logger) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultInitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: opts.Timeout}
+	}
+	return &Dispatcher{
+		subs:   append([]Subscription(nil), subs...),
+		opts:   opts,
+		client: client,
+		logger: logging.OrDiscard(logger),
+		queue:  make(chan *delivery, opts.QueueSize),
+	}
+}
+
+// HandleEvent は通知対象のイベントを購読している通知先ごとのキューに積む
+// キューが満杯なら待たずに捨てる（ゲームの進行を外部の遅延で止めない）
+func (d *Dispatcher) HandleEvent(event domain.Event) {
+	payload, ok := payloadFor(event)
+	if !ok {
+		return
+	}
+	payload.ID = fmt.Sprintf("evt_%d_%d", time.Now().UnixNano(), d.seq.Add(1))
+	body, err := json.Marshal(payload)
+	if err != nil {
+		d.logger.Error("failed to encode webhook payload", "event", payload.Event, "room_id", payload.RoomID, "error", err)
+		return
+	}
+	for _, sub := range d.subs {
+		if !sub.Wants(payload.Event) {
+			continue
+		}
+		select {
+		case d.queue <- &delivery{sub: sub, id: payload.ID, event: payload.Event, body: body}:
+		default:
+			d.logger.Warn("webhook queue is full; dropping event", "event", payload.Event, "room_id", payload.RoomID, "url", sub.URL)
+		}
+	}
+}
+
+// Run は ctx が終わるまで配送と再試行を行う
+// 終了時に残っている再試行は捨てる
+func (d *Dispatcher) Run(ctx context.Context) {
+	var retries []*delivery // nextAt の早い順
+	for {
+		var wait <-chan time.Time
+		var timer *time.Timer
+		if len(retries) > 0 {
+			timer = time.NewTimer(time.Until(retries[0].nextAt))
+			wait = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			if len(retries) > 0 {
+				d.logger.Warn("webhook dispatcher stopped with pending retries", "pending", len(retries))
+			}
+			return
+		case dl := <-d.queue:
+			retries = d.deliver(ctx, dl, retries)
+		case <-wait:
+			now := time.Now()
+			for len(retries) > 0 && !retries[0].nextAt.After(now) {
+				dl := retries[0]
+				retries = retries[1:]
+				retries = d.deliver(ctx, dl, retries)
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// deliver は1回送信し、再試行する場合は retries に加えて返す
+func (d *Dispatcher) deliver(ctx context.Context, dl *delivery, retries []*delivery) []*delivery {
+	dl.attempt++
+	retry, err := d.send(ctx, dl)
+	if err == nil {
+		d.logger.Debug("webhook delivered", "event", dl.event, "id", dl.id, "url", dl.sub.URL, "attempt", dl.attempt)
+		return retries
+	}
+	if !retry || dl.attempt >= d.opts.MaxAttempts || ctx.Err() != nil {
+		d.logger.Error("webhook delivery failed; giving up", "event", dl.event, "id", dl.id, "url", dl.sub.URL, "attempts", dl.attempt, "error", err)
+		return retries
+	}
+
+	delay := d.backoff(dl.attempt)
+	dl.nextAt = time.Now().Add(delay)
+	d.logger.Warn("webhook delivery failed; retrying", "event", dl.event, "id", dl.id, "url", dl.sub.URL, "attempt", dl.attempt, "retry_in", delay, "error", err)
+	i := sort.Search(len(retries), func(i int) bool { return retries[i].nextAt.After(dl.nextAt) })
+	retries = append(retries, nil)
+	copy(retries[i+1:], retries[i:])
+	retries[i] = dl
+	return retries
+}
+
+// send は署名を付けて POST する
+// 接続エラー・408・429・5xx は再試行し、それ以外の 4xx は通知先の拒否として再試行しない
+func (d *Dispatcher) send(ctx context.Context, dl *delivery) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.sub.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, dl.event)
+	req.Header.Set(HeaderID, dl.id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if dl.sub.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(dl.sub.Secret, timestamp, dl.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook endpoint returned %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook endpoint returned %d", resp.StatusCode)
+	}
+}
+
+// backoff は attempt 回目の失敗の後に待つ時間
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.opts.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.opts.MaxBackoff {
+			return d.opts.MaxBackoff
+		}
+	}
+	if delay > d.opts.MaxBackoff {
+		return d.opts.MaxBackoff
+	}
+	return delay
+}
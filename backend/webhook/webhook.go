@@ -0,0 +1,164 @@
+// Package webhook は対戦のライフサイクル（ルーム作成・開始・終了・切断）を外部のURLへ署名付きJSONで通知する
+// ドメインイベントの購読者として動き、配送の失敗は指数バックオフで再試行する
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"recaptchgame-backend/domain"
+)
+
+// 通知するイベント名
+const (
+	EventRoomCreated        = "room.created"
+	EventGameStarted        = "game.started"
+	EventGameFinished       = "game.finished"
+	EventPlayerDisconnected = "player.disconnected"
+)
+
+// 配送リクエストのヘッダー
+const (
+	HeaderSignature = "X-Webhook-Signature" // "sha256=" + HMAC-SHA256(secret, timestamp + "." + body) の16進
+	HeaderTimestamp = "X-Webhook-Timestamp" // 署名した時刻（Unix秒）
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID" // イベントごとのID（再試行でも変わらないので受信側の重複排除に使う）
+)
+
+const signaturePrefix = "sha256="
+
+// EventNames は通知できる全てのイベント名を返す
+func EventNames() []string {
+	return []string{EventRoomCreated, EventGameStarted, EventGameFinished, EventPlayerDisconnected}
+}
+
+// IsEventName は name が通知できるイベント名かを返す
+func IsEventName(name string) bool {
+	for _, n := range EventNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription は通知先
+// Events が空なら全てのイベントを通知する
+type Subscription struct {
+	URL    string
+	Secret string
+	Events []string
+}
+
+// Wants は通知先が name のイベントを受け取るかを返す
+func (s Subscription) Wants(name string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload は配送するJSONの本文
+type Payload struct {
+	ID         string      `json:"id"`
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	RoomID     string      `json:"room_id"`
+	Data       interface{} `json:"data"`
+}
+
+// RoomCreatedData は room.created の data
+type RoomCreatedData struct {
+	CreatorID    string `json:"creator_id"`
+	Capacity     int    `json:"capacity"`
+	WinningScore int    `json:"winning_score"`
+	Public       bool   `json:"public"`
+}
+
+// GameStartedData は game.started の data
+type GameStartedData struct {
+	PlayerIDs    []string `json:"player_ids"`
+	WinningScore int      `json:"winning_score"`
+	Unrated      bool     `json:"unrated"`
+}
+
+// Standing は game.finished の順位（スコアの高い順）
+type Standing struct {
+	PlayerID string `json:"player_id"`
+	Score    int    `json:"score"`
+}
+
+// GameFinishedData は game.finished の data
+type GameFinishedData struct {
+	WinnerID  string     `json:"winner_id"`
+	Reason    string     `json:"reason"` // score / forfeit / idle / admin
+	Standings []Standing `json:"standings"`
+	Unrated   bool       `json:"unrated"`
+	Note      string     `json:"note,omitempty"`
+}
+
+// PlayerDisconnectedData は player.disconnected の data
+type PlayerDisconnectedData struct {
+	PlayerID  string `json:"player_id"`
+	Remaining int    `json:"remaining"`
+	WasActive bool   `json:"was_active"`
+}
+
+// payloadFor はドメインイベントを通知の本文に変換する（通知しないイベントなら false）
+// ID は Dispatcher が付ける
+func payloadFor(event domain.Event) (Payload, bool) {
+	meta := event.Meta()
+	p := Payload{OccurredAt: meta.OccurredAt, RoomID: meta.RoomID}
+	switch e := event.(type) {
+	case domain.RoomCreated:
+		p.Event = EventRoomCreated
+		p.Data = RoomCreatedData{CreatorID: e.CreatorID, Capacity: e.Capacity, WinningScore: e.WinningScore, Public: e.Public}
+	case domain.GameStarted:
+		p.Event = EventGameStarted
+		p.Data = GameStartedData{PlayerIDs: e.PlayerIDs, WinningScore: e.WinningScore, Unrated: e.Unrated}
+	case domain.GameFinished:
+		record := domain.NewMatchRecord(e, time.Time{})
+		standings := make([]Standing, 0, len(record.Standings))
+		for _, s := range record.Standings {
+			standings = append(standings, Standing{PlayerID: s.PlayerID, Score: s.Score})
+		}
+		p.Event = EventGameFinished
+		p.Data = GameFinishedData{WinnerID: e.WinnerID, Reason: e.Reason, Standings: standings, Unrated: e.Unrated, Note: e.Note}
+	case domain.PlayerLeft:
+		// 明示的な退出は通知せず、切断したまま戻らなかった場合だけ通知する
+		if !e.Disconnected {
+			return Payload{}, false
+		}
+		p.Event = EventPlayerDisconnected
+		p.Data = PlayerDisconnectedData{PlayerID: e.PlayerID, Remaining: e.Remaining, WasActive: e.WasActive}
+	default:
+		return Payload{}, false
+	}
+	return p, true
+}
+
+// Sign は本文の署名（HeaderSignature の値）を返す
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify は受信側で署名を検証する
+// 再送攻撃を防ぐため、受信側は timestamp が十分新しいことも確認すること
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"recaptchgame-backend/domain"
+)
+
+// received は受信側で受け取った1回分のリクエスト
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// recordingServer は受け取ったリクエストを記録し、statuses の順に応答する（尽きたら 200）
+func recordingServer(t *testing.T, statuses ...int) (*httptest.Server, func() []received) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		n := len(got)
+		got = append(got, received{header: r.Header.Clone(), body: body})
+		mu.Unlock()
+		if n < len(statuses) {
+			w.WriteHeader(statuses[n])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received(nil), got...)
+	}
+}
+
+// waitFor は cond が満たされるまで待つ
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for webhook delivery")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func startDispatcher(t *testing.T, subs []Subscription, opts Options) *Dispatcher {
+	t.Helper()
+	d := NewDispatcher(subs, opts, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return d
+}
+
+// TestDispatcher は署名付きの配送・再試行・イベントの絞り込みのテスト
+func TestDispatcher(t *testing.T) {
+	fast := Options{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, MaxAttempts: 3}
+	finished := domain.GameFinished{
+		EventMeta: domain.EventMeta{RoomID: "room1", OccurredAt: time.Now()},
+		WinnerID:  "p2",
+		Reason:    domain.FinishReasonScore,
+		PlayerIDs: []string{"p1", "p2"},
+		Scores:    map[string]int{"p1": 2, "p2": 5},
+	}
+
+	// テスト1: 5xx は再試行し、成功した配送に順位と検証できる署名が付く
+	srv, got := recordingServer(t, http.StatusInternalServerError)
+	d := startDispatcher(t, []Subscription{{URL: srv.URL, Secret: "s3cret"}}, fast)
+	d.HandleEvent(finished)
+	waitFor(t, func() bool { return len(got()) == 2 })
+	reqs := got()
+	if reqs[0].header.Get(HeaderID) != reqs[1].header.Get(HeaderID) {
+		t.Error("expected retry to keep the delivery ID")
+	}
+	last := reqs[1]
+	if last.header.Get(HeaderEvent) != EventGameFinished {
+		t.Errorf("unexpected event header %q", last.header.Get(HeaderEvent))
+	}
+	if !Verify("s3cret", last.header.Get(HeaderTimestamp), last.body, last.header.Get(HeaderSignature)) {
+		t.Error("expected signature to verify")
+	}
+	if Verify("wrong", last.header.Get(HeaderTimestamp), last.body, last.header.Get(HeaderSignature)) {
+		t.Error("expected signature with wrong secret to fail")
+	}
+	var payload struct {
+		Event  string           `json:"event"`
+		RoomID string           `json:"room_id"`
+		Data   GameFinishedData `json:"data"`
+	}
+	if err := json.Unmarshal(last.body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload.RoomID != "room1" || payload.Data.WinnerID != "p2" || len(payload.Data.Standings) != 2 || payload.Data.Standings[0].PlayerID != "p2" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+
+	// テスト2: 4xx（408/429以外）は再試行しない、再試行は MaxAttempts で打ち切る
+	rejecting, rejected := recordingServer(t, http.StatusBadRequest)
+	failing, failed := recordingServer(t, 503, 503, 503, 503, 503)
+	d = startDispatcher(t, []Subscription{{URL: rejecting.URL}, {URL: failing.URL}}, fast)
+	d.HandleEvent(finished)
+	waitFor(t, func() bool { return len(failed()) == 3 })
+	time.Sleep(50 * time.Millisecond)
+	if n := len(rejected()); n != 1 {
+		t.Errorf("expected rejected delivery not to be retried, got %d requests", n)
+	}
+	if n := len(failed()); n != 3 {
+		t.Errorf("expected %d attempts, got %d", 3, n)
+	}
+
+	// テスト3: 購読していないイベントと、切断ではない退出は送らない
+	filtered, filteredGot := recordingServer(t)
+	d = startDispatcher(t, []Subscription{{URL: filtered.URL, Events: []string{EventRoomCreated, EventPlayerDisconnected}}}, fast)
+	d.HandleEvent(finished)
+	d.HandleEvent(domain.PlayerLeft{EventMeta: domain.EventMeta{RoomID: "room1"}, PlayerID: "p1"})
+	d.HandleEvent(domain.PlayerLeft{EventMeta: domain.EventMeta{RoomID: "room1"}, PlayerID: "p2", Disconnected: true})
+	d.HandleEvent(domain.RoomCreated{EventMeta: domain.EventMeta{RoomID: "room2"}, CreatorID: "p3", Capacity: 2})
+	waitFor(t, func() bool { return len(filteredGot()) == 2 })
+	time.Sleep(30 * time.Millisecond)
+	reqs = filteredGot()
+	if len(reqs) != 2 || reqs[0].header.Get(HeaderEvent) != EventPlayerDisconnected || reqs[1].header.Get(HeaderEvent) != EventRoomCreated {
+		t.Errorf("unexpected deliveries: %d", len(reqs))
+	}
+	if reqs[0].header.Get(HeaderSignature) != "" {
+		t.Error("expected no signature without secret")
+	}
+}
+
+// TestBackoff は再試行の間隔が倍々に延びて上限で止まることのテスト
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, Options{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, nil)
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := d.backoff(i + 1); got != w {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, w, got)
+		}
+	}
+}
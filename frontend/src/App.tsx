@@ -6,6 +6,7 @@ import useWebSocket from 'react-use-websocket';
 import { motion, AnimatePresence } from 'framer-motion';
 import { useGameStore } from './store';
 import { useSound } from './useSound';
+import { encodeMessage } from './utils/protocol';
 
 import { useObstructionEffect } from './hooks/useObstructionEffect';
 import { useCpuGame } from './hooks/useCpuGame';
@@ -79,6 +80,8 @@ function App() {
         startPopup, startMessage,
         isCreator, setIsCreator,
         handleVerifyOnline,
+        handleUseItemOnline,
+        handleSelectTargetOnline,
         stopMatching,
     } = useOnlineGame({ sendMessage, lastMessage, setGameMode, setMyScore, setWinningScore, playSuccess, playError, playWin, playLose, playStart, onObstructionFired: showBRAttack });
 
@@ -101,7 +104,7 @@ function App() {
         const handleVisibilityChange = () => {
             if (document.visibilityState !== 'visible') return;
             if (gameMode !== 'ONLINE') return;
-            sendMessage(JSON.stringify({ type: 'PONG', payload: {} }));
+            sendMessage(encodeMessage({ type: 'PONG', payload: {} }));
         };
 
         document.addEventListener('visibilitychange', handleVisibilityChange);
@@ -119,7 +122,7 @@ function App() {
         if (isReloading || isVerifying) return;
         useGameStore.getState().toggleMySelection(index);
         if (gameMode === 'ONLINE') {
-            sendMessage(JSON.stringify({
+            sendMessage(encodeMessage({
                 type: 'SELECT_IMAGE',
                 payload: { room_id: roomId, player_id: playerId, image_index: index },
             }));
@@ -188,7 +191,7 @@ function App() {
         setIsConnecting(true);
         setIsRandomMatch(true);
         setGameMode('ONLINE');
-        sendMessage(JSON.stringify({
+        sendMessage(encodeMessage({
             type: 'JOIN_ROOM',
             payload: { room_id: 'RANDOM', player_id: playerId, winning_score: 5, session_id: sessionID },
         }));
@@ -222,26 +225,38 @@ function App() {
         useGameStore.getState().setRoomInfo(room, playerId);
         // If creator, include chosen capacity in payload
         if (isCreator) {
-            sendMessage(JSON.stringify({
+            sendMessage(encodeMessage({
                 type: 'JOIN_ROOM',
                 payload: { room_id: room, player_id: playerId, winning_score: settingScore, session_id: sessionID, capacity: roomCapacity },
             }));
         } else {
-            sendMessage(JSON.stringify({
+            sendMessage(encodeMessage({
                 type: 'JOIN_ROOM',
                 payload: { room_id: room, player_id: playerId, winning_score: settingScore, session_id: sessionID },
             }));
         }
     };
 
+    // ページを開き直した直後は、保存済みのセッションで対戦中のルームに戻れるか一度だけ問い合わせる
+    // （サーバーが元のプレイヤーとして ROOM_ASSIGNED とスナップショットを返す。戻り先がなければ何も起きない）
+    const sessionResumeSentRef = useRef(false);
+
     // WebSocket再接続時に同一セッションで復帰を試みる
     useEffect(() => {
         if (suppressAutoJoinRef.current) return;
         if (readyState !== 1) return;
+        if (!sessionResumeSentRef.current && gameMode === null && !roomId) {
+            sessionResumeSentRef.current = true;
+            sendMessage(encodeMessage({
+                type: 'RESUME',
+                payload: { player_id: playerId, session_id: sessionID, last_seq: 0 },
+            }));
+            return;
+        }
         if (gameMode !== 'ONLINE') return;
         if (!roomId || !playerId) return;
 
-        sendMessage(JSON.stringify({
+        sendMessage(encodeMessage({
             type: 'JOIN_ROOM',
             payload: { room_id: roomId, player_id: playerId, winning_score: winningScore, session_id: sessionID },
         }));
@@ -259,7 +274,7 @@ function App() {
             setTimeout(() => { suppressAutoJoinRef.current = false; }, 2000);
 
             if (gameMode === 'ONLINE' || (roomId && roomId !== 'LOCAL_CPU')) {
-                sendMessage(JSON.stringify({
+                sendMessage(encodeMessage({
                     type: 'LEAVE_ROOM',
                     payload: { room_id: roomId, player_id: playerId },
                 }));
@@ -276,7 +291,7 @@ function App() {
         (window as any).__onImmediateRematch = () => {
             if (!roomId) return;
             // send JOIN_ROOM to attempt immediate rejoin in private match
-            sendMessage(JSON.stringify({
+            sendMessage(encodeMessage({
                 type: 'JOIN_ROOM',
                 payload: { room_id: roomId, player_id: playerId, winning_score: winningScore, session_id: sessionID },
             }));
@@ -313,7 +328,7 @@ function App() {
         setIsVerifying(false);
         setIsConnecting(false);
         if (gameMode === 'ONLINE' || (roomId && roomId !== 'LOCAL_CPU')) {
-            sendMessage(JSON.stringify({
+            sendMessage(encodeMessage({
                 type: 'LEAVE_ROOM',
                 payload: { room_id: roomId, player_id: playerId },
             }));
@@ -521,6 +536,8 @@ function App() {
                             handleImageClick={handleImageClick}
                             handleReload={handleReload}
                             handleVerify={handleVerify}
+                            handleUseItem={gameMode === 'ONLINE' ? handleUseItemOnline : undefined}
+                            handleSelectTarget={gameMode === 'ONLINE' ? handleSelectTargetOnline : undefined}
                         />
                     )}
 
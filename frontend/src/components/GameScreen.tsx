@@ -12,6 +12,14 @@ type GameScreenProps = {
     handleImageClick: (index: number) => void;
     handleReload: () => void;
     handleVerify: () => void;
+    handleUseItem?: (item: string) => void;
+    handleSelectTarget?: (targetId: string) => void;
+};
+
+const itemLabels: Record<string, string> = {
+    SHIELD: 'シールド',
+    CLEANSE: '解除',
+    REFLECT: '反射',
 };
 
 const obstructionVariants: Variants = {
@@ -66,11 +74,11 @@ const renderCaptchaImage = (img: string, className: string) => {
 export const GameScreen = ({
     myScore, winningScore, gameMode,
     isReloading, isVerifying,
-    handleImageClick, handleReload, handleVerify
+    handleImageClick, handleReload, handleVerify, handleUseItem, handleSelectTarget
 }: GameScreenProps) => {
     const {
         target, images, playerCombo, opponentCombo, playerEffect, opponentEffect,
-        mySelections, opponentSelections, opponentScore, cpuImages, cpuDifficulty, brOpponents
+        mySelections, opponentSelections, opponentScore, cpuImages, cpuDifficulty, brOpponents, myItems, targetChoice
     } = useGameStore();
 
     const isOneOnOne = !brOpponents || brOpponents.length === 0;
@@ -159,6 +167,35 @@ export const GameScreen = ({
                             <div className="w-6 sm:w-10"></div>
                         </div>
                     </motion.div>
+
+                    {handleUseItem && myItems.length > 0 && (
+                        <div className="flex gap-1 sm:gap-2 mt-1 sm:mt-2">
+                            {myItems.map((item, idx) => (
+                                <button
+                                    key={`${item}-${idx}`}
+                                    onClick={() => handleUseItem(item)}
+                                    className="bg-white border border-[#5B46F5] text-[#5B46F5] hover:bg-[#5B46F5] hover:text-white font-bold py-0.5 px-2 sm:py-1 sm:px-3 rounded text-[10px] sm:text-xs transition"
+                                >
+                                    {itemLabels[item] ?? item}
+                                </button>
+                            ))}
+                        </div>
+                    )}
+
+                    {handleSelectTarget && targetChoice && (
+                        <div className="flex flex-wrap items-center gap-1 sm:gap-2 mt-1 sm:mt-2">
+                            <span className="text-[10px] sm:text-xs font-bold text-gray-700">妨害を送る相手:</span>
+                            {targetChoice.candidates.map(c => (
+                                <button
+                                    key={c.id}
+                                    onClick={() => handleSelectTarget(c.id)}
+                                    className="bg-white border border-[#EA4335] text-[#EA4335] hover:bg-[#EA4335] hover:text-white font-bold py-0.5 px-2 sm:py-1 sm:px-3 rounded text-[10px] sm:text-xs transition"
+                                >
+                                    {c.id} ({c.score})
+                                </button>
+                            ))}
+                        </div>
+                    )}
                 </div>
 
                 <div className={`flex flex-col justify-center items-center shrink-0 ${opponentSizeClass} mt-3 md:mt-0`}>
@@ -11,6 +11,8 @@ export const ResultScreen = ({ gameMode }: ResultScreenProps) => {
     const { winner, playerId, disconnected } = useGameStore();
 
     const isWin = winner === playerId || (winner === 'human' && gameMode === 'CPU');
+    // 放置によるサーバー側の終了で同点だった場合は勝者なし
+    const isDraw = gameMode === 'ONLINE' && winner === '';
 
     return (
         <div className="flex flex-col items-center justify-center h-full text-center space-y-6 sm:space-y-10 py-4">
@@ -26,6 +28,16 @@ export const ResultScreen = ({ gameMode }: ResultScreenProps) => {
                         <p className="text-xs sm:text-base text-gray-400 mt-1">相手が退出したため、あなたの勝利です。</p>
                     </div>
                 </motion.div>
+            ) : isDraw ? (
+                <motion.div initial={{ scale: 0 }} animate={{ scale: 1 }} className="text-gray-600 space-y-3 sm:space-y-6">
+                    <div className="bg-gray-100 w-24 h-24 sm:w-32 sm:h-32 rounded-full flex items-center justify-center mx-auto shadow-lg">
+                        <span className="text-5xl sm:text-6xl">🤝</span>
+                    </div>
+                    <div>
+                        <h2 className="text-2xl sm:text-4xl md:text-5xl font-bold text-gray-800">DRAW</h2>
+                        <p className="text-base sm:text-xl text-gray-500 mt-1 sm:mt-3">引き分けになりました。</p>
+                    </div>
+                </motion.div>
             ) : isWin ? (
                 <motion.div initial={{ scale: 0 }} animate={{ scale: 1 }} className="text-green-600 space-y-3 sm:space-y-6">
                     <div className="bg-green-100 w-24 h-24 sm:w-32 sm:h-32 rounded-full flex items-center justify-center mx-auto shadow-lg">
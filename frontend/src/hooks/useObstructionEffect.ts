@@ -7,12 +7,15 @@ interface UseObstructionEffectOptions {
 
 /**
  * 妨害エフェクトのライフサイクルを一元管理するフック。
- * - playerEffect / opponentEffect の3秒後自動クリア
+ * - playerEffect / opponentEffect をサーバーが指定した持続時間（既定3秒）の後に自動クリア
  * - BRモードで自分が妨害発動したとき全対戦相手に effect をセット＋個別タイマーでクリア
  * - 妨害発動バナー用ステート管理
  */
 export function useObstructionEffect({ playObstruction }: UseObstructionEffectOptions) {
-    const { playerEffect, opponentEffect, playerEffectToken, opponentEffectToken, setPlayerEffect, setOpponentEffect } = useGameStore();
+    const {
+        playerEffect, opponentEffect, playerEffectToken, opponentEffectToken,
+        playerEffectDurationMs, opponentEffectDurationMs, setPlayerEffect, setOpponentEffect,
+    } = useGameStore();
 
     // 各BROpponentのeffectクリア用タイマー (id → timer)
     const brEffectTimersRef = useRef<Record<string, ReturnType<typeof setTimeout>>>({});
@@ -21,7 +24,7 @@ export function useObstructionEffect({ playObstruction }: UseObstructionEffectOp
     const [brAttackEffect, setBRAttackEffect] = useState<ObstructionType>(null);
     const brAttackBannerTimerRef = useRef<ReturnType<typeof setTimeout> | null>(null);
 
-    // playerEffect → サウンド再生 + 3秒後クリア
+    // playerEffect → サウンド再生 + 持続時間の後にクリア
     useEffect(() => {
         if (!playerEffect) return;
         playObstruction();
@@ -30,22 +33,22 @@ export function useObstructionEffect({ playObstruction }: UseObstructionEffectOp
             if (useGameStore.getState().playerEffectToken === token) {
                 setPlayerEffect(null);
             }
-        }, 3000);
-        // Safety fallback: if something prevents the 3s clear, ensure effect cleared within 4s
+        }, playerEffectDurationMs);
+        // Safety fallback: if something prevents the clear, ensure effect cleared within 1s after it should end
         const fallback = setTimeout(() => {
             const state = useGameStore.getState();
             if (state.playerEffect && state.playerEffectToken === token) {
-                console.warn('playerEffect fallback cleared after 4s');
+                console.warn(`playerEffect fallback cleared after ${playerEffectDurationMs + 1000}ms`);
                 setPlayerEffect(null);
             }
-        }, 4000);
+        }, playerEffectDurationMs + 1000);
         return () => {
             clearTimeout(t);
             clearTimeout(fallback);
         };
-    }, [playerEffect, playerEffectToken, setPlayerEffect, playObstruction]);
+    }, [playerEffect, playerEffectToken, playerEffectDurationMs, setPlayerEffect, playObstruction]);
 
-    // opponentEffect → 3秒後クリア
+    // opponentEffect → 持続時間の後にクリア
     useEffect(() => {
         if (!opponentEffect) return;
         const token = opponentEffectToken;
@@ -53,19 +56,19 @@ export function useObstructionEffect({ playObstruction }: UseObstructionEffectOp
             if (useGameStore.getState().opponentEffectToken === token) {
                 setOpponentEffect(null);
             }
-        }, 3000);
+        }, opponentEffectDurationMs);
         const fallback = setTimeout(() => {
             const state = useGameStore.getState();
             if (state.opponentEffect && state.opponentEffectToken === token) {
-                console.warn('opponentEffect fallback cleared after 4s');
+                console.warn(`opponentEffect fallback cleared after ${opponentEffectDurationMs + 1000}ms`);
                 setOpponentEffect(null);
             }
-        }, 4000);
+        }, opponentEffectDurationMs + 1000);
         return () => {
             clearTimeout(t);
             clearTimeout(fallback);
         };
-    }, [opponentEffect, opponentEffectToken, setOpponentEffect]);
+    }, [opponentEffect, opponentEffectToken, opponentEffectDurationMs, setOpponentEffect]);
 
     // cleanup on unmount: clear any player/br timers
     useEffect(() => {
@@ -1,8 +1,21 @@
 import { useEffect, useRef, useState } from 'react';
-import { useGameStore, ObstructionType } from '../store';
+import { useGameStore, ObstructionType, DEFAULT_EFFECT_DURATION_MS } from '../store';
+import type { ObstructionPayload } from '../types/protocol';
 import { sleep } from '../utils/game';
+import { encodeMessage, parseServerMessage } from '../utils/protocol';
 import { useGameController } from './useGameController';
 
+/**
+ * サーバーがエフェクトを解除するまでの残り時間（ミリ秒）。
+ * 重ねがけで延びた場合は expires_at が duration_ms より後になるので、時計のずれが小さい範囲でだけ expires_at を使う。
+ */
+function effectRemainingMs(payload: ObstructionPayload): number {
+    const duration = payload.duration_ms > 0 ? payload.duration_ms : DEFAULT_EFFECT_DURATION_MS;
+    if (!payload.expires_at) return duration;
+    const remaining = payload.expires_at - Date.now();
+    return remaining > 0 && remaining <= duration * 2 ? remaining : duration;
+}
+
 interface UseOnlineGameOptions {
     sendMessage: (msg: string) => void;
     lastMessage: MessageEvent<any> | null;
@@ -63,16 +76,17 @@ export function useOnlineGame({
         prevMessageRef.current = lastMessage;
 
         try {
-            const msg = JSON.parse(lastMessage.data);
+            const msg = parseServerMessage(lastMessage.data);
             const store = useGameStore.getState();
 
             switch (msg.type) {
                 case 'PING':
-                    sendMessage(JSON.stringify({ type: 'PONG', payload: {} }));
+                    sendMessage(encodeMessage({ type: 'PONG', payload: {} }));
                     break;
 
                 case 'ROOM_ASSIGNED':
-                    store.setRoomInfo(msg.payload.room_id, store.playerId);
+                    // セッションから元のプレイヤーに戻された場合はサーバーが返したIDを使う
+                    store.setRoomInfo(msg.payload.room_id, msg.payload.player_id || store.playerId);
                     setGameMode('ONLINE');
                     if (store.gameState !== 'PLAYING' && store.gameState !== 'RESULT') {
                         store.setGameState('WAITING');
@@ -101,8 +115,45 @@ export function useOnlineGame({
                     alert('部屋に入れませんでした');
                     break;
 
+                case 'KICKED':
+                    // 運営によって退出させられた場合はロビーに戻す
+                    setIsVerifying(false);
+                    isMatchingRef.current = false;
+                    setStartPopup(false);
+                    setGameMode(null);
+                    store.setRoomInfo('', store.playerId);
+                    store.setGameState('LOGIN');
+                    alert(msg.payload.reason);
+                    break;
+
+                case 'SERVER_NOTICE':
+                    if (msg.payload.level === 'warning') {
+                        console.warn('Server notice:', msg.payload.message);
+                    } else {
+                        console.info('Server notice:', msg.payload.message);
+                    }
+                    alert(msg.payload.message);
+                    break;
+
+                case 'SERVER_DRAINING':
+                    // 対戦中はそのまま続行し、マッチング待ちの場合のみロビーに戻す
+                    console.info('Server draining:', msg.payload.message);
+                    if (store.gameState === 'WAITING') {
+                        sendMessage(encodeMessage({
+                            type: 'LEAVE_ROOM',
+                            payload: { room_id: store.roomId, player_id: store.playerId },
+                        }));
+                        isMatchingRef.current = false;
+                        setStartPopup(false);
+                        setGameMode(null);
+                        store.setRoomInfo('', store.playerId);
+                        store.setGameState('LOGIN');
+                        alert(msg.payload.message);
+                    }
+                    break;
+
                 case 'GAME_START':
-                    const startPayload = msg.payload as any;
+                    const startPayload = msg.payload;
                     const existingBROpponents = store.brOpponents;
                     // If we're already actively matching/playing, avoid double-handling.
                     // However, ignore a stale `isMatchingRef` if we're not in PLAYING state —
@@ -124,13 +175,13 @@ export function useOnlineGame({
                         }, 3000);
                     }
                     if (Array.isArray(startPayload.br_opponents)) {
-                        store.setBROpponents(startPayload.br_opponents.map((opp: any) => {
+                        store.setBROpponents(startPayload.br_opponents.map(opp => {
                             const existingOpp = existingBROpponents.find(existing => existing.id === opp.player_id);
                             return {
                                 id: opp.player_id,
                                 score: opp.score ?? 0,
                                 combo: opp.combo ?? 0,
-                                effect: opp.effect ?? existingOpp?.effect ?? null,
+                                effect: (opp.effect as ObstructionType | undefined) ?? existingOpp?.effect ?? null,
                                 selections: existingOpp ? existingOpp.selections : [],
                                 images: Array.isArray(opp.images) ? opp.images : [],
                                 target: opp.target ?? '',
@@ -141,6 +192,7 @@ export function useOnlineGame({
                         setWinningScore(startPayload.winning_score);
                     }
                     setMyScore(() => startPayload.my_current_score ?? 0);
+                    store.setMyItems(startPayload.my_items ?? []);
                     setIsVerifying(false);
                     store.setPlayerCombo(startPayload.my_current_combo ?? 0);
                     store.setOpponentCombo(0);
@@ -190,8 +242,9 @@ export function useOnlineGame({
                         store.setPlayerCombo(useGameStore.getState().playerCombo + 1);
                     }
 
-                    if (msg.payload.current_score !== undefined) {
-                        setMyScore(() => msg.payload.current_score);
+                    const currentScore = msg.payload.current_score;
+                    if (currentScore !== undefined) {
+                        setMyScore(() => currentScore);
                     } else {
                         setMyScore(prev => prev + 1);
                     }
@@ -206,13 +259,13 @@ export function useOnlineGame({
                         store.setOpponentCombo(msg.payload.combo);
                     }
                     if (Array.isArray(msg.payload.br_opponents)) {
-                        store.setBROpponents(msg.payload.br_opponents.map((opp: any) => {
+                        store.setBROpponents(msg.payload.br_opponents.map(opp => {
                             const existingOpp = store.brOpponents.find(existing => existing.id === opp.player_id);
                             return {
                                 id: opp.player_id,
                                 score: opp.score ?? 0,
                                 combo: opp.combo ?? 0,
-                                effect: opp.effect ?? existingOpp?.effect ?? null,
+                                effect: (opp.effect as ObstructionType | undefined) ?? existingOpp?.effect ?? null,
                                 selections: existingOpp ? existingOpp.selections : [],
                                 images: Array.isArray(opp.images) ? opp.images : [],
                                 target: opp.target ?? '',
@@ -224,6 +277,7 @@ export function useOnlineGame({
 
                 case 'OBSTRUCTION':
                     const effect = msg.payload.effect as ObstructionType;
+                    const effectMs = effectRemainingMs(msg.payload);
                     const targetId = msg.payload.target_id as string | undefined;
                     const attackerId = msg.payload.attacker_id as string | undefined;
 
@@ -233,9 +287,13 @@ export function useOnlineGame({
                     if (attackerId && attackerId === store.playerId) {
                         break;
                     }
+                    // 対象のシールドが防いだ妨害はエフェクトをかけない
+                    if (msg.payload.blocked) {
+                        break;
+                    }
 
                     if (targetId && targetId === store.playerId) {
-                        store.setPlayerEffect(effect);
+                        store.setPlayerEffect(effect, effectMs);
                     } else if (targetId && store.brOpponents.some(opp => opp.id === targetId)) {
                         const timers = brObstructionTimersRef.current;
                         if (timers[targetId]) {
@@ -246,9 +304,9 @@ export function useOnlineGame({
                         timers[targetId] = setTimeout(() => {
                             useGameStore.getState().setBROpponentEffect(targetId, null);
                             delete timers[targetId];
-                        }, 3000);
+                        }, effectMs);
                     } else {
-                        store.setOpponentEffect(effect);
+                        store.setOpponentEffect(effect, effectMs);
                     }
                     // effect の解除は useObstructionEffect に一元管理
                     break;
@@ -258,6 +316,7 @@ export function useOnlineGame({
                         const eff = msg.payload.effect as string;
                         const aid = msg.payload.attacker_id as string;
                         if (onObstructionFired) onObstructionFired(eff as ObstructionType, aid);
+                        store.setTargetChoice(null);
                         // OBSTRUCTION_FIRED は攻撃者への「発動通知」のみ。
                         // 攻撃者自身には GRAYSCALE 等のエフェクトを適用しない。
                         // バナー表示は onObstructionFired (showBRAttack) で行われる。
@@ -265,6 +324,41 @@ export function useOnlineGame({
                         console.warn('Invalid OBSTRUCTION_FIRED payload', e);
                     }
                     break;
+                case 'ITEM_AWARDED':
+                    store.setMyItems(msg.payload.items);
+                    break;
+
+                case 'ITEM_USED':
+                    if (msg.payload.player_id === store.playerId) {
+                        store.setMyItems(msg.payload.items);
+                    }
+                    // 解除・反射したプレイヤーの妨害は外れる（反射先には OBSTRUCTION が届く）
+                    if (msg.payload.outcome === 'cleansed' || msg.payload.outcome === 'reflected') {
+                        const userId = msg.payload.player_id;
+                        if (userId === store.playerId) {
+                            store.setPlayerEffect(null);
+                        } else if (store.brOpponents.some(opp => opp.id === userId)) {
+                            store.setBROpponentEffect(userId, null);
+                        } else {
+                            store.setOpponentEffect(null);
+                        }
+                    }
+                    break;
+
+                case 'CHOOSE_TARGET':
+                    // 選ばないまま deadline を過ぎるとサーバーが無作為に送る
+                    store.setTargetChoice({
+                        candidates: (msg.payload.candidates || []).map(c => ({ id: c.player_id, score: c.score })),
+                        deadline: msg.payload.deadline,
+                    });
+                    controller.scheduleNamed('targetChoice', () => useGameStore.getState().setTargetChoice(null), msg.payload.timeout_ms);
+                    break;
+
+                case 'ITEM_REJECTED':
+                    store.setMyItems(msg.payload.items);
+                    playError();
+                    break;
+
                 case 'OPPONENT_SELECT':
                     if (msg.payload.player_id !== store.playerId) {
                         if (store.brOpponents.some(opp => opp.id === msg.payload.player_id)) {
@@ -284,7 +378,7 @@ export function useOnlineGame({
                     setStartPopup(false);           // カウントダウン演出中でも即座に閉じる
                     if (msg.payload.winner_id === store.playerId) {
                         playWin();
-                    } else {
+                    } else if (msg.payload.winner_id) {
                         playLose();
                     }
                     store.endGame(msg.payload.winner_id, msg.payload.message === 'Opponent Disconnected');
@@ -292,6 +386,14 @@ export function useOnlineGame({
 
                 case 'VERIFY_FAILED':
                     setIsVerifying(false);
+                    // 減点や回答回数上限による問題差し替えをサーバーの値に合わせる
+                    if (msg.payload) {
+                        const failedScore = msg.payload.current_score;
+                        setMyScore(() => failedScore);
+                        if (msg.payload.problem_replaced && msg.payload.target && msg.payload.images) {
+                            store.updatePlayerPattern(msg.payload.target, msg.payload.images);
+                        }
+                    }
                     // stale closure 回避: getState() で最新 feedback を取得
                     if (useGameStore.getState().feedback !== 'WRONG') {
                         const feedbackKey = `verifyError:${store.playerId}`;
@@ -319,7 +421,7 @@ export function useOnlineGame({
             return;
         }
         setIsVerifying(true);
-        sendMessage(JSON.stringify({
+        sendMessage(encodeMessage({
             type: 'VERIFY',
             payload: { room_id: store.roomId, player_id: store.playerId, target: store.target, selected_indices: store.mySelections },
         }));
@@ -327,6 +429,36 @@ export function useOnlineGame({
         controller.scheduleVerifyFallback(store.playerId, () => setIsVerifying(prev => prev ? false : prev), 1500);
     };
 
+    // ── アイテム使用（オンライン用）──────────────────────────
+    const handleUseItemOnline = (item: string) => {
+        const store = useGameStore.getState();
+        if (!store.myItems.includes(item)) return;
+        sendMessage(encodeMessage({
+            type: 'USE_ITEM',
+            payload: { room_id: store.roomId, item },
+        }));
+    };
+
+    // ── 妨害を送る相手の選び方・選択（オンライン用）─────────
+    const handleSetTargetingOnline = (mode: string, targetId?: string) => {
+        const store = useGameStore.getState();
+        sendMessage(encodeMessage({
+            type: 'SET_TARGETING',
+            payload: { room_id: store.roomId, mode, target_id: targetId },
+        }));
+    };
+
+    const handleSelectTargetOnline = (targetId: string) => {
+        const store = useGameStore.getState();
+        if (!store.targetChoice) return;
+        controller.clearNamed('targetChoice');
+        store.setTargetChoice(null);
+        sendMessage(encodeMessage({
+            type: 'SELECT_TARGET',
+            payload: { room_id: store.roomId, target_id: targetId },
+        }));
+    };
+
     const stopMatching = () => {
         isMatchingRef.current = false;
         setStartPopup(false);
@@ -340,6 +472,9 @@ export function useOnlineGame({
         isCreator,
         setIsCreator,
         handleVerifyOnline,
+        handleUseItemOnline,
+        handleSetTargetingOnline,
+        handleSelectTargetOnline,
         stopMatching,
     };
 }
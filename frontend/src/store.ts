@@ -5,6 +5,9 @@ export type FeedbackType = 'CORRECT' | 'WRONG' | null;
 // 妨害要素に GRAYSCALE, SEPIA, SKEW を追加
 export type ObstructionType = 'SHAKE' | 'SPIN' | 'BLUR' | 'INVERT' | 'ONION_RAIN' | 'GRAYSCALE' | 'SEPIA' | 'SKEW' | null;
 
+// サーバーが持続時間を送ってこない場合の妨害エフェクトの持続時間
+export const DEFAULT_EFFECT_DURATION_MS = 3000;
+
 // バトロワ対戦相手の型
 export type BROpponent = {
     id: string;
@@ -16,6 +19,12 @@ export type BROpponent = {
     target: string;  // 現在の出題ターゲット（getCorrectIndices に渡す）
 };
 
+// 妨害を送る相手の選択待ち（CHOOSE_TARGET。deadline は UNIXミリ秒）
+export type TargetChoice = {
+    candidates: { id: string; score: number }[];
+    deadline: number;
+};
+
 interface Store {
     gameState: GameState;
     roomId: string;
@@ -40,6 +49,13 @@ interface Store {
     opponentEffect: ObstructionType;
     playerEffectToken: number;
     opponentEffectToken: number;
+    // 妨害エフェクトを解除するまでの時間（ミリ秒）
+    playerEffectDurationMs: number;
+    opponentEffectDurationMs: number;
+    // 妨害を防ぐアイテムの持ち物（入手順。オンライン対戦のみ）
+    myItems: string[];
+    // 妨害を送る相手の選択待ち（なければ null）
+    targetChoice: TargetChoice | null;
 
     // バトロワ専用ステート
     brOpponents: BROpponent[];
@@ -68,8 +84,10 @@ interface Store {
 
     setPlayerCombo: (count: number) => void;
     setOpponentCombo: (count: number) => void;
-    setPlayerEffect: (effect: ObstructionType) => void;
-    setOpponentEffect: (effect: ObstructionType) => void;
+    setPlayerEffect: (effect: ObstructionType, durationMs?: number) => void;
+    setOpponentEffect: (effect: ObstructionType, durationMs?: number) => void;
+    setMyItems: (items: string[]) => void;
+    setTargetChoice: (choice: TargetChoice | null) => void;
 }
 
 export const useGameStore = create<Store>((set) => ({
@@ -95,6 +113,10 @@ export const useGameStore = create<Store>((set) => ({
     opponentEffect: null,
     playerEffectToken: 0,
     opponentEffectToken: 0,
+    playerEffectDurationMs: DEFAULT_EFFECT_DURATION_MS,
+    opponentEffectDurationMs: DEFAULT_EFFECT_DURATION_MS,
+    myItems: [],
+    targetChoice: null,
 
     brOpponents: [],
     setBROpponents: (opponents) => set({ brOpponents: opponents }),
@@ -129,6 +151,8 @@ export const useGameStore = create<Store>((set) => ({
         opponentEffect: null,
         playerEffectToken: 0,
         opponentEffectToken: 0,
+        myItems: [],
+        targetChoice: null,
         brOpponents: [],
     }),
     updatePattern: (target, images) => set({
@@ -178,18 +202,24 @@ export const useGameStore = create<Store>((set) => ({
         playerEffectToken: 0,
         opponentEffectToken: 0,
         feedback: null,
+        myItems: [],
+        targetChoice: null,
         brOpponents: [],
     }),
     setFeedback: (feedback) => set({ feedback }),
 
     setPlayerCombo: (count) => set({ playerCombo: count }),
     setOpponentCombo: (count) => set({ opponentCombo: count }),
-    setPlayerEffect: (effect) => set((state) => ({
+    setPlayerEffect: (effect, durationMs = DEFAULT_EFFECT_DURATION_MS) => set((state) => ({
         playerEffect: effect,
         playerEffectToken: effect ? state.playerEffectToken + 1 : state.playerEffectToken,
+        playerEffectDurationMs: effect ? durationMs : state.playerEffectDurationMs,
     })),
-    setOpponentEffect: (effect) => set((state) => ({
+    setOpponentEffect: (effect, durationMs = DEFAULT_EFFECT_DURATION_MS) => set((state) => ({
         opponentEffect: effect,
         opponentEffectToken: effect ? state.opponentEffectToken + 1 : state.opponentEffectToken,
+        opponentEffectDurationMs: effect ? durationMs : state.opponentEffectDurationMs,
     })),
+    setMyItems: (items) => set({ myItems: items || [] }),
+    setTargetChoice: (choice) => set({ targetChoice: choice }),
 }));
\ No newline at end of file
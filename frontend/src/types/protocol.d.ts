@@ -0,0 +1,283 @@
+// Code generated by go generate in backend/protocol; DO NOT EDIT.
+// メッセージ契約を変更した場合は backend で `go generate ./protocol` を実行すること
+
+export interface HelloPayload {
+    version: number;
+    capabilities?: string[];
+}
+
+export interface JoinRoomPayload {
+    room_id: string;
+    player_id: string;
+    winning_score?: number;
+    session_id?: string;
+    capacity?: number;
+}
+
+export interface ResumePayload {
+    room_id?: string;
+    player_id: string;
+    session_id?: string;
+    last_seq?: number;
+}
+
+export interface LeaveRoomPayload {
+    player_id: string;
+}
+
+export interface SelectImagePayload {
+    room_id?: string;
+    player_id: string;
+    image_index: number;
+}
+
+export interface VerifyPayload {
+    room_id: string;
+    player_id: string;
+    target?: string;
+    selected_indices: number[];
+}
+
+export type EmptyPayload = Record<string, never>;
+
+export interface UseItemPayload {
+    room_id?: string;
+    item: string;
+}
+
+export interface SetTargetingPayload {
+    room_id?: string;
+    mode: string;
+    target_id?: string;
+}
+
+export interface SelectTargetPayload {
+    room_id?: string;
+    target_id?: string;
+}
+
+export interface HelloAckPayload {
+    version: number;
+    min_version: number;
+    max_version: number;
+    capabilities: string[];
+}
+
+export interface UpgradeRequiredPayload {
+    min_version: number;
+    current_version: number;
+    message: string;
+}
+
+export interface AckPayload {
+    type: string;
+    duplicate?: boolean;
+}
+
+export interface ErrorPayload {
+    code: string;
+    message: string;
+    type?: string;
+    retry_after_ms?: number;
+}
+
+export interface RoomAssignedPayload {
+    room_id: string;
+    player_id: string;
+}
+
+export interface StatusUpdatePayload {
+    status?: string;
+    message?: string;
+    player_id?: string;
+    remaining_players?: number;
+}
+
+export interface JoinFailedPayload {
+    message: string;
+}
+
+export interface GameStartPayload {
+    target: string;
+    images: string[];
+    opponent_images: string[];
+    winning_score: number;
+    my_current_score?: number;
+    my_current_combo?: number;
+    opponent_current_score?: number;
+    player_effect?: string;
+    my_items?: string[];
+    br_opponents?: BROpponentPayload[];
+}
+
+export interface BROpponentPayload {
+    player_id: string;
+    target: string;
+    images: string[];
+    score: number;
+    combo: number;
+    effect?: string;
+    selections: number[];
+}
+
+export interface UpdatePatternPayload {
+    target: string;
+    images: string[];
+    current_score?: number;
+    current_combo?: number;
+}
+
+export interface VerifyFailedPayload {
+    locked_out?: boolean;
+    lockout_remaining_ms: number;
+    current_score: number;
+    score_deducted?: number;
+    problem_replaced?: boolean;
+    target?: string;
+    images?: string[];
+}
+
+export interface OpponentUpdatePayload {
+    images: string[];
+    score: number;
+    combo: number;
+    br_opponents?: BROpponentPayload[];
+}
+
+export interface OpponentDeltaPayload {
+    keyframe?: boolean;
+    opponents: BROpponentDelta[];
+    removed?: string[];
+}
+
+export interface BROpponentDelta {
+    player_id: string;
+    target?: string;
+    images?: string[];
+    score?: number;
+    combo?: number;
+    effect?: string;
+}
+
+export interface ObstructionPayload {
+    effect: string;
+    attacker_id: string;
+    target_id: string;
+    duration_ms: number;
+    expires_at: number;
+    intensity: number;
+    blocked?: boolean;
+    reflected?: boolean;
+}
+
+export interface GameResultPayload {
+    winner_id: string;
+    message: string;
+}
+
+export interface ResumeResultPayload {
+    room_id: string;
+    player_id?: string;
+    mode: string;
+    last_seq: number;
+    replayed?: number;
+}
+
+export interface ServerNoticePayload {
+    message: string;
+    level: string;
+}
+
+export interface KickedPayload {
+    room_id: string;
+    reason: string;
+}
+
+export interface ServerDrainingPayload {
+    message: string;
+    deadline: number;
+}
+
+export interface ItemAwardedPayload {
+    item: string;
+    reason: string;
+    items: string[];
+}
+
+export interface ItemUsedPayload {
+    player_id: string;
+    item: string;
+    outcome: string;
+    target_id?: string;
+    effect?: string;
+    expires_at?: number;
+    blocked?: boolean;
+    items: string[];
+}
+
+export interface ItemRejectedPayload {
+    item: string;
+    reason: string;
+    cooldown_remaining_ms?: number;
+    items: string[];
+}
+
+export interface ChooseTargetPayload {
+    candidates: TargetCandidatePayload[];
+    timeout_ms: number;
+    deadline: number;
+}
+
+export interface TargetCandidatePayload {
+    player_id: string;
+    score: number;
+}
+
+export type ClientMessage =
+    | { type: 'HELLO'; id?: string; seq?: number; payload: HelloPayload }
+    | { type: 'JOIN_ROOM'; id?: string; seq?: number; payload: JoinRoomPayload }
+    | { type: 'RESUME'; id?: string; seq?: number; payload: ResumePayload }
+    | { type: 'LEAVE_ROOM'; id?: string; seq?: number; payload: LeaveRoomPayload }
+    | { type: 'SELECT_IMAGE'; id?: string; seq?: number; payload: SelectImagePayload }
+    | { type: 'VERIFY'; id?: string; seq?: number; payload: VerifyPayload }
+    | { type: 'PONG'; id?: string; seq?: number; payload?: EmptyPayload }
+    | { type: 'REQUEST_KEYFRAME'; id?: string; seq?: number; payload?: EmptyPayload }
+    | { type: 'USE_ITEM'; id?: string; seq?: number; payload: UseItemPayload }
+    | { type: 'SET_TARGETING'; id?: string; seq?: number; payload: SetTargetingPayload }
+    | { type: 'SELECT_TARGET'; id?: string; seq?: number; payload: SelectTargetPayload }
+;
+
+export type ServerMessage =
+    | { type: 'HELLO_ACK'; id?: string; seq?: number; payload: HelloAckPayload }
+    | { type: 'UPGRADE_REQUIRED'; id?: string; seq?: number; payload: UpgradeRequiredPayload }
+    | { type: 'ACK'; id?: string; seq?: number; payload: AckPayload }
+    | { type: 'ERROR'; id?: string; seq?: number; payload: ErrorPayload }
+    | { type: 'PING'; id?: string; seq?: number; payload?: EmptyPayload }
+    | { type: 'ROOM_ASSIGNED'; id?: string; seq?: number; payload: RoomAssignedPayload }
+    | { type: 'STATUS_UPDATE'; id?: string; seq?: number; payload: StatusUpdatePayload }
+    | { type: 'JOIN_FAILED'; id?: string; seq?: number; payload: JoinFailedPayload }
+    | { type: 'GAME_START'; id?: string; seq?: number; payload: GameStartPayload }
+    | { type: 'UPDATE_PATTERN'; id?: string; seq?: number; payload: UpdatePatternPayload }
+    | { type: 'VERIFY_FAILED'; id?: string; seq?: number; payload: VerifyFailedPayload }
+    | { type: 'OPPONENT_UPDATE'; id?: string; seq?: number; payload: OpponentUpdatePayload }
+    | { type: 'OPPONENT_DELTA'; id?: string; seq?: number; payload: OpponentDeltaPayload }
+    | { type: 'OPPONENT_SELECT'; id?: string; seq?: number; payload: SelectImagePayload }
+    | { type: 'OBSTRUCTION'; id?: string; seq?: number; payload: ObstructionPayload }
+    | { type: 'OBSTRUCTION_FIRED'; id?: string; seq?: number; payload: ObstructionPayload }
+    | { type: 'GAME_FINISHED'; id?: string; seq?: number; payload: GameResultPayload }
+    | { type: 'RESUMED'; id?: string; seq?: number; payload: ResumeResultPayload }
+    | { type: 'SERVER_NOTICE'; id?: string; seq?: number; payload: ServerNoticePayload }
+    | { type: 'KICKED'; id?: string; seq?: number; payload: KickedPayload }
+    | { type: 'SERVER_DRAINING'; id?: string; seq?: number; payload: ServerDrainingPayload }
+    | { type: 'ITEM_AWARDED'; id?: string; seq?: number; payload: ItemAwardedPayload }
+    | { type: 'ITEM_USED'; id?: string; seq?: number; payload: ItemUsedPayload }
+    | { type: 'ITEM_REJECTED'; id?: string; seq?: number; payload: ItemRejectedPayload }
+    | { type: 'CHOOSE_TARGET'; id?: string; seq?: number; payload: ChooseTargetPayload }
+;
+
+export type ClientMessageType = ClientMessage['type'];
+export type ServerMessageType = ServerMessage['type'];
+
+/** type から対応するメッセージを取り出す */
+export type ClientMessageOf<T extends ClientMessageType> = Extract<ClientMessage, { type: T }>;
+export type ServerMessageOf<T extends ServerMessageType> = Extract<ServerMessage, { type: T }>;
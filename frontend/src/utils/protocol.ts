@@ -0,0 +1,9 @@
+import type { ClientMessage, ServerMessage } from '../types/protocol';
+
+// WebSocket メッセージの型付きエンコード/デコード。
+// 型定義はバックエンドの protocol パッケージから生成される（src/types/protocol.d.ts）。
+// 契約が変わると送受信箇所が型エラーになり、ビルドで検出できる。
+
+export const encodeMessage = (msg: ClientMessage): string => JSON.stringify(msg);
+
+export const parseServerMessage = (data: string): ServerMessage => JSON.parse(data) as ServerMessage;